@@ -0,0 +1,191 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"load-testing/internal/analysis"
+	"load-testing/internal/metrics"
+)
+
+// regressionExitCode is the exit status of compare when a run regressed, so
+// CI can tell a regression from a failure to compare.
+const regressionExitCode = 3
+
+// RunFiles names a run and the metrics files or summaries it wrote.
+type RunFiles struct {
+	Name  string
+	Files []string
+}
+
+// CompareConfig holds the command-line configuration for comparing runs.
+type CompareConfig struct {
+	Runs    []RunFiles
+	Compare analysis.CompareConfig
+	Format  string
+	Output  string
+}
+
+var compareFormats = []string{analysis.FormatMarkdown, analysis.FormatJSON}
+
+func parseCompareFlags(args []string) CompareConfig {
+	config := CompareConfig{Compare: analysis.DefaultCompareConfig()}
+
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: analyze-metrics compare [options] [name=]file[,file...] [name=]file[,file...]...\n\n")
+		fmt.Fprintf(flags.Output(), "Compares each run with the first, the baseline. A run's files are metrics files or\n")
+		fmt.Fprintf(flags.Output(), "manifests, or --summary-file summaries, of its skaters, viewers or both.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Float64Var(&config.Compare.Tolerance, "tolerance", analysis.DefaultTolerance, "Relative change in throughput or latency up to which a metric is unchanged (e.g., 0.1 for 10%)")
+	flags.Float64Var(&config.Compare.ErrorRateTolerance, "error-rate-tolerance", analysis.DefaultErrorRateTolerance, "Change in an error rate up to which it is unchanged (e.g., 0.01 for one percentage point)")
+	flags.Float64Var(&config.Compare.Significance, "significance", analysis.DefaultSignificance, "p-value below which a change is significant (0-1)")
+	flags.StringVar(&config.Format, "format", analysis.FormatMarkdown, fmt.Sprintf("Output format, one of %s", strings.Join(compareFormats, ", ")))
+	flags.StringVar(&config.Output, "output", "", "Output file (default stdout)")
+
+	flags.Parse(args)
+
+	if flags.NArg() < 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	names := make(map[string]bool)
+	for _, arg := range flags.Args() {
+		run := parseRun(arg)
+		if len(run.Files) == 0 {
+			log.Fatalf("No files given for run %q", run.Name)
+		}
+		if names[run.Name] {
+			log.Fatalf("Run %q given twice, name the runs with name=file", run.Name)
+		}
+		names[run.Name] = true
+		config.Runs = append(config.Runs, run)
+	}
+
+	if config.Compare.Tolerance < 0 {
+		log.Fatalf("Tolerance must not be negative, got: %v", config.Compare.Tolerance)
+	}
+	if config.Compare.ErrorRateTolerance < 0 || config.Compare.ErrorRateTolerance > 1 {
+		log.Fatalf("Error rate tolerance must be between 0 and 1, got: %v", config.Compare.ErrorRateTolerance)
+	}
+	if config.Compare.Significance <= 0 || config.Compare.Significance > 1 {
+		log.Fatalf("Significance must be greater than 0 and at most 1, got: %v", config.Compare.Significance)
+	}
+	if config.Format != analysis.FormatMarkdown && config.Format != analysis.FormatJSON {
+		log.Fatalf("Unknown output format %q, want one of %s", config.Format, strings.Join(compareFormats, ", "))
+	}
+
+	return config
+}
+
+// parseRun parses a run argument, [name=]file[,file...]. A run without a
+// name is named after the directory of its first file, or the file itself if
+// it is in the working directory.
+func parseRun(arg string) RunFiles {
+	var run RunFiles
+	if name, files, ok := strings.Cut(arg, "="); ok {
+		run.Name, arg = name, files
+	}
+	run.Files = splitList(arg)
+	if run.Name == "" && len(run.Files) > 0 {
+		run.Name = filepath.Dir(run.Files[0])
+		if run.Name == "." {
+			run.Name = run.Files[0]
+		}
+	}
+	return run
+}
+
+func runCompare(config CompareConfig) (analysis.Comparison, error) {
+	runs := make([]analysis.Run, len(config.Runs))
+	for i, files := range config.Runs {
+		run, err := loadRun(files)
+		if err != nil {
+			return analysis.Comparison{}, fmt.Errorf("run %s: %w", files.Name, err)
+		}
+		runs[i] = run
+	}
+	comparison := analysis.Compare(runs[0], runs[1:], config.Compare)
+
+	var w io.Writer = os.Stdout
+	if config.Output != "" {
+		file, err := os.Create(config.Output)
+		if err != nil {
+			return comparison, fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	var err error
+	if config.Format == analysis.FormatJSON {
+		err = comparison.WriteJSON(w)
+	} else {
+		err = comparison.WriteMarkdown(w)
+	}
+	if err != nil {
+		return comparison, fmt.Errorf("failed to write comparison: %w", err)
+	}
+	if file, ok := w.(*os.File); ok && file != os.Stdout {
+		if err := file.Close(); err != nil {
+			return comparison, fmt.Errorf("failed to write comparison: %w", err)
+		}
+		log.Printf("Comparison written to: %s", config.Output)
+	}
+	return comparison, nil
+}
+
+// loadRun reads a run's files: either summaries, or metrics files whose
+// response times and latencies are kept for the statistical tests.
+func loadRun(files RunFiles) (analysis.Run, error) {
+	var summaries, metricsFiles []string
+	for _, filename := range files.Files {
+		if filepath.Ext(filename) == ".json" && !metrics.IsManifest(filename) {
+			summaries = append(summaries, filename)
+		} else {
+			metricsFiles = append(metricsFiles, filename)
+		}
+	}
+	if len(summaries) > 0 && len(metricsFiles) > 0 {
+		return analysis.Run{}, fmt.Errorf("cannot compare summaries and metrics files in one run")
+	}
+
+	if len(summaries) > 0 {
+		var skaters *metrics.SkaterReport
+		var viewers *metrics.ViewerReport
+		for _, filename := range summaries {
+			s, v, err := metrics.ReadSummaryFile(filename)
+			if err != nil {
+				return analysis.Run{}, err
+			}
+			if (s != nil && skaters != nil) || (v != nil && viewers != nil) {
+				return analysis.Run{}, fmt.Errorf("%s summarises skaters or viewers already summarised", filename)
+			}
+			if s != nil {
+				skaters = s
+			}
+			if v != nil {
+				viewers = v
+			}
+		}
+		return analysis.SummaryRun(files.Name, skaters, viewers), nil
+	}
+
+	analyzer := analysis.New(analysis.DefaultInterval, analysis.KeepSamples())
+	for _, filename := range metricsFiles {
+		schema, err := metrics.DetectSchema(filename)
+		if err != nil {
+			return analysis.Run{}, err
+		}
+		if err := addFile(analyzer, filename, schema); err != nil {
+			return analysis.Run{}, err
+		}
+	}
+	return analyzer.Run(files.Name), nil
+}
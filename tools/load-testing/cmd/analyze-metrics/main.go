@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"load-testing/internal/analysis"
+	"load-testing/internal/metrics"
+)
+
+const defaultCSVOutput = "analysis"
+
+// Config holds the command-line configuration for analysing metrics files.
+type Config struct {
+	SkaterMetricsFiles []string
+	ViewerMetricsFiles []string
+	Interval           time.Duration
+	Format             string
+	Output             string
+	Title              string
+	Parameters         []analysis.Parameter
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		config := parseCompareFlags(os.Args[2:])
+		comparison, err := runCompare(config)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if comparison.Regressions > 0 {
+			log.Printf("%d regressions beyond tolerance", comparison.Regressions)
+			os.Exit(regressionExitCode)
+		}
+		return
+	}
+
+	config := parseFlags()
+
+	if err := run(config); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func parseFlags() Config {
+	var config Config
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: analyze-metrics [options]\n       analyze-metrics compare [options] RUN RUN...\n\n")
+		flag.PrintDefaults()
+	}
+
+	var skaterFiles, viewerFiles, intervalStr string
+	flag.StringVar(&skaterFiles, "skater-metrics-file", "", "Comma-separated skater metrics files written by simulate-skaters or simulate-event, or their manifests")
+	flag.StringVar(&viewerFiles, "viewer-metrics-file", "", "Comma-separated viewer metrics files written by simulate-viewers or simulate-event, or their manifests")
+	flag.StringVar(&intervalStr, "interval", analysis.DefaultInterval.String(), "Length of each point of the time series (e.g., 1m, 30s)")
+	flag.StringVar(&config.Format, "format", analysis.FormatMarkdown, fmt.Sprintf("Output format, one of %s", strings.Join(analysis.Formats, ", ")))
+	flag.StringVar(&config.Output, "output", "", fmt.Sprintf("Output file, or directory for csv (default stdout, or %s/ for csv)", defaultCSVOutput))
+
+	var paramsStr string
+	flag.StringVar(&config.Title, "title", analysis.DefaultTitle, "Title of the html report")
+	flag.StringVar(&paramsStr, "params", "", "Comma-separated name=value run parameters to list in the html report (e.g., skaters-per-event=10,update-interval=3s)")
+
+	flag.Parse()
+
+	config.SkaterMetricsFiles = splitList(skaterFiles)
+	config.ViewerMetricsFiles = splitList(viewerFiles)
+	if len(config.SkaterMetricsFiles) == 0 && len(config.ViewerMetricsFiles) == 0 {
+		log.Fatal("At least one of --skater-metrics-file or --viewer-metrics-file is required")
+	}
+
+	interval, err := time.ParseDuration(intervalStr)
+	if err != nil {
+		log.Fatalf("Invalid interval: %v", err)
+	}
+	if interval <= 0 {
+		log.Fatalf("Interval must be positive, got: %v", interval)
+	}
+	config.Interval = interval
+
+	if !slices.Contains(analysis.Formats, config.Format) {
+		log.Fatalf("Unknown output format %q, want one of %s", config.Format, strings.Join(analysis.Formats, ", "))
+	}
+	if config.Format == analysis.FormatCSV && config.Output == "" {
+		config.Output = defaultCSVOutput
+	}
+
+	for _, param := range splitList(paramsStr) {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			log.Fatalf("Invalid run parameter %q, want name=value", param)
+		}
+		config.Parameters = append(config.Parameters, analysis.Parameter{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+	}
+
+	return config
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func run(config Config) error {
+	analyzer := analysis.New(config.Interval)
+	for _, filename := range config.SkaterMetricsFiles {
+		if err := addFile(analyzer, filename, metrics.SkaterSchema); err != nil {
+			return err
+		}
+	}
+	for _, filename := range config.ViewerMetricsFiles {
+		if err := addFile(analyzer, filename, metrics.ViewerSchema); err != nil {
+			return err
+		}
+	}
+	report := analyzer.Report()
+
+	if config.Format == analysis.FormatCSV {
+		paths, err := report.WriteCSV(config.Output)
+		if err != nil {
+			return err
+		}
+		log.Printf("Wrote %s", strings.Join(paths, ", "))
+		return nil
+	}
+
+	var w io.Writer = os.Stdout
+	if config.Output != "" {
+		file, err := os.Create(config.Output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	var err error
+	switch config.Format {
+	case analysis.FormatJSON:
+		err = report.WriteJSON(w)
+	case analysis.FormatHTML:
+		err = report.WriteHTML(w, analysis.Page{Title: config.Title, Parameters: config.Parameters})
+	default:
+		err = report.WriteMarkdown(w)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write analysis: %w", err)
+	}
+	if file, ok := w.(*os.File); ok && file != os.Stdout {
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to write analysis: %w", err)
+		}
+		log.Printf("Analysis written to: %s", config.Output)
+	}
+	return nil
+}
+
+func addFile(analyzer *analysis.Analyzer, filename string, schema *metrics.Schema) error {
+	r, err := metrics.Open(filename, schema)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := analyzer.Add(r); err != nil {
+		return fmt.Errorf("failed to analyse %s: %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"load-testing/internal/delay"
+	"load-testing/internal/fakeserver"
+)
+
+const (
+	shutdownTimeout = 10 * time.Second
+)
+
+// Config holds the command-line configuration for the fake API server.
+type Config struct {
+	Listen string
+	Server fakeserver.Config
+}
+
+func main() {
+	config := parseFlags()
+
+	if err := run(config); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func parseFlags() Config {
+	var config Config
+
+	flag.StringVar(&config.Listen, "listen", ":9000", "Address to listen on")
+
+	var ttlStr, cleanupStr, batchIntervalStr string
+	flag.StringVar(&ttlStr, "location-ttl", fakeserver.DefaultLocationTTL.String(), "How long a location is kept after its last update")
+	flag.StringVar(&cleanupStr, "cleanup-interval", fakeserver.DefaultCleanupInterval.String(), "How often expired locations are removed")
+	flag.IntVar(&config.Server.BatchSize, "batch-size", fakeserver.DefaultBatchSize, "Maximum number of locations in a stream message")
+	flag.StringVar(&batchIntervalStr, "batch-interval", fakeserver.DefaultBatchInterval.String(), "Maximum time locations are held before a stream message is sent")
+	flag.IntVar(&config.Server.BufferSize, "buffer-size", fakeserver.DefaultBufferSize, "Locations buffered per stream subscriber before the oldest are dropped")
+
+	faults := &config.Server.Faults
+	var updateLatencyStr, handshakeDelayStr, stallDurationStr string
+	flag.StringVar(&updateLatencyStr, "update-latency", "", "Fault: delay before answering location updates (e.g., 50ms, uniform:10ms-200ms, normal:100ms,20ms, exp:50ms)")
+	flag.Float64Var(&faults.ErrorRate, "error-rate", 0, "Fault: fraction of location updates rejected with --error-status (0-1)")
+	flag.IntVar(&faults.ErrorStatus, "error-status", 503, "Fault: 5xx status code used for --error-rate")
+	flag.Float64Var(&faults.ThrottleRate, "throttle-rate", 0, "Fault: fraction of location updates rejected with 429 Too Many Requests (0-1)")
+	flag.StringVar(&handshakeDelayStr, "handshake-delay", "", "Fault: delay before accepting stream connections, in the same format as --update-latency")
+	flag.Float64Var(&faults.DropRate, "drop-rate", 0, "Fault: chance per batch of dropping the stream connection without a close frame (0-1)")
+	flag.Float64Var(&faults.MalformedRate, "malformed-rate", 0, "Fault: chance per batch of sending truncated JSON instead (0-1)")
+	flag.Float64Var(&faults.StallRate, "stall-rate", 0, "Fault: chance per batch of the stream stalling for --stall-duration (0-1)")
+	flag.StringVar(&stallDurationStr, "stall-duration", "30s", "Fault: how long a stalled stream sends nothing")
+	flag.Int64Var(&faults.Seed, "fault-seed", 0, "Random seed for reproducible fault injection (0 = random)")
+
+	flag.Parse()
+
+	config.Server.LocationTTL = parsePositiveDuration("location TTL", ttlStr)
+	config.Server.CleanupInterval = parsePositiveDuration("cleanup interval", cleanupStr)
+	config.Server.BatchInterval = parsePositiveDuration("batch interval", batchIntervalStr)
+
+	if config.Server.BatchSize <= 0 {
+		log.Fatalf("Batch size must be positive, got: %d", config.Server.BatchSize)
+	}
+
+	if config.Server.BufferSize <= 0 {
+		log.Fatalf("Buffer size must be positive, got: %d", config.Server.BufferSize)
+	}
+
+	var err error
+	if faults.UpdateLatency, err = delay.Parse(updateLatencyStr); err != nil {
+		log.Fatalf("Invalid update latency: %v", err)
+	}
+	if faults.HandshakeDelay, err = delay.Parse(handshakeDelayStr); err != nil {
+		log.Fatalf("Invalid handshake delay: %v", err)
+	}
+	faults.StallDuration = parsePositiveDuration("stall duration", stallDurationStr)
+
+	if err := faults.Validate(); err != nil {
+		log.Fatalf("Invalid fault configuration: %v", err)
+	}
+
+	return config
+}
+
+func parsePositiveDuration(name, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+	if d <= 0 {
+		log.Fatalf("The %s must be positive, got: %v", name, d)
+	}
+	return d
+}
+
+func run(config Config) error {
+	server := fakeserver.New(config.Server)
+	defer server.Close()
+
+	httpServer := &http.Server{
+		Addr:    config.Listen,
+		Handler: server,
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	log.Printf("Fake Skatemap API listening on %s (location TTL %s, batches of up to %d every %s)",
+		config.Listen, config.Server.LocationTTL, config.Server.BatchSize, config.Server.BatchInterval)
+	if f := config.Server.Faults; f.Enabled() {
+		log.Printf("Injecting faults: update latency %s, %.1f%% errors (%d), %.1f%% throttled, handshake delay %s, "+
+			"%.1f%% dropped, %.1f%% malformed, %.1f%% stalled for %s",
+			f.UpdateLatency, f.ErrorRate*100, f.ErrorStatus, f.ThrottleRate*100, f.HandshakeDelay,
+			f.DropRate*100, f.MalformedRate*100, f.StallRate*100, f.StallDuration)
+	}
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-sigChan:
+	}
+
+	log.Println("Shutting down...")
+
+	// Streams are hijacked connections that Shutdown does not wait for,
+	// so close them first to send each viewer a close frame.
+	server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	log.Println("Server stopped")
+	return nil
+}
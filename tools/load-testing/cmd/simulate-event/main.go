@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"load-testing/internal/correlation"
+	"load-testing/internal/delay"
+	"load-testing/internal/metrics"
+	"load-testing/internal/run"
+	"load-testing/internal/scenario"
+	"load-testing/internal/simulation"
+	"load-testing/internal/viewer"
+)
+
+const maxResultsBufferSize = 1000
+
+type Config struct {
+	Skaters           run.SkaterFlags
+	Metrics           run.MetricsFlags
+	ViewersPerEvent   int
+	TargetURL         string
+	SkaterMetricsFile string
+	ViewerMetricsFile string
+	ScenarioFile      string
+	Duration          time.Duration
+	ErrorBudget       int
+	SummaryFile       string
+	SkaterThresholds  metrics.SkaterThresholds
+	ViewerThresholds  metrics.ViewerThresholds
+	Reconnect         bool
+	Backoff           viewer.Backoff
+	Churn             *viewer.Churn
+}
+
+func main() {
+	config := parseFlags()
+
+	if err := runSimulation(config); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func parseFlags() Config {
+	var config Config
+
+	config.Skaters.Register()
+	flag.IntVar(&config.ViewersPerEvent, "viewers-per-event", 1, "Number of viewers per event (scenario events without a viewer count use this too)")
+
+	flag.StringVar(&config.TargetURL, "target-url", "", "Target URL for the API (required)")
+	flag.StringVar(&config.SkaterMetricsFile, "skater-metrics-file", "", "Output file for skater metrics (default metrics.<format>)")
+	flag.StringVar(&config.ViewerMetricsFile, "viewer-metrics-file", "", "Output file for viewer metrics (default viewer-metrics.<format>)")
+	config.Metrics.Register(":9100")
+	flag.StringVar(&config.ScenarioFile, "scenario", "", "Optional YAML or JSON scenario file describing events, skater and viewer counts, join/leave curves and duration (overrides --events, --skaters-per-event, --update-interval and --event-id)")
+
+	var durationStr string
+	flag.StringVar(&durationStr, "duration", "", "Optional run duration after which the simulation stops by itself (e.g., 45m, 2h; overrides the scenario duration)")
+	flag.IntVar(&config.ErrorBudget, "error-budget", -1, "Maximum number of failed updates and viewer errors combined before the run exits non-zero (-1 = unlimited)")
+	flag.StringVar(&config.SummaryFile, "summary-file", "", "Optional file to write the end-of-run skater and viewer summaries to as JSON")
+	flag.Float64Var(&config.SkaterThresholds.MaxErrorRate, "max-error-rate", -1, "SLO: maximum fraction of failed updates (0-1, -1 = unchecked)")
+
+	var maxP99ResponseStr string
+	flag.StringVar(&maxP99ResponseStr, "max-p99-response", "", "SLO: maximum p99 update response time (e.g., 200ms)")
+	flag.Float64Var(&config.ViewerThresholds.MaxErrorRate, "max-viewer-error-rate", -1, "SLO: maximum viewer errors as a fraction of messages and errors (0-1, -1 = unchecked)")
+	flag.IntVar(&config.ViewerThresholds.MinMessagesPerViewer, "min-messages-per-viewer", 0, "SLO: minimum number of messages every viewer must receive (0 = unchecked)")
+
+	var maxP99LatencyStr string
+	flag.StringVar(&maxP99LatencyStr, "max-p99-latency", "", "SLO: maximum p99 message latency (e.g., 500ms)")
+	flag.IntVar(&config.ViewerThresholds.MaxMissingSkaters, "max-missing-skaters", -1, "SLO: maximum number of skaters a viewer that should have seen them never received (-1 = unchecked)")
+	flag.IntVar(&config.ViewerThresholds.MaxBatchSize, "max-batch-size", metrics.DefaultMaxBatchSize, "SLO: maximum locations in a batch, the API's skatemap.stream.batchSize (0 = unchecked)")
+	flag.BoolVar(&config.Reconnect, "reconnect", false, "Reconnect viewers with exponential backoff and jitter when their connection fails or closes, rather than stopping them")
+
+	var reconnectBackoffStr, reconnectMaxBackoffStr string
+	flag.StringVar(&reconnectBackoffStr, "reconnect-backoff", "500ms", "Wait before the first reconnection attempt, doubled after each failed attempt")
+	flag.StringVar(&reconnectMaxBackoffStr, "reconnect-max-backoff", "30s", "Longest wait between reconnection attempts")
+
+	var churnSessionStr, churnRejoinStr string
+	var churnAbruptRate float64
+	flag.StringVar(&churnSessionStr, "churn-session", "", "Optional session length distribution after which each viewer leaves and rejoins (e.g., 2m, uniform:30s-5m, exp:2m)")
+	flag.StringVar(&churnRejoinStr, "churn-rejoin", "5s", "Delay distribution before a churning viewer rejoins (e.g., 5s, exp:10s)")
+	flag.Float64Var(&churnAbruptRate, "churn-abrupt-rate", 0, "Fraction of churning sessions ended by dropping the TCP connection rather than sending a close frame (0-1)")
+
+	flag.Parse()
+
+	if config.TargetURL == "" {
+		fmt.Println("Error: --target-url is required")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if _, err := url.Parse(config.TargetURL); err != nil {
+		log.Fatalf("Invalid target URL: %v", err)
+	}
+
+	config.Skaters.Parse()
+
+	if config.ViewersPerEvent <= 0 {
+		log.Fatalf("Number of viewers per event must be positive, got: %d", config.ViewersPerEvent)
+	}
+
+	config.Metrics.Parse()
+	if config.SkaterMetricsFile == "" {
+		config.SkaterMetricsFile = "metrics." + config.Metrics.Format
+	}
+	if config.ViewerMetricsFile == "" {
+		config.ViewerMetricsFile = "viewer-metrics." + config.Metrics.Format
+	}
+
+	if durationStr != "" {
+		duration, err := time.ParseDuration(durationStr)
+		if err != nil {
+			log.Fatalf("Invalid duration: %v", err)
+		}
+		if duration <= 0 {
+			log.Fatalf("Duration must be positive, got: %v", duration)
+		}
+		config.Duration = duration
+	}
+
+	if config.ErrorBudget < -1 {
+		log.Fatalf("Error budget must be -1 (unlimited) or non-negative, got: %d", config.ErrorBudget)
+	}
+
+	if config.SkaterThresholds.MaxErrorRate > 1 || (config.SkaterThresholds.MaxErrorRate < 0 && config.SkaterThresholds.MaxErrorRate != -1) {
+		log.Fatalf("Max error rate must be between 0 and 1, or -1 to disable, got: %f", config.SkaterThresholds.MaxErrorRate)
+	}
+
+	if config.ViewerThresholds.MaxErrorRate > 1 || (config.ViewerThresholds.MaxErrorRate < 0 && config.ViewerThresholds.MaxErrorRate != -1) {
+		log.Fatalf("Max viewer error rate must be between 0 and 1, or -1 to disable, got: %f", config.ViewerThresholds.MaxErrorRate)
+	}
+
+	if config.ViewerThresholds.MinMessagesPerViewer < 0 {
+		log.Fatalf("Min messages per viewer must be non-negative, got: %d", config.ViewerThresholds.MinMessagesPerViewer)
+	}
+
+	if config.ViewerThresholds.MaxMissingSkaters < -1 {
+		log.Fatalf("Max missing skaters must be -1 (unchecked) or non-negative, got: %d", config.ViewerThresholds.MaxMissingSkaters)
+	}
+
+	if config.ViewerThresholds.MaxBatchSize < 0 {
+		log.Fatalf("Max batch size must be non-negative, got: %d", config.ViewerThresholds.MaxBatchSize)
+	}
+
+	if maxP99ResponseStr != "" {
+		maxP99Response, err := time.ParseDuration(maxP99ResponseStr)
+		if err != nil {
+			log.Fatalf("Invalid max p99 response: %v", err)
+		}
+		if maxP99Response <= 0 {
+			log.Fatalf("Max p99 response must be positive, got: %v", maxP99Response)
+		}
+		config.SkaterThresholds.MaxP99Response = maxP99Response
+	}
+
+	if maxP99LatencyStr != "" {
+		maxP99Latency, err := time.ParseDuration(maxP99LatencyStr)
+		if err != nil {
+			log.Fatalf("Invalid max p99 latency: %v", err)
+		}
+		if maxP99Latency <= 0 {
+			log.Fatalf("Max p99 latency must be positive, got: %v", maxP99Latency)
+		}
+		config.ViewerThresholds.MaxP99Latency = maxP99Latency
+	}
+
+	config.Backoff = viewer.DefaultBackoff()
+	reconnectBackoff, err := time.ParseDuration(reconnectBackoffStr)
+	if err != nil {
+		log.Fatalf("Invalid reconnect backoff: %v", err)
+	}
+	reconnectMaxBackoff, err := time.ParseDuration(reconnectMaxBackoffStr)
+	if err != nil {
+		log.Fatalf("Invalid reconnect max backoff: %v", err)
+	}
+	if reconnectBackoff <= 0 || reconnectMaxBackoff < reconnectBackoff {
+		log.Fatalf("Reconnect backoff must be positive and no more than the max backoff, got: %v and %v", reconnectBackoff, reconnectMaxBackoff)
+	}
+	config.Backoff.Initial = reconnectBackoff
+	config.Backoff.Max = reconnectMaxBackoff
+
+	if churnSessionStr != "" {
+		sessionLength, err := delay.Parse(churnSessionStr)
+		if err != nil {
+			log.Fatalf("Invalid churn session length: %v", err)
+		}
+		if sessionLength.A <= 0 {
+			log.Fatalf("Churn session length must be positive, got: %s", sessionLength)
+		}
+		rejoinDelay, err := delay.Parse(churnRejoinStr)
+		if err != nil {
+			log.Fatalf("Invalid churn rejoin delay: %v", err)
+		}
+		if churnAbruptRate < 0 || churnAbruptRate > 1 {
+			log.Fatalf("Churn abrupt rate must be between 0 and 1, got: %f", churnAbruptRate)
+		}
+		config.Churn = &viewer.Churn{SessionLength: sessionLength, RejoinDelay: rejoinDelay, AbruptRate: churnAbruptRate}
+	}
+
+	return config
+}
+
+// viewerCounts returns the number of viewers to start for each event in a scenario.
+// Events without a viewer count use the --viewers-per-event default. Unlike
+// simulate-viewers, events need no explicit ID, as skaters and viewers share
+// the generated one.
+func viewerCounts(sc *scenario.Scenario, defaultViewers int) ([]string, []int) {
+	eventIDs := make([]string, len(sc.Events))
+	counts := make([]int, len(sc.Events))
+	for i, event := range sc.Events {
+		eventIDs[i] = event.ID
+		counts[i] = event.Viewers
+		if counts[i] == 0 {
+			counts[i] = defaultViewers
+		}
+	}
+	return eventIDs, counts
+}
+
+func runSimulation(config Config) error {
+	defaultMovement, shutdownTracing, err := config.Skaters.NewMovement()
+	if err != nil {
+		return err
+	}
+	defer shutdownTracing()
+
+	// Skaters and viewers share a clock in this process, so every sent location
+	// can be matched directly when a viewer receives it, and every skater that
+	// sends one is expected to reach the viewers of its event.
+	tracker := correlation.NewTracker(correlation.DefaultRetention)
+	delivery := metrics.NewDeliveryChecker(metrics.DefaultDeliveryConfig(), time.Now())
+	publishers := correlation.Publishers{tracker, delivery}
+	defaultMovement.SkaterOpts = append(defaultMovement.SkaterOpts, simulation.PublishSends(publishers.Publish))
+	viewerOpts := []viewer.Option{viewer.WithCorrelation(tracker)}
+	if config.Reconnect {
+		viewerOpts = append(viewerOpts, viewer.WithReconnect(config.Backoff))
+	}
+	if config.Churn != nil {
+		viewerOpts = append(viewerOpts, viewer.WithChurn(*config.Churn))
+	}
+
+	var plans []simulation.SkaterPlan
+	var eventIDs []string
+	var viewers []int
+	var runDuration time.Duration
+
+	if config.ScenarioFile != "" {
+		sc, err := scenario.Load(config.ScenarioFile)
+		if err != nil {
+			return err
+		}
+		runDuration = time.Duration(sc.Duration)
+
+		log.Printf("Starting scenario %q with %d events, %d skaters, duration: %s",
+			sc.Name, len(sc.Events), sc.TotalSkaters(), runDuration)
+		eventIDs, viewers = viewerCounts(sc, config.ViewersPerEvent)
+		for i, event := range sc.Events {
+			log.Printf("Event %q (%s): %d skaters, %d viewers, update interval: %s ± %s",
+				event.Name, event.ID, event.Skaters, viewers[i],
+				time.Duration(event.UpdateInterval), time.Duration(event.IntervalJitter))
+		}
+		log.Printf("Scenario event IDs: %v", eventIDs)
+
+		plans, err = simulation.BuildScenarioPlans(sc, config.TargetURL, defaultMovement)
+		if err != nil {
+			return err
+		}
+	} else {
+		skaters := config.Skaters
+		log.Printf("Starting simulation with %d events, %d skaters and %d viewers per event, update interval: %s",
+			skaters.NumEvents, skaters.SkatersPerEvent, config.ViewersPerEvent, skaters.UpdateInterval)
+
+		eventIDs, err = simulation.ParseEventIDs(skaters.EventIDs, skaters.NumEvents)
+		if err != nil {
+			return err
+		}
+
+		if skaters.EventIDs != "" {
+			log.Printf("Using provided event IDs: %v", eventIDs)
+		} else {
+			log.Printf("Generated event IDs: %v", eventIDs)
+		}
+
+		plans, err = simulation.BuildPlans(eventIDs, skaters.SkatersPerEvent, skaters.UpdateInterval, config.TargetURL, defaultMovement)
+		if err != nil {
+			return err
+		}
+		viewers = make([]int, len(eventIDs))
+		for i := range viewers {
+			viewers[i] = config.ViewersPerEvent
+		}
+	}
+
+	if config.Duration > 0 {
+		runDuration = config.Duration
+	}
+
+	config.Skaters.LogLoad(defaultMovement)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	limiter, rampUp := simulation.NewLimiter(config.Skaters.RateLimit, config.Skaters.RampUpDuration, plans)
+
+	live, err := config.Metrics.ServeLiveMetrics(true, true)
+	if err != nil {
+		return err
+	}
+	defer live.Close()
+	if limiter != nil {
+		live.Skaters.TrackRateLimit(func() float64 { return float64(limiter.Limit()) })
+	}
+	if live.Viewers != nil {
+		viewerOpts = append(viewerOpts, viewer.WithConnectionHooks(live.Viewers.ConnectionOpened, live.Viewers.ConnectionClosed))
+	}
+
+	skaterSink, err := config.Metrics.NewSink(config.SkaterMetricsFile, metrics.SkaterSchema, live.Queue)
+	if err != nil {
+		return err
+	}
+	defer run.CloseSinks(skaterSink)
+
+	viewerSink, err := config.Metrics.NewSink(config.ViewerMetricsFile, metrics.ViewerSchema, live.Queue)
+	if err != nil {
+		return err
+	}
+	defer run.CloseSinks(viewerSink)
+
+	viewerResults := make(chan viewer.ViewerResult, maxResultsBufferSize)
+	var skatersWg sync.WaitGroup
+	var viewersWg sync.WaitGroup
+	var metricsWg sync.WaitGroup
+
+	stopChan := make(chan struct{})
+	skaterSummary := metrics.NewSkaterSummary(time.Now())
+	viewerSummary := metrics.NewViewerSummary(time.Now())
+	viewerSummary.TrackDelivery(delivery)
+	skaterSummary.TrackMetricsQueue(skaterSink)
+	viewerSummary.TrackMetricsQueue(viewerSink)
+
+	metricsWg.Add(1)
+	go func() {
+		defer metricsWg.Done()
+		run.RecordViewers(viewerResults, viewerSummary, live.Viewers, viewerSink, logViewerResult)
+	}()
+
+	// Viewers connect first so they are watching before the first update is sent.
+	totalViewers := 0
+	for i, eventID := range eventIDs {
+		for j := 0; j < viewers[i]; j++ {
+			totalViewers++
+			viewerSummary.ExpectViewer(eventID, totalViewers)
+			v := viewer.New(ctx, eventID, totalViewers, config.TargetURL, viewerResults, &viewersWg, viewerOpts...)
+			viewersWg.Add(1)
+			go v.Start()
+		}
+	}
+	log.Printf("Started %d viewers", totalViewers)
+
+	// Skaters record their results themselves, straight into the metrics
+	// queue, so a slow metrics file holds them up only with the block
+	// overflow policy.
+	recordSkater := run.SkaterRecorder(skaterSummary, live.Skaters, skaterSink)
+	config.Skaters.StartSkaters(ctx, stopChan, plans, limiter, rampUp, live.Skaters, recordSkater, &skatersWg)
+
+	log.Printf("Metrics being written to: %s and %s", config.SkaterMetricsFile, config.ViewerMetricsFile)
+	check := func(final bool) []metrics.Breach {
+		return append(config.SkaterThresholds.Check(skaterSummary.Report(), final),
+			config.ViewerThresholds.Check(viewerSummary.Report(), final)...)
+	}
+	run.Wait(runDuration, check)
+
+	log.Println("Shutting down...")
+	cancel()
+	close(stopChan)
+
+	skatersWg.Wait()
+	viewersWg.Wait()
+	close(viewerResults)
+	metricsWg.Wait()
+	run.CloseSinks(skaterSink, viewerSink)
+
+	log.Println("Simulation stopped")
+
+	end := time.Now()
+	skaterSummary.Finish(end)
+	viewerSummary.Finish(end)
+	run.LogSummary("Skaters:", skaterSummary)
+	run.LogSummary("Viewers:", viewerSummary)
+	err = run.WriteSummaryFile(config.SummaryFile, func(filename string) error {
+		return metrics.WriteSimulationJSONFile(filename, skaterSummary, viewerSummary)
+	})
+	if err != nil {
+		return err
+	}
+
+	failures := fmt.Sprintf("%d failed updates and %d viewer errors", skaterSummary.Errors(), viewerSummary.Errors())
+	return run.Outcome(config.ErrorBudget, skaterSummary.Errors()+viewerSummary.Errors(), failures, check)
+}
+
+// logViewerResult logs viewer errors and reconnections, leaving out other
+// results so the log of a combined run stays readable.
+func logViewerResult(result viewer.ViewerResult) {
+	if result.Error != nil {
+		log.Printf("Error for viewer %d in event %s: %v",
+			result.ViewerNumber, result.EventID, result.Error)
+	} else if r := result.Reconnect; r != nil {
+		log.Printf("Viewer %d (event %s): reconnected after %s (%d attempts)",
+			result.ViewerNumber, result.EventID, r.Downtime.Round(time.Millisecond), r.Attempts)
+	}
+}
@@ -5,41 +5,35 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"math"
 	"net/url"
 	"os"
-	"os/signal"
-	"strings"
 	"sync"
-	"syscall"
 	"time"
 
+	"load-testing/internal/correlation"
 	"load-testing/internal/metrics"
-	"load-testing/internal/skater"
-
-	"github.com/google/uuid"
-	"golang.org/x/time/rate"
-)
-
-const (
-	maxResultsBufferSize = 1000
+	"load-testing/internal/run"
+	"load-testing/internal/scenario"
+	"load-testing/internal/simulation"
 )
 
 type Config struct {
-	NumEvents       int
-	SkatersPerEvent int
-	UpdateInterval  time.Duration
-	TargetURL       string
-	MetricsFile     string
-	EventIDs        string
-	RateLimit       float64
-	RampUpDuration  time.Duration
+	Skaters        run.SkaterFlags
+	Metrics        run.MetricsFlags
+	TargetURL      string
+	MetricsFile    string
+	ScenarioFile   string
+	Duration       time.Duration
+	ErrorBudget    int
+	SummaryFile    string
+	Thresholds     metrics.SkaterThresholds
+	CorrelationLog string
 }
 
 func main() {
 	config := parseFlags()
 
-	if err := run(config); err != nil {
+	if err := runSimulation(config); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -47,19 +41,21 @@ func main() {
 func parseFlags() Config {
 	var config Config
 
-	flag.IntVar(&config.NumEvents, "events", 1, "Number of events to simulate")
-	flag.IntVar(&config.SkatersPerEvent, "skaters-per-event", 10, "Number of skaters per event")
-
-	var intervalStr string
-	flag.StringVar(&intervalStr, "update-interval", "3s", "Interval between location updates (e.g., 3s, 1m)")
-
+	config.Skaters.Register()
 	flag.StringVar(&config.TargetURL, "target-url", "", "Target URL for the API (required)")
-	flag.StringVar(&config.MetricsFile, "metrics-file", "metrics.csv", "Output file for metrics")
-	flag.StringVar(&config.EventIDs, "event-id", "", "Comma-separated list of event IDs to use (optional, generates random if not provided)")
-	flag.Float64Var(&config.RateLimit, "rate-limit", 0, "Optional maximum requests per second (0 = unlimited)")
+	flag.StringVar(&config.MetricsFile, "metrics-file", "", "Output file for metrics (default metrics.<format>)")
+	config.Metrics.Register(":9100")
+	flag.StringVar(&config.ScenarioFile, "scenario", "", "Optional YAML or JSON scenario file describing events, skater counts, join/leave curves and duration (overrides --events, --skaters-per-event, --update-interval and --event-id)")
 
-	var rampUpStr string
-	flag.StringVar(&rampUpStr, "ramp-up-duration", "", "Optional duration to gradually increase load (e.g., 5m, 10s)")
+	var durationStr string
+	flag.StringVar(&durationStr, "duration", "", "Optional run duration after which the simulation stops by itself (e.g., 45m, 2h; overrides the scenario duration)")
+	flag.IntVar(&config.ErrorBudget, "error-budget", -1, "Maximum number of failed updates before the run exits non-zero (-1 = unlimited)")
+	flag.StringVar(&config.SummaryFile, "summary-file", "", "Optional file to write the end-of-run summary to as JSON")
+	flag.Float64Var(&config.Thresholds.MaxErrorRate, "max-error-rate", -1, "SLO: maximum fraction of failed updates (0-1, -1 = unchecked)")
+
+	var maxP99ResponseStr string
+	flag.StringVar(&maxP99ResponseStr, "max-p99-response", "", "SLO: maximum p99 response time (e.g., 200ms)")
+	flag.StringVar(&config.CorrelationLog, "correlation-log", "", "Optional file to record sent locations in, for simulate-viewers to measure end-to-end latency")
 
 	flag.Parse()
 
@@ -73,257 +69,173 @@ func parseFlags() Config {
 		log.Fatalf("Invalid target URL: %v", err)
 	}
 
-	if config.NumEvents <= 0 {
-		log.Fatalf("Number of events must be positive, got: %d", config.NumEvents)
+	config.Skaters.Parse()
+	config.Metrics.Parse()
+	if config.MetricsFile == "" {
+		config.MetricsFile = "metrics." + config.Metrics.Format
+	}
+
+	if durationStr != "" {
+		duration, err := time.ParseDuration(durationStr)
+		if err != nil {
+			log.Fatalf("Invalid duration: %v", err)
+		}
+		if duration <= 0 {
+			log.Fatalf("Duration must be positive, got: %v", duration)
+		}
+		config.Duration = duration
 	}
 
-	if config.SkatersPerEvent <= 0 {
-		log.Fatalf("Number of skaters per event must be positive, got: %d", config.SkatersPerEvent)
+	if config.ErrorBudget < -1 {
+		log.Fatalf("Error budget must be -1 (unlimited) or non-negative, got: %d", config.ErrorBudget)
 	}
 
-	interval, err := time.ParseDuration(intervalStr)
-	if err != nil {
-		log.Fatalf("Invalid update interval: %v", err)
+	if config.Thresholds.MaxErrorRate > 1 || (config.Thresholds.MaxErrorRate < 0 && config.Thresholds.MaxErrorRate != -1) {
+		log.Fatalf("Max error rate must be between 0 and 1, or -1 to disable, got: %f", config.Thresholds.MaxErrorRate)
 	}
-	config.UpdateInterval = interval
 
-	if rampUpStr != "" {
-		rampUp, err := time.ParseDuration(rampUpStr)
+	if maxP99ResponseStr != "" {
+		maxP99Response, err := time.ParseDuration(maxP99ResponseStr)
 		if err != nil {
-			log.Fatalf("Invalid ramp-up duration: %v", err)
+			log.Fatalf("Invalid max p99 response: %v", err)
 		}
-		if rampUp < time.Second {
-			log.Fatalf("Ramp-up duration must be at least 1 second, got: %v", rampUp)
+		if maxP99Response <= 0 {
+			log.Fatalf("Max p99 response must be positive, got: %v", maxP99Response)
 		}
-		if rampUp > time.Hour {
-			log.Fatalf("Ramp-up duration must be at most 1 hour, got: %v", rampUp)
-		}
-		config.RampUpDuration = rampUp
-	}
-
-	if config.RateLimit < 0 {
-		log.Fatalf("Rate limit must be non-negative, got: %f", config.RateLimit)
+		config.Thresholds.MaxP99Response = maxP99Response
 	}
 
 	return config
 }
 
-func parseEventIDs(eventIDsStr string, numEvents int) ([]string, error) {
-	if eventIDsStr == "" {
-		eventIDs := make([]string, numEvents)
-		for i := 0; i < numEvents; i++ {
-			eventIDs[i] = uuid.New().String()
-		}
-		return eventIDs, nil
+func runSimulation(config Config) error {
+	defaultMovement, shutdownTracing, err := config.Skaters.NewMovement()
+	if err != nil {
+		return err
 	}
+	defer shutdownTracing()
 
-	eventIDs := strings.Split(eventIDsStr, ",")
-	for i := range eventIDs {
-		eventIDs[i] = strings.TrimSpace(eventIDs[i])
-	}
+	if config.CorrelationLog != "" {
+		correlationLog, err := correlation.CreateLog(config.CorrelationLog)
+		if err != nil {
+			return err
+		}
+		defer correlationLog.Close()
 
-	if len(eventIDs) != numEvents {
-		return nil, fmt.Errorf("number of provided event IDs (%d) does not match --events (%d)", len(eventIDs), numEvents)
+		defaultMovement.SkaterOpts = append(defaultMovement.SkaterOpts, simulation.PublishSends(
+			func(update correlation.Update) {
+				if err := correlationLog.Publish(update); err != nil {
+					log.Printf("Error recording sent location: %v", err)
+				}
+			}))
+		log.Printf("Recording sent locations to: %s", config.CorrelationLog)
 	}
 
-	for i, id := range eventIDs {
-		if id == "" {
-			return nil, fmt.Errorf("empty event ID at position %d", i+1)
+	var plans []simulation.SkaterPlan
+	var runDuration time.Duration
+
+	if config.ScenarioFile != "" {
+		sc, err := scenario.Load(config.ScenarioFile)
+		if err != nil {
+			return err
 		}
-		if _, err := uuid.Parse(id); err != nil {
-			return nil, fmt.Errorf("invalid UUID format for event ID %d (%s): %w", i+1, id, err)
+		runDuration = time.Duration(sc.Duration)
+
+		log.Printf("Starting scenario %q with %d events, %d skaters, duration: %s",
+			sc.Name, len(sc.Events), sc.TotalSkaters(), runDuration)
+		eventIDs := make([]string, len(sc.Events))
+		for i, event := range sc.Events {
+			eventIDs[i] = event.ID
+			log.Printf("Event %q (%s): %d skaters, update interval: %s ± %s",
+				event.Name, event.ID, event.Skaters,
+				time.Duration(event.UpdateInterval), time.Duration(event.IntervalJitter))
 		}
-	}
-
-	return eventIDs, nil
-}
+		log.Printf("Scenario event IDs: %v", eventIDs)
 
-func run(config Config) error {
-	log.Printf("Starting simulation with %d events, %d skaters per event, update interval: %s",
-		config.NumEvents, config.SkatersPerEvent, config.UpdateInterval)
-
-	if config.RateLimit > 0 {
-		log.Printf("Rate limiting enabled: %.2f requests/second", config.RateLimit)
-	}
-	if config.RampUpDuration > 0 {
-		log.Printf("Ramp-up enabled: %s", config.RampUpDuration)
-	}
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+		plans, err = simulation.BuildScenarioPlans(sc, config.TargetURL, defaultMovement)
+		if err != nil {
+			return err
+		}
+	} else {
+		skaters := config.Skaters
+		log.Printf("Starting simulation with %d events, %d skaters per event, update interval: %s",
+			skaters.NumEvents, skaters.SkatersPerEvent, skaters.UpdateInterval)
 
-	var limiter *rate.Limiter
-	var rampUpConfig *struct {
-		initialRate float64
-		targetRate  float64
-	}
+		eventIDs, err := simulation.ParseEventIDs(skaters.EventIDs, skaters.NumEvents)
+		if err != nil {
+			return err
+		}
 
-	if config.RateLimit > 0 || config.RampUpDuration > 0 {
-		var initialRate float64
-		if config.RampUpDuration > 0 {
-			totalSkaters := config.NumEvents * config.SkatersPerEvent
-			naturalRate := float64(totalSkaters) / config.UpdateInterval.Seconds()
-			targetRate := naturalRate
-			if config.RateLimit > 0 && config.RateLimit < naturalRate {
-				targetRate = config.RateLimit
-			}
-			initialRate = math.Max(targetRate*0.1, 0.1)
-			rampUpConfig = &struct {
-				initialRate float64
-				targetRate  float64
-			}{initialRate, targetRate}
+		if skaters.EventIDs != "" {
+			log.Printf("Using provided event IDs: %v", eventIDs)
 		} else {
-			initialRate = config.RateLimit
+			log.Printf("Generated event IDs: %v", eventIDs)
 		}
-		burst := int(math.Ceil(initialRate))
-		if burst < 1 {
-			burst = 1
+
+		plans, err = simulation.BuildPlans(eventIDs, skaters.SkatersPerEvent, skaters.UpdateInterval, config.TargetURL, defaultMovement)
+		if err != nil {
+			return err
 		}
-		limiter = rate.NewLimiter(rate.Limit(initialRate), burst)
 	}
 
-	metricsWriter, err := metrics.NewWriter(config.MetricsFile)
-	if err != nil {
-		return fmt.Errorf("failed to create metrics writer: %w", err)
+	if config.Duration > 0 {
+		runDuration = config.Duration
 	}
-	defer metricsWriter.Close()
-
-	results := make(chan skater.UpdateResult, maxResultsBufferSize)
-	var skatersWg sync.WaitGroup
-	var metricsWg sync.WaitGroup
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	config.Skaters.LogLoad(defaultMovement)
 
-	stopChan := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	metricsWg.Add(1)
-	go func() {
-		defer metricsWg.Done()
-		for {
-			select {
-			case result, ok := <-results:
-				if !ok {
-					return
-				}
-				if err := metricsWriter.WriteResult(result); err != nil {
-					log.Printf("Error writing metric: %v", err)
-				}
-				if result.Error != nil {
-					log.Printf("Error updating location for skater %s in event %s: %v",
-						result.SkaterID, result.EventID, result.Error)
-				}
-			case <-stopChan:
-				for {
-					select {
-					case result, ok := <-results:
-						if !ok {
-							return
-						}
-						if err := metricsWriter.WriteResult(result); err != nil {
-							log.Printf("Error writing metric during shutdown: %v", err)
-						}
-					default:
-						return
-					}
-				}
-			}
-		}
-	}()
+	limiter, rampUp := simulation.NewLimiter(config.Skaters.RateLimit, config.Skaters.RampUpDuration, plans)
 
-	eventIDs, err := parseEventIDs(config.EventIDs, config.NumEvents)
+	live, err := config.Metrics.ServeLiveMetrics(true, false)
 	if err != nil {
 		return err
 	}
-
-	if config.EventIDs != "" {
-		log.Printf("Using provided event IDs: %v", eventIDs)
-	} else {
-		log.Printf("Generated event IDs: %v", eventIDs)
+	defer live.Close()
+	if limiter != nil {
+		live.Skaters.TrackRateLimit(func() float64 { return float64(limiter.Limit()) })
 	}
 
-	skaters := make([]*skater.Skater, 0, config.NumEvents*config.SkatersPerEvent)
-	for _, eventID := range eventIDs {
-		for j := 0; j < config.SkatersPerEvent; j++ {
-			skaterID := uuid.New().String()
-			s := skater.New(eventID, skaterID, config.TargetURL)
-			skaters = append(skaters, s)
-		}
+	metricsSink, err := config.Metrics.NewSink(config.MetricsFile, metrics.SkaterSchema, live.Queue)
+	if err != nil {
+		return err
 	}
+	defer run.CloseSinks(metricsSink)
 
-	if limiter != nil && rampUpConfig != nil {
-		go func() {
-			initialRate := rampUpConfig.initialRate
-			targetRate := rampUpConfig.targetRate
-			steps := 100
-			stepDuration := config.RampUpDuration / time.Duration(steps)
-			rateIncrement := (targetRate - initialRate) / float64(steps)
-
-			log.Printf("Ramping up from %.2f to %.2f requests/second over %s", initialRate, targetRate, config.RampUpDuration)
-
-			for i := 0; i < steps; i++ {
-				select {
-				case <-ctx.Done():
-					return
-				case <-time.After(stepDuration):
-					select {
-					case <-ctx.Done():
-						return
-					default:
-						newRate := initialRate + rateIncrement*float64(i+1)
-						newBurst := int(math.Ceil(newRate))
-						if newBurst < 1 {
-							newBurst = 1
-						}
-						limiter.SetLimit(rate.Limit(newRate))
-						limiter.SetBurst(newBurst)
-					}
-				}
-			}
-			log.Printf("Ramp-up complete: %.2f requests/second", targetRate)
-		}()
-	}
+	var skatersWg sync.WaitGroup
 
-	log.Printf("Starting %d skaters...", len(skaters))
-	for _, s := range skaters {
-		skatersWg.Add(1)
-		go func(sk *skater.Skater) {
-			defer skatersWg.Done()
-			ticker := time.NewTicker(config.UpdateInterval)
-			defer ticker.Stop()
-
-			for {
-				select {
-				case <-ticker.C:
-					if limiter != nil {
-						if err := limiter.Wait(ctx); err != nil {
-							return
-						}
-					}
-					sk.Move()
-					result := sk.UpdateLocation()
-					results <- result
-				case <-ctx.Done():
-					return
-				case <-stopChan:
-					return
-				}
-			}
-		}(s)
-	}
+	stopChan := make(chan struct{})
+	summary := metrics.NewSkaterSummary(time.Now())
+	summary.TrackMetricsQueue(metricsSink)
+
+	// Skaters record their results themselves, straight into the metrics
+	// queue, so a slow metrics file holds them up only with the block
+	// overflow policy.
+	record := run.SkaterRecorder(summary, live.Skaters, metricsSink)
+	config.Skaters.StartSkaters(ctx, stopChan, plans, limiter, rampUp, live.Skaters, record, &skatersWg)
 
-	log.Printf("Simulation running. Press Ctrl+C to stop.")
 	log.Printf("Metrics being written to: %s", config.MetricsFile)
+	check := func(final bool) []metrics.Breach {
+		return config.Thresholds.Check(summary.Report(), final)
+	}
+	run.Wait(runDuration, check)
 
-	<-sigChan
 	log.Println("Shutting down...")
 	cancel()
 	close(stopChan)
 
 	skatersWg.Wait()
-	close(results)
-	metricsWg.Wait()
+	run.CloseSinks(metricsSink)
 
 	log.Println("Simulation stopped")
-	return nil
+
+	summary.Finish(time.Now())
+	run.LogSummary("", summary)
+	if err := run.WriteSummaryFile(config.SummaryFile, summary.WriteJSONFile); err != nil {
+		return err
+	}
+
+	return run.Outcome(config.ErrorBudget, summary.Errors(), fmt.Sprintf("%d failed updates", summary.Errors()), check)
 }
@@ -7,31 +7,43 @@ import (
 	"log"
 	"net/url"
 	"os"
-	"os/signal"
 	"strings"
 	"sync"
-	"syscall"
+	"time"
 
+	"load-testing/internal/correlation"
+	"load-testing/internal/delay"
 	"load-testing/internal/metrics"
+	"load-testing/internal/run"
+	"load-testing/internal/scenario"
 	"load-testing/internal/viewer"
 )
 
-const (
-	defaultBufferSize = 1000
-)
+const defaultBufferSize = 1000
 
 type Config struct {
 	ViewersPerEvent int
 	EventIDs        []string
 	TargetURL       string
 	MetricsFile     string
+	Metrics         run.MetricsFlags
 	BufferSize      int
+	ScenarioFile    string
+	Duration        time.Duration
+	ErrorBudget     int
+	SummaryFile     string
+	Thresholds      metrics.ViewerThresholds
+	CorrelationLog  string
+	ExpectedSkaters string
+	Reconnect       bool
+	Backoff         viewer.Backoff
+	Churn           *viewer.Churn
 }
 
 func main() {
 	config := parseFlags()
 
-	if err := run(config); err != nil {
+	if err := runSimulation(config); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -43,8 +55,35 @@ func parseFlags() Config {
 	flag.IntVar(&config.ViewersPerEvent, "viewers-per-event", 1, "Number of viewers per event")
 	flag.StringVar(&eventsStr, "events", "", "Comma-separated list of event IDs (required)")
 	flag.StringVar(&config.TargetURL, "target-url", "", "Target URL for the API (required)")
-	flag.StringVar(&config.MetricsFile, "metrics-file", "viewer-metrics.csv", "Output file for metrics")
+	flag.StringVar(&config.MetricsFile, "metrics-file", "", "Output file for metrics (default viewer-metrics.<format>)")
+	config.Metrics.Register(":9101")
 	flag.IntVar(&config.BufferSize, "buffer-size", defaultBufferSize, "Size of results buffer")
+	flag.StringVar(&config.ScenarioFile, "scenario", "", "Optional YAML or JSON scenario file; viewers watch its events and stop when it finishes (overrides --events)")
+
+	var durationStr string
+	flag.StringVar(&durationStr, "duration", "", "Optional run duration after which the simulation stops by itself (e.g., 45m, 2h; overrides the scenario duration)")
+	flag.IntVar(&config.ErrorBudget, "error-budget", -1, "Maximum number of viewer errors before the run exits non-zero (-1 = unlimited)")
+	flag.StringVar(&config.SummaryFile, "summary-file", "", "Optional file to write the end-of-run summary to as JSON")
+	flag.Float64Var(&config.Thresholds.MaxErrorRate, "max-error-rate", -1, "SLO: maximum viewer errors as a fraction of messages and errors (0-1, -1 = unchecked)")
+	flag.IntVar(&config.Thresholds.MinMessagesPerViewer, "min-messages-per-viewer", 0, "SLO: minimum number of messages every viewer must receive (0 = unchecked)")
+
+	var maxP99LatencyStr string
+	flag.StringVar(&maxP99LatencyStr, "max-p99-latency", "", "SLO: maximum p99 message latency (e.g., 500ms)")
+	flag.StringVar(&config.CorrelationLog, "correlation-log", "", "Optional correlation log written by simulate-skaters on this machine, to measure end-to-end latency and check every active skater is delivered")
+	flag.StringVar(&config.ExpectedSkaters, "expected-skaters", "", "Optional YAML or JSON manifest of skaters every viewer of each event should receive throughout the run")
+	flag.IntVar(&config.Thresholds.MaxMissingSkaters, "max-missing-skaters", -1, "SLO: maximum number of skaters a viewer that should have seen them never received (-1 = unchecked; needs --correlation-log or --expected-skaters)")
+	flag.IntVar(&config.Thresholds.MaxBatchSize, "max-batch-size", metrics.DefaultMaxBatchSize, "SLO: maximum locations in a batch, the API's skatemap.stream.batchSize (0 = unchecked)")
+	flag.BoolVar(&config.Reconnect, "reconnect", false, "Reconnect viewers with exponential backoff and jitter when their connection fails or closes, rather than stopping them")
+
+	var reconnectBackoffStr, reconnectMaxBackoffStr string
+	flag.StringVar(&reconnectBackoffStr, "reconnect-backoff", "500ms", "Wait before the first reconnection attempt, doubled after each failed attempt")
+	flag.StringVar(&reconnectMaxBackoffStr, "reconnect-max-backoff", "30s", "Longest wait between reconnection attempts")
+
+	var churnSessionStr, churnRejoinStr string
+	var churnAbruptRate float64
+	flag.StringVar(&churnSessionStr, "churn-session", "", "Optional session length distribution after which each viewer leaves and rejoins (e.g., 2m, uniform:30s-5m, exp:2m)")
+	flag.StringVar(&churnRejoinStr, "churn-rejoin", "5s", "Delay distribution before a churning viewer rejoins (e.g., 5s, exp:10s)")
+	flag.Float64Var(&churnAbruptRate, "churn-abrupt-rate", 0, "Fraction of churning sessions ended by dropping the TCP connection rather than sending a close frame (0-1)")
 
 	flag.Parse()
 
@@ -54,8 +93,8 @@ func parseFlags() Config {
 		os.Exit(1)
 	}
 
-	if eventsStr == "" {
-		fmt.Println("Error: --events is required")
+	if eventsStr == "" && config.ScenarioFile == "" {
+		fmt.Println("Error: --events or --scenario is required")
 		flag.Usage()
 		os.Exit(1)
 	}
@@ -72,9 +111,95 @@ func parseFlags() Config {
 		log.Fatalf("Buffer size must be positive, got: %d", config.BufferSize)
 	}
 
-	config.EventIDs = parseEventIDs(eventsStr)
-	if len(config.EventIDs) == 0 {
-		log.Fatal("At least one event ID must be provided")
+	config.Metrics.Parse()
+	if config.MetricsFile == "" {
+		config.MetricsFile = "viewer-metrics." + config.Metrics.Format
+	}
+
+	if durationStr != "" {
+		duration, err := time.ParseDuration(durationStr)
+		if err != nil {
+			log.Fatalf("Invalid duration: %v", err)
+		}
+		if duration <= 0 {
+			log.Fatalf("Duration must be positive, got: %v", duration)
+		}
+		config.Duration = duration
+	}
+
+	if config.ErrorBudget < -1 {
+		log.Fatalf("Error budget must be -1 (unlimited) or non-negative, got: %d", config.ErrorBudget)
+	}
+
+	if config.Thresholds.MaxErrorRate > 1 || (config.Thresholds.MaxErrorRate < 0 && config.Thresholds.MaxErrorRate != -1) {
+		log.Fatalf("Max error rate must be between 0 and 1, or -1 to disable, got: %f", config.Thresholds.MaxErrorRate)
+	}
+
+	if config.Thresholds.MinMessagesPerViewer < 0 {
+		log.Fatalf("Min messages per viewer must be non-negative, got: %d", config.Thresholds.MinMessagesPerViewer)
+	}
+
+	if config.Thresholds.MaxMissingSkaters < -1 {
+		log.Fatalf("Max missing skaters must be -1 (unchecked) or non-negative, got: %d", config.Thresholds.MaxMissingSkaters)
+	}
+
+	if config.Thresholds.MaxBatchSize < 0 {
+		log.Fatalf("Max batch size must be non-negative, got: %d", config.Thresholds.MaxBatchSize)
+	}
+
+	if config.Thresholds.MaxMissingSkaters >= 0 && config.CorrelationLog == "" && config.ExpectedSkaters == "" {
+		log.Fatal("--max-missing-skaters needs --correlation-log or --expected-skaters")
+	}
+
+	if maxP99LatencyStr != "" {
+		maxP99Latency, err := time.ParseDuration(maxP99LatencyStr)
+		if err != nil {
+			log.Fatalf("Invalid max p99 latency: %v", err)
+		}
+		if maxP99Latency <= 0 {
+			log.Fatalf("Max p99 latency must be positive, got: %v", maxP99Latency)
+		}
+		config.Thresholds.MaxP99Latency = maxP99Latency
+	}
+
+	config.Backoff = viewer.DefaultBackoff()
+	reconnectBackoff, err := time.ParseDuration(reconnectBackoffStr)
+	if err != nil {
+		log.Fatalf("Invalid reconnect backoff: %v", err)
+	}
+	reconnectMaxBackoff, err := time.ParseDuration(reconnectMaxBackoffStr)
+	if err != nil {
+		log.Fatalf("Invalid reconnect max backoff: %v", err)
+	}
+	if reconnectBackoff <= 0 || reconnectMaxBackoff < reconnectBackoff {
+		log.Fatalf("Reconnect backoff must be positive and no more than the max backoff, got: %v and %v", reconnectBackoff, reconnectMaxBackoff)
+	}
+	config.Backoff.Initial = reconnectBackoff
+	config.Backoff.Max = reconnectMaxBackoff
+
+	if churnSessionStr != "" {
+		sessionLength, err := delay.Parse(churnSessionStr)
+		if err != nil {
+			log.Fatalf("Invalid churn session length: %v", err)
+		}
+		if sessionLength.A <= 0 {
+			log.Fatalf("Churn session length must be positive, got: %s", sessionLength)
+		}
+		rejoinDelay, err := delay.Parse(churnRejoinStr)
+		if err != nil {
+			log.Fatalf("Invalid churn rejoin delay: %v", err)
+		}
+		if churnAbruptRate < 0 || churnAbruptRate > 1 {
+			log.Fatalf("Churn abrupt rate must be between 0 and 1, got: %f", churnAbruptRate)
+		}
+		config.Churn = &viewer.Churn{SessionLength: sessionLength, RejoinDelay: rejoinDelay, AbruptRate: churnAbruptRate}
+	}
+
+	if config.ScenarioFile == "" {
+		config.EventIDs = parseEventIDs(eventsStr)
+		if len(config.EventIDs) == 0 {
+			log.Fatal("At least one event ID must be provided")
+		}
 	}
 
 	return config
@@ -94,67 +219,185 @@ func parseEventIDs(eventsStr string) []string {
 	return eventIDs
 }
 
-func run(config Config) error {
-	totalViewers := len(config.EventIDs) * config.ViewersPerEvent
-	log.Printf("Starting simulation with %d events, %d viewers per event (%d total viewers)",
-		len(config.EventIDs), config.ViewersPerEvent, totalViewers)
-	log.Printf("Event IDs: %v", config.EventIDs)
+// viewerCounts returns the number of viewers to start for each event in a scenario.
+// Events without a viewer count use the --viewers-per-event default.
+func viewerCounts(sc *scenario.Scenario, defaultViewers int) ([]string, []int, error) {
+	eventIDs := make([]string, len(sc.Events))
+	counts := make([]int, len(sc.Events))
+	for i, event := range sc.Events {
+		if event.IDGenerated {
+			return nil, nil, fmt.Errorf("event %q has no id; scenario events need explicit IDs to be watched by viewers", event.Name)
+		}
+		eventIDs[i] = event.ID
+		counts[i] = event.Viewers
+		if counts[i] == 0 {
+			counts[i] = defaultViewers
+		}
+	}
+	return eventIDs, counts, nil
+}
+
+func runSimulation(config Config) error {
+	eventIDs := config.EventIDs
+	counts := make([]int, len(eventIDs))
+	for i := range counts {
+		counts[i] = config.ViewersPerEvent
+	}
+
+	var runDuration time.Duration
+	if config.ScenarioFile != "" {
+		sc, err := scenario.Load(config.ScenarioFile)
+		if err != nil {
+			return err
+		}
+		eventIDs, counts, err = viewerCounts(sc, config.ViewersPerEvent)
+		if err != nil {
+			return err
+		}
+		runDuration = time.Duration(sc.Duration)
+		log.Printf("Loaded scenario %q, duration: %s", sc.Name, runDuration)
+	}
+
+	if config.Duration > 0 {
+		runDuration = config.Duration
+	}
+
+	totalViewers := 0
+	for _, c := range counts {
+		totalViewers += c
+	}
+	log.Printf("Starting simulation with %d events (%d total viewers)", len(eventIDs), totalViewers)
+	log.Printf("Event IDs: %v", eventIDs)
+
+	live, err := config.Metrics.ServeLiveMetrics(false, true)
+	if err != nil {
+		return err
+	}
+	defer live.Close()
+
+	var viewerOpts []viewer.Option
+	if live.Viewers != nil {
+		viewerOpts = append(viewerOpts, viewer.WithConnectionHooks(live.Viewers.ConnectionOpened, live.Viewers.ConnectionClosed))
+	}
+
+	if config.Reconnect {
+		viewerOpts = append(viewerOpts, viewer.WithReconnect(config.Backoff))
+		log.Printf("Viewers reconnect with backoff from %s up to %s", config.Backoff.Initial, config.Backoff.Max)
+	}
+	if config.Churn != nil {
+		viewerOpts = append(viewerOpts, viewer.WithChurn(*config.Churn))
+		log.Printf("Viewers churn with sessions of %s and rejoin after %s", config.Churn.SessionLength, config.Churn.RejoinDelay)
+	}
 
-	metricsWriter, err := metrics.NewViewerWriter(config.MetricsFile)
+	metricsSink, err := config.Metrics.NewSink(config.MetricsFile, metrics.ViewerSchema, live.Queue)
 	if err != nil {
-		return fmt.Errorf("failed to create metrics writer: %w", err)
+		return err
 	}
-	defer metricsWriter.Close()
+	defer run.CloseSinks(metricsSink)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	summary := metrics.NewViewerSummary(time.Now())
+	summary.TrackMetricsQueue(metricsSink)
+
+	var delivery *metrics.DeliveryChecker
+	if config.CorrelationLog != "" || config.ExpectedSkaters != "" {
+		delivery = metrics.NewDeliveryChecker(metrics.DefaultDeliveryConfig(), time.Now())
+		summary.TrackDelivery(delivery)
+	}
+
+	if config.ExpectedSkaters != "" {
+		manifest, err := correlation.LoadManifest(config.ExpectedSkaters)
+		if err != nil {
+			return err
+		}
+		delivery.ExpectManifest(manifest)
+		log.Printf("Checking delivery of skaters listed in: %s", config.ExpectedSkaters)
+	}
+
+	if config.CorrelationLog != "" {
+		tracker := correlation.NewTracker(correlation.DefaultRetention)
+		viewerOpts = append(viewerOpts, viewer.WithCorrelation(tracker))
+		go func() {
+			publishers := correlation.Publishers{tracker, delivery}
+			if err := correlation.Follow(ctx, config.CorrelationLog, publishers, correlation.DefaultPollInterval); err != nil {
+				log.Printf("Error following correlation log: %v", err)
+			}
+		}()
+		log.Printf("Matching received locations against: %s", config.CorrelationLog)
+	}
+
 	results := make(chan viewer.ViewerResult, config.BufferSize)
 	var viewersWg sync.WaitGroup
 	var metricsWg sync.WaitGroup
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
 	metricsWg.Add(1)
 	go func() {
 		defer metricsWg.Done()
-		for result := range results {
-			if err := metricsWriter.WriteResult(result); err != nil {
-				log.Printf("Error writing metric: %v", err)
-			}
-			if result.Error != nil {
-				log.Printf("Error for viewer %d in event %s: %v",
-					result.ViewerNumber, result.EventID, result.Error)
-			} else {
-				log.Printf("Viewer %d (event %s): received %d messages, latency %.2fms",
-					result.ViewerNumber, result.EventID, result.MessageCount,
-					float64(result.Latency.Microseconds())/1000.0)
-			}
-		}
+		run.RecordViewers(results, summary, live.Viewers, metricsSink, logResult)
 	}()
 
 	viewerNumber := 0
-	for _, eventID := range config.EventIDs {
-		for j := 0; j < config.ViewersPerEvent; j++ {
+	for i, eventID := range eventIDs {
+		for j := 0; j < counts[i]; j++ {
 			viewerNumber++
-			v := viewer.New(ctx, eventID, viewerNumber, config.TargetURL, results, &viewersWg)
+			summary.ExpectViewer(eventID, viewerNumber)
+			v := viewer.New(ctx, eventID, viewerNumber, config.TargetURL, results, &viewersWg, viewerOpts...)
 			viewersWg.Add(1)
 			go v.Start()
 		}
 	}
 
-	log.Printf("Simulation running with %d viewers. Press Ctrl+C to stop.", totalViewers)
 	log.Printf("Metrics being written to: %s", config.MetricsFile)
+	check := func(final bool) []metrics.Breach {
+		return config.Thresholds.Check(summary.Report(), final)
+	}
+	run.Wait(runDuration, check)
 
-	<-sigChan
 	log.Println("Shutting down...")
 	cancel()
 
 	viewersWg.Wait()
 	close(results)
 	metricsWg.Wait()
+	run.CloseSinks(metricsSink)
 
 	log.Println("Simulation stopped")
-	return nil
+
+	summary.Finish(time.Now())
+	run.LogSummary("", summary)
+	if err := run.WriteSummaryFile(config.SummaryFile, summary.WriteJSONFile); err != nil {
+		return err
+	}
+
+	return run.Outcome(config.ErrorBudget, summary.Errors(), fmt.Sprintf("%d viewer errors", summary.Errors()), check)
+}
+
+// logResult logs a viewer result as it is recorded.
+func logResult(result viewer.ViewerResult) {
+	switch {
+	case result.Error != nil:
+		log.Printf("Error for viewer %d in event %s: %v",
+			result.ViewerNumber, result.EventID, result.Error)
+	case result.Handshake != nil:
+		log.Printf("Viewer %d (event %s): handshake took %.2fms, status %d",
+			result.ViewerNumber, result.EventID, float64(result.Handshake.Duration.Microseconds())/1000.0, result.Handshake.Status)
+	case result.Connect != nil:
+		log.Printf("Viewer %d (event %s): connected (connection %d)",
+			result.ViewerNumber, result.EventID, result.Connect.Number)
+	case result.FirstMessage != nil:
+		log.Printf("Viewer %d (event %s): first message after %.2fms",
+			result.ViewerNumber, result.EventID, float64(result.FirstMessage.Wait.Microseconds())/1000.0)
+	case result.Leave != nil:
+		log.Printf("Viewer %d (event %s): left after %s (abrupt: %t)",
+			result.ViewerNumber, result.EventID, result.Leave.Session.Round(time.Millisecond), result.Leave.Abrupt)
+	case result.Reconnect != nil:
+		log.Printf("Viewer %d (event %s): reconnected after %s (%d attempts)",
+			result.ViewerNumber, result.EventID, result.Reconnect.Downtime.Round(time.Millisecond), result.Reconnect.Attempts)
+	default:
+		log.Printf("Viewer %d (event %s): received %d messages, latency %.2fms",
+			result.ViewerNumber, result.EventID, result.MessageCount,
+			float64(result.Latency.Microseconds())/1000.0)
+	}
 }
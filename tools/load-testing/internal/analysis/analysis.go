@@ -0,0 +1,339 @@
+// Package analysis turns the metrics files written by the simulators into the
+// tables used to compare runs: time series of throughput, errors and latency,
+// breakdowns per event and per skater, and a taxonomy of the errors seen.
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"load-testing/internal/metrics"
+)
+
+// DefaultInterval is the default length of each point of a time series.
+const DefaultInterval = time.Minute
+
+// Report holds the analysis of a run's skater and viewer metrics files.
+// Skaters or Viewers is nil if no file of that kind was analysed.
+type Report struct {
+	IntervalSeconds float64          `json:"interval_seconds"`
+	Skaters         *SkaterReport    `json:"skaters,omitempty"`
+	Viewers         *ViewerReport    `json:"viewers,omitempty"`
+	Errors          []ErrorKindCount `json:"errors"`
+}
+
+// SkaterReport analyses location update results.
+type SkaterReport struct {
+	StartTime         time.Time            `json:"start_time"`
+	EndTime           time.Time            `json:"end_time"`
+	Requests          int                  `json:"requests"`
+	Errors            int                  `json:"errors"`
+	ErrorRate         float64              `json:"error_rate"`
+	Skipped           int                  `json:"skipped,omitempty"`
+	RequestsPerSecond float64              `json:"requests_per_second"`
+	ResponseTime      metrics.LatencyStats `json:"response_time"`
+	Intervals         []SkaterInterval     `json:"intervals"`
+	Events            []SkaterBreakdown    `json:"events"`
+	Skaters           []SkaterBreakdown    `json:"skaters"`
+}
+
+// SkaterInterval holds the location updates sent during one interval of a
+// time series.
+type SkaterInterval struct {
+	Start             time.Time            `json:"start"`
+	Requests          int                  `json:"requests"`
+	RequestsPerSecond float64              `json:"requests_per_second"`
+	Errors            int                  `json:"errors"`
+	ErrorRate         float64              `json:"error_rate"`
+	ResponseTime      metrics.LatencyStats `json:"response_time"`
+}
+
+// SkaterBreakdown holds the location updates sent for a single event, or by
+// a single skater when SkaterID is set.
+type SkaterBreakdown struct {
+	EventID      string               `json:"event_id"`
+	SkaterID     string               `json:"skater_id,omitempty"`
+	Requests     int                  `json:"requests"`
+	Errors       int                  `json:"errors"`
+	ErrorRate    float64              `json:"error_rate"`
+	ResponseTime metrics.LatencyStats `json:"response_time"`
+}
+
+// ViewerReport analyses viewer results. Error rates are the share of
+// errors among received batches and errors.
+type ViewerReport struct {
+	StartTime         time.Time            `json:"start_time"`
+	EndTime           time.Time            `json:"end_time"`
+	Viewers           int                  `json:"viewers"`
+	Messages          int                  `json:"messages"`
+	Locations         int                  `json:"locations"`
+	Errors            int                  `json:"errors"`
+	ErrorRate         float64              `json:"error_rate"`
+	MessagesPerSecond float64              `json:"messages_per_second"`
+	MessagesPerViewer metrics.CountStats   `json:"messages_per_viewer"`
+	Latency           metrics.LatencyStats `json:"latency"`
+	EndToEnd          metrics.LatencyStats `json:"end_to_end_latency"`
+	Intervals         []ViewerInterval     `json:"intervals"`
+	Events            []ViewerBreakdown    `json:"events"`
+	PerViewer         []ViewerMessages     `json:"per_viewer"`
+}
+
+// ViewerInterval holds the viewer results of one interval of a time series.
+// Viewers counts the viewers that received a batch or had an error during it.
+type ViewerInterval struct {
+	Start             time.Time            `json:"start"`
+	Viewers           int                  `json:"viewers"`
+	Messages          int                  `json:"messages"`
+	MessagesPerSecond float64              `json:"messages_per_second"`
+	Locations         int                  `json:"locations"`
+	Errors            int                  `json:"errors"`
+	ErrorRate         float64              `json:"error_rate"`
+	Latency           metrics.LatencyStats `json:"latency"`
+	EndToEnd          metrics.LatencyStats `json:"end_to_end_latency"`
+}
+
+// ViewerBreakdown holds the viewer results of a single event.
+type ViewerBreakdown struct {
+	EventID   string               `json:"event_id"`
+	Viewers   int                  `json:"viewers"`
+	Messages  int                  `json:"messages"`
+	Locations int                  `json:"locations"`
+	Errors    int                  `json:"errors"`
+	ErrorRate float64              `json:"error_rate"`
+	Latency   metrics.LatencyStats `json:"latency"`
+	EndToEnd  metrics.LatencyStats `json:"end_to_end_latency"`
+}
+
+// ViewerMessages counts the batches received by a single viewer, and its
+// errors.
+type ViewerMessages struct {
+	EventID      string `json:"event_id"`
+	ViewerNumber int    `json:"viewer_number"`
+	Messages     int    `json:"messages"`
+	Locations    int    `json:"locations"`
+	Errors       int    `json:"errors"`
+}
+
+// ErrorKindCount counts the errors of one kind in the skater or viewer
+// results, as given by Source. Share is the fraction of that source's errors
+// of this kind, and Example the first error message seen.
+type ErrorKindCount struct {
+	Source    string    `json:"source"`
+	Kind      string    `json:"kind"`
+	Count     int       `json:"count"`
+	Share     float64   `json:"share"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Example   string    `json:"example"`
+}
+
+// Samples holds every skater response time and viewer latency of a run, in
+// milliseconds, for the statistical tests of Compare.
+type Samples struct {
+	ResponseTime []float64
+	Latency      []float64
+	EndToEnd     []float64
+}
+
+// Analyzer accumulates the events of metrics files. It keeps streaming
+// histograms rather than individual events, so files of any length can be
+// analysed, unless it is asked to keep samples too. It is not safe for
+// concurrent use.
+type Analyzer struct {
+	interval time.Duration
+	skaters  *skaterAnalysis
+	viewers  *viewerAnalysis
+	errors   map[errorKey]*ErrorKindCount
+	samples  *Samples
+}
+
+// Option configures an Analyzer.
+type Option func(*Analyzer)
+
+// KeepSamples makes the Analyzer keep every response time and latency, as
+// returned by Samples. Its memory use then grows with the number of events.
+func KeepSamples() Option {
+	return func(a *Analyzer) {
+		a.samples = &Samples{}
+	}
+}
+
+// New creates an Analyzer with time series of the given interval.
+func New(interval time.Duration, opts ...Option) *Analyzer {
+	a := &Analyzer{interval: interval, errors: make(map[errorKey]*ErrorKindCount)}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Samples returns the response times and latencies of the events added so
+// far, or nil if the Analyzer was not created with KeepSamples.
+func (a *Analyzer) Samples() *Samples {
+	return a.samples
+}
+
+type errorKey struct {
+	source, kind string
+}
+
+// timeRange is the span of the timestamps seen so far.
+type timeRange struct {
+	start, end time.Time
+}
+
+func (r *timeRange) add(t time.Time) {
+	if r.start.IsZero() || t.Before(r.start) {
+		r.start = t
+	}
+	if t.After(r.end) {
+		r.end = t
+	}
+}
+
+// Add analyses every event of a skater or viewer metrics file.
+func (a *Analyzer) Add(r *metrics.Reader) error {
+	var record func(values []any) error
+	switch r.Schema() {
+	case metrics.SkaterSchema:
+		if a.skaters == nil {
+			a.skaters = newSkaterAnalysis()
+		}
+		record = a.recordSkater
+	case metrics.ViewerSchema:
+		if a.viewers == nil {
+			a.viewers = newViewerAnalysis()
+		}
+		record = a.recordViewer
+	default:
+		return fmt.Errorf("cannot analyse %s metrics", r.Schema().Name)
+	}
+
+	for {
+		values, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := record(values); err != nil {
+			return err
+		}
+	}
+}
+
+// recordError adds an error to the taxonomy.
+func (a *Analyzer) recordError(source, kind, message string, timestamp time.Time) {
+	if kind == "" {
+		kind = metrics.ErrorKindOther
+	}
+
+	key := errorKey{source, kind}
+	count, ok := a.errors[key]
+	if !ok {
+		count = &ErrorKindCount{Source: source, Kind: kind, FirstSeen: timestamp, LastSeen: timestamp, Example: message}
+		a.errors[key] = count
+	}
+	count.Count++
+	if timestamp.Before(count.FirstSeen) {
+		count.FirstSeen = timestamp
+		count.Example = message
+	}
+	if timestamp.After(count.LastSeen) {
+		count.LastSeen = timestamp
+	}
+}
+
+// Report returns the analysis of the events added so far.
+func (a *Analyzer) Report() Report {
+	report := Report{IntervalSeconds: a.interval.Seconds(), Errors: []ErrorKindCount{}}
+	if a.skaters != nil {
+		skaters := a.skaters.report(a.interval)
+		report.Skaters = &skaters
+	}
+	if a.viewers != nil {
+		viewers := a.viewers.report(a.interval)
+		report.Viewers = &viewers
+	}
+
+	totals := make(map[string]int)
+	for key, count := range a.errors {
+		totals[key.source] += count.Count
+	}
+	for _, count := range a.errors {
+		c := *count
+		c.Share = fraction(c.Count, totals[c.Source])
+		report.Errors = append(report.Errors, c)
+	}
+	sort.Slice(report.Errors, func(i, j int) bool {
+		a, b := report.Errors[i], report.Errors[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Kind < b.Kind
+	})
+	return report
+}
+
+// intervalStarts returns the start of every interval from the one holding
+// r.start to the one holding r.end.
+func intervalStarts(r timeRange, interval time.Duration) []time.Time {
+	if r.start.IsZero() {
+		return nil
+	}
+
+	var starts []time.Time
+	for t := r.start.Truncate(interval); !t.After(r.end); t = t.Add(interval) {
+		starts = append(starts, t)
+	}
+	return starts
+}
+
+// intervalSeconds returns how much of the interval starting at start lies
+// within r, so rates in the first and last intervals are not understated.
+func intervalSeconds(start time.Time, interval time.Duration, r timeRange) float64 {
+	from, to := start, start.Add(interval)
+	if r.start.After(from) {
+		from = r.start
+	}
+	if r.end.Before(to) {
+		to = r.end
+	}
+	if !to.After(from) {
+		return interval.Seconds()
+	}
+	return to.Sub(from).Seconds()
+}
+
+func duration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+func fraction(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
+
+func rate(count int, seconds float64) float64 {
+	if seconds <= 0 {
+		return 0
+	}
+	return float64(count) / seconds
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,272 @@
+package analysis
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"load-testing/internal/metrics"
+	"load-testing/internal/skater"
+	"load-testing/internal/viewer"
+)
+
+var start = time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+
+// writeFile writes events to a metrics file in format and returns its name.
+func writeFile(t *testing.T, format string, schema *metrics.Schema, events []metrics.Event) string {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), schema.Name+"-metrics."+format)
+	sink, err := metrics.NewSink(format, filename, schema)
+	if err != nil {
+		t.Fatalf("NewSink() error = %v", err)
+	}
+	for _, event := range events {
+		if err := sink.Write(event); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return filename
+}
+
+func analyse(t *testing.T, interval time.Duration, files map[string]*metrics.Schema) Report {
+	t.Helper()
+
+	a := New(interval)
+	for filename, schema := range files {
+		r, err := metrics.Open(filename, schema)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		if err := a.Add(r); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		r.Close()
+	}
+	return a.Report()
+}
+
+func skaterEvents() []metrics.Event {
+	update := func(offset time.Duration, skaterID string, responseTime time.Duration, err error) metrics.Event {
+		return metrics.SkaterEvent(skater.UpdateResult{
+			EventID:      "event-a",
+			SkaterID:     skaterID,
+			Timestamp:    start.Add(offset),
+			ResponseTime: responseTime,
+			Error:        err,
+		})
+	}
+	return []metrics.Event{
+		update(0, "skater-1", 10*time.Millisecond, nil),
+		update(10*time.Second, "skater-2", 20*time.Millisecond, nil),
+		update(30*time.Second, "skater-1", 30*time.Millisecond, &skater.StatusError{StatusCode: 503}),
+		update(150*time.Second, "skater-2", 40*time.Millisecond, nil),
+		update(170*time.Second, "skater-2", 50*time.Millisecond, &skater.StatusError{StatusCode: 503}),
+		metrics.SkaterEvent(skater.UpdateResult{EventID: "event-b", SkaterID: "skater-3", Timestamp: start.Add(180 * time.Second), ResponseTime: 60 * time.Millisecond}),
+	}
+}
+
+func TestAnalyzer_Skaters(t *testing.T) {
+	for _, format := range metrics.Formats {
+		t.Run(format, func(t *testing.T) {
+			filename := writeFile(t, format, metrics.SkaterSchema, skaterEvents())
+			report := analyse(t, time.Minute, map[string]*metrics.Schema{filename: metrics.SkaterSchema})
+
+			s := report.Skaters
+			if s == nil || report.Viewers != nil {
+				t.Fatalf("expected only a skater report, got %+v", report)
+			}
+			if s.Requests != 6 || s.Errors != 2 || s.ErrorRate != 2.0/6 {
+				t.Errorf("expected 6 requests and 2 errors, got %d and %d (%v)", s.Requests, s.Errors, s.ErrorRate)
+			}
+			if !s.StartTime.Equal(start) || !s.EndTime.Equal(start.Add(3*time.Minute)) {
+				t.Errorf("expected the run to span 3 minutes, got %s to %s", s.StartTime, s.EndTime)
+			}
+
+			// The second minute has no updates but is still part of the
+			// series, and the last holds a single update at its start.
+			wantRequests := []int{3, 0, 2, 1}
+			if len(s.Intervals) != len(wantRequests) {
+				t.Fatalf("expected %d intervals, got %d", len(wantRequests), len(s.Intervals))
+			}
+			for i, want := range wantRequests {
+				interval := s.Intervals[i]
+				if !interval.Start.Equal(start.Add(time.Duration(i) * time.Minute)) {
+					t.Errorf("interval %d: expected to start at minute %d, got %s", i, i, interval.Start)
+				}
+				if interval.Requests != want {
+					t.Errorf("interval %d: expected %d requests, got %d", i, want, interval.Requests)
+				}
+			}
+			if got := s.Intervals[0].RequestsPerSecond; got != 0.05 {
+				t.Errorf("expected 3 requests a minute, got %v per second", got)
+			}
+			if got := s.Intervals[2].ErrorRate; got != 0.5 {
+				t.Errorf("expected half the third minute's requests to fail, got %v", got)
+			}
+			if got := s.Intervals[2].ResponseTime.Max; got < 49 || got > 51 {
+				t.Errorf("expected a max response time of about 50ms in the third minute, got %v", got)
+			}
+
+			if len(s.Events) != 2 || s.Events[0].EventID != "event-a" || s.Events[0].Requests != 5 || s.Events[1].Requests != 1 {
+				t.Errorf("expected 5 requests for event-a and 1 for event-b, got %+v", s.Events)
+			}
+
+			// Skaters with errors come first, the worst p99 first among them.
+			wantSkaters := []string{"skater-2", "skater-1", "skater-3"}
+			if len(s.Skaters) != len(wantSkaters) {
+				t.Fatalf("expected %d skaters, got %d", len(wantSkaters), len(s.Skaters))
+			}
+			for i, want := range wantSkaters {
+				if s.Skaters[i].SkaterID != want {
+					t.Errorf("skater %d: expected %s, got %s", i, want, s.Skaters[i].SkaterID)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzer_SkippedUpdates(t *testing.T) {
+	events := []metrics.Event{
+		metrics.SkaterEvent(skater.UpdateResult{EventID: "event-a", SkaterID: "skater-1", Timestamp: start, ResponseTime: 100 * time.Millisecond}),
+		metrics.SkaterEvent(skater.UpdateResult{EventID: "event-a", SkaterID: "skater-1", Timestamp: start.Add(time.Second), Error: skater.ErrSkipped}),
+	}
+	for _, format := range metrics.Formats {
+		t.Run(format, func(t *testing.T) {
+			filename := writeFile(t, format, metrics.SkaterSchema, events)
+			s := analyse(t, time.Minute, map[string]*metrics.Schema{filename: metrics.SkaterSchema}).Skaters
+
+			if s.Requests != 1 || s.Errors != 0 || s.Skipped != 1 {
+				t.Errorf("expected the skipped update on its own, got %d requests, %d errors and %d skipped", s.Requests, s.Errors, s.Skipped)
+			}
+			if s.ResponseTime.Count != 1 || s.ResponseTime.P50 < 99 {
+				t.Errorf("expected only the sent update to be timed, got %+v", s.ResponseTime)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_Viewers(t *testing.T) {
+	events := []metrics.Event{
+		metrics.ViewerEvent(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, Timestamp: start, Handshake: &viewer.Handshake{Duration: 20 * time.Millisecond, Status: 101}}),
+		metrics.ViewerEvent(viewer.ViewerResult{
+			EventID: "event-a", ViewerNumber: 1, MessageCount: 1, Timestamp: start.Add(time.Second),
+			Latency: 5 * time.Millisecond, SkaterIDs: []string{"skater-1", "skater-2"}, EndToEnd: []time.Duration{100 * time.Millisecond, 0}, Bytes: 300,
+		}),
+		metrics.ViewerEvent(viewer.ViewerResult{
+			EventID: "event-a", ViewerNumber: 2, MessageCount: 1, Timestamp: start.Add(2 * time.Second),
+			Latency: 15 * time.Millisecond, SkaterIDs: []string{"skater-1"}, Bytes: 200,
+		}),
+		metrics.ViewerEvent(viewer.ViewerResult{EventID: "event-b", ViewerNumber: 3, Timestamp: start.Add(70 * time.Second), Error: viewer.ErrMalformedBatch}),
+	}
+	filename := writeFile(t, metrics.FormatCSV, metrics.ViewerSchema, events)
+	report := analyse(t, time.Minute, map[string]*metrics.Schema{filename: metrics.ViewerSchema})
+
+	v := report.Viewers
+	if v == nil || report.Skaters != nil {
+		t.Fatalf("expected only a viewer report, got %+v", report)
+	}
+	if v.Viewers != 3 || v.Messages != 2 || v.Locations != 3 || v.Errors != 1 {
+		t.Errorf("expected 3 viewers, 2 messages, 3 locations and 1 error, got %d, %d, %d and %d", v.Viewers, v.Messages, v.Locations, v.Errors)
+	}
+	if v.ErrorRate != 1.0/3 {
+		t.Errorf("expected an error rate of 1 in 3, got %v", v.ErrorRate)
+	}
+	if v.EndToEnd.Count != 1 {
+		t.Errorf("expected 1 end-to-end latency, got %d", v.EndToEnd.Count)
+	}
+	if len(v.Intervals) != 2 || v.Intervals[0].Viewers != 2 || v.Intervals[0].Messages != 2 || v.Intervals[1].Errors != 1 {
+		t.Errorf("expected 2 messages then 1 error, got %+v", v.Intervals)
+	}
+	if len(v.Events) != 2 || v.Events[0].Viewers != 2 || v.Events[1].Errors != 1 {
+		t.Errorf("expected 2 viewers of event-a and an error for event-b, got %+v", v.Events)
+	}
+
+	// Viewers that received the fewest batches come first.
+	wantViewers := []ViewerMessages{
+		{EventID: "event-b", ViewerNumber: 3, Errors: 1},
+		{EventID: "event-a", ViewerNumber: 1, Messages: 1, Locations: 2},
+		{EventID: "event-a", ViewerNumber: 2, Messages: 1, Locations: 1},
+	}
+	if !slices.Equal(v.PerViewer, wantViewers) {
+		t.Errorf("expected viewers %+v, got %+v", wantViewers, v.PerViewer)
+	}
+	if want := (metrics.CountStats{Min: 0, Mean: 2.0 / 3, Max: 1}); v.MessagesPerViewer != want {
+		t.Errorf("expected messages per viewer %+v, got %+v", want, v.MessagesPerViewer)
+	}
+}
+
+func TestAnalyzer_Errors(t *testing.T) {
+	viewerEvents := []metrics.Event{
+		metrics.ViewerEvent(viewer.ViewerResult{EventID: "event-a", Timestamp: start.Add(time.Minute), Error: viewer.ErrMalformedBatch}),
+	}
+	files := map[string]*metrics.Schema{
+		writeFile(t, metrics.FormatJSONL, metrics.SkaterSchema, skaterEvents()): metrics.SkaterSchema,
+		writeFile(t, metrics.FormatJSONL, metrics.ViewerSchema, viewerEvents):   metrics.ViewerSchema,
+	}
+	report := analyse(t, time.Minute, files)
+
+	if len(report.Errors) != 2 {
+		t.Fatalf("expected 2 kinds of error, got %+v", report.Errors)
+	}
+	skaterErrors := report.Errors[0]
+	if skaterErrors.Source != "skater" || skaterErrors.Kind != "http_503" || skaterErrors.Count != 2 || skaterErrors.Share != 1 {
+		t.Errorf("expected 2 skater http_503 errors, got %+v", skaterErrors)
+	}
+	if !skaterErrors.FirstSeen.Equal(start.Add(30*time.Second)) || !skaterErrors.LastSeen.Equal(start.Add(170*time.Second)) {
+		t.Errorf("expected the errors to span 30s to 170s, got %s to %s", skaterErrors.FirstSeen, skaterErrors.LastSeen)
+	}
+	if skaterErrors.Example != "unexpected status code: 503" {
+		t.Errorf("expected an example message, got %q", skaterErrors.Example)
+	}
+	if viewerErrors := report.Errors[1]; viewerErrors.Source != "viewer" || viewerErrors.Kind != metrics.ErrorKindMalformedBatch {
+		t.Errorf("expected a viewer malformed_batch error, got %+v", viewerErrors)
+	}
+}
+
+func TestAnalyzer_Empty(t *testing.T) {
+	filename := writeFile(t, metrics.FormatCSV, metrics.SkaterSchema, nil)
+	report := analyse(t, time.Minute, map[string]*metrics.Schema{filename: metrics.SkaterSchema})
+
+	if report.Skaters == nil || report.Skaters.Requests != 0 || len(report.Skaters.Intervals) != 0 {
+		t.Errorf("expected an empty skater report, got %+v", report.Skaters)
+	}
+}
+
+func TestAnalyzer_KeepSamples(t *testing.T) {
+	filename := writeFile(t, metrics.FormatJSONL, metrics.SkaterSchema, skaterEvents())
+
+	for _, keep := range []bool{false, true} {
+		var opts []Option
+		if keep {
+			opts = append(opts, KeepSamples())
+		}
+		a := New(time.Minute, opts...)
+		r, err := metrics.Open(filename, metrics.SkaterSchema)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		if err := a.Add(r); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		r.Close()
+
+		samples := a.Samples()
+		if !keep {
+			if samples != nil {
+				t.Errorf("expected no samples unless kept, got %+v", samples)
+			}
+			continue
+		}
+		if samples == nil || len(samples.ResponseTime) != 6 || samples.ResponseTime[0] != 10 {
+			t.Errorf("expected the 6 response times, got %+v", samples)
+		}
+		if run := a.Run("run"); run.Name != "run" || run.Samples != samples || run.Report.Skaters.Requests != 6 {
+			t.Errorf("unexpected run %+v", run)
+		}
+	}
+}
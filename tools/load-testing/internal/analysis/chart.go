@@ -0,0 +1,253 @@
+package analysis
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+	"time"
+)
+
+// Size of a chart and the margins around its plot, in pixels.
+const (
+	chartWidth   = 720
+	chartHeight  = 240
+	marginLeft   = 56
+	marginRight  = 16
+	marginTop    = 32
+	marginBottom = 36
+	plotWidth    = chartWidth - marginLeft - marginRight
+	plotHeight   = chartHeight - marginTop - marginBottom
+	chartTicks   = 5
+)
+
+// Colours of chart series.
+const (
+	colorP50    = "#1f77b4"
+	colorP90    = "#ff7f0e"
+	colorP99    = "#d62728"
+	colorMax    = "#7f7f7f"
+	colorSkater = "#2ca02c"
+	colorViewer = "#9467bd"
+)
+
+// chart is an SVG chart of an HTML report.
+type chart interface {
+	svg() string
+}
+
+type point struct {
+	t time.Time
+	v float64
+}
+
+// line is a series of a timeChart.
+type line struct {
+	name   string
+	color  string
+	dashed bool
+	points []point
+}
+
+// band shades the area between two series of a timeChart, which have a
+// point at the same times.
+type band struct {
+	name      string
+	color     string
+	low, high []point
+}
+
+// timeChart plots series against the time elapsed since start.
+type timeChart struct {
+	title string
+	start time.Time
+	bands []band
+	lines []line
+}
+
+func (c timeChart) svg() string {
+	var from, to time.Time
+	top := 0.0
+	extend := func(points []point) {
+		for _, p := range points {
+			if from.IsZero() || p.t.Before(from) {
+				from = p.t
+			}
+			if p.t.After(to) {
+				to = p.t
+			}
+			top = math.Max(top, p.v)
+		}
+	}
+	for _, b := range c.bands {
+		extend(b.low)
+		extend(b.high)
+	}
+	for _, l := range c.lines {
+		extend(l.points)
+	}
+	if from.IsZero() {
+		return ""
+	}
+
+	top, step := niceScale(top)
+	x := func(t time.Time) float64 {
+		if !to.After(from) {
+			return marginLeft + plotWidth/2
+		}
+		return marginLeft + plotWidth*t.Sub(from).Seconds()/to.Sub(from).Seconds()
+	}
+	y := func(v float64) float64 {
+		return marginTop + plotHeight - plotHeight*v/top
+	}
+
+	var legend []legendItem
+	var b strings.Builder
+	writeYAxis(&b, top, step, y)
+	for i := 0; i <= chartTicks; i++ {
+		t := from.Add(time.Duration(float64(to.Sub(from)) * float64(i) / chartTicks))
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle" class="tick">%s</text>`+"\n",
+			x(t), chartHeight-marginBottom+16, formatMarkdown(elapsed(t.Sub(c.start))))
+		if !to.After(from) {
+			break
+		}
+	}
+
+	for _, bd := range c.bands {
+		var coords []string
+		for _, p := range bd.high {
+			coords = append(coords, fmt.Sprintf("%.1f,%.1f", x(p.t), y(p.v)))
+		}
+		for i := len(bd.low) - 1; i >= 0; i-- {
+			coords = append(coords, fmt.Sprintf("%.1f,%.1f", x(bd.low[i].t), y(bd.low[i].v)))
+		}
+		fmt.Fprintf(&b, `<polygon points="%s" fill="%s" fill-opacity="0.25" stroke="none"/>`+"\n", strings.Join(coords, " "), bd.color)
+		legend = append(legend, legendItem{bd.name, bd.color, true})
+	}
+	for _, l := range c.lines {
+		dash := ""
+		if l.dashed {
+			dash = ` stroke-dasharray="4 3"`
+		}
+		if len(l.points) == 1 {
+			p := l.points[0]
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"/>`+"\n", x(p.t), y(p.v), l.color)
+		} else if len(l.points) > 1 {
+			coords := make([]string, len(l.points))
+			for i, p := range l.points {
+				coords[i] = fmt.Sprintf("%.1f,%.1f", x(p.t), y(p.v))
+			}
+			fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5"%s/>`+"\n", strings.Join(coords, " "), l.color, dash)
+		}
+		legend = append(legend, legendItem{l.name, l.color, false})
+	}
+
+	return wrapSVG(c.title, "elapsed", legend, b.String())
+}
+
+// barChart plots a value for each of a set of labelled bars.
+type barChart struct {
+	title  string
+	xLabel string
+	color  string
+	labels []string
+	values []float64
+}
+
+func (c barChart) svg() string {
+	if len(c.values) == 0 {
+		return ""
+	}
+
+	top := 0.0
+	for _, v := range c.values {
+		top = math.Max(top, v)
+	}
+	top, step := niceScale(top)
+	y := func(v float64) float64 {
+		return marginTop + plotHeight - plotHeight*v/top
+	}
+
+	var b strings.Builder
+	writeYAxis(&b, top, step, y)
+	width := float64(plotWidth) / float64(len(c.values))
+	// Label at most about ten bars so the labels do not overlap.
+	every := (len(c.values) + 9) / 10
+	for i, v := range c.values {
+		left := marginLeft + width*float64(i)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %s</title></rect>`+"\n",
+			left+1, y(v), math.Max(width-2, 1), marginTop+plotHeight-y(v), c.color, html.EscapeString(c.labels[i]), formatNumber(v))
+		if i%every == 0 {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle" class="tick">%s</text>`+"\n",
+				left+width/2, chartHeight-marginBottom+16, html.EscapeString(c.labels[i]))
+		}
+	}
+
+	return wrapSVG(c.title, c.xLabel, nil, b.String())
+}
+
+type legendItem struct {
+	name  string
+	color string
+	area  bool
+}
+
+// writeYAxis writes the horizontal grid lines and labels of a y axis from 0
+// to top.
+func writeYAxis(b *strings.Builder, top, step float64, y func(float64) float64) {
+	for v := 0.0; v <= top+step/2; v += step {
+		fmt.Fprintf(b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" class="grid"/>`+"\n", marginLeft, chartWidth-marginRight, y(v), y(v))
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" text-anchor="end" class="tick">%s</text>`+"\n", marginLeft-6, y(v)+4, formatNumber(v))
+	}
+}
+
+// wrapSVG adds the title, legend and x axis label around the body of a
+// chart.
+func wrapSVG(title, xLabel string, legend []legendItem, body string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" role="img" aria-label="%s">`+"\n",
+		chartWidth, chartHeight, html.EscapeString(title))
+	fmt.Fprintf(&b, `<text x="%d" y="18" class="title">%s</text>`+"\n", marginLeft, html.EscapeString(title))
+
+	// Legend items are laid out from the right, allowing about 7 pixels a
+	// character.
+	right := float64(chartWidth - marginRight)
+	for i := len(legend) - 1; i >= 0; i-- {
+		item := legend[i]
+		right -= float64(len(item.name))*7 + 24
+		swatch := fmt.Sprintf(`<line x1="%.1f" x2="%.1f" y1="14" y2="14" stroke="%s" stroke-width="2"/>`, right, right+14, item.color)
+		if item.area {
+			swatch = fmt.Sprintf(`<rect x="%.1f" y="9" width="14" height="10" fill="%s" fill-opacity="0.25"/>`, right, item.color)
+		}
+		fmt.Fprintf(&b, "%s<text x=\"%.1f\" y=\"18\" class=\"tick\">%s</text>\n", swatch, right+18, html.EscapeString(item.name))
+	}
+
+	b.WriteString(body)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" class="tick">%s</text>`+"\n",
+		marginLeft+plotWidth/2, chartHeight-4, html.EscapeString(xLabel))
+	b.WriteString("</svg>")
+	return b.String()
+}
+
+// niceScale rounds max up to a round number for the top of an axis, and
+// returns it with the step between its ticks.
+func niceScale(max float64) (top, step float64) {
+	if max <= 0 {
+		return 1, 1.0 / chartTicks
+	}
+
+	raw := max / chartTicks
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	step = 10 * magnitude
+	for _, m := range []float64{1, 2, 5} {
+		if m*magnitude >= raw {
+			step = m * magnitude
+			break
+		}
+	}
+	return math.Ceil(max/step) * step, step
+}
+
+func formatNumber(v float64) string {
+	return fmt.Sprintf("%.4g", v)
+}
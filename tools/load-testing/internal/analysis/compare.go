@@ -0,0 +1,390 @@
+package analysis
+
+import (
+	"load-testing/internal/metrics"
+)
+
+// Defaults of a CompareConfig.
+const (
+	DefaultTolerance          = 0.1
+	DefaultErrorRateTolerance = 0.01
+	DefaultSignificance       = 0.05
+)
+
+// Verdicts of a compared metric.
+const (
+	// VerdictUnchanged is a change within tolerance.
+	VerdictUnchanged = "unchanged"
+	// VerdictNotSignificant is a change beyond tolerance that the
+	// statistical test cannot tell from noise.
+	VerdictNotSignificant = "not significant"
+	VerdictRegression     = "regression"
+	VerdictImprovement    = "improvement"
+)
+
+// Run is one of the runs compared by Compare: the analysis of its metrics
+// files, or the reports of its summaries. Samples is nil if the run's
+// response times and latencies are not known individually.
+type Run struct {
+	Name    string
+	Report  Report
+	Samples *Samples
+}
+
+// Run returns the events added so far as a run to compare. Its latencies can
+// only be tested for significance if the Analyzer keeps samples.
+func (a *Analyzer) Run(name string) Run {
+	return Run{Name: name, Report: a.Report(), Samples: a.samples}
+}
+
+// SummaryRun returns a run to compare from the end-of-run summaries written
+// by the simulators. Either report may be nil. A summary holds percentiles
+// rather than individual latencies, so only its error rates can be tested
+// for significance.
+func SummaryRun(name string, skaters *metrics.SkaterReport, viewers *metrics.ViewerReport) Run {
+	run := Run{Name: name, Report: Report{Errors: []ErrorKindCount{}}}
+	if s := skaters; s != nil {
+		run.Report.Skaters = &SkaterReport{
+			StartTime:         s.StartTime,
+			EndTime:           s.EndTime,
+			Requests:          s.Requests,
+			Errors:            s.Errors,
+			ErrorRate:         s.ErrorRate,
+			RequestsPerSecond: s.RequestsPerSecond,
+			ResponseTime:      s.ResponseTime,
+		}
+	}
+	if v := viewers; v != nil {
+		run.Report.Viewers = &ViewerReport{
+			StartTime:         v.StartTime,
+			EndTime:           v.EndTime,
+			Viewers:           v.Viewers,
+			Messages:          v.Messages,
+			Locations:         v.Locations,
+			Errors:            v.Errors,
+			ErrorRate:         fraction(v.Errors, v.Messages+v.Errors),
+			MessagesPerSecond: v.MessagesPerSecond,
+			MessagesPerViewer: v.MessagesPerViewer,
+			Latency:           v.Latency,
+			EndToEnd:          v.EndToEnd,
+		}
+	}
+	return run
+}
+
+// CompareConfig sets when a difference between runs counts as a regression
+// or an improvement.
+type CompareConfig struct {
+	// Tolerance is the relative change in throughput or latency, e.g. 0.1
+	// for 10%, up to which a metric is unchanged.
+	Tolerance float64
+	// ErrorRateTolerance is the change in an error rate, e.g. 0.01 for one
+	// percentage point, up to which it is unchanged.
+	ErrorRateTolerance float64
+	// Significance is the p-value below which a change is significant.
+	Significance float64
+}
+
+// DefaultCompareConfig returns a CompareConfig with the default tolerances
+// and significance level.
+func DefaultCompareConfig() CompareConfig {
+	return CompareConfig{
+		Tolerance:          DefaultTolerance,
+		ErrorRateTolerance: DefaultErrorRateTolerance,
+		Significance:       DefaultSignificance,
+	}
+}
+
+// Comparison compares runs with a baseline, metric by metric.
+type Comparison struct {
+	Baseline           string          `json:"baseline"`
+	Tolerance          float64         `json:"tolerance"`
+	ErrorRateTolerance float64         `json:"error_rate_tolerance"`
+	Significance       float64         `json:"significance"`
+	Runs               []RunComparison `json:"runs"`
+	Regressions        int             `json:"regressions"`
+}
+
+// RunComparison compares one run with the baseline. Metrics only holds the
+// metrics both runs have, so a run without viewers has no viewer metrics.
+type RunComparison struct {
+	Run         string             `json:"run"`
+	Metrics     []MetricComparison `json:"metrics"`
+	Regressions int                `json:"regressions"`
+}
+
+// MetricComparison compares a metric of a run with the baseline's.
+// Difference is Value less Baseline and Change the relative difference,
+// missing if Baseline is zero. PValue is that of a Mann-Whitney U test for
+// latencies, or a two-proportion z-test for error rates, and is missing if
+// the runs do not have what the test needs.
+type MetricComparison struct {
+	Source     string   `json:"source"`
+	Metric     string   `json:"metric"`
+	Baseline   float64  `json:"baseline"`
+	Value      float64  `json:"value"`
+	Difference float64  `json:"difference"`
+	Change     *float64 `json:"change,omitempty"`
+	PValue     *float64 `json:"p_value,omitempty"`
+	Verdict    string   `json:"verdict"`
+}
+
+// metricKind says which way a metric is better and how its tolerance
+// applies.
+type metricKind int
+
+const (
+	// throughputMetric is better higher, within a relative tolerance.
+	throughputMetric metricKind = iota
+	// latencyMetric is better lower, within a relative tolerance.
+	latencyMetric
+	// errorRateMetric is better lower, within an absolute tolerance.
+	errorRateMetric
+)
+
+// comparedMetric is a metric lined up between runs. value reports false if
+// the run does not have the metric.
+type comparedMetric struct {
+	source  string
+	name    string
+	title   string
+	kind    metricKind
+	value   func(Report) (float64, bool)
+	samples func(*Samples) []float64
+	counts  func(Report) (failed, total int)
+}
+
+var comparedMetrics = func() []comparedMetric {
+	skaters := func(f func(*SkaterReport) float64) func(Report) (float64, bool) {
+		return func(r Report) (float64, bool) {
+			if r.Skaters == nil {
+				return 0, false
+			}
+			return f(r.Skaters), true
+		}
+	}
+	viewers := func(f func(*ViewerReport) float64) func(Report) (float64, bool) {
+		return func(r Report) (float64, bool) {
+			if r.Viewers == nil {
+				return 0, false
+			}
+			return f(r.Viewers), true
+		}
+	}
+	latencies := func(source, name, title string, stats func(Report) *metrics.LatencyStats, samples func(*Samples) []float64) []comparedMetric {
+		value := func(f func(metrics.LatencyStats) float64) func(Report) (float64, bool) {
+			return func(r Report) (float64, bool) {
+				s := stats(r)
+				if s == nil {
+					return 0, false
+				}
+				return f(*s), true
+			}
+		}
+		return []comparedMetric{
+			{source, name + "_mean_ms", title + " mean (ms)", latencyMetric, value(func(l metrics.LatencyStats) float64 { return l.Mean }), samples, nil},
+			{source, name + "_p50_ms", title + " p50 (ms)", latencyMetric, value(func(l metrics.LatencyStats) float64 { return l.P50 }), samples, nil},
+			{source, name + "_p90_ms", title + " p90 (ms)", latencyMetric, value(func(l metrics.LatencyStats) float64 { return l.P90 }), samples, nil},
+			{source, name + "_p99_ms", title + " p99 (ms)", latencyMetric, value(func(l metrics.LatencyStats) float64 { return l.P99 }), samples, nil},
+		}
+	}
+
+	skater, viewer := metrics.SkaterSchema.Name, metrics.ViewerSchema.Name
+	list := []comparedMetric{
+		{
+			source: skater, name: "requests_per_second", title: "Requests/s", kind: throughputMetric,
+			value: skaters(func(s *SkaterReport) float64 { return s.RequestsPerSecond }),
+		},
+		{
+			source: skater, name: "error_rate", title: "Error rate", kind: errorRateMetric,
+			value:  skaters(func(s *SkaterReport) float64 { return s.ErrorRate }),
+			counts: func(r Report) (int, int) { return r.Skaters.Errors, r.Skaters.Requests },
+		},
+	}
+	list = append(list, latencies(skater, "response_time", "Response time", func(r Report) *metrics.LatencyStats {
+		if r.Skaters == nil {
+			return nil
+		}
+		return &r.Skaters.ResponseTime
+	}, func(s *Samples) []float64 { return s.ResponseTime })...)
+	list = append(list,
+		comparedMetric{
+			source: viewer, name: "messages_per_second", title: "Messages/s", kind: throughputMetric,
+			value: viewers(func(v *ViewerReport) float64 { return v.MessagesPerSecond }),
+		},
+		comparedMetric{
+			source: viewer, name: "error_rate", title: "Error rate", kind: errorRateMetric,
+			value:  viewers(func(v *ViewerReport) float64 { return v.ErrorRate }),
+			counts: func(r Report) (int, int) { return r.Viewers.Errors, r.Viewers.Messages + r.Viewers.Errors },
+		},
+	)
+	list = append(list, latencies(viewer, "latency", "Latency", func(r Report) *metrics.LatencyStats {
+		if r.Viewers == nil {
+			return nil
+		}
+		return &r.Viewers.Latency
+	}, func(s *Samples) []float64 { return s.Latency })...)
+	list = append(list, latencies(viewer, "end_to_end", "End-to-end", func(r Report) *metrics.LatencyStats {
+		if r.Viewers == nil {
+			return nil
+		}
+		return &r.Viewers.EndToEnd
+	}, func(s *Samples) []float64 { return s.EndToEnd })...)
+	return list
+}()
+
+// Compare lines up the throughput, error rates and latency percentiles of
+// each run with those of baseline. A metric regressed if it got worse by
+// more than the tolerance and, where the runs allow a statistical test, the
+// test finds the change significant and in the same direction. Latencies are
+// tested as a whole, so a shift in a tail percentile alone is not
+// significant.
+func Compare(baseline Run, runs []Run, config CompareConfig) Comparison {
+	c := Comparison{
+		Baseline:           baseline.Name,
+		Tolerance:          config.Tolerance,
+		ErrorRateTolerance: config.ErrorRateTolerance,
+		Significance:       config.Significance,
+		Runs:               []RunComparison{},
+	}
+
+	for _, run := range runs {
+		rc := RunComparison{Run: run.Name, Metrics: []MetricComparison{}}
+
+		// Each latency distribution is tested once for all its metrics.
+		tests := make(map[string]distributionTest)
+		for _, m := range comparedMetrics {
+			base, ok := m.value(baseline.Report)
+			if !ok {
+				continue
+			}
+			value, ok := m.value(run.Report)
+			if !ok {
+				continue
+			}
+
+			mc := MetricComparison{
+				Source:     m.source,
+				Metric:     m.name,
+				Baseline:   base,
+				Value:      value,
+				Difference: value - base,
+			}
+			if base != 0 {
+				change := (value - base) / base
+				mc.Change = &change
+			}
+
+			// shift is positive if the run's values are significantly larger,
+			// negative if significantly smaller, and zero otherwise.
+			shift := 0
+			tested := false
+			switch {
+			case m.samples != nil && baseline.Samples != nil && run.Samples != nil:
+				key := m.source + " " + m.title
+				test, ok := tests[key]
+				if !ok {
+					test = newDistributionTest(m.samples(baseline.Samples), m.samples(run.Samples))
+					tests[key] = test
+				}
+				if test.tested {
+					mc.PValue = &test.p
+					tested = true
+					if test.p < config.Significance {
+						shift = test.shift
+					}
+				}
+			case m.counts != nil:
+				failed1, total1 := m.counts(baseline.Report)
+				failed2, total2 := m.counts(run.Report)
+				if total1 > 0 && total2 > 0 {
+					p := twoProportions(failed1, total1, failed2, total2)
+					mc.PValue = &p
+					tested = true
+					if p < config.Significance {
+						shift = sign(mc.Difference)
+					}
+				}
+			}
+
+			mc.Verdict = verdict(m.kind, mc, config, tested, shift)
+			if mc.Verdict == VerdictRegression {
+				rc.Regressions++
+			}
+			rc.Metrics = append(rc.Metrics, mc)
+		}
+
+		c.Regressions += rc.Regressions
+		c.Runs = append(c.Runs, rc)
+	}
+	return c
+}
+
+// distributionTest is the result of a Mann-Whitney U test of two runs'
+// latencies. shift is 1 if the second run's tend to be larger and -1 if they
+// tend to be smaller.
+type distributionTest struct {
+	tested bool
+	p      float64
+	shift  int
+}
+
+func newDistributionTest(x, y []float64) distributionTest {
+	if len(x) == 0 || len(y) == 0 {
+		return distributionTest{}
+	}
+	p, superiority := mannWhitney(x, y)
+	return distributionTest{tested: true, p: p, shift: sign(superiority - 0.5)}
+}
+
+// verdict judges a metric's change. worse and better are its direction
+// beyond the tolerance; a tested change must also be significant in that
+// direction.
+func verdict(kind metricKind, mc MetricComparison, config CompareConfig, tested bool, shift int) string {
+	var worse, better bool
+	switch kind {
+	case errorRateMetric:
+		worse = mc.Difference > config.ErrorRateTolerance
+		better = mc.Difference < -config.ErrorRateTolerance
+	case latencyMetric, throughputMetric:
+		if mc.Change == nil {
+			// Anything is an infinite change from zero.
+			worse = mc.Value > 0 && kind == latencyMetric
+			better = mc.Value > 0 && kind == throughputMetric
+			break
+		}
+		worse = *mc.Change > config.Tolerance
+		better = *mc.Change < -config.Tolerance
+		if kind == throughputMetric {
+			worse, better = better, worse
+		}
+	}
+
+	// The direction in which values went up is worse for everything but
+	// throughput.
+	up := 1
+	if kind == throughputMetric {
+		up = -1
+	}
+	switch {
+	case !worse && !better:
+		return VerdictUnchanged
+	case tested && (shift == 0 || (worse && shift != up) || (better && shift != -up)):
+		return VerdictNotSignificant
+	case worse:
+		return VerdictRegression
+	default:
+		return VerdictImprovement
+	}
+}
+
+func sign(f float64) int {
+	switch {
+	case f > 0:
+		return 1
+	case f < 0:
+		return -1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,220 @@
+package analysis
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"load-testing/internal/metrics"
+)
+
+func TestMannWhitney(t *testing.T) {
+	tests := []struct {
+		name            string
+		x, y            []float64
+		wantP           float64
+		wantSuperiority float64
+	}{
+		{"separated", []float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}, 0.01219, 1},
+		{"reversed", []float64{6, 7, 8, 9, 10}, []float64{1, 2, 3, 4, 5}, 0.01219, 0},
+		{"identical", []float64{5, 5, 5}, []float64{5, 5}, 1, 0.5},
+		{"empty", nil, []float64{1}, 1, 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, superiority := mannWhitney(tt.x, tt.y)
+			if math.Abs(p-tt.wantP) > 0.0001 {
+				t.Errorf("expected p = %v, got %v", tt.wantP, p)
+			}
+			if superiority != tt.wantSuperiority {
+				t.Errorf("expected superiority %v, got %v", tt.wantSuperiority, superiority)
+			}
+		})
+	}
+}
+
+func TestTwoProportions(t *testing.T) {
+	tests := []struct {
+		name                             string
+		failed1, total1, failed2, total2 int
+		want                             float64
+	}{
+		{"different", 10, 100, 20, 100, 0.0477},
+		{"same", 10, 100, 10, 100, 1},
+		{"no failures", 0, 100, 0, 50, 1},
+		{"no results", 0, 0, 5, 10, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := twoProportions(tt.failed1, tt.total1, tt.failed2, tt.total2); math.Abs(got-tt.want) > 0.0001 {
+				t.Errorf("expected p = %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// latencyRun returns a run of skaters whose response times are the given
+// samples, with errors failed requests out of len(samples).
+func latencyRun(name string, requestsPerSecond float64, errors int, samples []float64) Run {
+	h := metrics.NewHistogram()
+	for _, ms := range samples {
+		h.Record(duration(ms))
+	}
+	return Run{
+		Name: name,
+		Report: Report{Skaters: &SkaterReport{
+			Requests:          len(samples),
+			Errors:            errors,
+			ErrorRate:         fraction(errors, len(samples)),
+			RequestsPerSecond: requestsPerSecond,
+			ResponseTime:      h.Stats(),
+		}},
+		Samples: &Samples{ResponseTime: samples},
+	}
+}
+
+func spread(from, to float64, n int) []float64 {
+	samples := make([]float64, n)
+	for i := range samples {
+		samples[i] = from + (to-from)*float64(i)/float64(n)
+	}
+	return samples
+}
+
+func verdicts(rc RunComparison) map[string]string {
+	got := make(map[string]string)
+	for _, mc := range rc.Metrics {
+		got[mc.Source+" "+mc.Metric] = mc.Verdict
+	}
+	return got
+}
+
+func TestCompare(t *testing.T) {
+	baseline := latencyRun("baseline", 10, 10, spread(10, 20, 1000))
+	tests := []struct {
+		name            string
+		run             Run
+		want            map[string]string
+		wantRegressions int
+	}{
+		{
+			name: "same",
+			run:  latencyRun("same", 10, 10, spread(10, 20, 1000)),
+			want: map[string]string{
+				"skater requests_per_second":  VerdictUnchanged,
+				"skater error_rate":           VerdictUnchanged,
+				"skater response_time_p99_ms": VerdictUnchanged,
+			},
+		},
+		{
+			name: "slower",
+			run:  latencyRun("slower", 10, 10, spread(15, 30, 1000)),
+			want: map[string]string{
+				"skater response_time_mean_ms": VerdictRegression,
+				"skater response_time_p50_ms":  VerdictRegression,
+				"skater response_time_p99_ms":  VerdictRegression,
+			},
+			wantRegressions: 4,
+		},
+		{
+			name: "faster with more errors and less throughput",
+			run:  latencyRun("faster", 5, 100, spread(5, 10, 1000)),
+			want: map[string]string{
+				"skater requests_per_second":  VerdictRegression,
+				"skater error_rate":           VerdictRegression,
+				"skater response_time_p90_ms": VerdictImprovement,
+			},
+			wantRegressions: 2,
+		},
+		{
+			// Too few samples to tell whether the latencies changed.
+			name: "noisy",
+			run:  latencyRun("noisy", 10, 0, []float64{12, 19, 25}),
+			want: map[string]string{
+				"skater response_time_p99_ms": VerdictNotSignificant,
+			},
+		},
+		{
+			// Summaries have no samples, so changes are not tested.
+			name: "summary",
+			run: SummaryRun("summary", &metrics.SkaterReport{
+				Requests:          1000,
+				Errors:            10,
+				ErrorRate:         0.01,
+				RequestsPerSecond: 10,
+				ResponseTime:      metrics.LatencyStats{Mean: 15, P50: 15, P90: 19, P99: 40},
+			}, nil),
+			want: map[string]string{
+				"skater response_time_p50_ms": VerdictUnchanged,
+				"skater response_time_p99_ms": VerdictRegression,
+			},
+			wantRegressions: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Compare(baseline, []Run{tt.run}, DefaultCompareConfig())
+
+			if len(c.Runs) != 1 {
+				t.Fatalf("expected 1 run comparison, got %d", len(c.Runs))
+			}
+			got := verdicts(c.Runs[0])
+			for metric, want := range tt.want {
+				if got[metric] != want {
+					t.Errorf("%s: expected %q, got %q", metric, want, got[metric])
+				}
+			}
+			if c.Regressions != tt.wantRegressions || c.Runs[0].Regressions != tt.wantRegressions {
+				t.Errorf("expected %d regressions, got %d (%+v)", tt.wantRegressions, c.Regressions, c.Runs[0].Metrics)
+			}
+		})
+	}
+}
+
+func TestCompare_LinesUpMetrics(t *testing.T) {
+	baseline := SummaryRun("baseline", &metrics.SkaterReport{Requests: 100}, &metrics.ViewerReport{Messages: 100, MessagesPerSecond: 2})
+	run := SummaryRun("run", nil, &metrics.ViewerReport{Messages: 100, MessagesPerSecond: 1})
+
+	c := Compare(baseline, []Run{run}, DefaultCompareConfig())
+
+	for _, mc := range c.Runs[0].Metrics {
+		if mc.Source != metrics.ViewerSchema.Name {
+			t.Errorf("expected only viewer metrics, got %s %s", mc.Source, mc.Metric)
+		}
+	}
+	if got := verdicts(c.Runs[0])["viewer messages_per_second"]; got != VerdictRegression {
+		t.Errorf("expected halved throughput to regress, got %q", got)
+	}
+	if got := verdicts(c.Runs[0])["viewer latency_p50_ms"]; got != VerdictUnchanged {
+		t.Errorf("expected zero latencies to be unchanged, got %q", got)
+	}
+}
+
+func TestComparison_WriteMarkdown(t *testing.T) {
+	baseline := latencyRun("baseline", 10, 10, spread(10, 20, 1000))
+	c := Compare(baseline, []Run{latencyRun("slower", 10, 10, spread(15, 30, 1000))}, DefaultCompareConfig())
+
+	var buf bytes.Buffer
+	if err := c.WriteMarkdown(&buf); err != nil {
+		t.Fatalf("WriteMarkdown() error = %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"Baseline: baseline. A change beyond 10% in throughput or latency, or 1.00 pp in an error rate",
+		"**4 regressions.**\n",
+		"## slower\n\n### Compared with baseline\n",
+		"| Source | Metric | baseline | slower | Change | p-value | Verdict |\n",
+		"| skater | Requests/s | 10.00 | 10.00 | +0.00% |  | unchanged |\n",
+		"| skater | Error rate | 1.00% | 1.00% | +0.00 pp | 1.0000 | unchanged |\n",
+		"| skater | Response time mean (ms) | 14.99 | 22.49 | +50.01% | <0.0001 | regression |\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
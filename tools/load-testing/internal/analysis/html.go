@@ -0,0 +1,334 @@
+package analysis
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"load-testing/internal/metrics"
+)
+
+// DefaultTitle is the title of an HTML report without one of its own.
+const DefaultTitle = "Load test report"
+
+// maxHistogramBars is the most bars of the messages per viewer chart.
+const maxHistogramBars = 20
+
+// Page holds what an HTML report shows besides the analysis.
+type Page struct {
+	Title string
+	// Parameters describe the run, e.g. the simulator options, and are
+	// listed after those worked out from the metrics files.
+	Parameters []Parameter
+}
+
+// Parameter is a row of the run parameters table of an HTML report.
+type Parameter struct {
+	Name  string
+	Value string
+}
+
+type htmlPage struct {
+	Title      string
+	Parameters []Parameter
+	Sections   []htmlSection
+}
+
+type htmlSection struct {
+	Title  string
+	Charts []template.HTML
+	Tables []htmlTable
+}
+
+type htmlTable struct {
+	Title   string
+	Open    bool
+	Columns []string
+	Rows    [][]htmlCell
+}
+
+type htmlCell struct {
+	Text    string
+	Numeric bool
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 1100px; padding: 0 1em; color: #222; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.3em; border-bottom: 1px solid #ddd; padding-bottom: 0.2em; margin-top: 2em; }
+table { border-collapse: collapse; margin: 0.5em 0 1em; font-size: 0.85em; }
+th, td { border: 1px solid #ddd; padding: 0.25em 0.6em; text-align: left; }
+th { background: #f5f5f5; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+summary { cursor: pointer; font-weight: 600; margin: 0.5em 0; }
+.charts { display: grid; grid-template-columns: repeat(auto-fit, minmax(480px, 1fr)); gap: 1em; }
+svg { width: 100%; height: auto; font-size: 11px; }
+svg .title { font-size: 13px; font-weight: 600; fill: #222; }
+svg .tick { fill: #555; }
+svg .grid { stroke: #eee; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<h2>Run parameters</h2>
+<table>
+{{- range .Parameters}}
+<tr><th>{{.Name}}</th><td>{{.Value}}</td></tr>
+{{- end}}
+</table>
+{{- range .Sections}}
+<h2>{{.Title}}</h2>
+{{- if .Charts}}
+<div class="charts">
+{{- range .Charts}}
+<div>{{.}}</div>
+{{- end}}
+</div>
+{{- end}}
+{{- range .Tables}}
+<details{{if .Open}} open{{end}}>
+<summary>{{.Title}}</summary>
+{{- if .Rows}}
+<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Rows}}
+<tr>{{range .}}<td{{if .Numeric}} class="num"{{end}}>{{.Text}}</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- else}}
+<p>None.</p>
+{{- end}}
+</details>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes the report as a single HTML page, with the charts drawn
+// as inline SVG and no scripts or external assets, so it can be viewed
+// offline and attached to a CI run. Times are in UTC.
+func (r Report) WriteHTML(w io.Writer, page Page) error {
+	data := htmlPage{Title: page.Title, Parameters: append(r.parameters(), page.Parameters...)}
+	if data.Title == "" {
+		data.Title = DefaultTitle
+	}
+
+	for _, s := range r.sections() {
+		hs := htmlSection{Title: s.title}
+		for _, c := range s.charts {
+			if svg := c.svg(); svg != "" {
+				hs.Charts = append(hs.Charts, template.HTML(svg))
+			}
+		}
+		for i, t := range s.tables {
+			ht := htmlTable{Title: t.title, Open: i == 0}
+			for _, c := range t.columns {
+				ht.Columns = append(ht.Columns, c.title)
+			}
+			for _, row := range t.rows {
+				cells := make([]htmlCell, len(row))
+				for j, v := range row {
+					cells[j] = formatHTML(v)
+				}
+				ht.Rows = append(ht.Rows, cells)
+			}
+			hs.Tables = append(hs.Tables, ht)
+		}
+		data.Sections = append(data.Sections, hs)
+	}
+
+	if err := htmlTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to write HTML report: %w", err)
+	}
+	return nil
+}
+
+func formatHTML(v any) htmlCell {
+	switch v := v.(type) {
+	case string:
+		return htmlCell{Text: v}
+	case time.Time:
+		return htmlCell{Text: formatMarkdown(v)}
+	default:
+		return htmlCell{Text: formatMarkdown(v), Numeric: true}
+	}
+}
+
+// parameters returns the run parameters that can be worked out from the
+// metrics files.
+func (r Report) parameters() []Parameter {
+	var span timeRange
+	events := make(map[string]bool)
+	if s := r.Skaters; s != nil {
+		if !s.StartTime.IsZero() {
+			span.add(s.StartTime)
+			span.add(s.EndTime)
+		}
+		for _, e := range s.Events {
+			events[e.EventID] = true
+		}
+	}
+	if v := r.Viewers; v != nil {
+		if !v.StartTime.IsZero() {
+			span.add(v.StartTime)
+			span.add(v.EndTime)
+		}
+		for _, e := range v.Events {
+			events[e.EventID] = true
+		}
+	}
+
+	params := []Parameter{
+		{"Start", formatMarkdown(span.start)},
+		{"End", formatMarkdown(span.end)},
+		{"Duration", span.end.Sub(span.start).Round(time.Second).String()},
+		{"Interval", time.Duration(r.IntervalSeconds * float64(time.Second)).String()},
+		{"Events", fmt.Sprint(len(events))},
+	}
+	if s := r.Skaters; s != nil {
+		params = append(params, Parameter{"Skaters", fmt.Sprint(len(s.Skaters))})
+		if s.RequestsPerSecond > 0 {
+			interval := time.Duration(float64(len(s.Skaters)) / s.RequestsPerSecond * float64(time.Second))
+			params = append(params, Parameter{"Mean update interval", interval.Round(time.Millisecond).String()})
+		}
+	}
+	if v := r.Viewers; v != nil {
+		params = append(params, Parameter{"Viewers", fmt.Sprint(v.Viewers)})
+	}
+	return params
+}
+
+// latencyChart plots percentile bands of a latency over time: the p50 to
+// p90 and p90 to p99 ranges, the median and the maximum.
+func latencyChart(title string, start time.Time, times []time.Time, stats []metrics.LatencyStats) chart {
+	var p50, p90, p99, peak []point
+	for i, l := range stats {
+		if l.Count == 0 {
+			continue
+		}
+		p50 = append(p50, point{times[i], l.P50})
+		p90 = append(p90, point{times[i], l.P90})
+		p99 = append(p99, point{times[i], l.P99})
+		peak = append(peak, point{times[i], l.Max})
+	}
+	return timeChart{
+		title: title + " (ms)",
+		start: start,
+		bands: []band{
+			{name: "p90–p99", color: colorP99, low: p90, high: p99},
+			{name: "p50–p90", color: colorP90, low: p50, high: p90},
+		},
+		lines: []line{
+			{name: "p50", color: colorP50, points: p50},
+			{name: "max", color: colorMax, dashed: true, points: peak},
+		},
+	}
+}
+
+func skaterCharts(s *SkaterReport) []chart {
+	if len(s.Intervals) == 0 {
+		return nil
+	}
+	start := s.Intervals[0].Start
+	times := make([]time.Time, len(s.Intervals))
+	stats := make([]metrics.LatencyStats, len(s.Intervals))
+	var requests []point
+	for i, interval := range s.Intervals {
+		times[i] = interval.Start
+		stats[i] = interval.ResponseTime
+		requests = append(requests, point{interval.Start, interval.RequestsPerSecond})
+	}
+	return []chart{
+		latencyChart("Response time", start, times, stats),
+		timeChart{title: "Requests per second", start: start, lines: []line{{name: "requests/s", color: colorSkater, points: requests}}},
+	}
+}
+
+func viewerCharts(v *ViewerReport) []chart {
+	if len(v.Intervals) == 0 {
+		return nil
+	}
+	start := v.Intervals[0].Start
+	times := make([]time.Time, len(v.Intervals))
+	latency := make([]metrics.LatencyStats, len(v.Intervals))
+	endToEnd := make([]metrics.LatencyStats, len(v.Intervals))
+	var messages, perViewer []point
+	for i, interval := range v.Intervals {
+		times[i] = interval.Start
+		latency[i] = interval.Latency
+		endToEnd[i] = interval.EndToEnd
+		messages = append(messages, point{interval.Start, interval.MessagesPerSecond})
+		if interval.Viewers > 0 {
+			perViewer = append(perViewer, point{interval.Start, interval.MessagesPerSecond / float64(interval.Viewers)})
+		}
+	}
+	return []chart{
+		latencyChart("Latency", start, times, latency),
+		latencyChart("End-to-end latency", start, times, endToEnd),
+		timeChart{title: "Messages per second", start: start, lines: []line{
+			{name: "all viewers", color: colorViewer, points: messages},
+			{name: "per viewer", color: colorP50, points: perViewer},
+		}},
+		messagesPerViewerChart(v.PerViewer),
+	}
+}
+
+// messagesPerViewerChart is a histogram of the batches each viewer received.
+func messagesPerViewerChart(viewers []ViewerMessages) chart {
+	c := barChart{title: "Viewers by messages received", xLabel: "messages", color: colorViewer}
+	if len(viewers) == 0 {
+		return c
+	}
+
+	stats := messagesPerViewer(viewers)
+	width := 1
+	if n := stats.Max - stats.Min + 1; n > maxHistogramBars {
+		width = (n + maxHistogramBars - 1) / maxHistogramBars
+	}
+	bars := (stats.Max-stats.Min)/width + 1
+	c.values = make([]float64, bars)
+	for _, v := range viewers {
+		c.values[(v.Messages-stats.Min)/width]++
+	}
+	for i := range bars {
+		low := stats.Min + i*width
+		if width == 1 {
+			c.labels = append(c.labels, fmt.Sprint(low))
+		} else {
+			c.labels = append(c.labels, fmt.Sprintf("%d–%d", low, low+width-1))
+		}
+	}
+	return c
+}
+
+// errorCharts plots the skater and viewer errors of each interval on one
+// timeline.
+func errorCharts(r Report) []chart {
+	c := timeChart{title: "Errors per interval"}
+	if s := r.Skaters; s != nil && len(s.Intervals) > 0 {
+		c.start = s.Intervals[0].Start
+		var points []point
+		for _, interval := range s.Intervals {
+			points = append(points, point{interval.Start, float64(interval.Errors)})
+		}
+		c.lines = append(c.lines, line{name: "skaters", color: colorSkater, points: points})
+	}
+	if v := r.Viewers; v != nil && len(v.Intervals) > 0 {
+		if c.start.IsZero() || v.Intervals[0].Start.Before(c.start) {
+			c.start = v.Intervals[0].Start
+		}
+		var points []point
+		for _, interval := range v.Intervals {
+			points = append(points, point{interval.Start, float64(interval.Errors)})
+		}
+		c.lines = append(c.lines, line{name: "viewers", color: colorViewer, points: points})
+	}
+	return []chart{c}
+}
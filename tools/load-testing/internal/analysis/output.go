@@ -0,0 +1,495 @@
+package analysis
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"load-testing/internal/metrics"
+)
+
+// Output formats of a Report.
+const (
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+	FormatCSV      = "csv"
+	FormatHTML     = "html"
+)
+
+// Formats lists the output formats, Markdown first as the default.
+var Formats = []string{FormatMarkdown, FormatJSON, FormatCSV, FormatHTML}
+
+// maxExampleLength is the most characters of an example error message shown
+// in a Markdown table.
+const maxExampleLength = 100
+
+// Cell types that are formatted differently for reading and for further
+// processing.
+type (
+	// percent is a fraction, shown as a percentage in Markdown.
+	percent float64
+	// ms is a duration in milliseconds.
+	ms float64
+	// perSecond is a rate.
+	perSecond float64
+	// elapsed is the time since the start of the run, shown as h:mm:ss in
+	// Markdown and in seconds in CSV.
+	elapsed time.Duration
+	// change is a relative change, shown as a signed percentage in Markdown.
+	change float64
+	// points is a difference between fractions, shown in percentage points
+	// in Markdown.
+	points float64
+	// pValue is the p-value of a statistical test.
+	pValue float64
+)
+
+// table is one table of a report, written as a Markdown table or a CSV file.
+type table struct {
+	// title is the table's Markdown heading.
+	title string
+	// file is the name of the table's CSV file.
+	file    string
+	columns []column
+	rows    [][]any
+}
+
+type column struct {
+	title string
+	name  string
+}
+
+var latencyColumns = []column{
+	{"p50 (ms)", "p50_ms"},
+	{"p90 (ms)", "p90_ms"},
+	{"p99 (ms)", "p99_ms"},
+	{"Max (ms)", "max_ms"},
+}
+
+func latencyCells(l metrics.LatencyStats) []any {
+	return []any{ms(l.P50), ms(l.P90), ms(l.P99), ms(l.Max)}
+}
+
+// sections returns the report's tables, under the Markdown heading of each
+// group.
+func (r Report) sections() []section {
+	perInterval := "Per " + formatInterval(time.Duration(r.IntervalSeconds*float64(time.Second)))
+
+	var sections []section
+	if s := r.Skaters; s != nil {
+		sections = append(sections, section{title: "Skaters", charts: skaterCharts(s), tables: skaterTables(s, perInterval)})
+	}
+	if v := r.Viewers; v != nil {
+		sections = append(sections, section{title: "Viewers", charts: viewerCharts(v), tables: viewerTables(v, perInterval)})
+	}
+
+	errors := table{
+		title: "Errors by kind",
+		file:  "errors.csv",
+		columns: []column{
+			{"Source", "source"}, {"Kind", "kind"}, {"Count", "count"}, {"Share", "share"},
+			{"First seen", "first_seen"}, {"Last seen", "last_seen"}, {"Example", "example"},
+		},
+	}
+	for _, e := range r.Errors {
+		errors.rows = append(errors.rows, []any{e.Source, e.Kind, e.Count, percent(e.Share), e.FirstSeen, e.LastSeen, e.Example})
+	}
+	sections = append(sections, section{title: "Errors", charts: errorCharts(r), tables: []table{errors}})
+	return sections
+}
+
+// formatInterval formats a time series interval for a heading, e.g. minute,
+// 5m or 30s.
+func formatInterval(d time.Duration) string {
+	switch {
+	case d == time.Minute:
+		return "minute"
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return d.String()
+	}
+}
+
+// section is a group of tables under a heading, with the charts drawn above
+// them in an HTML report.
+type section struct {
+	title  string
+	charts []chart
+	tables []table
+}
+
+func skaterTables(s *SkaterReport, perInterval string) []table {
+	overall := table{
+		title: "Overall",
+		file:  "skater-overall.csv",
+		columns: append([]column{
+			{"Start", "start_time"}, {"End", "end_time"}, {"Requests", "requests"}, {"Requests/s", "requests_per_second"},
+			{"Errors", "errors"}, {"Error rate", "error_rate"},
+		}, latencyColumns...),
+		rows: [][]any{append([]any{
+			s.StartTime, s.EndTime, s.Requests, perSecond(s.RequestsPerSecond), s.Errors, percent(s.ErrorRate),
+		}, latencyCells(s.ResponseTime)...)},
+	}
+
+	intervals := table{
+		title: perInterval,
+		file:  "skater-intervals.csv",
+		columns: append([]column{
+			{"Elapsed", "elapsed_seconds"}, {"Time", "start"}, {"Requests", "requests"}, {"Requests/s", "requests_per_second"},
+			{"Errors", "errors"}, {"Error rate", "error_rate"},
+		}, latencyColumns...),
+	}
+	for _, i := range s.Intervals {
+		intervals.rows = append(intervals.rows, append([]any{
+			elapsed(i.Start.Sub(s.Intervals[0].Start)), i.Start, i.Requests, perSecond(i.RequestsPerSecond), i.Errors, percent(i.ErrorRate),
+		}, latencyCells(i.ResponseTime)...))
+	}
+
+	breakdownColumns := append([]column{
+		{"Requests", "requests"}, {"Errors", "errors"}, {"Error rate", "error_rate"},
+	}, latencyColumns...)
+	breakdownCells := func(b SkaterBreakdown) []any {
+		return append([]any{b.Requests, b.Errors, percent(b.ErrorRate)}, latencyCells(b.ResponseTime)...)
+	}
+
+	events := table{
+		title:   "Per event",
+		file:    "skater-events.csv",
+		columns: append([]column{{"Event", "event_id"}}, breakdownColumns...),
+	}
+	for _, e := range s.Events {
+		events.rows = append(events.rows, append([]any{e.EventID}, breakdownCells(e)...))
+	}
+
+	skaters := table{
+		title:   "Per skater",
+		file:    "skaters.csv",
+		columns: append([]column{{"Event", "event_id"}, {"Skater", "skater_id"}}, breakdownColumns...),
+	}
+	for _, sk := range s.Skaters {
+		skaters.rows = append(skaters.rows, append([]any{sk.EventID, sk.SkaterID}, breakdownCells(sk)...))
+	}
+
+	return []table{overall, intervals, events, skaters}
+}
+
+func viewerTables(v *ViewerReport, perInterval string) []table {
+	latencyAndEndToEnd := []column{
+		{"Latency p50 (ms)", "latency_p50_ms"},
+		{"Latency p99 (ms)", "latency_p99_ms"},
+		{"End-to-end p50 (ms)", "end_to_end_p50_ms"},
+		{"End-to-end p99 (ms)", "end_to_end_p99_ms"},
+	}
+	latencyCells := func(latency, endToEnd metrics.LatencyStats) []any {
+		return []any{ms(latency.P50), ms(latency.P99), ms(endToEnd.P50), ms(endToEnd.P99)}
+	}
+
+	overall := table{
+		title: "Overall",
+		file:  "viewer-overall.csv",
+		columns: append([]column{
+			{"Start", "start_time"}, {"End", "end_time"}, {"Viewers", "viewers"}, {"Messages", "messages"},
+			{"Messages/s", "messages_per_second"}, {"Locations", "locations"}, {"Errors", "errors"}, {"Error rate", "error_rate"},
+		}, latencyAndEndToEnd...),
+		rows: [][]any{append([]any{
+			v.StartTime, v.EndTime, v.Viewers, v.Messages, perSecond(v.MessagesPerSecond), v.Locations, v.Errors, percent(v.ErrorRate),
+		}, latencyCells(v.Latency, v.EndToEnd)...)},
+	}
+
+	intervals := table{
+		title: perInterval,
+		file:  "viewer-intervals.csv",
+		columns: append([]column{
+			{"Elapsed", "elapsed_seconds"}, {"Time", "start"}, {"Viewers", "viewers"}, {"Messages", "messages"},
+			{"Messages/s", "messages_per_second"}, {"Locations", "locations"}, {"Errors", "errors"}, {"Error rate", "error_rate"},
+		}, latencyAndEndToEnd...),
+	}
+	for _, i := range v.Intervals {
+		intervals.rows = append(intervals.rows, append([]any{
+			elapsed(i.Start.Sub(v.Intervals[0].Start)), i.Start, i.Viewers, i.Messages, perSecond(i.MessagesPerSecond), i.Locations, i.Errors, percent(i.ErrorRate),
+		}, latencyCells(i.Latency, i.EndToEnd)...))
+	}
+
+	events := table{
+		title: "Per event",
+		file:  "viewer-events.csv",
+		columns: append([]column{
+			{"Event", "event_id"}, {"Viewers", "viewers"}, {"Messages", "messages"}, {"Locations", "locations"},
+			{"Errors", "errors"}, {"Error rate", "error_rate"},
+		}, latencyAndEndToEnd...),
+	}
+	for _, e := range v.Events {
+		events.rows = append(events.rows, append([]any{
+			e.EventID, e.Viewers, e.Messages, e.Locations, e.Errors, percent(e.ErrorRate),
+		}, latencyCells(e.Latency, e.EndToEnd)...))
+	}
+
+	viewers := table{
+		title: "Per viewer",
+		file:  "viewers.csv",
+		columns: []column{
+			{"Event", "event_id"}, {"Viewer", "viewer_number"}, {"Messages", "messages"}, {"Locations", "locations"}, {"Errors", "errors"},
+		},
+	}
+	for _, pv := range v.PerViewer {
+		viewers.rows = append(viewers.rows, []any{pv.EventID, pv.ViewerNumber, pv.Messages, pv.Locations, pv.Errors})
+	}
+
+	return []table{overall, intervals, events, viewers}
+}
+
+// WriteMarkdown writes the report as Markdown tables, ready to paste into a
+// results document. Times are in UTC.
+func (r Report) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	writeMarkdownSections(&b, r.sections())
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeMarkdownSections(b *strings.Builder, sections []section) {
+	for i, s := range sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(b, "## %s\n", s.title)
+		for _, t := range s.tables {
+			fmt.Fprintf(b, "\n### %s\n\n", t.title)
+			if len(t.rows) == 0 {
+				b.WriteString("None.\n")
+				continue
+			}
+
+			titles := make([]string, len(t.columns))
+			rule := make([]string, len(t.columns))
+			for j, c := range t.columns {
+				titles[j] = c.title
+				rule[j] = "---"
+			}
+			writeMarkdownRow(b, titles)
+			writeMarkdownRow(b, rule)
+			for _, row := range t.rows {
+				cells := make([]string, len(row))
+				for j, v := range row {
+					cells[j] = formatMarkdown(v)
+				}
+				writeMarkdownRow(b, cells)
+			}
+		}
+	}
+}
+
+func writeMarkdownRow(b *strings.Builder, cells []string) {
+	fmt.Fprintf(b, "| %s |\n", strings.Join(cells, " | "))
+}
+
+func formatMarkdown(v any) string {
+	switch v := v.(type) {
+	case percent:
+		return fmt.Sprintf("%.2f%%", float64(v)*100)
+	case ms:
+		return fmt.Sprintf("%.2f", float64(v))
+	case perSecond:
+		return fmt.Sprintf("%.2f", float64(v))
+	case elapsed:
+		d := time.Duration(v).Round(time.Second)
+		return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	case change:
+		return fmt.Sprintf("%+.2f%%", float64(v)*100)
+	case points:
+		return fmt.Sprintf("%+.2f pp", float64(v)*100)
+	case pValue:
+		if v < 0.0001 {
+			return "<0.0001"
+		}
+		return fmt.Sprintf("%.4f", float64(v))
+	case nil:
+		return ""
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.UTC().Format("2006-01-02 15:04:05")
+	case string:
+		s := strings.Join(strings.Fields(v), " ")
+		if runes := []rune(s); len(runes) > maxExampleLength {
+			s = string(runes[:maxExampleLength-1]) + "…"
+		}
+		return strings.ReplaceAll(s, "|", `\|`)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("failed to encode analysis: %w", err)
+	}
+	return nil
+}
+
+// WriteCSV writes each table of the report to a CSV file of its own in dir,
+// creating dir if needed, and returns the paths of the files written.
+// Durations are in milliseconds, error rates are fractions and times are
+// RFC 3339.
+func (r Report) WriteCSV(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	var paths []string
+	for _, s := range r.sections() {
+		for _, t := range s.tables {
+			path := filepath.Join(dir, t.file)
+			if err := writeCSVFile(path, t); err != nil {
+				return paths, err
+			}
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+func writeCSVFile(path string, t table) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	names := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		names[i] = c.name
+	}
+	if err := writer.Write(names); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	for _, row := range t.rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			record[i] = formatCSV(v)
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+func formatCSV(v any) string {
+	switch v := v.(type) {
+	case percent:
+		return strconv.FormatFloat(float64(v), 'f', -1, 64)
+	case ms:
+		return fmt.Sprintf("%.2f", float64(v))
+	case perSecond:
+		return fmt.Sprintf("%.2f", float64(v))
+	case elapsed:
+		return strconv.FormatFloat(time.Duration(v).Seconds(), 'f', -1, 64)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// sections returns a table for each run compared with the baseline.
+func (c Comparison) sections() []section {
+	var sections []section
+	for _, rc := range c.Runs {
+		t := table{
+			title: "Compared with " + c.Baseline,
+			columns: []column{
+				{"Source", "source"}, {"Metric", "metric"}, {c.Baseline, "baseline"}, {rc.Run, "value"},
+				{"Change", "change"}, {"p-value", "p_value"}, {"Verdict", "verdict"},
+			},
+		}
+		for _, mc := range rc.Metrics {
+			m := findComparedMetric(mc.Source, mc.Metric)
+			value := func(v float64) any {
+				switch m.kind {
+				case errorRateMetric:
+					return percent(v)
+				case throughputMetric:
+					return perSecond(v)
+				default:
+					return ms(v)
+				}
+			}
+
+			var diff, p any
+			switch {
+			case m.kind == errorRateMetric:
+				diff = points(mc.Difference)
+			case mc.Change != nil:
+				diff = change(*mc.Change)
+			}
+			if mc.PValue != nil {
+				p = pValue(*mc.PValue)
+			}
+			t.rows = append(t.rows, []any{mc.Source, m.title, value(mc.Baseline), value(mc.Value), diff, p, mc.Verdict})
+		}
+		sections = append(sections, section{title: rc.Run, tables: []table{t}})
+	}
+	return sections
+}
+
+func findComparedMetric(source, name string) comparedMetric {
+	for _, m := range comparedMetrics {
+		if m.source == source && m.name == name {
+			return m
+		}
+	}
+	return comparedMetric{source: source, name: name, title: name}
+}
+
+// WriteMarkdown writes the comparison as a Markdown table for each run.
+func (c Comparison) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Baseline: %s. A change beyond %.4g%% in throughput or latency, or %.2f pp in an error rate, "+
+		"is a regression or an improvement if it is significant at p < %.4g.\n\n",
+		c.Baseline, c.Tolerance*100, c.ErrorRateTolerance*100, c.Significance)
+	switch c.Regressions {
+	case 0:
+		b.WriteString("No regressions.\n\n")
+	case 1:
+		b.WriteString("**1 regression.**\n\n")
+	default:
+		fmt.Fprintf(&b, "**%d regressions.**\n\n", c.Regressions)
+	}
+	writeMarkdownSections(&b, c.sections())
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the comparison as indented JSON.
+func (c Comparison) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c); err != nil {
+		return fmt.Errorf("failed to encode comparison: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package analysis
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"load-testing/internal/metrics"
+)
+
+func testReport(t *testing.T) Report {
+	t.Helper()
+
+	filename := writeFile(t, metrics.FormatCSV, metrics.SkaterSchema, skaterEvents())
+	return analyse(t, time.Minute, map[string]*metrics.Schema{filename: metrics.SkaterSchema})
+}
+
+func TestReport_WriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport(t).WriteMarkdown(&buf); err != nil {
+		t.Fatalf("WriteMarkdown() error = %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"## Skaters\n",
+		"### Per minute\n",
+		"| Elapsed | Time | Requests | Requests/s | Errors | Error rate | p50 (ms) | p90 (ms) | p99 (ms) | Max (ms) |\n",
+		"| 0:02:00 | 2024-10-27 12:02:00 | 2 | 0.03 | 1 | 50.00% |",
+		"| event-b | 1 | 0 | 0.00% |",
+		"### Per skater\n",
+		"| skater | http_503 | 2 | 100.00% | 2024-10-27 12:00:30 | 2024-10-27 12:02:50 | unexpected status code: 503 |\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "## Viewers") {
+		t.Errorf("expected no viewer section without viewer metrics, got:\n%s", out)
+	}
+}
+
+func TestFormatMarkdown(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{percent(0.0005), "0.05%"},
+		{ms(12.345), "12.35"},
+		{elapsed(3725 * time.Second), "1:02:05"},
+		{time.Time{}, ""},
+		{"a | b\nc", `a \| b c`},
+		{strings.Repeat("x", 150), strings.Repeat("x", maxExampleLength-1) + "…"},
+	}
+
+	for _, tt := range tests {
+		if got := formatMarkdown(tt.value); got != tt.want {
+			t.Errorf("formatMarkdown(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFormatInterval(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		want     string
+	}{
+		{time.Minute, "minute"},
+		{5 * time.Minute, "5m"},
+		{2 * time.Hour, "2h"},
+		{30 * time.Second, "30s"},
+		{90 * time.Second, "1m30s"},
+	}
+
+	for _, tt := range tests {
+		if got := formatInterval(tt.interval); got != tt.want {
+			t.Errorf("formatInterval(%s) = %q, want %q", tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestReport_WriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport(t).WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if decoded.IntervalSeconds != 60 || decoded.Skaters == nil || decoded.Skaters.Requests != 6 || len(decoded.Errors) != 1 {
+		t.Errorf("unexpected decoded report %+v", decoded)
+	}
+}
+
+func TestReport_WriteCSV(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "analysis")
+	paths, err := testReport(t).WriteCSV(dir)
+	if err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+
+	var names []string
+	for _, path := range paths {
+		names = append(names, filepath.Base(path))
+	}
+	want := "skater-overall.csv skater-intervals.csv skater-events.csv skaters.csv errors.csv"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("expected files %s, got %s", want, got)
+	}
+
+	file, err := os.Open(filepath.Join(dir, "skater-intervals.csv"))
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+
+	if len(records) != 5 {
+		t.Fatalf("expected a header and 4 intervals, got %d records", len(records))
+	}
+	if got := strings.Join(records[0][:6], ","); got != "elapsed_seconds,start,requests,requests_per_second,errors,error_rate" {
+		t.Errorf("unexpected header %s", got)
+	}
+	if got := strings.Join(records[3][:6], ","); got != "120,2024-10-27T12:02:00Z,2,0.03,1,0.5" {
+		t.Errorf("unexpected third interval %s", got)
+	}
+}
+
+func TestReport_WriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	page := Page{Title: "Soak <run>", Parameters: []Parameter{{"branch", "fix/drop-head"}}}
+	if err := testReport(t).WriteHTML(&buf, page); err != nil {
+		t.Fatalf("WriteHTML() error = %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"<title>Soak &lt;run&gt;</title>",
+		"<tr><th>Duration</th><td>3m0s</td></tr>",
+		"<tr><th>Skaters</th><td>3</td></tr>",
+		"<tr><th>branch</th><td>fix/drop-head</td></tr>",
+		`aria-label="Response time (ms)"`,
+		`aria-label="Requests per second"`,
+		`aria-label="Errors per interval"`,
+		"<summary>Per minute</summary>",
+		`<td class="num">50.00%</td>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+
+	// The page must work offline.
+	for _, unwanted := range []string{"<script", "<link", "src=", "https://"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("expected no %q in the page", unwanted)
+		}
+	}
+}
+
+func TestNiceScale(t *testing.T) {
+	tests := []struct {
+		max      float64
+		wantTop  float64
+		wantStep float64
+	}{
+		{0, 1, 0.2},
+		{7, 8, 2},
+		{83.2, 100, 20},
+		{0.03, 0.03, 0.01},
+		{1234, 1500, 500},
+	}
+
+	for _, tt := range tests {
+		top, step := niceScale(tt.max)
+		if math.Abs(top-tt.wantTop) > 1e-9 || math.Abs(step-tt.wantStep) > 1e-9 {
+			t.Errorf("niceScale(%v) = %v, %v, want %v, %v", tt.max, top, step, tt.wantTop, tt.wantStep)
+		}
+	}
+}
+
+func TestMessagesPerViewerChart(t *testing.T) {
+	viewers := func(counts ...int) []ViewerMessages {
+		var v []ViewerMessages
+		for _, c := range counts {
+			v = append(v, ViewerMessages{Messages: c})
+		}
+		return v
+	}
+	tests := []struct {
+		name       string
+		viewers    []ViewerMessages
+		wantBars   int
+		wantLabels map[int]string
+		wantValues map[int]float64
+	}{
+		{"one bar per count", viewers(3, 5, 5), 3, map[int]string{0: "3", 2: "5"}, map[int]float64{0: 1, 1: 0, 2: 2}},
+		{"binned", viewers(0, 1, 39), maxHistogramBars, map[int]string{0: "0–1", 19: "38–39"}, map[int]float64{0: 2, 19: 1}},
+		{"no viewers", nil, 0, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := messagesPerViewerChart(tt.viewers).(barChart)
+			if len(c.values) != tt.wantBars || len(c.labels) != tt.wantBars {
+				t.Fatalf("expected %d bars, got %v %v", tt.wantBars, c.labels, c.values)
+			}
+			for i, want := range tt.wantLabels {
+				if c.labels[i] != want {
+					t.Errorf("bar %d: expected label %q, got %q", i, want, c.labels[i])
+				}
+			}
+			for i, want := range tt.wantValues {
+				if c.values[i] != want {
+					t.Errorf("bar %d: expected %v viewers, got %v", i, want, c.values[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"load-testing/internal/metrics"
+)
+
+// Positions of the skater columns used in the analysis.
+var (
+	skaterTimestamp    = metrics.SkaterSchema.Index("timestamp")
+	skaterEventID      = metrics.SkaterSchema.Index("event_id")
+	skaterSkaterID     = metrics.SkaterSchema.Index("skater_id")
+	skaterResponseTime = metrics.SkaterSchema.Index("response_time_ms")
+	skaterErrorKind    = metrics.SkaterSchema.Index("error_kind")
+	skaterError        = metrics.SkaterSchema.Index("error")
+)
+
+type skaterStats struct {
+	requests     int
+	errors       int
+	skipped      int
+	responseTime *metrics.Histogram
+}
+
+func newSkaterStats() *skaterStats {
+	return &skaterStats{responseTime: metrics.NewHistogram()}
+}
+
+func (s *skaterStats) record(responseTime time.Duration, failed bool) {
+	s.requests++
+	s.responseTime.Record(responseTime)
+	if failed {
+		s.errors++
+	}
+}
+
+type skaterKey struct {
+	eventID, skaterID string
+}
+
+type skaterAnalysis struct {
+	span      timeRange
+	overall   *skaterStats
+	intervals map[time.Time]*skaterStats
+	events    map[string]*skaterStats
+	skaters   map[skaterKey]*skaterStats
+}
+
+func newSkaterAnalysis() *skaterAnalysis {
+	return &skaterAnalysis{
+		overall:   newSkaterStats(),
+		intervals: make(map[time.Time]*skaterStats),
+		events:    make(map[string]*skaterStats),
+		skaters:   make(map[skaterKey]*skaterStats),
+	}
+}
+
+func (a *Analyzer) recordSkater(values []any) error {
+	timestamp, ok := values[skaterTimestamp].(time.Time)
+	if !ok {
+		return fmt.Errorf("skater result without a timestamp")
+	}
+	timestamp = timestamp.UTC()
+	eventID := values[skaterEventID].(string)
+	skaterID := values[skaterSkaterID].(string)
+	responseTime, _ := values[skaterResponseTime].(float64)
+	kind := values[skaterErrorKind].(string)
+	message := values[skaterError].(string)
+	failed := kind != "" || message != ""
+
+	s := a.skaters
+	s.span.add(timestamp)
+
+	// Updates skipped by the rate limit were never sent, so they are neither
+	// requests nor errors.
+	if kind == metrics.ErrorKindSkipped {
+		s.overall.skipped++
+		return nil
+	}
+
+	interval := timestamp.Truncate(a.interval)
+	if s.intervals[interval] == nil {
+		s.intervals[interval] = newSkaterStats()
+	}
+	if s.events[eventID] == nil {
+		s.events[eventID] = newSkaterStats()
+	}
+	key := skaterKey{eventID, skaterID}
+	if s.skaters[key] == nil {
+		s.skaters[key] = newSkaterStats()
+	}
+
+	d := duration(responseTime)
+	for _, stats := range []*skaterStats{s.overall, s.intervals[interval], s.events[eventID], s.skaters[key]} {
+		stats.record(d, failed)
+	}
+	if a.samples != nil {
+		a.samples.ResponseTime = append(a.samples.ResponseTime, responseTime)
+	}
+	if failed {
+		a.recordError(metrics.SkaterSchema.Name, kind, message, timestamp)
+	}
+	return nil
+}
+
+func (s *skaterAnalysis) report(interval time.Duration) SkaterReport {
+	report := SkaterReport{
+		StartTime:         s.span.start,
+		EndTime:           s.span.end,
+		Requests:          s.overall.requests,
+		Errors:            s.overall.errors,
+		Skipped:           s.overall.skipped,
+		ErrorRate:         fraction(s.overall.errors, s.overall.requests),
+		RequestsPerSecond: rate(s.overall.requests, s.span.end.Sub(s.span.start).Seconds()),
+		ResponseTime:      s.overall.responseTime.Stats(),
+		Intervals:         []SkaterInterval{},
+		Events:            []SkaterBreakdown{},
+		Skaters:           []SkaterBreakdown{},
+	}
+
+	for _, start := range intervalStarts(s.span, interval) {
+		stats := s.intervals[start]
+		if stats == nil {
+			stats = newSkaterStats()
+		}
+		report.Intervals = append(report.Intervals, SkaterInterval{
+			Start:             start,
+			Requests:          stats.requests,
+			RequestsPerSecond: rate(stats.requests, intervalSeconds(start, interval, s.span)),
+			Errors:            stats.errors,
+			ErrorRate:         fraction(stats.errors, stats.requests),
+			ResponseTime:      stats.responseTime.Stats(),
+		})
+	}
+
+	for _, eventID := range sortedKeys(s.events) {
+		report.Events = append(report.Events, skaterBreakdown(skaterKey{eventID: eventID}, s.events[eventID]))
+	}
+
+	for key, stats := range s.skaters {
+		report.Skaters = append(report.Skaters, skaterBreakdown(key, stats))
+	}
+	// The skaters that fared worst come first.
+	sort.Slice(report.Skaters, func(i, j int) bool {
+		a, b := report.Skaters[i], report.Skaters[j]
+		if a.Errors != b.Errors {
+			return a.Errors > b.Errors
+		}
+		if a.ResponseTime.P99 != b.ResponseTime.P99 {
+			return a.ResponseTime.P99 > b.ResponseTime.P99
+		}
+		if a.EventID != b.EventID {
+			return a.EventID < b.EventID
+		}
+		return a.SkaterID < b.SkaterID
+	})
+	return report
+}
+
+func skaterBreakdown(key skaterKey, stats *skaterStats) SkaterBreakdown {
+	return SkaterBreakdown{
+		EventID:      key.eventID,
+		SkaterID:     key.skaterID,
+		Requests:     stats.requests,
+		Errors:       stats.errors,
+		ErrorRate:    fraction(stats.errors, stats.requests),
+		ResponseTime: stats.responseTime.Stats(),
+	}
+}
@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+)
+
+// mannWhitney runs a two-sided Mann-Whitney U test of whether the values of y
+// tend to be larger or smaller than those of x, using the normal
+// approximation with a correction for ties, which suits the thousands of
+// values of a load test. It returns the p-value and the probability that a
+// value of y is larger than a value of x, counting ties as half.
+func mannWhitney(x, y []float64) (p, superiority float64) {
+	n1, n2 := float64(len(x)), float64(len(y))
+	if n1 == 0 || n2 == 0 {
+		return 1, 0.5
+	}
+
+	type value struct {
+		v     float64
+		fromY bool
+	}
+	values := make([]value, 0, len(x)+len(y))
+	for _, v := range x {
+		values = append(values, value{v, false})
+	}
+	for _, v := range y {
+		values = append(values, value{v, true})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].v < values[j].v })
+
+	// Tied values share the mean of their ranks.
+	var rankSumY, ties float64
+	for i := 0; i < len(values); {
+		j := i + 1
+		for j < len(values) && values[j].v == values[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		t := float64(j - i)
+		ties += t*t*t - t
+		for _, v := range values[i:j] {
+			if v.fromY {
+				rankSumY += rank
+			}
+		}
+		i = j
+	}
+
+	u := rankSumY - n2*(n2+1)/2
+	superiority = u / (n1 * n2)
+
+	n := n1 + n2
+	variance := n1 * n2 / 12 * (n + 1 - ties/(n*(n-1)))
+	if variance <= 0 {
+		// Every value is the same.
+		return 1, superiority
+	}
+	z := (math.Abs(u-n1*n2/2) - 0.5) / math.Sqrt(variance)
+	if z < 0 {
+		z = 0
+	}
+	return math.Erfc(z / math.Sqrt2), superiority
+}
+
+// twoProportions runs a two-sided z-test of whether the proportions
+// failed1/total1 and failed2/total2 differ, and returns its p-value.
+func twoProportions(failed1, total1, failed2, total2 int) float64 {
+	if total1 == 0 || total2 == 0 {
+		return 1
+	}
+
+	pooled := float64(failed1+failed2) / float64(total1+total2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(total1) + 1/float64(total2)))
+	if se == 0 {
+		return 1
+	}
+	z := math.Abs(float64(failed2)/float64(total2)-float64(failed1)/float64(total1)) / se
+	return math.Erfc(z / math.Sqrt2)
+}
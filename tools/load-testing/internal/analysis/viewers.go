@@ -0,0 +1,225 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"load-testing/internal/metrics"
+)
+
+// Positions of the viewer columns used in the analysis.
+var (
+	viewerTimestamp = metrics.ViewerSchema.Index("timestamp")
+	viewerEventID   = metrics.ViewerSchema.Index("event_id")
+	viewerNumber    = metrics.ViewerSchema.Index("viewer_number")
+	viewerLatency   = metrics.ViewerSchema.Index("latency_ms")
+	viewerSkaterIDs = metrics.ViewerSchema.Index("skater_ids")
+	viewerEndToEnd  = metrics.ViewerSchema.Index("end_to_end_ms")
+	viewerBytes     = metrics.ViewerSchema.Index("bytes")
+	viewerErrorKind = metrics.ViewerSchema.Index("error_kind")
+	viewerError     = metrics.ViewerSchema.Index("error")
+)
+
+type viewerKey struct {
+	eventID string
+	number  int
+}
+
+type viewerStats struct {
+	viewers   map[viewerKey]bool
+	messages  int
+	locations int
+	errors    int
+	latency   *metrics.Histogram
+	endToEnd  *metrics.Histogram
+}
+
+func newViewerStats() *viewerStats {
+	return &viewerStats{
+		viewers:  make(map[viewerKey]bool),
+		latency:  metrics.NewHistogram(),
+		endToEnd: metrics.NewHistogram(),
+	}
+}
+
+func (s *viewerStats) errorRate() float64 {
+	return fraction(s.errors, s.messages+s.errors)
+}
+
+type viewerAnalysis struct {
+	span      timeRange
+	overall   *viewerStats
+	intervals map[time.Time]*viewerStats
+	events    map[string]*viewerStats
+	viewers   map[viewerKey]*ViewerMessages
+}
+
+func newViewerAnalysis() *viewerAnalysis {
+	return &viewerAnalysis{
+		overall:   newViewerStats(),
+		intervals: make(map[time.Time]*viewerStats),
+		events:    make(map[string]*viewerStats),
+		viewers:   make(map[viewerKey]*ViewerMessages),
+	}
+}
+
+// recordViewer adds a viewer result. Only received batches and errors are
+// analysed; connection events just count their viewer.
+func (a *Analyzer) recordViewer(values []any) error {
+	timestamp, ok := values[viewerTimestamp].(time.Time)
+	if !ok {
+		return fmt.Errorf("viewer result without a timestamp")
+	}
+	timestamp = timestamp.UTC()
+	eventID := values[viewerEventID].(string)
+	number, _ := values[viewerNumber].(int)
+	kind := values[viewerErrorKind].(string)
+	message := values[viewerError].(string)
+	failed := kind != "" || message != ""
+	batch := !failed && values[viewerBytes] != nil
+
+	s := a.viewers
+	s.span.add(timestamp)
+
+	interval := timestamp.Truncate(a.interval)
+	if s.intervals[interval] == nil {
+		s.intervals[interval] = newViewerStats()
+	}
+	if s.events[eventID] == nil {
+		s.events[eventID] = newViewerStats()
+	}
+
+	key := viewerKey{eventID, number}
+	latency, _ := values[viewerLatency].(float64)
+	skaterIDs, _ := values[viewerSkaterIDs].([]string)
+	endToEnd, _ := values[viewerEndToEnd].([]float64)
+
+	v := s.viewers[key]
+	if v == nil {
+		v = &ViewerMessages{EventID: eventID, ViewerNumber: number}
+		s.viewers[key] = v
+	}
+	switch {
+	case failed:
+		v.Errors++
+	case batch:
+		v.Messages++
+		v.Locations += len(skaterIDs)
+	}
+
+	for _, stats := range []*viewerStats{s.overall, s.intervals[interval], s.events[eventID]} {
+		stats.viewers[key] = true
+		switch {
+		case failed:
+			stats.errors++
+		case batch:
+			stats.messages++
+			stats.locations += len(skaterIDs)
+			stats.latency.Record(duration(latency))
+			for _, ms := range endToEnd {
+				if ms > 0 {
+					stats.endToEnd.Record(duration(ms))
+				}
+			}
+		}
+	}
+	if a.samples != nil && batch {
+		a.samples.Latency = append(a.samples.Latency, latency)
+		for _, ms := range endToEnd {
+			if ms > 0 {
+				a.samples.EndToEnd = append(a.samples.EndToEnd, ms)
+			}
+		}
+	}
+	if failed {
+		a.recordError(metrics.ViewerSchema.Name, kind, message, timestamp)
+	}
+	return nil
+}
+
+func (s *viewerAnalysis) report(interval time.Duration) ViewerReport {
+	report := ViewerReport{
+		StartTime:         s.span.start,
+		EndTime:           s.span.end,
+		Viewers:           len(s.overall.viewers),
+		Messages:          s.overall.messages,
+		Locations:         s.overall.locations,
+		Errors:            s.overall.errors,
+		ErrorRate:         s.overall.errorRate(),
+		MessagesPerSecond: rate(s.overall.messages, s.span.end.Sub(s.span.start).Seconds()),
+		Latency:           s.overall.latency.Stats(),
+		EndToEnd:          s.overall.endToEnd.Stats(),
+		Intervals:         []ViewerInterval{},
+		Events:            []ViewerBreakdown{},
+	}
+
+	for _, start := range intervalStarts(s.span, interval) {
+		stats := s.intervals[start]
+		if stats == nil {
+			stats = newViewerStats()
+		}
+		report.Intervals = append(report.Intervals, ViewerInterval{
+			Start:             start,
+			Viewers:           len(stats.viewers),
+			Messages:          stats.messages,
+			MessagesPerSecond: rate(stats.messages, intervalSeconds(start, interval, s.span)),
+			Locations:         stats.locations,
+			Errors:            stats.errors,
+			ErrorRate:         stats.errorRate(),
+			Latency:           stats.latency.Stats(),
+			EndToEnd:          stats.endToEnd.Stats(),
+		})
+	}
+
+	for _, eventID := range sortedKeys(s.events) {
+		stats := s.events[eventID]
+		report.Events = append(report.Events, ViewerBreakdown{
+			EventID:   eventID,
+			Viewers:   len(stats.viewers),
+			Messages:  stats.messages,
+			Locations: stats.locations,
+			Errors:    stats.errors,
+			ErrorRate: stats.errorRate(),
+			Latency:   stats.latency.Stats(),
+			EndToEnd:  stats.endToEnd.Stats(),
+		})
+	}
+
+	// Viewers that received the fewest batches come first.
+	report.PerViewer = make([]ViewerMessages, 0, len(s.viewers))
+	for _, v := range s.viewers {
+		report.PerViewer = append(report.PerViewer, *v)
+	}
+	sort.Slice(report.PerViewer, func(i, j int) bool {
+		a, b := report.PerViewer[i], report.PerViewer[j]
+		if a.Messages != b.Messages {
+			return a.Messages < b.Messages
+		}
+		if a.Errors != b.Errors {
+			return a.Errors > b.Errors
+		}
+		if a.EventID != b.EventID {
+			return a.EventID < b.EventID
+		}
+		return a.ViewerNumber < b.ViewerNumber
+	})
+	report.MessagesPerViewer = messagesPerViewer(report.PerViewer)
+	return report
+}
+
+func messagesPerViewer(viewers []ViewerMessages) metrics.CountStats {
+	if len(viewers) == 0 {
+		return metrics.CountStats{}
+	}
+
+	stats := metrics.CountStats{Min: viewers[0].Messages}
+	total := 0
+	for _, v := range viewers {
+		stats.Min = min(stats.Min, v.Messages)
+		stats.Max = max(stats.Max, v.Messages)
+		total += v.Messages
+	}
+	stats.Mean = float64(total) / float64(len(viewers))
+	return stats
+}
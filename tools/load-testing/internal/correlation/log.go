@@ -0,0 +1,117 @@
+package correlation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultPollInterval is how often Follow checks a correlation log for new updates.
+const DefaultPollInterval = 20 * time.Millisecond
+
+// Log writes sent updates to a file as JSON lines, for viewers in another
+// process to Follow. Each update is written before its request is sent, so it
+// reaches the file before the location can reach a viewer. It is safe for concurrent use.
+type Log struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// CreateLog creates (or truncates) the named correlation log.
+func CreateLog(filename string) (*Log, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create correlation log: %w", err)
+	}
+	return &Log{file: file}, nil
+}
+
+// Publish appends an update to the log.
+func (l *Log) Publish(u Update) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return fmt.Errorf("failed to encode update: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write correlation log: %w", err)
+	}
+	return nil
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
+
+// Follow reads updates from the named correlation log into publisher as they are
+// written, polling for new lines every pollInterval, until ctx is cancelled.
+// If the file does not exist yet, Follow waits for it to be created, so viewers
+// can be started before skaters.
+func Follow(ctx context.Context, filename string, publisher Publisher, pollInterval time.Duration) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var file *os.File
+	for file == nil {
+		f, err := os.Open(filename)
+		switch {
+		case err == nil:
+			file = f
+		case !errors.Is(err, os.ErrNotExist):
+			return fmt.Errorf("failed to open correlation log: %w", err)
+		default:
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var partial []byte
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read correlation log: %w", err)
+		}
+
+		if errors.Is(err, io.EOF) {
+			// A line still being written is kept until the rest of it arrives.
+			partial = append(partial, line...)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+			continue
+		}
+
+		line = append(partial, line...)
+		partial = nil
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var u Update
+		if err := json.Unmarshal(line, &u); err != nil {
+			return fmt.Errorf("invalid correlation log line %q: %w", bytes.TrimSpace(line), err)
+		}
+		publisher.Publish(u)
+	}
+}
@@ -0,0 +1,102 @@
+package correlation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func waitForMatch(t *testing.T, tracker *Tracker, skaterID string, receivedAt time.Time) time.Time {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if sentAt, found := tracker.Match("event-1", skaterID, -0.1278, 51.5074, receivedAt); found {
+			return sentAt
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("update for %s was not read from the log", skaterID)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLog_Follow(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "correlation.jsonl")
+	tracker := NewTracker(DefaultRetention)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Follow(ctx, filename, tracker, time.Millisecond)
+	}()
+
+	// The follower starts before the log exists, as viewers may start before skaters.
+	time.Sleep(10 * time.Millisecond)
+
+	log, err := CreateLog(filename)
+	if err != nil {
+		t.Fatalf("CreateLog() error = %v", err)
+	}
+	defer log.Close()
+
+	sentAt := time.Date(2024, 10, 27, 12, 0, 0, 123456789, time.UTC)
+	for _, skaterID := range []string{"skater-1", "skater-2"} {
+		update := Update{EventID: "event-1", SkaterID: skaterID, Longitude: -0.1278, Latitude: 51.5074, SentAt: sentAt}
+		if err := log.Publish(update); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+
+	for _, skaterID := range []string{"skater-1", "skater-2"} {
+		if got := waitForMatch(t, tracker, skaterID, sentAt.Add(time.Second)); !got.Equal(sentAt) {
+			t.Errorf("expected sent time %v, got %v", sentAt, got)
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Follow() error = %v", err)
+	}
+}
+
+func TestLog_FollowPartialLine(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "correlation.jsonl")
+	line := `{"eventId":"event-1","skaterId":"skater-1","longitude":-0.1278,"latitude":51.5074,"sentAt":"2024-10-27T12:00:00Z"}` + "\n"
+	if err := os.WriteFile(filename, []byte(line[:40]), 0o644); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+
+	tracker := NewTracker(DefaultRetention)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Follow(ctx, filename, tracker, time.Millisecond)
+
+	time.Sleep(10 * time.Millisecond)
+
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open log: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(line[40:]); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+
+	waitForMatch(t, tracker, "skater-1", time.Date(2024, 10, 27, 12, 0, 1, 0, time.UTC))
+}
+
+func TestLog_FollowInvalidLine(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "correlation.jsonl")
+	if err := os.WriteFile(filename, []byte("not json\n"), 0o644); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := Follow(ctx, filename, NewTracker(DefaultRetention), time.Millisecond); err == nil {
+		t.Error("expected an error for an invalid line")
+	}
+}
@@ -0,0 +1,79 @@
+package correlation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest lists the skaters expected to be visible to viewers of each event
+// for the whole run, for checking delivery when the skaters' updates cannot be
+// followed, for example because they are sent from another machine.
+type Manifest struct {
+	Events []ManifestEvent `yaml:"events"`
+}
+
+// ManifestEvent lists the skaters expected in a single event.
+type ManifestEvent struct {
+	ID      string   `yaml:"id"`
+	Skaters []string `yaml:"skaters"`
+}
+
+// LoadManifest reads a YAML or JSON manifest from the named file.
+func LoadManifest(filename string) (*Manifest, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	m, err := ParseManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", filename, err)
+	}
+	return m, nil
+}
+
+// ParseManifest decodes and validates a manifest from YAML or JSON content.
+// Unknown fields are rejected so that typos are not silently ignored.
+func ParseManifest(data []byte) (*Manifest, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var m Manifest
+	if err := decoder.Decode(&m); err != nil {
+		return nil, err
+	}
+
+	if len(m.Events) == 0 {
+		return nil, errors.New("at least one event is required")
+	}
+	seen := make(map[string]bool, len(m.Events))
+	for i, event := range m.Events {
+		if event.ID == "" {
+			return nil, fmt.Errorf("event %d: id is required", i+1)
+		}
+		if seen[event.ID] {
+			return nil, fmt.Errorf("event %d: duplicate id %q", i+1, event.ID)
+		}
+		seen[event.ID] = true
+
+		for j, skaterID := range event.Skaters {
+			if skaterID == "" {
+				return nil, fmt.Errorf("event %q: skater %d: id is required", event.ID, j+1)
+			}
+		}
+	}
+	return &m, nil
+}
+
+// EventIDs returns the IDs of the manifest's events, in file order.
+func (m *Manifest) EventIDs() []string {
+	ids := make([]string, len(m.Events))
+	for i, event := range m.Events {
+		ids[i] = event.ID
+	}
+	return ids
+}
@@ -0,0 +1,82 @@
+package correlation
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	content := `
+events:
+  - id: event-1
+    skaters: [skater-1, skater-2]
+  - id: event-2
+    skaters:
+      - skater-3
+`
+	m, err := ParseManifest([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseManifest() error = %v", err)
+	}
+
+	want := &Manifest{Events: []ManifestEvent{
+		{ID: "event-1", Skaters: []string{"skater-1", "skater-2"}},
+		{ID: "event-2", Skaters: []string{"skater-3"}},
+	}}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("expected %+v, got %+v", want, m)
+	}
+	if got := m.EventIDs(); !reflect.DeepEqual(got, []string{"event-1", "event-2"}) {
+		t.Errorf("unexpected event IDs %v", got)
+	}
+}
+
+func TestParseManifest_JSON(t *testing.T) {
+	m, err := ParseManifest([]byte(`{"events": [{"id": "event-1", "skaters": ["skater-1"]}]}`))
+	if err != nil {
+		t.Fatalf("ParseManifest() error = %v", err)
+	}
+	if len(m.Events) != 1 || len(m.Events[0].Skaters) != 1 {
+		t.Errorf("unexpected manifest %+v", m)
+	}
+}
+
+func TestParseManifest_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "no events", content: "events: []", wantErr: "at least one event"},
+		{name: "missing id", content: "events: [{skaters: [a]}]", wantErr: "id is required"},
+		{name: "duplicate id", content: "events: [{id: e, skaters: [a]}, {id: e}]", wantErr: "duplicate id"},
+		{name: "empty skater id", content: "events: [{id: e, skaters: ['']}]", wantErr: "skater 1: id is required"},
+		{name: "unknown field", content: "events: [{id: e, skatres: [a]}]", wantErr: "skatres"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseManifest([]byte(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "manifest.yaml")
+	if err := os.WriteFile(filename, []byte("events: [{id: event-1, skaters: [skater-1]}]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadManifest(filename); err != nil {
+		t.Errorf("LoadManifest() error = %v", err)
+	}
+	if _, err := LoadManifest(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
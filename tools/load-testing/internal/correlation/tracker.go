@@ -0,0 +1,137 @@
+// Package correlation matches locations received by viewers with the skater
+// updates that published them, to measure end-to-end latency from a skater
+// sending an update to a viewer receiving it.
+//
+// The API replaces client timestamps with its own, so updates are matched on
+// event, skater and coordinates, which the API passes through unchanged.
+// Skaters and viewers must share a clock: either they run in the same process
+// and share a Tracker, or skaters write a Log that viewers on the same machine Follow.
+package correlation
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultRetention is how long sent updates are kept for matching. It is twice
+// the API's location TTL, after which a location can no longer be received.
+const DefaultRetention = 60 * time.Second
+
+// Update is a location update as sent by a skater.
+type Update struct {
+	EventID   string    `json:"eventId"`
+	SkaterID  string    `json:"skaterId"`
+	Longitude float64   `json:"longitude"`
+	Latitude  float64   `json:"latitude"`
+	SentAt    time.Time `json:"sentAt"`
+}
+
+// Publisher is told about every update a skater sends.
+type Publisher interface {
+	Publish(u Update)
+}
+
+// Publishers passes each update on to every publisher in the list.
+type Publishers []Publisher
+
+// Publish passes u on to every publisher.
+func (p Publishers) Publish(u Update) {
+	for _, publisher := range p {
+		publisher.Publish(u)
+	}
+}
+
+type key struct {
+	eventID   string
+	skaterID  string
+	longitude float64
+	latitude  float64
+}
+
+// Tracker remembers sent updates so received locations can be matched with them.
+// Updates older than the retention period are forgotten, so memory use is bounded
+// by the update rate rather than the length of the run. It is safe for concurrent use.
+type Tracker struct {
+	retention time.Duration
+
+	mu        sync.Mutex
+	sent      map[key][]time.Time
+	lastPrune time.Time
+}
+
+// NewTracker creates a Tracker that keeps updates for retention.
+func NewTracker(retention time.Duration) *Tracker {
+	return &Tracker{
+		retention: retention,
+		sent:      make(map[key][]time.Time),
+	}
+}
+
+// Publish records that an update was sent.
+func (t *Tracker) Publish(u Update) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := key{u.EventID, u.SkaterID, u.Longitude, u.Latitude}
+	t.sent[k] = append(t.sent[k], u.SentAt)
+
+	if u.SentAt.Sub(t.lastPrune) > t.retention/2 {
+		t.prune(u.SentAt.Add(-t.retention))
+		t.lastPrune = u.SentAt
+	}
+}
+
+// prune forgets updates sent before cutoff.
+func (t *Tracker) prune(cutoff time.Time) {
+	for k, times := range t.sent {
+		kept := times[:0]
+		for _, sentAt := range times {
+			if !sentAt.Before(cutoff) {
+				kept = append(kept, sentAt)
+			}
+		}
+		if len(kept) == 0 {
+			delete(t.sent, k)
+		} else {
+			t.sent[k] = kept
+		}
+	}
+}
+
+// Match returns when the update that published a received location was sent.
+// If a skater sent the same coordinates more than once, the latest update sent
+// before receivedAt is used. It reports false if no such update is known.
+func (t *Tracker) Match(eventID, skaterID string, longitude, latitude float64, receivedAt time.Time) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var match time.Time
+	found := false
+	for _, sentAt := range t.sent[key{eventID, skaterID, longitude, latitude}] {
+		if !sentAt.After(receivedAt) && (!found || sentAt.After(match)) {
+			match = sentAt
+			found = true
+		}
+	}
+	return match, found
+}
+
+// Sent returns how many updates to an event were sent from from up to, but not
+// including, to. Updates older than the retention period are not counted.
+func (t *Tracker) Sent(eventID string, from, to time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	count := 0
+	for k, times := range t.sent {
+		if k.eventID != eventID {
+			continue
+		}
+		for _, sentAt := range times {
+			if !sentAt.Before(from) && sentAt.Before(to) {
+				count++
+			}
+		}
+	}
+	return count
+}
@@ -0,0 +1,91 @@
+package correlation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTracker_Match(t *testing.T) {
+	base := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker(DefaultRetention)
+
+	tracker.Publish(Update{EventID: "event-1", SkaterID: "skater-1", Longitude: -0.1278, Latitude: 51.5074, SentAt: base})
+	tracker.Publish(Update{EventID: "event-1", SkaterID: "skater-1", Longitude: -0.1279, Latitude: 51.5075, SentAt: base.Add(time.Second)})
+
+	tests := []struct {
+		name       string
+		eventID    string
+		skaterID   string
+		longitude  float64
+		latitude   float64
+		receivedAt time.Time
+		expected   time.Time
+		found      bool
+	}{
+		{"first update", "event-1", "skater-1", -0.1278, 51.5074, base.Add(500 * time.Millisecond), base, true},
+		{"second update", "event-1", "skater-1", -0.1279, 51.5075, base.Add(2 * time.Second), base.Add(time.Second), true},
+		{"other coordinates", "event-1", "skater-1", -0.1, 51.5, base.Add(time.Second), time.Time{}, false},
+		{"other skater", "event-1", "skater-2", -0.1278, 51.5074, base.Add(time.Second), time.Time{}, false},
+		{"other event", "event-2", "skater-1", -0.1278, 51.5074, base.Add(time.Second), time.Time{}, false},
+		{"received before sent", "event-1", "skater-1", -0.1279, 51.5075, base.Add(500 * time.Millisecond), time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sentAt, found := tracker.Match(tt.eventID, tt.skaterID, tt.longitude, tt.latitude, tt.receivedAt)
+			if found != tt.found || !sentAt.Equal(tt.expected) {
+				t.Errorf("Match() = %v, %v, want %v, %v", sentAt, found, tt.expected, tt.found)
+			}
+		})
+	}
+}
+
+func TestTracker_RepeatedCoordinatesUseLatestSentBeforeReceipt(t *testing.T) {
+	base := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker(DefaultRetention)
+
+	for i := 0; i < 3; i++ {
+		tracker.Publish(Update{EventID: "event-1", SkaterID: "skater-1", SentAt: base.Add(time.Duration(i) * time.Second)})
+	}
+
+	sentAt, found := tracker.Match("event-1", "skater-1", 0, 0, base.Add(1500*time.Millisecond))
+	if !found || !sentAt.Equal(base.Add(time.Second)) {
+		t.Errorf("expected the update sent at 1s, got %v, %v", sentAt, found)
+	}
+}
+
+func TestTracker_ForgetsOldUpdates(t *testing.T) {
+	base := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker(10 * time.Second)
+
+	tracker.Publish(Update{EventID: "event-1", SkaterID: "old", SentAt: base})
+	tracker.Publish(Update{EventID: "event-1", SkaterID: "new", SentAt: base.Add(20 * time.Second)})
+
+	if _, found := tracker.Match("event-1", "old", 0, 0, base.Add(20*time.Second)); found {
+		t.Error("expected an update older than the retention period to be forgotten")
+	}
+	if _, found := tracker.Match("event-1", "new", 0, 0, base.Add(20*time.Second)); !found {
+		t.Error("expected a recent update to be kept")
+	}
+	if len(tracker.sent) != 1 {
+		t.Errorf("expected 1 tracked location, got %d", len(tracker.sent))
+	}
+}
+
+func TestTracker_Sent(t *testing.T) {
+	base := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker(DefaultRetention)
+
+	for i := 0; i < 5; i++ {
+		tracker.Publish(Update{EventID: "event-1", SkaterID: "skater-1", Longitude: float64(i), SentAt: base.Add(time.Duration(i) * time.Second)})
+		tracker.Publish(Update{EventID: "event-1", SkaterID: "skater-2", Longitude: float64(i), SentAt: base.Add(time.Duration(i) * time.Second)})
+	}
+	tracker.Publish(Update{EventID: "event-2", SkaterID: "skater-3", SentAt: base.Add(2 * time.Second)})
+
+	if got := tracker.Sent("event-1", base.Add(time.Second), base.Add(3*time.Second)); got != 4 {
+		t.Errorf("expected 4 updates sent from 1s up to 3s, got %d", got)
+	}
+	if got := tracker.Sent("event-2", base, base.Add(time.Minute)); got != 1 {
+		t.Errorf("expected 1 update sent to event-2, got %d", got)
+	}
+}
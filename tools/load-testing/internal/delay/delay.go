@@ -0,0 +1,122 @@
+// Package delay draws random durations from simple distributions, for
+// injected latency and simulated user behaviour.
+package delay
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Distributions accepted by Parse.
+const (
+	Fixed       = "fixed"
+	Uniform     = "uniform"
+	Normal      = "normal"
+	Exponential = "exp"
+)
+
+// Delay is a random delay drawn from a distribution. The zero value is no delay.
+//
+//   - fixed: always A
+//   - uniform: between A and B
+//   - normal: mean A, standard deviation B, never below zero
+//   - exp: exponential with mean A
+type Delay struct {
+	Distribution string
+	A            time.Duration
+	B            time.Duration
+}
+
+// Parse parses a delay such as "50ms", "uniform:10ms-200ms",
+// "normal:100ms,20ms" or "exp:50ms". An empty string is no delay.
+func Parse(spec string) (Delay, error) {
+	if spec == "" {
+		return Delay{}, nil
+	}
+
+	distribution, params, found := strings.Cut(spec, ":")
+	if !found {
+		d, err := parseNonNegative(spec)
+		if err != nil {
+			return Delay{}, err
+		}
+		return Delay{Distribution: Fixed, A: d}, nil
+	}
+
+	switch distribution {
+	case Fixed, Exponential:
+		d, err := parseNonNegative(params)
+		if err != nil {
+			return Delay{}, err
+		}
+		return Delay{Distribution: distribution, A: d}, nil
+	case Uniform, Normal:
+		separator := "-"
+		if distribution == Normal {
+			separator = ","
+		}
+		first, second, ok := strings.Cut(params, separator)
+		if !ok {
+			return Delay{}, fmt.Errorf("%s delay needs two durations separated by %q, got: %s", distribution, separator, params)
+		}
+		a, err := parseNonNegative(first)
+		if err != nil {
+			return Delay{}, err
+		}
+		b, err := parseNonNegative(second)
+		if err != nil {
+			return Delay{}, err
+		}
+		if distribution == Uniform && b < a {
+			return Delay{}, fmt.Errorf("uniform delay maximum %s is less than minimum %s", b, a)
+		}
+		return Delay{Distribution: distribution, A: a, B: b}, nil
+	default:
+		return Delay{}, fmt.Errorf("unknown delay distribution %q (must be %s, %s, %s or %s)",
+			distribution, Fixed, Uniform, Normal, Exponential)
+	}
+}
+
+func parseNonNegative(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("delay must be non-negative, got: %s", d)
+	}
+	return d, nil
+}
+
+func (d Delay) String() string {
+	switch d.Distribution {
+	case "":
+		return "none"
+	case Uniform:
+		return fmt.Sprintf("%s:%s-%s", d.Distribution, d.A, d.B)
+	case Normal:
+		return fmt.Sprintf("%s:%s,%s", d.Distribution, d.A, d.B)
+	default:
+		return fmt.Sprintf("%s:%s", d.Distribution, d.A)
+	}
+}
+
+// Sample draws a duration from the distribution using rng, which the caller
+// must not use concurrently. The zero value always returns zero.
+func (d Delay) Sample(rng *rand.Rand) time.Duration {
+	switch d.Distribution {
+	case "":
+		return 0
+	case Uniform:
+		return d.A + time.Duration(rng.Int63n(int64(d.B-d.A)+1))
+	case Normal:
+		return time.Duration(math.Max(0, float64(d.A)+rng.NormFloat64()*float64(d.B)))
+	case Exponential:
+		return time.Duration(rng.ExpFloat64() * float64(d.A))
+	default:
+		return d.A
+	}
+}
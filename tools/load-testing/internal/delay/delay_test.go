@@ -0,0 +1,61 @@
+package delay
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected Delay
+		wantErr  bool
+	}{
+		{"", Delay{}, false},
+		{"50ms", Delay{Distribution: Fixed, A: 50 * time.Millisecond}, false},
+		{"fixed:1s", Delay{Distribution: Fixed, A: time.Second}, false},
+		{"uniform:10ms-200ms", Delay{Distribution: Uniform, A: 10 * time.Millisecond, B: 200 * time.Millisecond}, false},
+		{"normal:100ms,20ms", Delay{Distribution: Normal, A: 100 * time.Millisecond, B: 20 * time.Millisecond}, false},
+		{"exp:50ms", Delay{Distribution: Exponential, A: 50 * time.Millisecond}, false},
+		{"uniform:200ms-10ms", Delay{}, true},
+		{"uniform:10ms", Delay{}, true},
+		{"pareto:1s", Delay{}, true},
+		{"-5ms", Delay{}, true},
+		{"soon", Delay{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := Parse(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("Parse(%q) = %+v, expected %+v", tt.spec, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSample(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	uniform := Delay{Distribution: Uniform, A: 10 * time.Millisecond, B: 20 * time.Millisecond}
+	normal := Delay{Distribution: Normal, A: 5 * time.Millisecond, B: 50 * time.Millisecond}
+	for n := 0; n < 1000; n++ {
+		if d := uniform.Sample(rng); d < uniform.A || d > uniform.B {
+			t.Fatalf("uniform delay %s outside %s-%s", d, uniform.A, uniform.B)
+		}
+		if d := normal.Sample(rng); d < 0 {
+			t.Fatalf("normal delay %s is negative", d)
+		}
+	}
+
+	if d := (Delay{Distribution: Fixed, A: time.Second}).Sample(rng); d != time.Second {
+		t.Errorf("expected a fixed delay of 1s, got %s", d)
+	}
+	if d := (Delay{}).Sample(rng); d != 0 {
+		t.Errorf("expected no delay, got %s", d)
+	}
+}
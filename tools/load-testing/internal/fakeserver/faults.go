@@ -0,0 +1,165 @@
+package fakeserver
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"load-testing/internal/delay"
+)
+
+// Faults configures deliberate misbehaviour. Rates are probabilities between 0 and 1.
+// Update faults apply to each location update; stream faults are rolled before
+// each batch is sent. Each group's faults exclude one another, so their rates
+// are the chances of each fault and add up to at most 1. The zero value injects
+// no faults.
+type Faults struct {
+	// UpdateLatency delays every location update response.
+	UpdateLatency delay.Delay
+	// ErrorRate is the fraction of updates rejected with ErrorStatus (503 if unset).
+	ErrorRate   float64
+	ErrorStatus int
+	// ThrottleRate is the fraction of updates rejected with 429 Too Many Requests.
+	ThrottleRate float64
+
+	// HandshakeDelay delays every stream before the WebSocket upgrade.
+	HandshakeDelay delay.Delay
+	// DropRate is the chance of closing the stream's TCP connection without a close frame.
+	DropRate float64
+	// MalformedRate is the chance of sending a batch as truncated JSON.
+	MalformedRate float64
+	// StallRate is the chance of the stream sending nothing for StallDuration.
+	// The connection stays open and keeps answering pings while stalled.
+	StallRate     float64
+	StallDuration time.Duration
+
+	// Seed makes fault injection reproducible. Zero uses a time-based seed.
+	Seed int64
+}
+
+// Validate checks that the rates are probabilities and the error status is a server error.
+func (f Faults) Validate() error {
+	rates := []struct {
+		name string
+		rate float64
+	}{
+		{"error rate", f.ErrorRate},
+		{"throttle rate", f.ThrottleRate},
+		{"drop rate", f.DropRate},
+		{"malformed rate", f.MalformedRate},
+		{"stall rate", f.StallRate},
+	}
+	for _, r := range rates {
+		if r.rate < 0 || r.rate > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, got: %f", r.name, r.rate)
+		}
+	}
+
+	if f.ErrorRate+f.ThrottleRate > 1 {
+		return fmt.Errorf("error rate and throttle rate must not add up to more than 1, got: %f", f.ErrorRate+f.ThrottleRate)
+	}
+
+	if sum := f.DropRate + f.MalformedRate + f.StallRate; sum > 1 {
+		return fmt.Errorf("drop, malformed and stall rates must not add up to more than 1, got: %f", sum)
+	}
+
+	if f.ErrorStatus != 0 && (f.ErrorStatus < 500 || f.ErrorStatus > 599) {
+		return fmt.Errorf("error status must be a 5xx code, got: %d", f.ErrorStatus)
+	}
+
+	if f.StallRate > 0 && f.StallDuration <= 0 {
+		return fmt.Errorf("stall duration must be positive when stall rate is set, got: %s", f.StallDuration)
+	}
+
+	return nil
+}
+
+// Enabled reports whether any fault is configured to occur.
+func (f Faults) Enabled() bool {
+	return f.UpdateLatency.Distribution != "" || f.HandshakeDelay.Distribution != "" ||
+		f.ErrorRate > 0 || f.ThrottleRate > 0 || f.DropRate > 0 || f.MalformedRate > 0 || f.StallRate > 0
+}
+
+// Stream faults rolled before each batch.
+const (
+	streamOK = iota
+	streamDrop
+	streamMalformed
+	streamStall
+)
+
+// injector draws random faults. It is safe for concurrent use.
+type injector struct {
+	faults Faults
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newInjector(faults Faults) *injector {
+	seed := faults.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if faults.ErrorStatus == 0 {
+		faults.ErrorStatus = http.StatusServiceUnavailable
+	}
+	return &injector{faults: faults, rng: rand.New(rand.NewSource(seed))}
+}
+
+func (i *injector) float64() float64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.rng.Float64()
+}
+
+func (i *injector) delay(d delay.Delay) time.Duration {
+	if d.Distribution == "" {
+		return 0
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return d.Sample(i.rng)
+}
+
+// updateStatus returns the status to reject an update with, or zero to accept it.
+func (i *injector) updateStatus() int {
+	if i.faults.ErrorRate == 0 && i.faults.ThrottleRate == 0 {
+		return 0
+	}
+
+	r := i.float64()
+	switch {
+	case r < i.faults.ErrorRate:
+		return i.faults.ErrorStatus
+	case r < i.faults.ErrorRate+i.faults.ThrottleRate:
+		return http.StatusTooManyRequests
+	default:
+		return 0
+	}
+}
+
+// streamFault returns the fault to apply before the next batch.
+func (i *injector) streamFault() int {
+	f := i.faults
+	if f.DropRate == 0 && f.MalformedRate == 0 && f.StallRate == 0 {
+		return streamOK
+	}
+
+	r := i.float64()
+	switch {
+	case r < f.DropRate:
+		return streamDrop
+	case r < f.DropRate+f.MalformedRate:
+		return streamMalformed
+	case r < f.DropRate+f.MalformedRate+f.StallRate:
+		return streamStall
+	default:
+		return streamOK
+	}
+}
@@ -0,0 +1,227 @@
+package fakeserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"load-testing/internal/delay"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+func TestFaults_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		faults  Faults
+		wantErr bool
+	}{
+		{"none", Faults{}, false},
+		{"valid", Faults{ErrorRate: 0.1, ErrorStatus: 502, ThrottleRate: 0.1, StallRate: 0.5, StallDuration: time.Second}, false},
+		{"rate above one", Faults{DropRate: 1.5}, true},
+		{"negative rate", Faults{MalformedRate: -0.1}, true},
+		{"error and throttle above one", Faults{ErrorRate: 0.6, ThrottleRate: 0.6}, true},
+		{"stream faults above one", Faults{DropRate: 0.4, MalformedRate: 0.4, StallRate: 0.4, StallDuration: time.Second}, true},
+		{"non-5xx status", Faults{ErrorStatus: 404}, true},
+		{"stall without duration", Faults{StallRate: 0.1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.faults.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFaults_Enabled(t *testing.T) {
+	if (Faults{ErrorStatus: 503, StallDuration: time.Second, Seed: 1}).Enabled() {
+		t.Error("expected settings without rates or delays to inject nothing")
+	}
+	if !(Faults{DropRate: 0.1}).Enabled() {
+		t.Error("expected a drop rate to be enabled")
+	}
+	if !(Faults{HandshakeDelay: delay.Delay{Distribution: delay.Fixed, A: time.Second}}).Enabled() {
+		t.Error("expected a handshake delay to be enabled")
+	}
+}
+
+func TestInjector_Delay(t *testing.T) {
+	i := newInjector(Faults{Seed: 1})
+
+	uniform := delay.Delay{Distribution: delay.Uniform, A: 10 * time.Millisecond, B: 20 * time.Millisecond}
+	normal := delay.Delay{Distribution: delay.Normal, A: 5 * time.Millisecond, B: 50 * time.Millisecond}
+	for n := 0; n < 1000; n++ {
+		if d := i.delay(uniform); d < uniform.A || d > uniform.B {
+			t.Fatalf("uniform delay %s outside %s-%s", d, uniform.A, uniform.B)
+		}
+		if d := i.delay(normal); d < 0 {
+			t.Fatalf("normal delay %s is negative", d)
+		}
+	}
+
+	if d := i.delay(delay.Delay{}); d != 0 {
+		t.Errorf("expected no delay, got %s", d)
+	}
+}
+
+func TestInjector_UpdateStatusRates(t *testing.T) {
+	i := newInjector(Faults{ErrorRate: 0.2, ThrottleRate: 0.1, Seed: 1})
+
+	counts := make(map[int]int)
+	for n := 0; n < 10000; n++ {
+		counts[i.updateStatus()]++
+	}
+
+	if errors := counts[http.StatusServiceUnavailable]; errors < 1800 || errors > 2200 {
+		t.Errorf("expected about 2000 503 responses, got %d", errors)
+	}
+	if throttled := counts[http.StatusTooManyRequests]; throttled < 850 || throttled > 1150 {
+		t.Errorf("expected about 1000 429 responses, got %d", throttled)
+	}
+}
+
+func TestInjector_StreamFaultRates(t *testing.T) {
+	i := newInjector(Faults{DropRate: 0.1, MalformedRate: 0.2, StallRate: 0.3, StallDuration: time.Second, Seed: 1})
+
+	counts := make(map[int]int)
+	for n := 0; n < 10000; n++ {
+		counts[i.streamFault()]++
+	}
+
+	want := map[int]int{streamDrop: 1000, streamMalformed: 2000, streamStall: 3000, streamOK: 4000}
+	for fault, expected := range want {
+		if got := counts[fault]; got < expected*9/10 || got > expected*11/10 {
+			t.Errorf("fault %d: expected about %d, got %d", fault, expected, got)
+		}
+	}
+}
+
+func TestFaults_UpdateResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		faults     Faults
+		status     int
+		retryAfter string
+	}{
+		{"server error", Faults{ErrorRate: 1}, http.StatusServiceUnavailable, ""},
+		{"custom server error", Faults{ErrorRate: 1, ErrorStatus: http.StatusBadGateway}, http.StatusBadGateway, ""},
+		{"throttled", Faults{ThrottleRate: 1}, http.StatusTooManyRequests, "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.Faults = tt.faults
+			server, httpServer := newTestServer(t, config)
+
+			eventID := uuid.New().String()
+			resp := putLocation(t, httpServer.URL, eventID, uuid.New().String(), `{"coordinates":[0,0]}`)
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+			if got := resp.Header.Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("expected Retry-After %q, got %q", tt.retryAfter, got)
+			}
+			if stored := server.store.getAll(eventID); len(stored) != 0 {
+				t.Errorf("rejected update should not be stored, got %+v", stored)
+			}
+		})
+	}
+}
+
+func TestFaults_UpdateLatency(t *testing.T) {
+	config := testConfig()
+	config.Faults.UpdateLatency = delay.Delay{Distribution: delay.Fixed, A: 100 * time.Millisecond}
+	_, httpServer := newTestServer(t, config)
+
+	start := time.Now()
+	resp := putLocation(t, httpServer.URL, uuid.New().String(), uuid.New().String(), `{"coordinates":[0,0]}`)
+	elapsed := time.Since(start)
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("expected status 202, got %d", resp.StatusCode)
+	}
+	if elapsed < 100*time.Millisecond {
+		t.Errorf("expected response to take at least 100ms, took %s", elapsed)
+	}
+}
+
+func TestFaults_HandshakeDelay(t *testing.T) {
+	config := testConfig()
+	config.Faults.HandshakeDelay = delay.Delay{Distribution: delay.Fixed, A: 100 * time.Millisecond}
+	_, httpServer := newTestServer(t, config)
+
+	start := time.Now()
+	dialStream(t, httpServer.URL, uuid.New().String())
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected handshake to take at least 100ms, took %s", elapsed)
+	}
+}
+
+func TestFaults_DroppedStream(t *testing.T) {
+	config := testConfig()
+	config.Faults.DropRate = 1
+	_, httpServer := newTestServer(t, config)
+
+	eventID := uuid.New().String()
+	putLocation(t, httpServer.URL, eventID, uuid.New().String(), `{"coordinates":[0,0]}`)
+	conn := dialStream(t, httpServer.URL, eventID)
+
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("failed to set read deadline: %v", err)
+	}
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseAbnormalClosure) {
+		t.Errorf("expected an abnormal closure without a close frame, got %v", err)
+	}
+}
+
+func TestFaults_MalformedBatch(t *testing.T) {
+	config := testConfig()
+	config.Faults.MalformedRate = 1
+	_, httpServer := newTestServer(t, config)
+
+	eventID := uuid.New().String()
+	putLocation(t, httpServer.URL, eventID, uuid.New().String(), `{"coordinates":[0,0]}`)
+	conn := dialStream(t, httpServer.URL, eventID)
+
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("failed to set read deadline: %v", err)
+	}
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+
+	var batch LocationBatch
+	if err := json.Unmarshal(message, &batch); err == nil {
+		t.Errorf("expected malformed JSON, got %s", message)
+	}
+}
+
+func TestFaults_StalledStream(t *testing.T) {
+	config := testConfig()
+	config.Faults.StallRate = 1
+	config.Faults.StallDuration = 200 * time.Millisecond
+	_, httpServer := newTestServer(t, config)
+
+	eventID := uuid.New().String()
+	putLocation(t, httpServer.URL, eventID, uuid.New().String(), `{"coordinates":[0,0]}`)
+
+	start := time.Now()
+	conn := dialStream(t, httpServer.URL, eventID)
+	batch := readBatch(t, conn)
+
+	if len(batch.Locations) != 1 {
+		t.Errorf("expected the held batch to be sent after the stall, got %+v", batch)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected the first batch after at least 200ms, took %s", elapsed)
+	}
+}
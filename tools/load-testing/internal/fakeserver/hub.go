@@ -0,0 +1,63 @@
+package fakeserver
+
+import "sync"
+
+// hub fans published locations out to the stream subscribers of each event.
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan Location]struct{}
+}
+
+func newHub() *hub {
+	return &hub{subscribers: make(map[string]map[chan Location]struct{})}
+}
+
+func (h *hub) subscribe(eventID string, bufferSize int) chan Location {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subs, ok := h.subscribers[eventID]
+	if !ok {
+		subs = make(map[chan Location]struct{})
+		h.subscribers[eventID] = subs
+	}
+	ch := make(chan Location, bufferSize)
+	subs[ch] = struct{}{}
+	return ch
+}
+
+func (h *hub) unsubscribe(eventID string, ch chan Location) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subs := h.subscribers[eventID]
+	delete(subs, ch)
+	if len(subs) == 0 {
+		delete(h.subscribers, eventID)
+	}
+}
+
+// publish sends a location to every subscriber of the event without blocking.
+// When a subscriber's buffer is full its oldest location is dropped, matching
+// the real server's drop-head overflow strategy.
+func (h *hub) publish(eventID string, location Location) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers[eventID] {
+		select {
+		case ch <- location:
+			continue
+		default:
+		}
+
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- location:
+		default:
+		}
+	}
+}
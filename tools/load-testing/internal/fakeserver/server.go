@@ -0,0 +1,312 @@
+// Package fakeserver is an in-process stand-in for the Skatemap API.
+// It implements the location update, event stream and health endpoints with the
+// same TTL and batching behaviour as services/api, so the simulators and smoke
+// tests can run without network access.
+package fakeserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Defaults matching services/api application.conf.
+const (
+	DefaultLocationTTL     = 30 * time.Second
+	DefaultCleanupInterval = 10 * time.Second
+	DefaultBatchSize       = 100
+	DefaultBatchInterval   = 500 * time.Millisecond
+	DefaultBufferSize      = 128
+)
+
+const (
+	writeTimeout = 10 * time.Second
+)
+
+// malformedBatch is sent in place of a batch when a malformed batch fault is injected.
+const malformedBatch = `{"locations":[{"skaterId":`
+
+// errDropped is returned when a stream connection is dropped by fault injection.
+var errDropped = errors.New("connection dropped")
+
+// Config holds the server's expiry and batching settings, and any faults to inject.
+// BufferSize is the number of locations buffered for each stream subscriber
+// before the oldest are dropped.
+type Config struct {
+	LocationTTL     time.Duration
+	CleanupInterval time.Duration
+	BatchSize       int
+	BatchInterval   time.Duration
+	BufferSize      int
+	Faults          Faults
+}
+
+// DefaultConfig returns the settings used by the deployed API.
+func DefaultConfig() Config {
+	return Config{
+		LocationTTL:     DefaultLocationTTL,
+		CleanupInterval: DefaultCleanupInterval,
+		BatchSize:       DefaultBatchSize,
+		BatchInterval:   DefaultBatchInterval,
+		BufferSize:      DefaultBufferSize,
+	}
+}
+
+// Server is a fake Skatemap API. It is an http.Handler, so it can be served by
+// net/http or httptest. Close must be called to stop its background work and
+// disconnect stream subscribers.
+type Server struct {
+	config   Config
+	store    *store
+	hub      *hub
+	faults   *injector
+	mux      *http.ServeMux
+	upgrader websocket.Upgrader
+
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New creates a Server and starts its location cleanup loop.
+// The configured faults are assumed to be valid; see Faults.Validate.
+func New(config Config) *Server {
+	s := &Server{
+		config: config,
+		store:  newStore(),
+		hub:    newHub(),
+		faults: newInjector(config.Faults),
+		mux:    http.NewServeMux(),
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(*http.Request) bool { return true },
+		},
+		done: make(chan struct{}),
+	}
+
+	s.mux.HandleFunc("GET /health", s.handleHealth)
+	s.mux.HandleFunc("PUT /skatingEvents/{eventId}/skaters/{skaterId}", s.handleUpdateLocation)
+	s.mux.HandleFunc("GET /skatingEvents/{eventId}/stream", s.handleStream)
+
+	s.wg.Add(1)
+	go s.cleanupLoop()
+
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Close stops the cleanup loop and closes all open streams, waiting for them to finish.
+func (s *Server) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// track registers a stream with Close, reporting false if the server is already closed.
+func (s *Server) track() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) cleanupLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.store.cleanup(time.Now().Add(-s.config.LocationTTL))
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleUpdateLocation stores and publishes a location. Injected latency and
+// error responses are applied before the request is validated, as an overloaded
+// server would reject requests without looking at them.
+func (s *Server) handleUpdateLocation(w http.ResponseWriter, r *http.Request) {
+	if !s.sleep(s.faults.delay(s.config.Faults.UpdateLatency), r.Context().Done()) {
+		return
+	}
+
+	if status := s.faults.updateStatus(); status != 0 {
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	eventID := r.PathValue("eventId")
+	skaterID := r.PathValue("skaterId")
+
+	longitude, latitude, verr := validateUpdate(eventID, skaterID, r)
+	if verr != nil {
+		writeValidationError(w, verr)
+		return
+	}
+
+	location := Location{
+		SkaterID:  skaterID,
+		Latitude:  latitude,
+		Longitude: longitude,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	s.store.put(eventID, location)
+	s.hub.publish(eventID, location)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleStream upgrades to a WebSocket and sends the event's stored locations
+// followed by live updates, in batches of at most BatchSize sent at most every
+// BatchInterval. Empty batches are never sent.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	eventID := r.PathValue("eventId")
+	if _, err := uuid.Parse(eventID); err != nil {
+		writeValidationError(w, errInvalidSkatingEventID)
+		return
+	}
+
+	if !s.track() {
+		http.Error(w, "server closed", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.wg.Done()
+
+	if !s.sleep(s.faults.delay(s.config.Faults.HandshakeDelay), r.Context().Done()) {
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	updates := s.hub.subscribe(eventID, s.config.BufferSize)
+	defer s.hub.unsubscribe(eventID, updates)
+
+	pending := s.store.getAll(eventID)
+
+	disconnected := make(chan struct{})
+	go func() {
+		defer close(disconnected)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(s.config.BatchInterval)
+	defer ticker.Stop()
+
+	for {
+		for len(pending) >= s.config.BatchSize {
+			if err := s.sendBatch(conn, pending[:s.config.BatchSize], disconnected); err != nil {
+				return
+			}
+			pending = pending[s.config.BatchSize:]
+		}
+
+		select {
+		case location := <-updates:
+			pending = append(pending, location)
+		case <-ticker.C:
+			if len(pending) == 0 {
+				continue
+			}
+			if err := s.sendBatch(conn, pending, disconnected); err != nil {
+				return
+			}
+			pending = nil
+		case <-disconnected:
+			return
+		case <-s.done:
+			message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+			_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeTimeout))
+			return
+		}
+	}
+}
+
+// sendBatch sends a batch of locations, first applying any injected stream fault.
+// A malformed batch replaces the real one, so its locations are lost.
+func (s *Server) sendBatch(conn *websocket.Conn, locations []Location, disconnected <-chan struct{}) error {
+	switch s.faults.streamFault() {
+	case streamDrop:
+		conn.NetConn().Close()
+		return errDropped
+	case streamMalformed:
+		if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+			return err
+		}
+		return conn.WriteMessage(websocket.TextMessage, []byte(malformedBatch))
+	case streamStall:
+		if !s.sleep(s.config.Faults.StallDuration, disconnected) {
+			return errDropped
+		}
+	}
+
+	batch := LocationBatch{
+		Locations:  locations,
+		ServerTime: time.Now().UnixMilli(),
+	}
+	if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	return conn.WriteJSON(batch)
+}
+
+// sleep waits for d, returning false if cancel is closed or the server is closed first.
+func (s *Server) sleep(d time.Duration, cancel <-chan struct{}) bool {
+	if d <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-cancel:
+		return false
+	case <-s.done:
+		return false
+	}
+}
+
+func writeValidationError(w http.ResponseWriter, verr *validationError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(verr)
+}
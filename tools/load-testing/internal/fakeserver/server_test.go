@@ -0,0 +1,248 @@
+package fakeserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+func newTestServer(t *testing.T, config Config) (*Server, *httptest.Server) {
+	t.Helper()
+
+	server := New(config)
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(func() {
+		server.Close()
+		httpServer.Close()
+	})
+	return server, httpServer
+}
+
+func testConfig() Config {
+	config := DefaultConfig()
+	config.BatchInterval = 20 * time.Millisecond
+	return config
+}
+
+func putLocation(t *testing.T, baseURL, eventID, skaterID, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPut, baseURL+"/skatingEvents/"+eventID+"/skaters/"+skaterID, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func dialStream(t *testing.T, baseURL, eventID string) *websocket.Conn {
+	t.Helper()
+
+	wsURL := "ws" + strings.TrimPrefix(baseURL, "http") + "/skatingEvents/" + eventID + "/stream"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to connect to stream: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readBatch(t *testing.T, conn *websocket.Conn) LocationBatch {
+	t.Helper()
+
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("failed to set read deadline: %v", err)
+	}
+
+	var batch LocationBatch
+	if err := conn.ReadJSON(&batch); err != nil {
+		t.Fatalf("failed to read batch: %v", err)
+	}
+	return batch
+}
+
+func TestHealth(t *testing.T) {
+	_, httpServer := newTestServer(t, testConfig())
+
+	resp, err := http.Get(httpServer.URL + "/health")
+	if err != nil {
+		t.Fatalf("health request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestUpdateLocation_Validation(t *testing.T) {
+	_, httpServer := newTestServer(t, testConfig())
+
+	eventID := uuid.New().String()
+	skaterID := uuid.New().String()
+
+	tests := []struct {
+		name     string
+		eventID  string
+		skaterID string
+		body     string
+		status   int
+		code     string
+	}{
+		{"valid", eventID, skaterID, `{"coordinates":[-0.1278,51.5074]}`, http.StatusAccepted, ""},
+		{"invalid event ID", "not-a-uuid", skaterID, `{"coordinates":[0,0]}`, http.StatusBadRequest, "INVALID_SKATING_EVENT_ID"},
+		{"invalid skater ID", eventID, "not-a-uuid", `{"coordinates":[0,0]}`, http.StatusBadRequest, "INVALID_SKATER_ID"},
+		{"invalid JSON", eventID, skaterID, `{"coordinates":`, http.StatusBadRequest, "INVALID_JSON"},
+		{"missing coordinates", eventID, skaterID, `{"location":[0,0]}`, http.StatusBadRequest, "MISSING_COORDINATES"},
+		{"coordinates not numbers", eventID, skaterID, `{"coordinates":["a","b"]}`, http.StatusBadRequest, "MISSING_COORDINATES"},
+		{"wrong length", eventID, skaterID, `{"coordinates":[0,0,0]}`, http.StatusBadRequest, "INVALID_COORDINATES_LENGTH"},
+		{"longitude out of range", eventID, skaterID, `{"coordinates":[181,0]}`, http.StatusBadRequest, "INVALID_LONGITUDE"},
+		{"latitude out of range", eventID, skaterID, `{"coordinates":[0,-91]}`, http.StatusBadRequest, "INVALID_LATITUDE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := putLocation(t, httpServer.URL, tt.eventID, tt.skaterID, tt.body)
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+			if tt.code == "" {
+				return
+			}
+
+			var body validationError
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode error body: %v", err)
+			}
+			if body.Code != tt.code {
+				t.Errorf("expected error code %s, got %s", tt.code, body.Code)
+			}
+		})
+	}
+}
+
+func TestStream_SendsStoredAndLiveLocations(t *testing.T) {
+	_, httpServer := newTestServer(t, testConfig())
+
+	eventID := uuid.New().String()
+	stored := uuid.New().String()
+	live := uuid.New().String()
+
+	putLocation(t, httpServer.URL, eventID, stored, `{"coordinates":[-0.1,51.5]}`)
+	putLocation(t, httpServer.URL, uuid.New().String(), uuid.New().String(), `{"coordinates":[1,1]}`)
+
+	conn := dialStream(t, httpServer.URL, eventID)
+
+	batch := readBatch(t, conn)
+	if len(batch.Locations) != 1 || batch.Locations[0].SkaterID != stored {
+		t.Fatalf("expected stored location for %s, got %+v", stored, batch.Locations)
+	}
+	if batch.Locations[0].Longitude != -0.1 || batch.Locations[0].Latitude != 51.5 {
+		t.Errorf("unexpected coordinates: %+v", batch.Locations[0])
+	}
+	if batch.ServerTime < batch.Locations[0].Timestamp {
+		t.Errorf("expected server time %d to be after the location timestamp %d", batch.ServerTime, batch.Locations[0].Timestamp)
+	}
+
+	putLocation(t, httpServer.URL, eventID, live, `{"coordinates":[-0.2,51.6]}`)
+
+	batch = readBatch(t, conn)
+	if len(batch.Locations) != 1 || batch.Locations[0].SkaterID != live {
+		t.Errorf("expected live location for %s, got %+v", live, batch.Locations)
+	}
+}
+
+func TestStream_LimitsBatchSize(t *testing.T) {
+	config := testConfig()
+	config.BatchSize = 2
+	_, httpServer := newTestServer(t, config)
+
+	eventID := uuid.New().String()
+	for i := 0; i < 5; i++ {
+		putLocation(t, httpServer.URL, eventID, uuid.New().String(), `{"coordinates":[0,0]}`)
+	}
+
+	conn := dialStream(t, httpServer.URL, eventID)
+
+	var sizes []int
+	for total := 0; total < 5; {
+		batch := readBatch(t, conn)
+		sizes = append(sizes, len(batch.Locations))
+		total += len(batch.Locations)
+	}
+
+	expected := []int{2, 2, 1}
+	if len(sizes) != len(expected) {
+		t.Fatalf("expected batch sizes %v, got %v", expected, sizes)
+	}
+	for i := range expected {
+		if sizes[i] != expected[i] {
+			t.Errorf("expected batch sizes %v, got %v", expected, sizes)
+			break
+		}
+	}
+}
+
+func TestStream_InvalidEventID(t *testing.T) {
+	_, httpServer := newTestServer(t, testConfig())
+
+	wsURL := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/skatingEvents/not-a-uuid/stream"
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err == nil {
+		t.Fatal("expected dial to fail")
+	}
+	if resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %v", resp)
+	}
+}
+
+func TestCleanup_ExpiresLocations(t *testing.T) {
+	config := testConfig()
+	config.LocationTTL = 50 * time.Millisecond
+	config.CleanupInterval = 10 * time.Millisecond
+	server, httpServer := newTestServer(t, config)
+
+	eventID := uuid.New().String()
+	putLocation(t, httpServer.URL, eventID, uuid.New().String(), `{"coordinates":[0,0]}`)
+
+	if got := len(server.store.getAll(eventID)); got != 1 {
+		t.Fatalf("expected 1 stored location, got %d", got)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(server.store.getAll(eventID)) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("location was not removed after its TTL")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClose_DisconnectsStreams(t *testing.T) {
+	server, httpServer := newTestServer(t, testConfig())
+
+	conn := dialStream(t, httpServer.URL, uuid.New().String())
+
+	server.Close()
+
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("failed to set read deadline: %v", err)
+	}
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("expected going away close, got %v", err)
+	}
+}
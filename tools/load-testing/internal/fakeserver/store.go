@@ -0,0 +1,81 @@
+package fakeserver
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Location is a skater's position as sent to stream subscribers.
+// Timestamp is the time the server accepted the update, in Unix milliseconds.
+type Location struct {
+	SkaterID  string  `json:"skaterId"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// LocationBatch is a single stream message.
+type LocationBatch struct {
+	Locations  []Location `json:"locations"`
+	ServerTime int64      `json:"serverTime"`
+}
+
+// store holds the latest location of each skater in each event. As in the real
+// server, expired locations are only removed when cleanup runs, so a location
+// can outlive the TTL by up to one cleanup interval.
+type store struct {
+	mu     sync.Mutex
+	events map[string]map[string]Location
+}
+
+func newStore() *store {
+	return &store{events: make(map[string]map[string]Location)}
+}
+
+func (s *store) put(eventID string, location Location) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	skaters, ok := s.events[eventID]
+	if !ok {
+		skaters = make(map[string]Location)
+		s.events[eventID] = skaters
+	}
+	skaters[location.SkaterID] = location
+}
+
+// getAll returns the stored locations for an event, ordered by skater ID.
+func (s *store) getAll(eventID string) []Location {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	skaters := s.events[eventID]
+	locations := make([]Location, 0, len(skaters))
+	for _, location := range skaters {
+		locations = append(locations, location)
+	}
+	sort.Slice(locations, func(i, j int) bool { return locations[i].SkaterID < locations[j].SkaterID })
+	return locations
+}
+
+// cleanup removes locations accepted at or before cutoff and returns how many were removed.
+func (s *store) cleanup(cutoff time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoffMillis := cutoff.UnixMilli()
+	removed := 0
+	for eventID, skaters := range s.events {
+		for skaterID, location := range skaters {
+			if location.Timestamp <= cutoffMillis {
+				delete(skaters, skaterID)
+				removed++
+			}
+		}
+		if len(skaters) == 0 {
+			delete(s.events, eventID)
+		}
+	}
+	return removed
+}
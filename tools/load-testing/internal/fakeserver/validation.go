@@ -0,0 +1,104 @@
+package fakeserver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const (
+	minLongitude = -180.0
+	maxLongitude = 180.0
+	minLatitude  = -90.0
+	maxLatitude  = 90.0
+)
+
+// validationError is the 400 response body, with the same codes and
+// messages as the real API's ValidationError types.
+type validationError struct {
+	Code    string         `json:"error"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+var (
+	errInvalidSkatingEventID = &validationError{
+		Code:    "INVALID_SKATING_EVENT_ID",
+		Message: "Skating event ID must be a valid UUID",
+	}
+	errInvalidSkaterID = &validationError{
+		Code:    "INVALID_SKATER_ID",
+		Message: "Skater ID must be a valid UUID",
+	}
+	errInvalidJSON = &validationError{
+		Code:    "INVALID_JSON",
+		Message: "Request body must be valid JSON",
+	}
+	errMissingCoordinates = &validationError{
+		Code:    "MISSING_COORDINATES",
+		Message: "Request must contain 'coordinates' field with array of numbers",
+	}
+	errInvalidCoordinatesLength = &validationError{
+		Code:    "INVALID_COORDINATES_LENGTH",
+		Message: "Coordinates array must contain exactly 2 numbers [longitude, latitude]",
+	}
+)
+
+func invalidLongitude(value float64) *validationError {
+	return &validationError{
+		Code:    "INVALID_LONGITUDE",
+		Message: "Longitude must be between -180.0 and 180.0",
+		Details: map[string]any{"field": "coordinates[0]", "value": value, "constraint": "range(-180.0, 180.0)"},
+	}
+}
+
+func invalidLatitude(value float64) *validationError {
+	return &validationError{
+		Code:    "INVALID_LATITUDE",
+		Message: "Latitude must be between -90.0 and 90.0",
+		Details: map[string]any{"field": "coordinates[1]", "value": value, "constraint": "range(-90.0, 90.0)"},
+	}
+}
+
+// validateUpdate checks a location update in the same order as the real API:
+// IDs, then the presence and length of the coordinates, then their bounds.
+func validateUpdate(eventID, skaterID string, r *http.Request) (longitude, latitude float64, verr *validationError) {
+	if _, err := uuid.Parse(eventID); err != nil {
+		return 0, 0, errInvalidSkatingEventID
+	}
+	if _, err := uuid.Parse(skaterID); err != nil {
+		return 0, 0, errInvalidSkaterID
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return 0, 0, errInvalidJSON
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return 0, 0, errInvalidJSON
+	}
+
+	var coordinates []float64
+	raw, ok := fields["coordinates"]
+	if !ok || json.Unmarshal(raw, &coordinates) != nil || coordinates == nil {
+		return 0, 0, errMissingCoordinates
+	}
+
+	if len(coordinates) != 2 {
+		return 0, 0, errInvalidCoordinatesLength
+	}
+
+	longitude, latitude = coordinates[0], coordinates[1]
+	if longitude < minLongitude || longitude > maxLongitude {
+		return 0, 0, invalidLongitude(longitude)
+	}
+	if latitude < minLatitude || latitude > maxLatitude {
+		return 0, 0, invalidLatitude(latitude)
+	}
+
+	return longitude, latitude, nil
+}
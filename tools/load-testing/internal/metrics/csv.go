@@ -0,0 +1,177 @@
+package metrics
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewCSVSink creates a Sink that writes events of the given schema to the
+// named file as CSV. The file is created (or truncated) and the header, the
+// schema's column names, is written immediately. Durations are written in
+// milliseconds with 2 decimal places, and lists are separated by "|".
+func NewCSVSink(filename string, schema *Schema, opts ...SinkOption) (Sink, error) {
+	return newFileSink(FormatCSV, filename, schema, newCSVEncoder, opts)
+}
+
+type csvEncoder struct {
+	writer *csv.Writer
+	schema *Schema
+}
+
+func newCSVEncoder(w io.Writer, schema *Schema) (encoder, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(schema.ColumnNames()); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	return &csvEncoder{writer: writer, schema: schema}, nil
+}
+
+func (e *csvEncoder) encode(values []any) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = formatCSV(e.schema.Columns[i].Type, v)
+	}
+	return e.writer.Write(record)
+}
+
+func (e *csvEncoder) flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvEncoder) close() error {
+	return e.flush()
+}
+
+// formatCSV formats a single value of a column of type t.
+func formatCSV(t ColumnType, v any) string {
+	if v == nil {
+		return ""
+	}
+
+	switch t {
+	case IntColumn:
+		return strconv.Itoa(v.(int))
+	case FloatColumn:
+		return fmt.Sprintf("%.2f", v.(float64))
+	case TimeColumn:
+		return v.(time.Time).Format(time.RFC3339)
+	case StringListColumn:
+		return strings.Join(v.([]string), "|")
+	case FloatListColumn:
+		values := v.([]float64)
+		parts := make([]string, len(values))
+		for i, f := range values {
+			if f != 0 {
+				parts[i] = fmt.Sprintf("%.2f", f)
+			}
+		}
+		return strings.Join(parts, "|")
+	default:
+		return v.(string)
+	}
+}
+
+type csvDecoder struct {
+	file   io.ReadCloser
+	reader *csv.Reader
+	schema *Schema
+}
+
+func newCSVDecoder(seg segmentFile, schema *Schema) (decoder, error) {
+	file, err := openSegmentFile(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read the header of %s: %w", seg.path, err)
+	}
+	if !slices.Equal(header, schema.ColumnNames()) {
+		file.Close()
+		return nil, fmt.Errorf("%s does not have the columns of a %s metrics file", seg.path, schema.Name)
+	}
+
+	return &csvDecoder{file: file, reader: reader, schema: schema}, nil
+}
+
+func (d *csvDecoder) decode() ([]any, error) {
+	record, err := d.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]any, len(record))
+	for i, field := range record {
+		c := d.schema.Columns[i]
+		if values[i], err = parseCSV(c.Type, field); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", c.Name, field, err)
+		}
+	}
+	return values, nil
+}
+
+func (d *csvDecoder) close() error {
+	return d.file.Close()
+}
+
+// parseCSV parses a single value of a column of type t, as formatted by
+// formatCSV.
+func parseCSV(t ColumnType, s string) (any, error) {
+	switch t {
+	case StringColumn:
+		return s, nil
+	case StringListColumn:
+		if s == "" {
+			return []string(nil), nil
+		}
+		return strings.Split(s, "|"), nil
+	case FloatListColumn:
+		if s == "" {
+			return []float64(nil), nil
+		}
+		parts := strings.Split(s, "|")
+		values := make([]float64, len(parts))
+		for i, part := range parts {
+			if part == "" {
+				continue
+			}
+			f, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = f
+		}
+		return values, nil
+	}
+
+	if s == "" {
+		if t == TimeColumn {
+			return nil, fmt.Errorf("missing timestamp")
+		}
+		return nil, nil
+	}
+	switch t {
+	case IntColumn:
+		return strconv.Atoi(s)
+	case FloatColumn:
+		return strconv.ParseFloat(s, 64)
+	case TimeColumn:
+		return time.Parse(time.RFC3339, s)
+	default:
+		return nil, fmt.Errorf("unknown column type %d", t)
+	}
+}
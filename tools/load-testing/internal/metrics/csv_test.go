@@ -0,0 +1,414 @@
+package metrics
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"load-testing/internal/skater"
+	"load-testing/internal/viewer"
+)
+
+func TestNewCSVSink_Skater(t *testing.T) {
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "test-metrics.csv")
+
+	w, err := NewCSVSink(filename, SkaterSchema)
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	defer w.Close()
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	expectedHeader := "timestamp,event_id,skater_id,response_time_ms,schedule_lag_ms,status_code,trace_id,error_kind,error\n"
+	if string(content) != expectedHeader {
+		t.Errorf("expected header %q, got %q", expectedHeader, string(content))
+	}
+}
+
+func TestCSVSink_SkaterResult(t *testing.T) {
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "test-metrics.csv")
+
+	w, err := NewCSVSink(filename, SkaterSchema)
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+
+	timestamp := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	result := skater.UpdateResult{
+		EventID:      "event-123",
+		SkaterID:     "skater-456",
+		Timestamp:    timestamp,
+		ResponseTime: 150 * time.Millisecond,
+		TraceID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+		Error:        nil,
+	}
+
+	err = w.Write(SkaterEvent(result))
+	if err != nil {
+		t.Errorf("failed to write result: %v", err)
+	}
+
+	w.Close()
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	lines := strings.Split(string(content), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("expected at least 2 lines, got %d", len(lines))
+	}
+
+	expectedFields := []string{
+		timestamp.Format(time.RFC3339),
+		"event-123",
+		"skater-456",
+		"150.00",
+		"",
+		"202",
+		"4bf92f3577b34da6a3ce929d0e0e4736",
+		"",
+		"",
+	}
+
+	dataLine := lines[1]
+	fields := strings.Split(dataLine, ",")
+
+	if len(fields) != len(expectedFields) {
+		t.Errorf("expected %d fields, got %d", len(expectedFields), len(fields))
+	}
+
+	for i, expected := range expectedFields {
+		if i < len(fields) && fields[i] != expected {
+			t.Errorf("field %d: expected %q, got %q", i, expected, fields[i])
+		}
+	}
+}
+
+func TestCSVSink_SkaterError(t *testing.T) {
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "test-metrics.csv")
+
+	w, err := NewCSVSink(filename, SkaterSchema)
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+
+	timestamp := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	result := skater.UpdateResult{
+		EventID:      "event-123",
+		SkaterID:     "skater-456",
+		Timestamp:    timestamp,
+		ResponseTime: 0,
+		Error:        fmt.Errorf("connection timeout"),
+	}
+
+	err = w.Write(SkaterEvent(result))
+	if err != nil {
+		t.Errorf("failed to write result: %v", err)
+	}
+
+	w.Close()
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	if !strings.Contains(string(content), "connection timeout") {
+		t.Error("expected error message in CSV output")
+	}
+}
+
+func TestCSVSink_Concurrent(t *testing.T) {
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "test-metrics.csv")
+
+	w, err := NewCSVSink(filename, SkaterSchema)
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	defer w.Close()
+
+	numWrites := 100
+	done := make(chan bool, numWrites)
+
+	for i := 0; i < numWrites; i++ {
+		go func(n int) {
+			result := skater.UpdateResult{
+				EventID:      fmt.Sprintf("event-%d", n),
+				SkaterID:     fmt.Sprintf("skater-%d", n),
+				Timestamp:    time.Now(),
+				ResponseTime: time.Duration(n) * time.Millisecond,
+				Error:        nil,
+			}
+			w.Write(SkaterEvent(result))
+			done <- true
+		}(i)
+	}
+
+	for i := 0; i < numWrites; i++ {
+		<-done
+	}
+
+	w.Close()
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	lines := strings.Split(string(content), "\n")
+	dataLines := 0
+	for _, line := range lines {
+		if line != "" && !strings.HasPrefix(line, "timestamp") {
+			dataLines++
+		}
+	}
+
+	if dataLines != numWrites {
+		t.Errorf("expected %d data lines, got %d", numWrites, dataLines)
+	}
+}
+
+func TestNewCSVSink_Viewer(t *testing.T) {
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "test-metrics.csv")
+
+	writer, err := NewCSVSink(filename, ViewerSchema)
+	if err != nil {
+		t.Fatalf("NewCSVSink() error = %v", err)
+	}
+	defer writer.Close()
+
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		t.Error("Expected file to be created")
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		t.Fatalf("Failed to read header: %v", err)
+	}
+
+	expectedHeader := []string{"timestamp", "event_id", "viewer_number", "message_count", "latency_ms", "skater_ids", "end_to_end_ms", "bytes", "handshake_ms", "upgrade_status", "first_message_ms", "session_ms", "leave", "reconnect_attempts", "downtime_ms", "missed_messages", "missed_locations", "error_kind", "error"}
+	if len(header) != len(expectedHeader) {
+		t.Fatalf("Expected %d columns, got %d", len(expectedHeader), len(header))
+	}
+
+	for i, expected := range expectedHeader {
+		if header[i] != expected {
+			t.Errorf("Column %d: expected '%s', got '%s'", i, expected, header[i])
+		}
+	}
+}
+
+func TestCSVSink_ViewerResult(t *testing.T) {
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "test-metrics.csv")
+
+	writer, err := NewCSVSink(filename, ViewerSchema)
+	if err != nil {
+		t.Fatalf("NewCSVSink() error = %v", err)
+	}
+
+	timestamp := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	result := viewer.ViewerResult{
+		EventID:      "test-event",
+		ViewerNumber: 42,
+		Timestamp:    timestamp,
+		MessageCount: 10,
+		Latency:      150 * time.Millisecond,
+		SkaterIDs:    []string{"skater1", "skater2"},
+		EndToEnd:     []time.Duration{0, 812310 * time.Microsecond},
+		Bytes:        2048,
+		Error:        nil,
+	}
+
+	if err := writer.Write(ViewerEvent(result)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	writer.Close()
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records (header + data), got %d", len(records))
+	}
+
+	record := records[1]
+	if record[0] != "2024-01-01T12:00:00Z" {
+		t.Errorf("Expected timestamp '2024-01-01T12:00:00Z', got '%s'", record[0])
+	}
+	if record[1] != "test-event" {
+		t.Errorf("Expected event_id 'test-event', got '%s'", record[1])
+	}
+	if record[2] != "42" {
+		t.Errorf("Expected viewer_number '42', got '%s'", record[2])
+	}
+	if record[3] != "10" {
+		t.Errorf("Expected message_count '10', got '%s'", record[3])
+	}
+	if record[4] != "150.00" {
+		t.Errorf("Expected latency_ms '150.00', got '%s'", record[4])
+	}
+	if record[5] != "skater1|skater2" {
+		t.Errorf("Expected skater_ids 'skater1|skater2', got '%s'", record[5])
+	}
+	if record[6] != "|812.31" {
+		t.Errorf("Expected end_to_end_ms '|812.31', got '%s'", record[6])
+	}
+	if record[7] != "2048" {
+		t.Errorf("Expected bytes '2048', got '%s'", record[7])
+	}
+	for i := 8; i < 17; i++ {
+		if record[i] != "" {
+			t.Errorf("Expected empty connection column %d, got '%s'", i, record[i])
+		}
+	}
+	if record[17] != "" {
+		t.Errorf("Expected empty error, got '%s'", record[17])
+	}
+}
+
+func TestCSVSink_ViewerResultWithError(t *testing.T) {
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "test-metrics.csv")
+
+	writer, err := NewCSVSink(filename, ViewerSchema)
+	if err != nil {
+		t.Fatalf("NewCSVSink() error = %v", err)
+	}
+
+	result := viewer.ViewerResult{
+		EventID:      "test-event",
+		ViewerNumber: 1,
+		Timestamp:    time.Now(),
+		MessageCount: 5,
+		Latency:      0,
+		SkaterIDs:    []string{},
+		Error:        &testError{"connection failed"},
+	}
+
+	if err := writer.Write(ViewerEvent(result)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	writer.Close()
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records (header + data), got %d", len(records))
+	}
+
+	if kind := records[1][17]; kind != ErrorKindOther {
+		t.Errorf("Expected error kind '%s', got '%s'", ErrorKindOther, kind)
+	}
+
+	errorStr := records[1][18]
+	if errorStr != "connection failed" {
+		t.Errorf("Expected error 'connection failed', got '%s'", errorStr)
+	}
+}
+
+func TestCSVSink_ViewerConnectionEvents(t *testing.T) {
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "test-metrics.csv")
+
+	writer, err := NewCSVSink(filename, ViewerSchema)
+	if err != nil {
+		t.Fatalf("NewCSVSink() error = %v", err)
+	}
+
+	results := []viewer.ViewerResult{
+		{Handshake: &viewer.Handshake{Duration: 12345 * time.Microsecond, Status: 101}},
+		{Handshake: &viewer.Handshake{Duration: 3 * time.Millisecond}},
+		{Connect: &viewer.Connect{Number: 1}},
+		{FirstMessage: &viewer.FirstMessage{Wait: 750 * time.Millisecond}},
+		{Leave: &viewer.Leave{Session: 90 * time.Second, Abrupt: true}},
+		{Reconnect: &viewer.Reconnect{Attempts: 3, Downtime: 2500 * time.Millisecond, MissedMessages: 4, MissedLocations: 12}},
+	}
+	for _, result := range results {
+		result.EventID = "test-event"
+		result.Timestamp = time.Now()
+		if err := writer.Write(ViewerEvent(result)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	writer.Close()
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+
+	expected := [][]string{
+		{"12.35", "101", "", "", "", "", "", "", "", "", ""},
+		{"3.00", "", "", "", "", "", "", "", "", "", ""},
+		{"", "", "", "", "", "", "", "", "", "", ""},
+		{"", "", "750.00", "", "", "", "", "", "", "", ""},
+		{"", "", "", "90000.00", "abrupt", "", "", "", "", "", ""},
+		{"", "", "", "", "", "3", "2500.00", "4", "12", "", ""},
+	}
+	for row, columns := range expected {
+		for i, want := range columns {
+			if got := records[row+1][8+i]; got != want {
+				t.Errorf("Row %d column %s: expected '%s', got '%s'", row+1, records[0][8+i], want, got)
+			}
+		}
+	}
+}
+
+type testError struct {
+	msg string
+}
+
+func (e *testError) Error() string {
+	return e.msg
+}
@@ -0,0 +1,369 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"load-testing/internal/correlation"
+	"load-testing/internal/viewer"
+)
+
+// Defaults matching services/api/src/main/resources/application.conf.
+const (
+	DefaultLocationTTL     = 30 * time.Second
+	DefaultCleanupInterval = 10 * time.Second
+	DefaultDeliveryGrace   = 2 * time.Second
+)
+
+// maxMissingListed is how many missing skaters the text summary names.
+const maxMissingListed = 10
+
+// DeliveryConfig configures how a DeliveryChecker decides which skaters a
+// viewer should see.
+type DeliveryConfig struct {
+	// LocationTTL is how long the API keeps a location after its last update.
+	LocationTTL time.Duration
+	// CleanupInterval is how often the API removes expired locations, so a
+	// location can outlive its TTL by up to this long.
+	CleanupInterval time.Duration
+	// Grace is how long a sent location may take to reach viewers before a
+	// viewer that has not seen it counts as missing it.
+	Grace time.Duration
+}
+
+// DefaultDeliveryConfig returns the configuration matching the API's defaults.
+func DefaultDeliveryConfig() DeliveryConfig {
+	return DeliveryConfig{
+		LocationTTL:     DefaultLocationTTL,
+		CleanupInterval: DefaultCleanupInterval,
+		Grace:           DefaultDeliveryGrace,
+	}
+}
+
+// SkaterDeliveryReport describes how a single expected skater reached viewers.
+// MaxStaleness is the longest any viewer of its event went without seeing it
+// while it was still sending updates.
+type SkaterDeliveryReport struct {
+	EventID        string  `json:"event_id"`
+	SkaterID       string  `json:"skater_id"`
+	ViewersMissing int     `json:"viewers_missing"`
+	LateLocations  int     `json:"late_locations"`
+	MaxStaleness   float64 `json:"max_staleness_ms"`
+}
+
+// DeliveryReport describes whether viewers received every skater they should
+// have. ExpectedSkaters counts skaters at least one viewer should have seen,
+// and MissingSkaters those that at least one such viewer never received;
+// MissedDeliveries counts each such viewer and skater pair. LateLocations
+// counts locations received after the skater's last update should have
+// expired. Staleness is the time between a viewer seeing the same skater
+// twice. Skaters lists expected skaters and those with late locations,
+// ordered by event and skater ID.
+type DeliveryReport struct {
+	ExpectedSkaters  int                    `json:"expected_skaters"`
+	MissingSkaters   int                    `json:"missing_skaters"`
+	MissedDeliveries int                    `json:"missed_deliveries"`
+	LateLocations    int                    `json:"late_locations"`
+	Staleness        LatencyStats           `json:"staleness"`
+	Skaters          []SkaterDeliveryReport `json:"skaters"`
+}
+
+// expectedSkater is a skater that viewers of its event should see: either
+// listed in a manifest, and so expected throughout the run, or seen sending
+// updates between firstSent and lastSent.
+type expectedSkater struct {
+	listed        bool
+	firstSent     time.Time
+	lastSent      time.Time
+	lateLocations int
+	maxStaleness  time.Duration
+}
+
+func (k *expectedSkater) sent() bool {
+	return !k.firstSent.IsZero()
+}
+
+// viewerDelivery is what a single viewer has seen. end is zero while the
+// viewer is connected.
+type viewerDelivery struct {
+	eventID  string
+	end      time.Time
+	lastSeen map[string]time.Time
+}
+
+// DeliveryChecker checks that viewers receive every skater they should see.
+// It learns which skaters to expect from the updates skaters send, published
+// to it directly or through a correlation log, or from a manifest, and which
+// skaters each viewer saw from viewer results.
+//
+// A skater that sent updates is expected by a viewer if one of them was still
+// alive while the viewer was connected and was sent at least Grace before the
+// viewer stopped. A listed skater is expected by every viewer of its event.
+// Skaters and viewers must share a clock, as for end-to-end latency.
+// It is safe for concurrent use.
+type DeliveryChecker struct {
+	config DeliveryConfig
+
+	mu        sync.Mutex
+	start     time.Time
+	end       time.Time
+	skaters   map[string]map[string]*expectedSkater
+	viewers   map[int]*viewerDelivery
+	staleness *Histogram
+	late      int
+}
+
+// NewDeliveryChecker creates a DeliveryChecker for viewers connected from start.
+func NewDeliveryChecker(config DeliveryConfig, start time.Time) *DeliveryChecker {
+	return &DeliveryChecker{
+		config:    config,
+		start:     start,
+		skaters:   make(map[string]map[string]*expectedSkater),
+		viewers:   make(map[int]*viewerDelivery),
+		staleness: NewHistogram(),
+	}
+}
+
+func (c *DeliveryChecker) skater(eventID, skaterID string) *expectedSkater {
+	event, ok := c.skaters[eventID]
+	if !ok {
+		event = make(map[string]*expectedSkater)
+		c.skaters[eventID] = event
+	}
+	k, ok := event[skaterID]
+	if !ok {
+		k = &expectedSkater{}
+		event[skaterID] = k
+	}
+	return k
+}
+
+// Publish records that a skater sent an update, so it implements
+// correlation.Publisher.
+func (c *DeliveryChecker) Publish(u correlation.Update) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := c.skater(u.EventID, u.SkaterID)
+	if !k.sent() || u.SentAt.Before(k.firstSent) {
+		k.firstSent = u.SentAt
+	}
+	if u.SentAt.After(k.lastSent) {
+		k.lastSent = u.SentAt
+	}
+}
+
+// ExpectManifest expects every skater listed in m throughout the run.
+func (c *DeliveryChecker) ExpectManifest(m *correlation.Manifest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, event := range m.Events {
+		for _, skaterID := range event.Skaters {
+			c.skater(event.ID, skaterID).listed = true
+		}
+	}
+}
+
+// ExpectViewer registers a viewer before it produces any results, so that a
+// viewer which never receives a message is still checked.
+func (c *DeliveryChecker) ExpectViewer(eventID string, viewerNumber int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.viewer(eventID, viewerNumber)
+}
+
+func (c *DeliveryChecker) viewer(eventID string, viewerNumber int) *viewerDelivery {
+	v, ok := c.viewers[viewerNumber]
+	if !ok {
+		v = &viewerDelivery{eventID: eventID, lastSeen: make(map[string]time.Time)}
+		c.viewers[viewerNumber] = v
+	}
+	return v
+}
+
+// Record adds the skaters a viewer received in a single result. An error
+// other than a malformed batch, or a churning viewer leaving, means the viewer
+// has stopped, so it is not expected to see skaters after that, unless it
+// connects again. A viewer that connects again is expected to catch up from
+// the stored locations the API sends to new connections, so the gap counts as
+// staleness rather than excusing it.
+func (c *DeliveryChecker) Record(result viewer.ViewerResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v := c.viewer(result.EventID, result.ViewerNumber)
+	switch {
+	case result.Handshake != nil, result.FirstMessage != nil:
+		return
+	case result.Connect != nil, result.Reconnect != nil:
+		v.end = time.Time{}
+		return
+	case result.Leave != nil:
+		if v.end.IsZero() {
+			v.end = result.Timestamp
+		}
+		return
+	}
+	if result.Error != nil {
+		if !errors.Is(result.Error, viewer.ErrMalformedBatch) && v.end.IsZero() {
+			v.end = result.Timestamp
+		}
+		return
+	}
+
+	receivedAt := result.Timestamp
+	expiry := c.config.LocationTTL + c.config.CleanupInterval
+	for _, skaterID := range result.SkaterIDs {
+		k := c.skater(result.EventID, skaterID)
+		if k.sent() && k.lastSent.Before(receivedAt) && receivedAt.Sub(k.lastSent) > expiry {
+			k.lateLocations++
+			c.late++
+		}
+
+		if lastSeen, ok := v.lastSeen[skaterID]; ok && receivedAt.After(lastSeen) {
+			k.observeStaleness(c.staleness, receivedAt.Sub(lastSeen))
+		}
+		if receivedAt.After(v.lastSeen[skaterID]) {
+			v.lastSeen[skaterID] = receivedAt
+		}
+	}
+}
+
+func (k *expectedSkater) observeStaleness(h *Histogram, d time.Duration) {
+	h.Record(d)
+	if d > k.maxStaleness {
+		k.maxStaleness = d
+	}
+}
+
+// Finish marks the end of the run. Viewers still connected are checked up to this time.
+func (c *DeliveryChecker) Finish(end time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.end = end
+}
+
+// expected reports whether a viewer connected from the start of the run until
+// end should have seen k.
+func (c *DeliveryChecker) expected(k *expectedSkater, end time.Time) bool {
+	if end.Sub(c.start) <= c.config.Grace {
+		return false
+	}
+	if k.listed {
+		return true
+	}
+	return k.sent() &&
+		!k.firstSent.After(end.Add(-c.config.Grace)) &&
+		!k.lastSent.Add(c.config.LocationTTL).Before(c.start)
+}
+
+// Report returns the delivery statistics. Skaters are only counted as missing,
+// and trailing staleness only included, once Finish has been called, as
+// until then viewers may still receive them.
+func (c *DeliveryChecker) Report() DeliveryReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	staleness := NewHistogram()
+	staleness.Merge(c.staleness)
+
+	expectedBy := make(map[*expectedSkater]int)
+	missing := make(map[*expectedSkater]int)
+	trailing := make(map[*expectedSkater]time.Duration)
+	if !c.end.IsZero() {
+		for _, v := range c.viewers {
+			end := v.end
+			if end.IsZero() || end.After(c.end) {
+				end = c.end
+			}
+			for skaterID, k := range c.skaters[v.eventID] {
+				if !c.expected(k, end) {
+					continue
+				}
+				expectedBy[k]++
+				lastSeen, seen := v.lastSeen[skaterID]
+				if !seen {
+					missing[k]++
+					continue
+				}
+				// A skater still sending when the viewer stopped has been stale
+				// since it was last seen, if the viewer should have seen a newer update.
+				if k.listed || (k.lastSent.After(lastSeen) && !k.lastSent.After(end.Add(-c.config.Grace))) {
+					d := end.Sub(lastSeen)
+					staleness.Record(d)
+					if d > trailing[k] {
+						trailing[k] = d
+					}
+				}
+			}
+		}
+	}
+
+	report := DeliveryReport{
+		LateLocations: c.late,
+		Staleness:     latencyStats(staleness),
+		Skaters:       []SkaterDeliveryReport{},
+	}
+	for _, eventID := range sortedKeys(c.skaters) {
+		for _, skaterID := range sortedKeys(c.skaters[eventID]) {
+			k := c.skaters[eventID][skaterID]
+			if expectedBy[k] == 0 && k.lateLocations == 0 {
+				continue
+			}
+			maxStaleness := k.maxStaleness
+			if trailing[k] > maxStaleness {
+				maxStaleness = trailing[k]
+			}
+			if expectedBy[k] > 0 {
+				report.ExpectedSkaters++
+			}
+			report.MissedDeliveries += missing[k]
+			if missing[k] > 0 {
+				report.MissingSkaters++
+			}
+			report.Skaters = append(report.Skaters, SkaterDeliveryReport{
+				EventID:        eventID,
+				SkaterID:       skaterID,
+				ViewersMissing: missing[k],
+				LateLocations:  k.lateLocations,
+				MaxStaleness:   milliseconds(maxStaleness),
+			})
+		}
+	}
+	return report
+}
+
+// WriteText writes a human-readable summary of the delivery check.
+func (r DeliveryReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Delivery: %d skaters expected, %d missing (%d missed deliveries), %d late locations\n",
+		r.ExpectedSkaters, r.MissingSkaters, r.MissedDeliveries, r.LateLocations)
+	if r.Staleness.Count > 0 {
+		fmt.Fprintf(&b, "Staleness: %s\n", r.Staleness)
+	}
+
+	var missing []string
+	for _, k := range r.Skaters {
+		if k.ViewersMissing > 0 {
+			missing = append(missing, fmt.Sprintf("%s/%s (%d viewers)", k.EventID, k.SkaterID, k.ViewersMissing))
+		}
+	}
+	if len(missing) > 0 {
+		more := ""
+		if len(missing) > maxMissingListed {
+			more = fmt.Sprintf(" and %d more", len(missing)-maxMissingListed)
+			missing = missing[:maxMissingListed]
+		}
+		fmt.Fprintf(&b, "Missing skaters: %s%s\n", strings.Join(missing, ", "), more)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,202 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"load-testing/internal/correlation"
+	"load-testing/internal/viewer"
+)
+
+func publishAt(c *DeliveryChecker, skaterID string, sentAt time.Time) {
+	c.Publish(correlation.Update{EventID: "event-a", SkaterID: skaterID, SentAt: sentAt})
+}
+
+func receiveAt(c *DeliveryChecker, viewerNumber int, receivedAt time.Time, skaterIDs ...string) {
+	c.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: viewerNumber, Timestamp: receivedAt, SkaterIDs: skaterIDs})
+}
+
+func skaterReport(t *testing.T, report DeliveryReport, skaterID string) SkaterDeliveryReport {
+	t.Helper()
+
+	for _, k := range report.Skaters {
+		if k.SkaterID == skaterID {
+			return k
+		}
+	}
+	t.Fatalf("skater %s not in report %+v", skaterID, report.Skaters)
+	return SkaterDeliveryReport{}
+}
+
+func TestDeliveryChecker(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	at := func(seconds float64) time.Time { return start.Add(time.Duration(seconds * float64(time.Second))) }
+
+	c := NewDeliveryChecker(DefaultDeliveryConfig(), start)
+	c.ExpectViewer("event-a", 1)
+	c.ExpectViewer("event-a", 2)
+
+	// Delivered to both viewers every time.
+	for _, s := range []float64{1, 4, 7} {
+		publishAt(c, "delivered", at(s))
+		receiveAt(c, 1, at(s+0.5), "delivered")
+		receiveAt(c, 2, at(s+0.5), "delivered")
+	}
+	// Never delivered.
+	publishAt(c, "missing", at(1))
+	// Only sent once viewer 2 had stopped, so only viewer 1 misses it.
+	publishAt(c, "after-disconnect", at(10))
+	// Sent too close to the end to have been delivered yet.
+	publishAt(c, "too-late-to-check", at(19))
+	// Expired before the viewers connected.
+	publishAt(c, "expired", at(-45))
+	// Only sent at the start, but received long after it should have expired.
+	publishAt(c, "stale", at(1))
+	receiveAt(c, 1, at(1.5), "stale")
+	receiveAt(c, 2, at(1.5), "stale")
+	// Still sending, but viewer 1 stops seeing it.
+	publishAt(c, "stopped-arriving", at(2))
+	receiveAt(c, 1, at(2.5), "stopped-arriving")
+	receiveAt(c, 2, at(2.5), "stopped-arriving")
+	publishAt(c, "stopped-arriving", at(12))
+	receiveAt(c, 2, at(12.5), "stopped-arriving")
+
+	c.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 2, Timestamp: at(8), Error: viewer.ErrConnectionLost})
+	receiveAt(c, 1, at(50), "stale")
+
+	if before := c.Report(); before.MissingSkaters != 0 || before.MissedDeliveries != 0 {
+		t.Errorf("expected no missing skaters before the run finishes, got %+v", before)
+	}
+
+	c.Finish(at(20))
+	report := c.Report()
+
+	if report.ExpectedSkaters != 5 {
+		t.Errorf("expected 5 skaters, got %d", report.ExpectedSkaters)
+	}
+	if report.MissingSkaters != 2 || report.MissedDeliveries != 3 {
+		t.Errorf("expected 2 missing skaters and 3 missed deliveries, got %d and %d", report.MissingSkaters, report.MissedDeliveries)
+	}
+	if report.LateLocations != 1 {
+		t.Errorf("expected 1 late location, got %d", report.LateLocations)
+	}
+
+	tests := []struct {
+		skaterID     string
+		missing      int
+		late         int
+		maxStaleness float64
+	}{
+		{skaterID: "delivered", maxStaleness: 3000},
+		{skaterID: "missing", missing: 2},
+		{skaterID: "after-disconnect", missing: 1},
+		{skaterID: "stale", late: 1, maxStaleness: 48500},
+		{skaterID: "stopped-arriving", maxStaleness: 17500},
+	}
+	for _, tt := range tests {
+		k := skaterReport(t, report, tt.skaterID)
+		if k.ViewersMissing != tt.missing || k.LateLocations != tt.late || k.MaxStaleness != tt.maxStaleness {
+			t.Errorf("skater %s: expected %d missing, %d late, max staleness %.0fms, got %+v",
+				tt.skaterID, tt.missing, tt.late, tt.maxStaleness, k)
+		}
+	}
+	for _, k := range report.Skaters {
+		if k.SkaterID == "too-late-to-check" || k.SkaterID == "expired" {
+			t.Errorf("skater %s should not have been expected", k.SkaterID)
+		}
+	}
+}
+
+func TestDeliveryChecker_Manifest(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+
+	c := NewDeliveryChecker(DefaultDeliveryConfig(), start)
+	c.ExpectManifest(&correlation.Manifest{Events: []correlation.ManifestEvent{
+		{ID: "event-a", Skaters: []string{"listed-1", "listed-2"}},
+		{ID: "event-b", Skaters: []string{"other-event"}},
+	}})
+	c.ExpectViewer("event-a", 1)
+
+	receiveAt(c, 1, start.Add(time.Second), "listed-1", "unlisted")
+	receiveAt(c, 1, start.Add(4*time.Second), "listed-1", "unlisted")
+	c.Finish(start.Add(10 * time.Second))
+
+	report := c.Report()
+	if report.ExpectedSkaters != 2 {
+		t.Errorf("expected the 2 skaters listed for the viewer's event, got %d", report.ExpectedSkaters)
+	}
+	if report.MissingSkaters != 1 || skaterReport(t, report, "listed-2").ViewersMissing != 1 {
+		t.Errorf("expected only listed-2 to be missing, got %+v", report.Skaters)
+	}
+	if got := skaterReport(t, report, "listed-1").MaxStaleness; got != 6000 {
+		t.Errorf("expected listed-1 to be stale for 6000ms at the end, got %.0fms", got)
+	}
+}
+
+func TestDeliveryChecker_Reconnect(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	at := func(seconds float64) time.Time { return start.Add(time.Duration(seconds * float64(time.Second))) }
+
+	c := NewDeliveryChecker(DefaultDeliveryConfig(), start)
+	c.ExpectViewer("event-a", 1)
+
+	publishAt(c, "skater-1", at(1))
+	receiveAt(c, 1, at(1.5), "skater-1")
+	c.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, Timestamp: at(2), Error: viewer.ErrConnectionLost})
+	c.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, Timestamp: at(3), Error: viewer.ErrConnect})
+	// Sent while the viewer was disconnected, and only seen in the stored
+	// locations sent when it connects again.
+	publishAt(c, "skater-2", at(4))
+	publishAt(c, "skater-1", at(4))
+	c.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, Timestamp: at(6), Reconnect: &viewer.Reconnect{Attempts: 2}})
+	receiveAt(c, 1, at(6.5), "skater-1", "skater-2")
+	// Only sent after the viewer reconnected.
+	publishAt(c, "skater-3", at(10))
+	c.Finish(at(20))
+
+	report := c.Report()
+	if report.ExpectedSkaters != 3 || report.MissingSkaters != 1 {
+		t.Errorf("expected the viewer to be checked after reconnecting, got %+v", report)
+	}
+	if k := skaterReport(t, report, "skater-3"); k.ViewersMissing != 1 {
+		t.Errorf("expected skater-3 to be missing, got %+v", k)
+	}
+	if k := skaterReport(t, report, "skater-1"); k.MaxStaleness != 5000 {
+		t.Errorf("expected skater-1 to be stale for 5000ms across the gap, got %.0fms", k.MaxStaleness)
+	}
+}
+
+func TestDeliveryReport_WriteText(t *testing.T) {
+	report := DeliveryReport{
+		ExpectedSkaters:  12,
+		MissingSkaters:   11,
+		MissedDeliveries: 12,
+		LateLocations:    1,
+		Staleness:        LatencyStats{Count: 10, P50: 3000, P90: 3100, P99: 6000, Max: 6000},
+	}
+	for i := 0; i < 12; i++ {
+		missing := 1
+		if i == 0 {
+			missing = 0
+		}
+		report.Skaters = append(report.Skaters, SkaterDeliveryReport{EventID: "event-a", SkaterID: fmt.Sprintf("skater-%02d", i), ViewersMissing: missing})
+	}
+
+	var b strings.Builder
+	if err := report.WriteText(&b); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"Delivery: 12 skaters expected, 11 missing (12 missed deliveries), 1 late locations\n",
+		"Staleness: p50 3000.00ms, p90 3100.00ms, p99 6000.00ms, max 6000.00ms\n",
+		"Missing skaters: event-a/skater-01 (1 viewers), ",
+		"event-a/skater-10 (1 viewers) and 1 more\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, b.String())
+		}
+	}
+}
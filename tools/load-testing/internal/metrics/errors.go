@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	"load-testing/internal/skater"
+	"load-testing/internal/viewer"
+)
+
+// Error kinds reported in summaries. HTTP status errors are reported as
+// "http_" followed by the status code, e.g. "http_503".
+const (
+	ErrorKindTimeout           = "timeout"
+	ErrorKindConnectionRefused = "connection_refused"
+	ErrorKindConnectionReset   = "connection_reset"
+	ErrorKindDNS               = "dns"
+	ErrorKindInvalidURL        = "invalid_url"
+	ErrorKindConnect           = "connect"
+	ErrorKindDisconnect        = "disconnect"
+	ErrorKindMalformedBatch    = "malformed_batch"
+	ErrorKindSkipped           = "skipped"
+	ErrorKindOther             = "other"
+)
+
+// ErrorKind classifies an error from a skater update or viewer result into a
+// short, stable category suitable for counting. It returns an empty string for nil.
+func ErrorKind(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var statusErr *skater.StatusError
+	if errors.As(err, &statusErr) {
+		return fmt.Sprintf("http_%d", statusErr.StatusCode)
+	}
+
+	switch {
+	case errors.Is(err, skater.ErrSkipped):
+		return ErrorKindSkipped
+	case errors.Is(err, viewer.ErrInvalidURL):
+		return ErrorKindInvalidURL
+	case errors.Is(err, viewer.ErrMalformedBatch):
+		return ErrorKindMalformedBatch
+	case errors.Is(err, viewer.ErrConnectionLost), errors.Is(err, viewer.ErrReadDeadline):
+		return ErrorKindDisconnect
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorKindTimeout
+	}
+
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr):
+		return ErrorKindDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorKindConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return ErrorKindConnectionReset
+	case errors.Is(err, viewer.ErrConnect):
+		return ErrorKindConnect
+	}
+
+	return ErrorKindOther
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"load-testing/internal/fakeserver"
+	"load-testing/internal/skater"
+	"load-testing/internal/viewer"
+
+	"github.com/google/uuid"
+)
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"nil", nil, ""},
+		{"http status", fmt.Errorf("update: %w", &skater.StatusError{StatusCode: 429}), "http_429"},
+		{"invalid url", fmt.Errorf("%w: bad", viewer.ErrInvalidURL), ErrorKindInvalidURL},
+		{"malformed batch", fmt.Errorf("%w: bad json", viewer.ErrMalformedBatch), ErrorKindMalformedBatch},
+		{"connection lost", fmt.Errorf("%w: eof", viewer.ErrConnectionLost), ErrorKindDisconnect},
+		{"read deadline", fmt.Errorf("%w: timeout", viewer.ErrReadDeadline), ErrorKindDisconnect},
+		{"timeout", &net.OpError{Op: "dial", Err: context.DeadlineExceeded}, ErrorKindTimeout},
+		{"dns", &net.DNSError{Err: "no such host", Name: "example.invalid"}, ErrorKindDNS},
+		{"refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, ErrorKindConnectionRefused},
+		{"reset", &net.OpError{Op: "read", Err: syscall.ECONNRESET}, ErrorKindConnectionReset},
+		{"connect", fmt.Errorf("%w: handshake failed", viewer.ErrConnect), ErrorKindConnect},
+		{"skipped", skater.ErrSkipped, ErrorKindSkipped},
+		{"other", errors.New("something else"), ErrorKindOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorKind(tt.err); got != tt.expected {
+				t.Errorf("ErrorKind(%v) = %q, expected %q", tt.err, got, tt.expected)
+			}
+		})
+	}
+}
+
+func newFakeServer(t *testing.T, faults fakeserver.Faults) string {
+	t.Helper()
+
+	config := fakeserver.DefaultConfig()
+	config.BatchInterval = 10 * time.Millisecond
+	config.Faults = faults
+	fake := fakeserver.New(config)
+	server := httptest.NewServer(fake)
+	t.Cleanup(func() {
+		fake.Close()
+		server.Close()
+	})
+	return server.URL
+}
+
+func TestSkaterSummary_InjectedFaults(t *testing.T) {
+	baseURL := newFakeServer(t, fakeserver.Faults{ErrorRate: 0.5, ThrottleRate: 0.5, Seed: 1})
+
+	summary := NewSkaterSummary(time.Now())
+	s := skater.New(uuid.New().String(), uuid.New().String(), baseURL)
+	for i := 0; i < 20; i++ {
+		summary.Record(s.UpdateLocation())
+	}
+
+	report := summary.Report()
+	if report.Errors != 20 {
+		t.Errorf("expected every update to fail, got %d errors", report.Errors)
+	}
+	if report.ErrorsByKind["http_503"] == 0 || report.ErrorsByKind["http_429"] == 0 {
+		t.Errorf("expected both 503 and 429 errors, got %v", report.ErrorsByKind)
+	}
+}
+
+func TestViewerSummary_InjectedFaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		faults fakeserver.Faults
+		kind   string
+	}{
+		{"dropped connection", fakeserver.Faults{DropRate: 1}, ErrorKindDisconnect},
+		{"malformed batch", fakeserver.Faults{MalformedRate: 1}, ErrorKindMalformedBatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseURL := newFakeServer(t, tt.faults)
+
+			eventID := uuid.New().String()
+			if result := skater.New(eventID, uuid.New().String(), baseURL).UpdateLocation(); result.Error != nil {
+				t.Fatalf("failed to send location: %v", result.Error)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			results := make(chan viewer.ViewerResult, 10)
+			var wg sync.WaitGroup
+			v := viewer.New(ctx, eventID, 1, baseURL, results, &wg)
+			wg.Add(1)
+			go v.Start()
+
+			// The connection opening is reported before the fault.
+			summary := NewViewerSummary(time.Now())
+			for summary.Errors() == 0 {
+				select {
+				case result := <-results:
+					summary.Record(result)
+				case <-time.After(2 * time.Second):
+					t.Fatal("timeout waiting for viewer result")
+				}
+			}
+			cancel()
+			wg.Wait()
+
+			report := summary.Report()
+			if report.ErrorsByKind[tt.kind] != 1 {
+				t.Errorf("expected one %s error, got %v", tt.kind, report.ErrorsByKind)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+
+	"load-testing/internal/skater"
+	"load-testing/internal/viewer"
+)
+
+// ColumnType is the type of the values in a Column.
+type ColumnType int
+
+const (
+	// StringColumn values are strings, empty when they do not apply.
+	StringColumn ColumnType = iota
+	// IntColumn values are ints, or nil when they do not apply.
+	IntColumn
+	// FloatColumn values are float64s, or nil when they do not apply.
+	// Durations are in milliseconds.
+	FloatColumn
+	// TimeColumn values are time.Times.
+	TimeColumn
+	// StringListColumn values are []strings.
+	StringListColumn
+	// FloatListColumn values are []float64s, in which zero means no value.
+	FloatListColumn
+)
+
+// Column is a single named field of a Schema.
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Schema describes the columns of one kind of result. The last column is
+// always error, empty for results that succeeded.
+type Schema struct {
+	Name    string
+	Columns []Column
+}
+
+// ColumnNames returns the names of the schema's columns, in order.
+func (s *Schema) ColumnNames() []string {
+	names := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// Index returns the position of the named column, or -1 if the schema has
+// no such column.
+func (s *Schema) Index(name string) int {
+	for i, c := range s.Columns {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Event is a single result in the form every Sink writes: the values of its
+// schema's columns, in order. A new kind of result only needs a Schema and an
+// Event to be written in every output format.
+type Event interface {
+	Schema() *Schema
+	Values() []any
+}
+
+// SkaterSchema describes the location update results of simulated skaters.
+var SkaterSchema = &Schema{
+	Name: "skater",
+	Columns: []Column{
+		{Name: "timestamp", Type: TimeColumn},
+		{Name: "event_id", Type: StringColumn},
+		{Name: "skater_id", Type: StringColumn},
+		{Name: "response_time_ms", Type: FloatColumn},
+		{Name: "schedule_lag_ms", Type: FloatColumn},
+		{Name: "status_code", Type: IntColumn},
+		{Name: "trace_id", Type: StringColumn},
+		{Name: "error_kind", Type: StringColumn},
+		{Name: "error", Type: StringColumn},
+	},
+}
+
+// ViewerSchema describes the results of simulated viewers: received batches,
+// errors and connection events, each leaving the columns of the others empty.
+var ViewerSchema = &Schema{
+	Name: "viewer",
+	Columns: []Column{
+		{Name: "timestamp", Type: TimeColumn},
+		{Name: "event_id", Type: StringColumn},
+		{Name: "viewer_number", Type: IntColumn},
+		{Name: "message_count", Type: IntColumn},
+		{Name: "latency_ms", Type: FloatColumn},
+		{Name: "skater_ids", Type: StringListColumn},
+		{Name: "end_to_end_ms", Type: FloatListColumn},
+		{Name: "bytes", Type: IntColumn},
+		{Name: "handshake_ms", Type: FloatColumn},
+		{Name: "upgrade_status", Type: IntColumn},
+		{Name: "first_message_ms", Type: FloatColumn},
+		{Name: "session_ms", Type: FloatColumn},
+		{Name: "leave", Type: StringColumn},
+		{Name: "reconnect_attempts", Type: IntColumn},
+		{Name: "downtime_ms", Type: FloatColumn},
+		{Name: "missed_messages", Type: IntColumn},
+		{Name: "missed_locations", Type: IntColumn},
+		{Name: "error_kind", Type: StringColumn},
+		{Name: "error", Type: StringColumn},
+	},
+}
+
+// Schemas lists the schemas of the metrics files the simulators write.
+var Schemas = []*Schema{SkaterSchema, ViewerSchema}
+
+type skaterEvent skater.UpdateResult
+
+// SkaterEvent returns the Event for a skater's location update result.
+func SkaterEvent(result skater.UpdateResult) Event {
+	return skaterEvent(result)
+}
+
+func (e skaterEvent) Schema() *Schema {
+	return SkaterSchema
+}
+
+func (e skaterEvent) Values() []any {
+	// A skipped update was never sent, so it has no response time.
+	var responseTime any
+	if !errors.Is(e.Error, skater.ErrSkipped) {
+		responseTime = milliseconds(e.ResponseTime)
+	}
+
+	var lag any
+	if e.ScheduleLag != nil {
+		lag = milliseconds(*e.ScheduleLag)
+	}
+
+	return []any{
+		e.Timestamp,
+		e.EventID,
+		e.SkaterID,
+		responseTime,
+		lag,
+		statusCode(e.Error),
+		e.TraceID,
+		ErrorKind(e.Error),
+		errorString(e.Error),
+	}
+}
+
+type viewerEvent viewer.ViewerResult
+
+// ViewerEvent returns the Event for a viewer result.
+func ViewerEvent(result viewer.ViewerResult) Event {
+	return viewerEvent(result)
+}
+
+func (e viewerEvent) Schema() *Schema {
+	return ViewerSchema
+}
+
+func (e viewerEvent) Values() []any {
+	var endToEnd []float64
+	for _, d := range e.EndToEnd {
+		endToEnd = append(endToEnd, milliseconds(d))
+	}
+
+	var bytes any
+	if e.Bytes > 0 {
+		bytes = e.Bytes
+	}
+
+	var handshake, status, firstMessage any
+	if h := e.Handshake; h != nil {
+		handshake = milliseconds(h.Duration)
+		if h.Status != 0 {
+			status = h.Status
+		}
+	}
+	if f := e.FirstMessage; f != nil {
+		firstMessage = milliseconds(f.Wait)
+	}
+
+	var session any
+	leave := ""
+	if l := e.Leave; l != nil {
+		session = milliseconds(l.Session)
+		leave = "clean"
+		if l.Abrupt {
+			leave = "abrupt"
+		}
+	}
+
+	var attempts, downtime, missedMessages, missedLocations any
+	if r := e.Reconnect; r != nil {
+		attempts = r.Attempts
+		downtime = milliseconds(r.Downtime)
+		missedMessages = r.MissedMessages
+		missedLocations = r.MissedLocations
+	}
+
+	return []any{
+		e.Timestamp,
+		e.EventID,
+		e.ViewerNumber,
+		e.MessageCount,
+		milliseconds(e.Latency),
+		e.SkaterIDs,
+		endToEnd,
+		bytes,
+		handshake,
+		status,
+		firstMessage,
+		session,
+		leave,
+		attempts,
+		downtime,
+		missedMessages,
+		missedLocations,
+		ErrorKind(e.Error),
+		errorString(e.Error),
+	}
+}
+
+// statusCode returns the HTTP status code the API answered a location update
+// with, or nil when no response was received.
+func statusCode(err error) any {
+	if err == nil {
+		return http.StatusAccepted
+	}
+
+	var statusErr *skater.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return nil
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,268 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"load-testing/internal/skater"
+	"load-testing/internal/viewer"
+)
+
+// SkaterMetrics are the live Prometheus metrics published by simulate-skaters.
+// A nil *SkaterMetrics records nothing, so callers need not check whether
+// metrics are enabled.
+type SkaterMetrics struct {
+	registry      *Registry
+	requests      *CounterVec
+	skipped       *CounterVec
+	responseTime  *HistogramVec
+	activeSkaters *GaugeVec
+}
+
+// NewSkaterMetrics registers the skater metrics with r.
+func NewSkaterMetrics(r *Registry) *SkaterMetrics {
+	return &SkaterMetrics{
+		registry: r,
+		requests: r.Counter("skatemap_sim_requests_total",
+			"Location updates sent, by HTTP status code or network error kind.", "status"),
+		skipped: r.Counter("skatemap_sim_updates_skipped_total",
+			"Location updates not sent because they were over the rate limit."),
+		responseTime: r.Histogram("skatemap_sim_response_time_seconds",
+			"Location update response times, including failed updates.", DefaultBuckets),
+		activeSkaters: r.Gauge("skatemap_sim_active_skaters",
+			"Skaters that have joined and not yet left."),
+	}
+}
+
+// Record counts a location update result.
+func (m *SkaterMetrics) Record(result skater.UpdateResult) {
+	if m == nil {
+		return
+	}
+	if errors.Is(result.Error, skater.ErrSkipped) {
+		m.skipped.Inc()
+		return
+	}
+	m.requests.Inc(requestStatus(result.Error))
+	m.responseTime.Observe(result.ResponseTime.Seconds())
+}
+
+// SkaterJoined increments the active skater gauge.
+func (m *SkaterMetrics) SkaterJoined() {
+	if m == nil {
+		return
+	}
+	m.activeSkaters.Add(1)
+}
+
+// SkaterLeft decrements the active skater gauge.
+func (m *SkaterMetrics) SkaterLeft() {
+	if m == nil {
+		return
+	}
+	m.activeSkaters.Add(-1)
+}
+
+// TrackRateLimit publishes the current request rate limit, read from limit at
+// scrape time, so ramp-up progress can be followed.
+func (m *SkaterMetrics) TrackRateLimit(limit func() float64) {
+	if m == nil {
+		return
+	}
+	m.registry.GaugeFunc("skatemap_sim_rate_limit",
+		"Current request rate limit in requests per second.", limit)
+}
+
+// requestStatus returns the status label for an update: the HTTP status code,
+// or the error kind when no response was received.
+func requestStatus(err error) string {
+	if err == nil {
+		return strconv.Itoa(http.StatusAccepted)
+	}
+
+	var statusErr *skater.StatusError
+	if errors.As(err, &statusErr) {
+		return strconv.Itoa(statusErr.StatusCode)
+	}
+	return ErrorKind(err)
+}
+
+// ViewerMetrics are the live Prometheus metrics published by simulate-viewers.
+// A nil *ViewerMetrics records nothing.
+type ViewerMetrics struct {
+	connections  *GaugeVec
+	messages     *CounterVec
+	locations    *CounterVec
+	bytes        *CounterVec
+	messageBytes *HistogramVec
+	batchSize    *HistogramVec
+	errors       *CounterVec
+	latency      *HistogramVec
+	endToEnd     *HistogramVec
+	handshake    *HistogramVec
+	upgrades     *CounterVec
+	firstMessage *HistogramVec
+	leaves       *CounterVec
+	reconnects   *CounterVec
+	downtime     *HistogramVec
+	missed       *CounterVec
+}
+
+// NewViewerMetrics registers the viewer metrics with r.
+func NewViewerMetrics(r *Registry) *ViewerMetrics {
+	return &ViewerMetrics{
+		connections: r.Gauge("skatemap_sim_websocket_connections",
+			"Open viewer WebSocket connections."),
+		messages: r.Counter("skatemap_sim_messages_received_total",
+			"Location batches received by viewers."),
+		locations: r.Counter("skatemap_sim_locations_received_total",
+			"Locations received by viewers across all batches."),
+		bytes: r.Counter("skatemap_sim_bytes_received_total",
+			"Bytes of location batches received by viewers."),
+		messageBytes: r.Histogram("skatemap_sim_message_bytes",
+			"Size of each location batch received, in bytes.", MessageSizeBuckets),
+		batchSize: r.Histogram("skatemap_sim_batch_locations",
+			"Locations in each batch received.", BatchSizeBuckets),
+		errors: r.Counter("skatemap_sim_viewer_errors_total",
+			"Viewer errors, by kind.", "kind"),
+		latency: r.Histogram("skatemap_sim_broadcast_latency_seconds",
+			"Time from the server sending a batch to a viewer receiving it.", DefaultBuckets),
+		endToEnd: r.Histogram("skatemap_sim_end_to_end_latency_seconds",
+			"Time from a skater sending a location to a viewer receiving it, for matched locations.", DefaultBuckets),
+		handshake: r.Histogram("skatemap_sim_websocket_handshake_seconds",
+			"Time taken to open each viewer WebSocket connection, for successful upgrades.", DefaultBuckets),
+		upgrades: r.Counter("skatemap_sim_websocket_upgrades_total",
+			"WebSocket handshake responses, by HTTP status code.", "status"),
+		firstMessage: r.Histogram("skatemap_sim_viewer_first_message_seconds",
+			"Time from a viewer's connection opening to the first batch arriving on it.", DefaultBuckets),
+		leaves: r.Counter("skatemap_sim_viewer_leaves_total",
+			"Sessions ended by churning viewers, by whether they sent a close frame.", "close"),
+		reconnects: r.Counter("skatemap_sim_viewer_reconnects_total",
+			"Times viewers connected again after losing their connection."),
+		downtime: r.Histogram("skatemap_sim_viewer_downtime_seconds",
+			"Time from a viewer losing its connection to connecting again.", DowntimeBuckets),
+		missed: r.Counter("skatemap_sim_viewer_missed_messages_total",
+			"Location batches viewers are estimated to have missed while reconnecting."),
+	}
+}
+
+// Record counts a viewer result.
+func (m *ViewerMetrics) Record(result viewer.ViewerResult) {
+	if m == nil {
+		return
+	}
+	if h := result.Handshake; h != nil {
+		if h.Status != 0 {
+			m.upgrades.Inc(strconv.Itoa(h.Status))
+		}
+		if h.Status == http.StatusSwitchingProtocols {
+			m.handshake.Observe(h.Duration.Seconds())
+		}
+		return
+	}
+	if f := result.FirstMessage; f != nil {
+		m.firstMessage.Observe(f.Wait.Seconds())
+		return
+	}
+	if result.Connect != nil {
+		return
+	}
+	if l := result.Leave; l != nil {
+		if l.Abrupt {
+			m.leaves.Inc("abrupt")
+		} else {
+			m.leaves.Inc("clean")
+		}
+		return
+	}
+	if r := result.Reconnect; r != nil {
+		m.reconnects.Inc()
+		m.downtime.Observe(r.Downtime.Seconds())
+		m.missed.Add(float64(r.MissedMessages))
+		return
+	}
+	if result.Error != nil {
+		m.errors.Inc(ErrorKind(result.Error))
+		return
+	}
+	m.messages.Inc()
+	m.locations.Add(float64(len(result.SkaterIDs)))
+	m.bytes.Add(float64(result.Bytes))
+	m.messageBytes.Observe(float64(result.Bytes))
+	m.batchSize.Observe(float64(len(result.SkaterIDs)))
+	m.latency.Observe(result.Latency.Seconds())
+	for _, d := range result.EndToEnd {
+		if d > 0 {
+			m.endToEnd.Observe(d.Seconds())
+		}
+	}
+}
+
+// ConnectionOpened increments the open connection gauge.
+func (m *ViewerMetrics) ConnectionOpened() {
+	if m == nil {
+		return
+	}
+	m.connections.Add(1)
+}
+
+// ConnectionClosed decrements the open connection gauge.
+func (m *ViewerMetrics) ConnectionClosed() {
+	if m == nil {
+		return
+	}
+	m.connections.Add(-1)
+}
+
+// Outcomes of the events written to a QueuedSink.
+const (
+	outcomeWritten    = "written"
+	outcomeDropped    = "dropped"
+	outcomeSampled    = "sampled_out"
+	outcomeWriteError = "write_error"
+)
+
+// QueueMetrics are the live Prometheus metrics of the queues in front of the
+// metrics files, labelled with the schema of each file's events, so
+// measurement overhead shows up alongside the load it measures. A nil
+// *QueueMetrics records nothing.
+type QueueMetrics struct {
+	queueDepth *GaugeVec
+	outcomes   *CounterVec
+	blocked    *CounterVec
+}
+
+// NewQueueMetrics registers the metrics file queue metrics with r.
+func NewQueueMetrics(r *Registry) *QueueMetrics {
+	return &QueueMetrics{
+		queueDepth: r.Gauge("skatemap_sim_metrics_queue_depth",
+			"Results waiting to be written to the metrics file.", "schema"),
+		outcomes: r.Counter("skatemap_sim_metrics_records_total",
+			"Results given to the metrics file, by whether they were written, dropped, sampled out or failed to write.", "schema", "outcome"),
+		blocked: r.Counter("skatemap_sim_metrics_blocked_seconds_total",
+			"Time spent waiting for space in a full metrics file queue.", "schema"),
+	}
+}
+
+func (m *QueueMetrics) depth(schema string, depth int64) {
+	if m == nil {
+		return
+	}
+	m.queueDepth.Set(float64(depth), schema)
+}
+
+func (m *QueueMetrics) records(schema, outcome string, n int) {
+	if m == nil || n == 0 {
+		return
+	}
+	m.outcomes.Add(float64(n), schema, outcome)
+}
+
+func (m *QueueMetrics) blockedFor(schema string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.blocked.Add(d.Seconds(), schema)
+}
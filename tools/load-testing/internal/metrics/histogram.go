@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+const (
+	// subBucketBits sets the histogram's precision: every power-of-two range is
+	// split into 2^subBucketBits/2 buckets, bounding relative error to under 1%.
+	subBucketBits  = 8
+	subBucketCount = 1 << subBucketBits
+	subBucketHalf  = subBucketCount / 2
+)
+
+// Histogram is a streaming histogram of durations with HDR-style log-linear
+// buckets. Memory grows with the logarithm of the largest recorded value rather
+// than the number of values, so it stays small on multi-hour runs.
+// Values are recorded with microsecond resolution; negative values, such as
+// latencies distorted by clock skew, are recorded as zero.
+// Histogram is not safe for concurrent use.
+type Histogram struct {
+	counts []uint64
+	total  uint64
+	sum    float64
+	min    int64
+	max    int64
+}
+
+// NewHistogram creates an empty Histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{min: math.MaxInt64}
+}
+
+// Record adds a duration to the histogram.
+func (h *Histogram) Record(d time.Duration) {
+	v := d.Microseconds()
+	if v < 0 {
+		v = 0
+	}
+
+	idx := bucketIndex(v)
+	if idx >= len(h.counts) {
+		grown := make([]uint64, idx+1)
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+
+	h.counts[idx]++
+	h.total++
+	h.sum += float64(v)
+	if v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+}
+
+// Count returns the number of recorded values.
+func (h *Histogram) Count() uint64 {
+	return h.total
+}
+
+// Max returns the largest recorded value, or zero if the histogram is empty.
+func (h *Histogram) Max() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.max) * time.Microsecond
+}
+
+// Min returns the smallest recorded value, or zero if the histogram is empty.
+func (h *Histogram) Min() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.min) * time.Microsecond
+}
+
+// Mean returns the mean of recorded values, or zero if the histogram is empty.
+func (h *Histogram) Mean() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.sum/float64(h.total)) * time.Microsecond
+}
+
+// Percentile returns the value at or below which the given percentage
+// (0-100) of recorded values fall, to within the histogram's precision.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+
+	target := uint64(math.Ceil(p / 100 * float64(h.total)))
+	if target < 1 {
+		target = 1
+	}
+
+	var cumulative uint64
+	for idx, count := range h.counts {
+		cumulative += count
+		if cumulative >= target {
+			v := bucketUpperBound(idx)
+			if v > h.max {
+				v = h.max
+			}
+			if v < h.min {
+				v = h.min
+			}
+			return time.Duration(v) * time.Microsecond
+		}
+	}
+
+	return h.Max()
+}
+
+// Merge adds all values recorded in other to h.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.total == 0 {
+		return
+	}
+
+	if len(other.counts) > len(h.counts) {
+		grown := make([]uint64, len(other.counts))
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+	for idx, count := range other.counts {
+		h.counts[idx] += count
+	}
+
+	h.total += other.total
+	h.sum += other.sum
+	if other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+// bucketIndex maps a value to its bucket. Values below subBucketCount get a
+// bucket each; larger values share buckets whose width doubles with every
+// power of two, keeping the top subBucketBits bits of the value.
+func bucketIndex(v int64) int {
+	if v < subBucketCount {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits
+	return shift*subBucketHalf + int(v>>shift)
+}
+
+// bucketUpperBound returns the largest value that maps to the bucket.
+func bucketUpperBound(idx int) int64 {
+	if idx < subBucketCount {
+		return int64(idx)
+	}
+	shift := (idx - subBucketHalf) / subBucketHalf
+	sub := int64(idx - shift*subBucketHalf)
+	return (sub+1)<<shift - 1
+}
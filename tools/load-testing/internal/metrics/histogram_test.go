@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestHistogram_Empty(t *testing.T) {
+	h := NewHistogram()
+
+	if h.Count() != 0 || h.Max() != 0 || h.Min() != 0 || h.Mean() != 0 || h.Percentile(99) != 0 {
+		t.Errorf("expected zero values from empty histogram")
+	}
+}
+
+func TestHistogram_Percentiles(t *testing.T) {
+	h := NewHistogram()
+	values := make([]time.Duration, 0, 10000)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		d := time.Duration(r.ExpFloat64()*float64(50*time.Millisecond)) + time.Millisecond
+		values = append(values, d)
+		h.Record(d)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	for _, p := range []float64{50, 90, 99, 99.9} {
+		exact := values[int(math.Ceil(p/100*float64(len(values))))-1]
+		got := h.Percentile(p)
+		if diff := float64(got-exact) / float64(exact); diff < -0.01 || diff > 0.01 {
+			t.Errorf("p%v: expected about %s, got %s", p, exact, got)
+		}
+	}
+
+	if h.Percentile(100) != values[len(values)-1].Truncate(time.Microsecond) {
+		t.Errorf("expected p100 %s to equal max, got %s", values[len(values)-1], h.Percentile(100))
+	}
+	if h.Count() != 10000 {
+		t.Errorf("expected 10000 values, got %d", h.Count())
+	}
+}
+
+func TestHistogram_MinMaxMean(t *testing.T) {
+	h := NewHistogram()
+	h.Record(10 * time.Millisecond)
+	h.Record(20 * time.Millisecond)
+	h.Record(30 * time.Millisecond)
+	h.Record(-5 * time.Millisecond)
+
+	if h.Min() != 0 {
+		t.Errorf("expected negative value to be recorded as 0, got min %s", h.Min())
+	}
+	if h.Max() != 30*time.Millisecond {
+		t.Errorf("expected max 30ms, got %s", h.Max())
+	}
+	if h.Mean() != 15*time.Millisecond {
+		t.Errorf("expected mean 15ms, got %s", h.Mean())
+	}
+}
+
+func TestHistogram_Merge(t *testing.T) {
+	a := NewHistogram()
+	b := NewHistogram()
+	for i := 1; i <= 50; i++ {
+		a.Record(time.Duration(i) * time.Millisecond)
+		b.Record(time.Duration(i+50) * time.Millisecond)
+	}
+
+	a.Merge(b)
+	a.Merge(NewHistogram())
+
+	if a.Count() != 100 {
+		t.Errorf("expected 100 values, got %d", a.Count())
+	}
+	if a.Min() != time.Millisecond || a.Max() != 100*time.Millisecond {
+		t.Errorf("expected range 1ms-100ms, got %s-%s", a.Min(), a.Max())
+	}
+	if p50 := a.Percentile(50); p50 < 49*time.Millisecond || p50 > 51*time.Millisecond {
+		t.Errorf("expected p50 about 50ms, got %s", p50)
+	}
+}
+
+func TestBucketIndex_UpperBoundRoundTrip(t *testing.T) {
+	for _, v := range []int64{0, 1, 255, 256, 257, 511, 512, 1000, 123456, 1 << 40} {
+		idx := bucketIndex(v)
+		upper := bucketUpperBound(idx)
+		if upper < v {
+			t.Errorf("value %d: bucket %d upper bound %d is below the value", v, idx, upper)
+		}
+		if bucketIndex(upper) != idx {
+			t.Errorf("value %d: upper bound %d maps to bucket %d, expected %d", v, upper, bucketIndex(upper), idx)
+		}
+		if bucketIndex(upper+1) != idx+1 {
+			t.Errorf("value %d: value after upper bound %d should start the next bucket", v, upper)
+		}
+	}
+}
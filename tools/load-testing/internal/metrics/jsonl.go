@@ -0,0 +1,198 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// NewJSONLSink creates a Sink that writes events of the given schema to the
+// named file as JSON Lines: one object per event, keyed by column name in
+// schema order. Lists are arrays, times are RFC 3339 with nanoseconds, and
+// values that do not apply, including empty strings and missing entries of
+// float lists, are null.
+func NewJSONLSink(filename string, schema *Schema, opts ...SinkOption) (Sink, error) {
+	return newFileSink(FormatJSONL, filename, schema, newJSONLEncoder, opts)
+}
+
+type jsonlEncoder struct {
+	writer *bufio.Writer
+	schema *Schema
+	keys   [][]byte
+}
+
+func newJSONLEncoder(w io.Writer, schema *Schema) (encoder, error) {
+	keys := make([][]byte, len(schema.Columns))
+	for i, c := range schema.Columns {
+		key, err := json.Marshal(c.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode column name %s: %w", c.Name, err)
+		}
+		keys[i] = append(key, ':')
+	}
+
+	return &jsonlEncoder{writer: bufio.NewWriter(w), schema: schema, keys: keys}, nil
+}
+
+func (e *jsonlEncoder) encode(values []any) error {
+	line := []byte{'{'}
+	for i, v := range values {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		value, err := json.Marshal(jsonValue(e.schema.Columns[i].Type, v))
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", e.schema.Columns[i].Name, err)
+		}
+		line = append(line, e.keys[i]...)
+		line = append(line, value...)
+	}
+	line = append(line, '}', '\n')
+
+	_, err := e.writer.Write(line)
+	return err
+}
+
+func (e *jsonlEncoder) flush() error {
+	return e.writer.Flush()
+}
+
+func (e *jsonlEncoder) close() error {
+	return e.flush()
+}
+
+// jsonValue returns the value of a column of type t as it is encoded in JSON.
+func jsonValue(t ColumnType, v any) any {
+	switch t {
+	case StringColumn:
+		if v == "" {
+			return nil
+		}
+	case TimeColumn:
+		return v.(time.Time).Format(time.RFC3339Nano)
+	case FloatListColumn:
+		values, _ := v.([]float64)
+		if values == nil {
+			return nil
+		}
+		entries := make([]any, len(values))
+		for i, f := range values {
+			if f != 0 {
+				entries[i] = f
+			}
+		}
+		return entries
+	}
+	return v
+}
+
+type jsonlDecoder struct {
+	file    io.ReadCloser
+	decoder *json.Decoder
+	schema  *Schema
+	live    bool
+}
+
+func newJSONLDecoder(seg segmentFile, schema *Schema) (decoder, error) {
+	file, err := openSegmentFile(seg)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonlDecoder{file: file, decoder: json.NewDecoder(file), schema: schema, live: seg.live}, nil
+}
+
+func (d *jsonlDecoder) decode() ([]any, error) {
+	var object map[string]json.RawMessage
+	if err := d.decoder.Decode(&object); err != nil {
+		if d.live && errors.Is(err, io.ErrUnexpectedEOF) {
+			// The writer has not finished the last line yet.
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	values := make([]any, len(d.schema.Columns))
+	for i, c := range d.schema.Columns {
+		value, err := parseJSON(c.Type, object[c.Name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", c.Name, err)
+		}
+		values[i] = value
+	}
+	for key := range object {
+		if d.schema.Index(key) < 0 {
+			return nil, fmt.Errorf("unknown key %q for a %s metrics file", key, d.schema.Name)
+		}
+	}
+	return values, nil
+}
+
+func (d *jsonlDecoder) close() error {
+	return d.file.Close()
+}
+
+// parseJSON parses the value of a column of type t, as encoded by jsonValue.
+// A missing or null value is the value that does not apply for the type.
+func parseJSON(t ColumnType, raw json.RawMessage) (any, error) {
+	isNull := len(raw) == 0 || string(raw) == "null"
+
+	switch t {
+	case StringColumn:
+		var s string
+		if isNull {
+			return s, nil
+		}
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	case IntColumn:
+		if isNull {
+			return nil, nil
+		}
+		var n int
+		err := json.Unmarshal(raw, &n)
+		return n, err
+	case FloatColumn:
+		if isNull {
+			return nil, nil
+		}
+		var f float64
+		err := json.Unmarshal(raw, &f)
+		return f, err
+	case TimeColumn:
+		if isNull {
+			return nil, fmt.Errorf("missing timestamp")
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	case StringListColumn:
+		var values []string
+		if isNull {
+			return values, nil
+		}
+		err := json.Unmarshal(raw, &values)
+		return values, err
+	case FloatListColumn:
+		if isNull {
+			return []float64(nil), nil
+		}
+		var entries []*float64
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return nil, err
+		}
+		values := make([]float64, len(entries))
+		for i, f := range entries {
+			if f != nil {
+				values[i] = *f
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("unknown column type %d", t)
+	}
+}
@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"load-testing/internal/skater"
+	"load-testing/internal/viewer"
+)
+
+func readJSONL(t *testing.T, filename string) []map[string]any {
+	t.Helper()
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	var records []map[string]any
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("failed to parse line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	return records
+}
+
+func TestJSONLSink_ViewerResult(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "viewer-metrics.jsonl")
+
+	sink, err := NewJSONLSink(filename, ViewerSchema)
+	if err != nil {
+		t.Fatalf("NewJSONLSink() error = %v", err)
+	}
+
+	timestamp := time.Date(2024, 1, 1, 12, 0, 0, 500, time.UTC)
+	results := []viewer.ViewerResult{
+		{
+			EventID:      "event-1",
+			ViewerNumber: 2,
+			MessageCount: 3,
+			Timestamp:    timestamp,
+			Latency:      1500 * time.Microsecond,
+			SkaterIDs:    []string{"skater-1", "skater-2"},
+			EndToEnd:     []time.Duration{40 * time.Millisecond, 0},
+			Bytes:        512,
+		},
+		{
+			EventID:   "event-1",
+			Timestamp: timestamp,
+			Error:     viewer.ErrMalformedBatch,
+		},
+	}
+	for _, result := range results {
+		if err := sink.Write(ViewerEvent(result)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	records := readJSONL(t, filename)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	batch := records[0]
+	if len(batch) != len(ViewerSchema.Columns) {
+		t.Errorf("expected %d keys, got %d", len(ViewerSchema.Columns), len(batch))
+	}
+	if got := batch["timestamp"]; got != timestamp.Format(time.RFC3339Nano) {
+		t.Errorf("timestamp = %v, want %s", got, timestamp.Format(time.RFC3339Nano))
+	}
+	if got := batch["latency_ms"]; got != 1.5 {
+		t.Errorf("latency_ms = %v, want 1.5", got)
+	}
+	if got, ok := batch["skater_ids"].([]any); !ok || len(got) != 2 || got[0] != "skater-1" || got[1] != "skater-2" {
+		t.Errorf("skater_ids = %v, want [skater-1 skater-2]", batch["skater_ids"])
+	}
+	if got, ok := batch["end_to_end_ms"].([]any); !ok || len(got) != 2 || got[0] != 40.0 || got[1] != nil {
+		t.Errorf("end_to_end_ms = %v, want [40 <nil>]", batch["end_to_end_ms"])
+	}
+	if got := batch["bytes"]; got != 512.0 {
+		t.Errorf("bytes = %v, want 512", got)
+	}
+	for _, column := range []string{"handshake_ms", "leave", "error_kind", "error"} {
+		if got := batch[column]; got != nil {
+			t.Errorf("%s = %v, want null", column, got)
+		}
+	}
+
+	failure := records[1]
+	if got := failure["error_kind"]; got != ErrorKindMalformedBatch {
+		t.Errorf("error_kind = %v, want %s", got, ErrorKindMalformedBatch)
+	}
+	if got := failure["error"]; got != viewer.ErrMalformedBatch.Error() {
+		t.Errorf("error = %v, want %s", got, viewer.ErrMalformedBatch)
+	}
+}
+
+func TestJSONLSink_SkaterStatusCode(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "metrics.jsonl")
+
+	sink, err := NewJSONLSink(filename, SkaterSchema)
+	if err != nil {
+		t.Fatalf("NewJSONLSink() error = %v", err)
+	}
+
+	results := []skater.UpdateResult{
+		{EventID: "event-1"},
+		{EventID: "event-1", Error: &skater.StatusError{StatusCode: 503}},
+		{EventID: "event-1", Error: os.ErrDeadlineExceeded},
+	}
+	for _, result := range results {
+		if err := sink.Write(SkaterEvent(result)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	records := readJSONL(t, filename)
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+
+	want := []struct {
+		status any
+		kind   any
+	}{
+		{202.0, nil},
+		{503.0, "http_503"},
+		{nil, ErrorKindTimeout},
+	}
+	for i, w := range want {
+		if got := records[i]["status_code"]; got != w.status {
+			t.Errorf("record %d: status_code = %v, want %v", i, got, w.status)
+		}
+		if got := records[i]["error_kind"]; got != w.kind {
+			t.Errorf("record %d: error_kind = %v, want %v", i, got, w.kind)
+		}
+	}
+}
+
+func TestJSONLSink_SkaterSchedule(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "metrics.jsonl")
+
+	sink, err := NewJSONLSink(filename, SkaterSchema)
+	if err != nil {
+		t.Fatalf("NewJSONLSink() error = %v", err)
+	}
+
+	lag := 25500 * time.Microsecond
+	for _, result := range []skater.UpdateResult{
+		{EventID: "event-1", ScheduleLag: &lag},
+		{EventID: "event-1"},
+		{EventID: "event-1", Error: skater.ErrSkipped},
+	} {
+		if err := sink.Write(SkaterEvent(result)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	records := readJSONL(t, filename)
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	if got := records[0]["schedule_lag_ms"]; got != 25.5 {
+		t.Errorf("schedule_lag_ms = %v, want 25.5", got)
+	}
+	if got, ok := records[1]["schedule_lag_ms"]; !ok || got != nil {
+		t.Errorf("schedule_lag_ms = %v, want null", got)
+	}
+	if got, ok := records[2]["response_time_ms"]; !ok || got != nil {
+		t.Errorf("response_time_ms of a skipped update = %v, want null", got)
+	}
+	if got := records[2]["error_kind"]; got != ErrorKindSkipped {
+		t.Errorf("error_kind = %v, want %s", got, ErrorKindSkipped)
+	}
+}
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetRowGroupSize is the number of events in each Parquet row group. A
+// row group is held in memory until it is complete, so it is kept small
+// enough for viewer results listing hundreds of skater IDs.
+const parquetRowGroupSize = 10000
+
+// NewParquetSink creates a Sink that writes events of the given schema to the
+// named file as zstd-compressed Parquet, with a column for each of the
+// schema's columns. Times are millisecond timestamps, lists are LIST columns,
+// and values that do not apply, including empty strings, are null; missing
+// entries of float lists are 0.
+//
+// Events are written a row group at a time and a file is only readable once
+// its footer is written when it is closed, so it cannot be followed during a
+// run; with WithRotation, each finished segment can be. The columns are
+// compressed already, so WithCompression is rejected.
+func NewParquetSink(filename string, schema *Schema, opts ...SinkOption) (Sink, error) {
+	config, err := newSinkConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if config.compression != CompressionNone {
+		return nil, fmt.Errorf("parquet metrics files are compressed already, cannot add %s compression", config.compression)
+	}
+	return newFileSink(FormatParquet, filename, schema, newParquetEncoder, opts)
+}
+
+type parquetEncoder struct {
+	writer  *parquet.Writer
+	rowType reflect.Type
+	schema  *Schema
+}
+
+func newParquetEncoder(w io.Writer, schema *Schema) (encoder, error) {
+	rowType, err := parquetRowType(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := parquet.NewWriter(w,
+		parquet.SchemaOf(reflect.New(rowType).Interface()),
+		parquet.MaxRowsPerRowGroup(parquetRowGroupSize),
+		parquet.Compression(&parquet.Zstd),
+	)
+	return &parquetEncoder{writer: writer, rowType: rowType, schema: schema}, nil
+}
+
+// parquetRowType builds a struct type with a parquet-tagged field for each
+// of the schema's columns, in order.
+func parquetRowType(schema *Schema) (reflect.Type, error) {
+	fields := make([]reflect.StructField, len(schema.Columns))
+	for i, c := range schema.Columns {
+		var fieldType reflect.Type
+		var tag string
+		switch c.Type {
+		case StringColumn:
+			fieldType, tag = reflect.TypeOf(""), "optional"
+		case IntColumn:
+			fieldType, tag = reflect.TypeOf((*int64)(nil)), "optional"
+		case FloatColumn:
+			fieldType, tag = reflect.TypeOf((*float64)(nil)), "optional"
+		case TimeColumn:
+			fieldType, tag = reflect.TypeOf(time.Time{}), "timestamp(millisecond)"
+		case StringListColumn:
+			fieldType, tag = reflect.TypeOf([]string(nil)), "list"
+		case FloatListColumn:
+			fieldType, tag = reflect.TypeOf([]float64(nil)), "list"
+		default:
+			return nil, fmt.Errorf("column %s has unknown type %d", c.Name, c.Type)
+		}
+		fields[i] = reflect.StructField{
+			Name: fmt.Sprintf("Column%d", i),
+			Type: fieldType,
+			Tag:  reflect.StructTag(fmt.Sprintf(`parquet:"%s,%s"`, c.Name, tag)),
+		}
+	}
+	return reflect.StructOf(fields), nil
+}
+
+func (e *parquetEncoder) encode(values []any) error {
+	row := reflect.New(e.rowType)
+	fields := row.Elem()
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+
+		switch e.schema.Columns[i].Type {
+		case IntColumn:
+			n := int64(v.(int))
+			fields.Field(i).Set(reflect.ValueOf(&n))
+		case FloatColumn:
+			f := v.(float64)
+			fields.Field(i).Set(reflect.ValueOf(&f))
+		default:
+			fields.Field(i).Set(reflect.ValueOf(v))
+		}
+	}
+
+	return e.writer.Write(row.Interface())
+}
+
+// flush does nothing: row groups are written as they fill up, since a
+// Parquet file cannot be read before it is closed anyway.
+func (e *parquetEncoder) flush() error {
+	return nil
+}
+
+func (e *parquetEncoder) close() error {
+	return e.writer.Close()
+}
+
+type parquetDecoder struct {
+	file    *os.File
+	reader  *parquet.Reader
+	rowType reflect.Type
+	schema  *Schema
+}
+
+// newParquetDecoder opens a Parquet segment. Parquet segments are never
+// compressed, and one still being written cannot be read as it has no
+// footer yet.
+func newParquetDecoder(seg segmentFile, schema *Schema) (decoder, error) {
+	file, err := os.Open(seg.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metrics file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open metrics file: %w", err)
+	}
+
+	pf, err := parquet.OpenFile(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read %s, which may not be finished yet: %w", seg.path, err)
+	}
+
+	fields := pf.Schema().Fields()
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		columns[i] = f.Name()
+	}
+	if !slices.Equal(columns, schema.ColumnNames()) {
+		file.Close()
+		return nil, fmt.Errorf("%s does not have the columns of a %s metrics file", seg.path, schema.Name)
+	}
+
+	rowType, err := parquetRowType(schema)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	reader := parquet.NewReader(pf, parquet.SchemaOf(reflect.New(rowType).Interface()))
+	return &parquetDecoder{file: file, reader: reader, rowType: rowType, schema: schema}, nil
+}
+
+func (d *parquetDecoder) decode() ([]any, error) {
+	row := reflect.New(d.rowType)
+	if err := d.reader.Read(row.Interface()); err != nil {
+		return nil, err
+	}
+
+	fields := row.Elem()
+	values := make([]any, len(d.schema.Columns))
+	for i, c := range d.schema.Columns {
+		field := fields.Field(i)
+		switch c.Type {
+		case IntColumn:
+			if !field.IsNil() {
+				values[i] = int(field.Elem().Int())
+			}
+		case FloatColumn:
+			if !field.IsNil() {
+				values[i] = field.Elem().Float()
+			}
+		default:
+			values[i] = field.Interface()
+		}
+	}
+	return values, nil
+}
+
+func (d *parquetDecoder) close() error {
+	return errors.Join(d.reader.Close(), d.file.Close())
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"load-testing/internal/viewer"
+)
+
+func TestParquetSink_ViewerResult(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "viewer-metrics.parquet")
+
+	sink, err := NewParquetSink(filename, ViewerSchema)
+	if err != nil {
+		t.Fatalf("NewParquetSink() error = %v", err)
+	}
+
+	timestamp := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	results := []viewer.ViewerResult{
+		{
+			EventID:      "event-1",
+			ViewerNumber: 2,
+			MessageCount: 3,
+			Timestamp:    timestamp,
+			Latency:      1500 * time.Microsecond,
+			SkaterIDs:    []string{"skater-1", "skater-2"},
+			EndToEnd:     []time.Duration{40 * time.Millisecond, 0},
+			Bytes:        512,
+		},
+		{
+			EventID:   "event-1",
+			Timestamp: timestamp,
+			Error:     viewer.ErrMalformedBatch,
+		},
+	}
+	for _, result := range results {
+		if err := sink.Write(ViewerEvent(result)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatalf("failed to stat file: %v", err)
+	}
+	pf, err := parquet.OpenFile(file, info.Size())
+	if err != nil {
+		t.Fatalf("parquet.OpenFile() error = %v", err)
+	}
+
+	fields := pf.Schema().Fields()
+	if len(fields) != len(ViewerSchema.Columns) {
+		t.Fatalf("expected %d columns, got %d", len(ViewerSchema.Columns), len(fields))
+	}
+	for i, c := range ViewerSchema.Columns {
+		if fields[i].Name() != c.Name {
+			t.Errorf("column %d: expected %s, got %s", i, c.Name, fields[i].Name())
+		}
+	}
+
+	rowType, err := parquetRowType(ViewerSchema)
+	if err != nil {
+		t.Fatalf("parquetRowType() error = %v", err)
+	}
+	reader := parquet.NewReader(pf)
+	defer reader.Close()
+
+	var rows []reflect.Value
+	for i := 0; i < len(results); i++ {
+		row := reflect.New(rowType)
+		if err := reader.Read(row.Interface()); err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		rows = append(rows, row.Elem())
+	}
+
+	column := func(row reflect.Value, name string) any {
+		for i, c := range ViewerSchema.Columns {
+			if c.Name == name {
+				return row.Field(i).Interface()
+			}
+		}
+		t.Fatalf("no column %s", name)
+		return nil
+	}
+
+	batch := rows[0]
+	if got := column(batch, "timestamp").(time.Time); !got.Equal(timestamp) {
+		t.Errorf("timestamp = %v, want %v", got, timestamp)
+	}
+	if got := column(batch, "viewer_number").(*int64); got == nil || *got != 2 {
+		t.Errorf("viewer_number = %v, want 2", got)
+	}
+	if got := column(batch, "latency_ms").(*float64); got == nil || *got != 1.5 {
+		t.Errorf("latency_ms = %v, want 1.5", got)
+	}
+	if got := column(batch, "skater_ids").([]string); !reflect.DeepEqual(got, []string{"skater-1", "skater-2"}) {
+		t.Errorf("skater_ids = %v, want [skater-1 skater-2]", got)
+	}
+	if got := column(batch, "end_to_end_ms").([]float64); !reflect.DeepEqual(got, []float64{40, 0}) {
+		t.Errorf("end_to_end_ms = %v, want [40 0]", got)
+	}
+	if got := column(batch, "handshake_ms").(*float64); got != nil {
+		t.Errorf("handshake_ms = %v, want null", *got)
+	}
+
+	failure := rows[1]
+	if got := column(failure, "error_kind").(string); got != ErrorKindMalformedBatch {
+		t.Errorf("error_kind = %q, want %s", got, ErrorKindMalformedBatch)
+	}
+	if got := column(failure, "error").(string); got != viewer.ErrMalformedBatch.Error() {
+		t.Errorf("error = %q, want %s", got, viewer.ErrMalformedBatch)
+	}
+}
@@ -0,0 +1,311 @@
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram bucket upper bounds in seconds, suitable for
+// response times and latencies from a few milliseconds to ten seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DowntimeBuckets are histogram bucket upper bounds in seconds, suitable for
+// how long a viewer is disconnected, from a fast reconnect to a slow deploy.
+var DowntimeBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// MessageSizeBuckets are histogram bucket upper bounds in bytes, suitable for
+// stream messages from a single location to a full batch of long skater IDs.
+var MessageSizeBuckets = []float64{256, 1024, 4096, 8192, 16384, 32768, 65536, 262144}
+
+// BatchSizeBuckets are histogram bucket upper bounds in locations per batch,
+// with a bound at DefaultMaxBatchSize so oversized batches stand out.
+var BatchSizeBuckets = []float64{1, 5, 10, 25, 50, 75, 100, 250, 500}
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// Registry holds live metrics and writes them in the Prometheus text
+// exposition format. It is safe for concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+type family struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	buckets    []float64
+	fn         func() float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	buckets     []uint64
+	count       uint64
+	sum         float64
+}
+
+func (r *Registry) register(f *family) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.families {
+		if existing.name == f.name {
+			panic(fmt.Sprintf("metric %s registered twice", f.name))
+		}
+	}
+	f.series = make(map[string]*series)
+	if len(f.labelNames) == 0 && f.fn == nil {
+		// Unlabelled metrics are exposed as zero before their first update.
+		f.with(nil)
+	}
+	r.families = append(r.families, f)
+	return f
+}
+
+// Counter registers a counter with the given label names.
+func (r *Registry) Counter(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{r.register(&family{name: name, help: help, kind: kindCounter, labelNames: labelNames})}
+}
+
+// Gauge registers a gauge with the given label names.
+func (r *Registry) Gauge(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{r.register(&family{name: name, help: help, kind: kindGauge, labelNames: labelNames})}
+}
+
+// GaugeFunc registers an unlabelled gauge whose value is read from fn at scrape time.
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	r.register(&family{name: name, help: help, kind: kindGauge, fn: fn})
+}
+
+// Histogram registers a histogram with the given bucket upper bounds and label names.
+func (r *Registry) Histogram(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return &HistogramVec{r.register(&family{name: name, help: help, kind: kindHistogram, labelNames: labelNames, buckets: buckets})}
+}
+
+func (f *family) with(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metric %s takes %d label values, got %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.kind == kindHistogram {
+			s.buckets = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	f *family
+}
+
+// Add increases the counter for the given label values by delta, which must not be negative.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("counter %s cannot decrease", c.f.name))
+	}
+
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+
+	c.f.with(labelValues).value += delta
+}
+
+// Inc increases the counter for the given label values by one.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// GaugeVec is a gauge partitioned by labels.
+type GaugeVec struct {
+	f *family
+}
+
+// Set sets the gauge for the given label values.
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+
+	g.f.with(labelValues).value = value
+}
+
+// Add adds delta, which may be negative, to the gauge for the given label values.
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+
+	g.f.with(labelValues).value += delta
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	f *family
+}
+
+// Observe records a value, in seconds for durations, for the given label values.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+
+	s := h.f.with(labelValues)
+	for i, upper := range h.f.buckets {
+		if value <= upper {
+			s.buckets[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// Write writes every metric in the Prometheus text exposition format,
+// in registration order with series sorted by label values.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	families := append([]*family(nil), r.families...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (f *family) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+
+	if f.fn != nil {
+		fmt.Fprintf(w, "%s %s\n", f.name, formatFloat(f.fn()))
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := f.series[k]
+		labels := formatLabels(f.labelNames, s.labelValues, "", "")
+		if f.kind != kindHistogram {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, labels, formatFloat(s.value))
+			continue
+		}
+
+		for i, upper := range f.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name,
+				formatLabels(f.labelNames, s.labelValues, "le", formatFloat(upper)), s.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, formatLabels(f.labelNames, s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, labels, formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, labels, s.count)
+	}
+}
+
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+
+	parts := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%q", name, escapeLabelValue(values[i])))
+	}
+	if extraName != "" {
+		parts = append(parts, fmt.Sprintf("%s=%q", extraName, extraValue))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// escapeLabelValue leaves only the escaping done by %q that the exposition
+// format also uses: backslash, double quote and newline.
+func escapeLabelValue(v string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\n' {
+			return -1
+		}
+		return r
+	}, v)
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// Handler returns an http.Handler that serves the registry's metrics.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.Write(w); err != nil {
+			log.Printf("Error writing metrics: %v", err)
+		}
+	})
+}
+
+// Serve starts serving the registry's metrics at /metrics on addr in the background.
+// It returns once the address is bound, so a bad address is reported immediately.
+// The returned server's Addr is the bound address, and it should be closed when the run ends.
+func Serve(addr string, r *Registry) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for metrics on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r.Handler())
+	server := &http.Server{Addr: listener.Addr().String(), Handler: mux}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Metrics server error: %v", err)
+		}
+	}()
+
+	return server, nil
+}
@@ -0,0 +1,260 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"load-testing/internal/skater"
+	"load-testing/internal/viewer"
+)
+
+func writeRegistry(t *testing.T, r *Registry) string {
+	t.Helper()
+
+	var out strings.Builder
+	if err := r.Write(&out); err != nil {
+		t.Fatalf("failed to write metrics: %v", err)
+	}
+	return out.String()
+}
+
+func assertLines(t *testing.T, output string, expected ...string) {
+	t.Helper()
+
+	lines := strings.Split(output, "\n")
+	for _, want := range expected {
+		found := false
+		for _, line := range lines {
+			if line == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected line %q in output:\n%s", want, output)
+		}
+	}
+}
+
+func TestRegistry_Write(t *testing.T) {
+	r := NewRegistry()
+	requests := r.Counter("requests_total", "Requests sent.", "status")
+	active := r.Gauge("active", "Active things.")
+	latency := r.Histogram("latency_seconds", "Latency.", []float64{0.1, 1})
+	r.GaugeFunc("limit", "Current limit.", func() float64 { return 2.5 })
+
+	requests.Inc("503")
+	requests.Inc("202")
+	requests.Add(2, "202")
+	active.Add(3)
+	active.Add(-1)
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(3)
+
+	output := writeRegistry(t, r)
+
+	assertLines(t, output,
+		"# HELP requests_total Requests sent.",
+		"# TYPE requests_total counter",
+		`requests_total{status="202"} 3`,
+		`requests_total{status="503"} 1`,
+		"# TYPE active gauge",
+		"active 2",
+		"# TYPE latency_seconds histogram",
+		`latency_seconds_bucket{le="0.1"} 1`,
+		`latency_seconds_bucket{le="1"} 2`,
+		`latency_seconds_bucket{le="+Inf"} 3`,
+		"latency_seconds_sum 3.55",
+		"latency_seconds_count 3",
+		"limit 2.5",
+	)
+
+	if strings.Index(output, `status="202"`) > strings.Index(output, `status="503"`) {
+		t.Errorf("expected series sorted by label value:\n%s", output)
+	}
+	if strings.Index(output, "requests_total") > strings.Index(output, "limit") {
+		t.Errorf("expected metrics in registration order:\n%s", output)
+	}
+}
+
+func TestRegistry_UnlabelledMetricsStartAtZero(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("messages_total", "Messages.")
+	r.Histogram("latency_seconds", "Latency.", []float64{1})
+
+	assertLines(t, writeRegistry(t, r),
+		"messages_total 0",
+		`latency_seconds_bucket{le="+Inf"} 0`,
+		"latency_seconds_count 0",
+	)
+}
+
+func TestRegistry_EscapesLabelValues(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("errors_total", "Errors.", "kind").Inc("a \"quoted\" \\ value\n")
+
+	assertLines(t, writeRegistry(t, r), `errors_total{kind="a \"quoted\" \\ value\n"} 1`)
+}
+
+func TestRegistry_DuplicateNamePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering a metric twice to panic")
+		}
+	}()
+
+	r := NewRegistry()
+	r.Counter("requests_total", "Requests.")
+	r.Gauge("requests_total", "Requests.")
+}
+
+func TestServe(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("requests_total", "Requests.").Inc()
+
+	server, err := Serve("127.0.0.1:0", r)
+	if err != nil {
+		t.Fatalf("failed to serve metrics: %v", err)
+	}
+	defer server.Close()
+
+	resp, err := http.Get("http://" + server.Addr + "/metrics")
+	if err != nil {
+		t.Fatalf("failed to fetch metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("expected Prometheus text content type, got %q", ct)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+	assertLines(t, string(body), "requests_total 1")
+}
+
+func TestServe_AddressInUse(t *testing.T) {
+	r := NewRegistry()
+
+	server, err := Serve("127.0.0.1:0", r)
+	if err != nil {
+		t.Fatalf("failed to serve metrics: %v", err)
+	}
+	defer server.Close()
+
+	if _, err := Serve(server.Addr, r); err == nil {
+		t.Error("expected serving on an address in use to fail")
+	}
+}
+
+func TestSkaterMetrics(t *testing.T) {
+	r := NewRegistry()
+	m := NewSkaterMetrics(r)
+	m.TrackRateLimit(func() float64 { return 12.5 })
+
+	m.SkaterJoined()
+	m.SkaterJoined()
+	m.SkaterLeft()
+	m.Record(skater.UpdateResult{ResponseTime: 20 * time.Millisecond})
+	m.Record(skater.UpdateResult{ResponseTime: 30 * time.Millisecond, Error: &skater.StatusError{StatusCode: 503}})
+	m.Record(skater.UpdateResult{ResponseTime: 10 * time.Second, Error: errors.New("boom")})
+	m.Record(skater.UpdateResult{Error: skater.ErrSkipped})
+
+	assertLines(t, writeRegistry(t, r),
+		`skatemap_sim_requests_total{status="202"} 1`,
+		`skatemap_sim_requests_total{status="503"} 1`,
+		`skatemap_sim_requests_total{status="other"} 1`,
+		"skatemap_sim_updates_skipped_total 1",
+		`skatemap_sim_response_time_seconds_bucket{le="0.025"} 1`,
+		"skatemap_sim_response_time_seconds_count 3",
+		"skatemap_sim_active_skaters 1",
+		"skatemap_sim_rate_limit 12.5",
+	)
+}
+
+func TestViewerMetrics(t *testing.T) {
+	r := NewRegistry()
+	m := NewViewerMetrics(r)
+
+	m.ConnectionOpened()
+	m.ConnectionOpened()
+	m.ConnectionClosed()
+	m.Record(viewer.ViewerResult{Latency: 40 * time.Millisecond, SkaterIDs: []string{"a", "b"}, Bytes: 300})
+	m.Record(viewer.ViewerResult{Latency: 60 * time.Millisecond, SkaterIDs: []string{"a"}, Bytes: 2000})
+	m.Record(viewer.ViewerResult{Error: errors.Join(viewer.ErrMalformedBatch, errors.New("bad json"))})
+	m.Record(viewer.ViewerResult{Reconnect: &viewer.Reconnect{Attempts: 2, Downtime: 3 * time.Second, MissedMessages: 6}})
+	m.Record(viewer.ViewerResult{Handshake: &viewer.Handshake{Duration: 20 * time.Millisecond, Status: 101}})
+	m.Record(viewer.ViewerResult{Handshake: &viewer.Handshake{Duration: 2 * time.Second, Status: 503}})
+	m.Record(viewer.ViewerResult{FirstMessage: &viewer.FirstMessage{Wait: 700 * time.Millisecond}})
+
+	assertLines(t, writeRegistry(t, r),
+		"skatemap_sim_websocket_connections 1",
+		"skatemap_sim_messages_received_total 2",
+		"skatemap_sim_locations_received_total 3",
+		"skatemap_sim_bytes_received_total 2300",
+		`skatemap_sim_message_bytes_bucket{le="1024"} 1`,
+		`skatemap_sim_message_bytes_bucket{le="4096"} 2`,
+		`skatemap_sim_batch_locations_bucket{le="1"} 1`,
+		`skatemap_sim_batch_locations_bucket{le="5"} 2`,
+		`skatemap_sim_viewer_errors_total{kind="malformed_batch"} 1`,
+		`skatemap_sim_broadcast_latency_seconds_bucket{le="0.05"} 1`,
+		`skatemap_sim_broadcast_latency_seconds_bucket{le="0.1"} 2`,
+		"skatemap_sim_viewer_reconnects_total 1",
+		`skatemap_sim_viewer_downtime_seconds_bucket{le="2.5"} 0`,
+		`skatemap_sim_viewer_downtime_seconds_bucket{le="5"} 1`,
+		"skatemap_sim_viewer_missed_messages_total 6",
+		`skatemap_sim_websocket_upgrades_total{status="101"} 1`,
+		`skatemap_sim_websocket_upgrades_total{status="503"} 1`,
+		"skatemap_sim_websocket_handshake_seconds_count 1",
+		`skatemap_sim_viewer_first_message_seconds_bucket{le="0.5"} 0`,
+		`skatemap_sim_viewer_first_message_seconds_bucket{le="1"} 1`,
+	)
+}
+
+func TestQueueMetrics(t *testing.T) {
+	r := NewRegistry()
+	sink := newGatedSink()
+	close(sink.release)
+	q, err := NewQueuedSink(sink, QueueConfig{Size: 10, Overflow: OverflowBlock, Metrics: NewQueueMetrics(r)})
+	if err != nil {
+		t.Fatalf("NewQueuedSink() error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := q.Write(testSkaterEvent()); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	q.Close()
+
+	assertLines(t, writeRegistry(t, r),
+		`skatemap_sim_metrics_records_total{schema="skater",outcome="written"} 3`,
+		`skatemap_sim_metrics_queue_depth{schema="skater"} 0`,
+	)
+}
+
+func TestNilMetricsRecordNothing(t *testing.T) {
+	var skaterMetrics *SkaterMetrics
+	skaterMetrics.SkaterJoined()
+	skaterMetrics.SkaterLeft()
+	skaterMetrics.TrackRateLimit(func() float64 { return 1 })
+	skaterMetrics.Record(skater.UpdateResult{})
+
+	var viewerMetrics *ViewerMetrics
+	viewerMetrics.ConnectionOpened()
+	viewerMetrics.ConnectionClosed()
+	viewerMetrics.Record(viewer.ViewerResult{})
+
+	var queueMetrics *QueueMetrics
+	queueMetrics.depth("skater", 1)
+	queueMetrics.records("skater", outcomeDropped, 1)
+	queueMetrics.blockedFor("skater", time.Second)
+}
@@ -0,0 +1,276 @@
+package metrics
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Overflow policies for a full QueuedSink.
+const (
+	// OverflowBlock makes Write wait for space, so no event is lost but a
+	// slow disk slows down whatever is writing.
+	OverflowBlock = "block"
+	// OverflowDrop discards events that do not fit and counts them.
+	OverflowDrop = "drop"
+	// OverflowSample keeps one in every SampleEvery events once the queue is
+	// half full, counting the rest as sampled out, and discards events that
+	// do not fit, so the file stays representative of a period of overload
+	// rather than missing all of it.
+	OverflowSample = "sample"
+)
+
+// OverflowPolicies lists the overflow policies, block first as the default.
+var OverflowPolicies = []string{OverflowBlock, OverflowDrop, OverflowSample}
+
+const (
+	// DefaultQueueSize is the default number of events a QueuedSink holds.
+	DefaultQueueSize = 10000
+	// DefaultSampleEvery is the default sampling interval of OverflowSample.
+	DefaultSampleEvery = 10
+
+	// queueBatchSize is the most events written to the underlying sink at once.
+	queueBatchSize = 500
+)
+
+// QueueConfig configures a QueuedSink.
+type QueueConfig struct {
+	// Size is the number of events the queue holds.
+	Size int
+	// Overflow is what Write does when the queue is full, one of
+	// OverflowPolicies.
+	Overflow string
+	// SampleEvery is the sampling interval of OverflowSample.
+	SampleEvery int
+	// Metrics publishes the queue's depth and outcomes, labelled with the
+	// schema of its events. It may be nil.
+	Metrics *QueueMetrics
+}
+
+// QueueStats describes what a QueuedSink did with the events written to it.
+// Blocked counts the writes that had to wait for space in the queue, and
+// BlockedSeconds the total time they waited.
+type QueueStats struct {
+	Capacity       int     `json:"capacity"`
+	MaxDepth       int     `json:"max_depth"`
+	Written        int64   `json:"written"`
+	Dropped        int64   `json:"dropped"`
+	Sampled        int64   `json:"sampled_out"`
+	WriteErrors    int64   `json:"write_errors"`
+	Blocked        int64   `json:"blocked"`
+	BlockedSeconds float64 `json:"blocked_seconds"`
+}
+
+// Lost returns the number of events that did not reach the file.
+func (s QueueStats) Lost() int64 {
+	return s.Dropped + s.Sampled + s.WriteErrors
+}
+
+func (s QueueStats) String() string {
+	return fmt.Sprintf("%d records written, %d dropped, %d sampled out, %d write errors; max queue depth %d/%d; blocked %d times for %s",
+		s.Written, s.Dropped, s.Sampled, s.WriteErrors, s.MaxDepth, s.Capacity, s.Blocked,
+		(time.Duration(s.BlockedSeconds * float64(time.Second))).Round(time.Millisecond))
+}
+
+// batchWriter is implemented by sinks that can write several events more
+// cheaply than one at a time. writeBatch returns how many events it wrote
+// before any error.
+type batchWriter interface {
+	writeBatch(events []Event) (int, error)
+}
+
+// QueuedSink writes events to another Sink from a goroutine of its own,
+// through a bounded queue, so writing to disk does not hold up the caller.
+// What happens when the queue is full depends on its overflow policy, and
+// every event that does not reach the file is counted.
+type QueuedSink struct {
+	sink   Sink
+	config QueueConfig
+	queue  chan Event
+	done   chan struct{}
+
+	// mu is held for reading while sending to queue, and for writing to
+	// close it.
+	mu     sync.RWMutex
+	closed bool
+
+	closeOnce sync.Once
+	closeErr  error
+
+	sampleCount    atomic.Int64
+	maxDepth       atomic.Int64
+	written        atomic.Int64
+	dropped        atomic.Int64
+	sampled        atomic.Int64
+	writeErrors    atomic.Int64
+	blocked        atomic.Int64
+	blockedNanos   atomic.Int64
+	lastErrorLogAt atomic.Int64
+}
+
+// NewQueuedSink starts writing the events written to the returned sink to
+// sink. Closing it writes out the events still queued and closes sink.
+func NewQueuedSink(sink Sink, config QueueConfig) (*QueuedSink, error) {
+	if config.Size <= 0 {
+		return nil, fmt.Errorf("metrics queue size must be positive, got %d", config.Size)
+	}
+	if !slices.Contains(OverflowPolicies, config.Overflow) {
+		return nil, fmt.Errorf("unknown metrics overflow policy %q (want one of %s)", config.Overflow, strings.Join(OverflowPolicies, ", "))
+	}
+	if config.Overflow == OverflowSample && config.SampleEvery <= 0 {
+		return nil, fmt.Errorf("metrics sampling interval must be positive, got %d", config.SampleEvery)
+	}
+
+	q := &QueuedSink{
+		sink:   sink,
+		config: config,
+		queue:  make(chan Event, config.Size),
+		done:   make(chan struct{}),
+	}
+	go q.run()
+	return q, nil
+}
+
+// Write queues event to be written, or drops it as the overflow policy
+// says. It only returns an error once the sink is closed.
+func (q *QueuedSink) Write(event Event) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return fmt.Errorf("cannot write to a closed metrics queue")
+	}
+
+	schema := event.Schema().Name
+	if q.config.Overflow == OverflowSample && len(q.queue) >= cap(q.queue)/2 {
+		if q.sampleCount.Add(1)%int64(q.config.SampleEvery) != 0 {
+			q.sampled.Add(1)
+			q.config.Metrics.records(schema, outcomeSampled, 1)
+			return nil
+		}
+	}
+
+	select {
+	case q.queue <- event:
+	default:
+		if q.config.Overflow != OverflowBlock {
+			q.dropped.Add(1)
+			q.config.Metrics.records(schema, outcomeDropped, 1)
+			return nil
+		}
+
+		start := time.Now()
+		q.queue <- event
+		waited := time.Since(start)
+		q.blocked.Add(1)
+		q.blockedNanos.Add(int64(waited))
+		q.config.Metrics.blockedFor(schema, waited)
+	}
+
+	depth := int64(len(q.queue))
+	for {
+		prev := q.maxDepth.Load()
+		if depth <= prev || q.maxDepth.CompareAndSwap(prev, depth) {
+			break
+		}
+	}
+	q.config.Metrics.depth(schema, depth)
+	return nil
+}
+
+// run writes queued events to the sink in batches until the queue is closed
+// and empty.
+func (q *QueuedSink) run() {
+	defer close(q.done)
+
+	batch := make([]Event, 0, queueBatchSize)
+	for event := range q.queue {
+		batch = append(batch[:0], event)
+	fill:
+		for len(batch) < queueBatchSize {
+			select {
+			case event, ok := <-q.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, event)
+			default:
+				break fill
+			}
+		}
+		q.write(batch)
+	}
+}
+
+func (q *QueuedSink) write(batch []Event) {
+	schema := batch[0].Schema().Name
+
+	var written int
+	var err error
+	if bw, ok := q.sink.(batchWriter); ok {
+		written, err = bw.writeBatch(batch)
+	} else {
+		for _, event := range batch {
+			if writeErr := q.sink.Write(event); writeErr != nil {
+				err = writeErr
+				continue
+			}
+			written++
+		}
+	}
+
+	q.written.Add(int64(written))
+	q.config.Metrics.records(schema, outcomeWritten, written)
+	q.config.Metrics.depth(schema, int64(len(q.queue)))
+	if failed := len(batch) - written; failed > 0 {
+		q.writeErrors.Add(int64(failed))
+		q.config.Metrics.records(schema, outcomeWriteError, failed)
+	}
+	if err != nil {
+		q.logError(err)
+	}
+}
+
+// logError logs a write error at most once a second, so a full disk does
+// not flood the log.
+func (q *QueuedSink) logError(err error) {
+	now := time.Now().UnixNano()
+	last := q.lastErrorLogAt.Load()
+	if now-last < int64(time.Second) || !q.lastErrorLogAt.CompareAndSwap(last, now) {
+		return
+	}
+	log.Printf("Error writing metric: %v", err)
+}
+
+// Stats returns what the sink has done with the events written to it so far.
+func (q *QueuedSink) Stats() QueueStats {
+	return QueueStats{
+		Capacity:       cap(q.queue),
+		MaxDepth:       int(q.maxDepth.Load()),
+		Written:        q.written.Load(),
+		Dropped:        q.dropped.Load(),
+		Sampled:        q.sampled.Load(),
+		WriteErrors:    q.writeErrors.Load(),
+		Blocked:        q.blocked.Load(),
+		BlockedSeconds: time.Duration(q.blockedNanos.Load()).Seconds(),
+	}
+}
+
+// Close stops accepting events, waits for the queued ones to be written and
+// closes the underlying sink. It is safe to call more than once.
+func (q *QueuedSink) Close() error {
+	q.closeOnce.Do(func() {
+		q.mu.Lock()
+		q.closed = true
+		close(q.queue)
+		q.mu.Unlock()
+
+		<-q.done
+		q.closeErr = q.sink.Close()
+	})
+	return q.closeErr
+}
@@ -0,0 +1,243 @@
+package metrics
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"load-testing/internal/skater"
+)
+
+// gatedSink holds every write until release is closed, standing in for a
+// slow disk. started is closed by the first write.
+type gatedSink struct {
+	release chan struct{}
+	started chan struct{}
+	once    sync.Once
+	err     error
+
+	mu     sync.Mutex
+	events int
+	closed bool
+}
+
+func newGatedSink() *gatedSink {
+	return &gatedSink{release: make(chan struct{}), started: make(chan struct{})}
+}
+
+func (s *gatedSink) Write(event Event) error {
+	s.once.Do(func() { close(s.started) })
+	<-s.release
+	if s.err != nil {
+		return s.err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events++
+	return nil
+}
+
+func (s *gatedSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func testSkaterEvent() Event {
+	return SkaterEvent(skater.UpdateResult{EventID: "event-1", Timestamp: time.Now()})
+}
+
+func newTestQueue(t *testing.T, sink Sink, config QueueConfig) *QueuedSink {
+	t.Helper()
+
+	q, err := NewQueuedSink(sink, config)
+	if err != nil {
+		t.Fatalf("NewQueuedSink() error = %v", err)
+	}
+	return q
+}
+
+func writeEvents(t *testing.T, q *QueuedSink, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		if err := q.Write(testSkaterEvent()); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+}
+
+// startWriting writes one event and waits for the queue to hand it to the
+// sink, so the queue is empty and the sink busy.
+func startWriting(t *testing.T, q *QueuedSink, sink *gatedSink) {
+	t.Helper()
+
+	writeEvents(t, q, 1)
+	select {
+	case <-sink.started:
+	case <-time.After(time.Second):
+		t.Fatal("expected the queue to start writing")
+	}
+}
+
+func TestNewQueuedSink_Validation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config QueueConfig
+		want   string
+	}{
+		{"zero size", QueueConfig{Overflow: OverflowBlock}, "queue size must be positive"},
+		{"unknown policy", QueueConfig{Size: 1, Overflow: "spill"}, `unknown metrics overflow policy "spill"`},
+		{"zero sampling interval", QueueConfig{Size: 1, Overflow: OverflowSample}, "sampling interval must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewQueuedSink(newGatedSink(), tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestQueuedSink_Block(t *testing.T) {
+	sink := newGatedSink()
+	q := newTestQueue(t, sink, QueueConfig{Size: 2, Overflow: OverflowBlock})
+
+	done := make(chan error)
+	go func() {
+		var err error
+		for i := 0; i < 10 && err == nil; i++ {
+			err = q.Write(testSkaterEvent())
+		}
+		done <- err
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for len(q.queue) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the queue to fill up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(sink.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	stats := q.Stats()
+	if stats.Written != 10 || stats.Lost() != 0 {
+		t.Errorf("expected all 10 events written, got %s", stats)
+	}
+	if stats.Blocked == 0 || stats.BlockedSeconds <= 0 {
+		t.Errorf("expected blocked writes to be counted, got %s", stats)
+	}
+	if stats.MaxDepth != 2 || stats.Capacity != 2 {
+		t.Errorf("expected a max depth of 2/2, got %d/%d", stats.MaxDepth, stats.Capacity)
+	}
+	if sink.events != 10 || !sink.closed {
+		t.Errorf("expected the sink to get 10 events and be closed, got %d events, closed %t", sink.events, sink.closed)
+	}
+}
+
+func TestQueuedSink_Drop(t *testing.T) {
+	sink := newGatedSink()
+	q := newTestQueue(t, sink, QueueConfig{Size: 2, Overflow: OverflowDrop})
+
+	startWriting(t, q, sink)
+	writeEvents(t, q, 9)
+	close(sink.release)
+	q.Close()
+
+	stats := q.Stats()
+	if stats.Written != 3 || stats.Dropped != 7 || stats.Blocked != 0 {
+		t.Errorf("expected 3 written and 7 dropped, got %s", stats)
+	}
+}
+
+func TestQueuedSink_Sample(t *testing.T) {
+	sink := newGatedSink()
+	q := newTestQueue(t, sink, QueueConfig{Size: 4, Overflow: OverflowSample, SampleEvery: 2})
+
+	// The first two queue while it is under half full; after that every
+	// second event is kept until the queue is full, when the rest are dropped.
+	startWriting(t, q, sink)
+	writeEvents(t, q, 8)
+	close(sink.release)
+	q.Close()
+
+	stats := q.Stats()
+	if stats.Written != 5 || stats.Sampled != 3 || stats.Dropped != 1 {
+		t.Errorf("expected 5 written, 3 sampled out and 1 dropped, got %s", stats)
+	}
+}
+
+func TestQueuedSink_WriteErrors(t *testing.T) {
+	sink := newGatedSink()
+	sink.err = errors.New("disk full")
+	close(sink.release)
+	q := newTestQueue(t, sink, QueueConfig{Size: 10, Overflow: OverflowBlock})
+
+	writeEvents(t, q, 3)
+	q.Close()
+
+	if stats := q.Stats(); stats.WriteErrors != 3 || stats.Written != 0 {
+		t.Errorf("expected 3 write errors, got %s", stats)
+	}
+}
+
+func TestQueuedSink_Close(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "metrics.csv")
+	fileSink, err := NewCSVSink(filename, SkaterSchema)
+	if err != nil {
+		t.Fatalf("NewCSVSink() error = %v", err)
+	}
+	q := newTestQueue(t, fileSink, QueueConfig{Size: 100, Overflow: OverflowBlock})
+
+	writeEvents(t, q, 1234)
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Errorf("expected closing twice to succeed, got %v", err)
+	}
+	if err := q.Write(testSkaterEvent()); err == nil {
+		t.Error("expected writing after Close to fail")
+	}
+
+	r, err := OpenCSV(filename)
+	if err != nil {
+		t.Fatalf("OpenCSV() error = %v", err)
+	}
+	defer r.Close()
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if len(records) != 1234 {
+		t.Errorf("expected every queued event to be written before Close returned, got %d records", len(records))
+	}
+}
+
+func TestQueueStats_String(t *testing.T) {
+	stats := QueueStats{Capacity: 100, MaxDepth: 40, Written: 90, Dropped: 7, Sampled: 2, WriteErrors: 1, Blocked: 3, BlockedSeconds: 0.25}
+
+	want := "90 records written, 7 dropped, 2 sampled out, 1 write errors; max queue depth 40/100; blocked 3 times for 250ms"
+	if got := stats.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got := stats.Lost(); got != 10 {
+		t.Errorf("Lost() = %d, want 10", got)
+	}
+}
@@ -0,0 +1,361 @@
+package metrics
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// segmentFile is one file to read of a metrics file. A live segment may still
+// be being written, so its compressed stream may end part way through. A file
+// without a manifest is always treated as live, as nothing records whether it
+// was finished.
+type segmentFile struct {
+	path string
+	live bool
+}
+
+// segmentFiles returns the files holding the metrics written to filename, in
+// order. filename is a metrics file as given to NewSink, or its manifest. A
+// file at exactly filename is read on its own; otherwise the segments listed
+// in its manifest are read, or the file with a compression extension added.
+func segmentFiles(filename string) ([]segmentFile, error) {
+	if !strings.HasSuffix(filename, manifestSuffix) {
+		if _, err := os.Stat(filename); err == nil {
+			return []segmentFile{{path: filename, live: true}}, nil
+		}
+	}
+
+	manifestPath := filename
+	if !strings.HasSuffix(filename, manifestSuffix) {
+		manifestPath = ManifestPath(filename)
+	}
+	if _, err := os.Stat(manifestPath); err == nil {
+		m, err := ReadManifest(manifestPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(m.Segments) == 0 {
+			return nil, fmt.Errorf("metrics manifest %s lists no segments", manifestPath)
+		}
+
+		dir := filepath.Dir(manifestPath)
+		files := make([]segmentFile, len(m.Segments))
+		for i, seg := range m.Segments {
+			files[i] = segmentFile{path: filepath.Join(dir, seg.File), live: seg.Ended == nil}
+		}
+		return files, nil
+	}
+
+	for _, compression := range Compressions {
+		path := compressedName(filename, compression)
+		if _, err := os.Stat(path); err == nil {
+			return []segmentFile{{path: path, live: true}}, nil
+		}
+	}
+	return nil, fmt.Errorf("no metrics file or manifest for %s: %w", filename, os.ErrNotExist)
+}
+
+// openSegmentFile opens a metrics file, decompressing it according to its
+// extension.
+func openSegmentFile(seg segmentFile) (io.ReadCloser, error) {
+	file, err := os.Open(seg.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metrics file: %w", err)
+	}
+
+	var r io.Reader
+	closeReader := func() {}
+	switch {
+	case strings.HasSuffix(seg.path, compressionExtensions[CompressionGzip]):
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read %s: %w", seg.path, err)
+		}
+		r = gz
+	case strings.HasSuffix(seg.path, compressionExtensions[CompressionZstd]):
+		zr, err := zstd.NewReader(file, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read %s: %w", seg.path, err)
+		}
+		r, closeReader = zr, zr.Close
+	default:
+		r = file
+	}
+	if seg.live {
+		r = liveReader{r}
+	}
+
+	return &segmentReader{Reader: r, file: file, closeReader: closeReader}, nil
+}
+
+type segmentReader struct {
+	io.Reader
+	file        *os.File
+	closeReader func()
+}
+
+func (r *segmentReader) Close() error {
+	r.closeReader()
+	return r.file.Close()
+}
+
+// liveReader reads a compressed segment that is still being written, which
+// ends where its writer last flushed rather than with the end of the stream.
+type liveReader struct {
+	r io.Reader
+}
+
+func (l liveReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// CSVReader reads a CSV metrics file, or all the segments of a rotated one,
+// as a single stream of records with one header.
+type CSVReader struct {
+	segments []segmentFile
+	next     int
+	file     io.ReadCloser
+	reader   *csv.Reader
+	header   []string
+}
+
+// OpenCSV opens the CSV metrics file written to filename by a CSV Sink,
+// rotated or compressed or not, and reads its header. filename can also be
+// the manifest of a rotated file.
+func OpenCSV(filename string) (*CSVReader, error) {
+	segments, err := segmentFiles(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &CSVReader{segments: segments}
+	if err := r.openNext(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Header returns the column names.
+func (r *CSVReader) Header() []string {
+	return r.header
+}
+
+// Read returns the next record, or io.EOF after the last one of the last
+// segment.
+func (r *CSVReader) Read() ([]string, error) {
+	for {
+		record, err := r.reader.Read()
+		if err != io.EOF {
+			return record, err
+		}
+		if r.next == len(r.segments) {
+			return nil, io.EOF
+		}
+		if err := r.openNext(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ReadAll returns the remaining records.
+func (r *CSVReader) ReadAll() ([][]string, error) {
+	var records [][]string
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
+
+// openNext closes the current segment and opens the next, checking its
+// header matches the first segment's.
+func (r *CSVReader) openNext() error {
+	r.Close()
+
+	seg := r.segments[r.next]
+	r.next++
+
+	file, err := openSegmentFile(seg)
+	if err != nil {
+		return err
+	}
+	r.file = file
+	r.reader = csv.NewReader(file)
+
+	header, err := r.reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read the header of %s: %w", seg.path, err)
+	}
+	if r.header == nil {
+		r.header = header
+	} else if !slices.Equal(header, r.header) {
+		return fmt.Errorf("%s has different columns from the first segment", seg.path)
+	}
+	return nil
+}
+
+// Close closes the segment being read.
+func (r *CSVReader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// decoder reads the events of one segment file in one format.
+type decoder interface {
+	// decode returns the values of the next event in schema order, with the
+	// types described by ColumnType, or io.EOF after the last one.
+	decode() ([]any, error)
+	close() error
+}
+
+var newDecoders = map[string]func(segmentFile, *Schema) (decoder, error){
+	FormatCSV:     newCSVDecoder,
+	FormatJSONL:   newJSONLDecoder,
+	FormatParquet: newParquetDecoder,
+}
+
+// Reader reads the events of a metrics file in any format, rotated or
+// compressed or not, as the values a Sink was given for them.
+type Reader struct {
+	schema     *Schema
+	format     string
+	segments   []segmentFile
+	next       int
+	decoder    decoder
+	newDecoder func(segmentFile, *Schema) (decoder, error)
+}
+
+// Open opens the metrics file written to filename by a Sink for schema, or
+// the manifest of a rotated one. Its format is worked out from the file
+// extension.
+func Open(filename string, schema *Schema) (*Reader, error) {
+	segments, err := segmentFiles(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	_, ext := splitExtension(segments[0].path)
+	format := strings.TrimPrefix(ext, ".")
+	newDecoder, ok := newDecoders[format]
+	if !ok {
+		return nil, fmt.Errorf("cannot tell the format of %s from its extension (want one of %s)", segments[0].path, strings.Join(Formats, ", "))
+	}
+
+	r := &Reader{schema: schema, format: format, segments: segments, newDecoder: newDecoder}
+	if err := r.openNext(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// DetectSchema returns the first of Schemas whose columns the metrics file
+// written to filename, or its manifest, has. An empty file is taken to have
+// the first.
+func DetectSchema(filename string) (*Schema, error) {
+	for _, schema := range Schemas {
+		r, err := Open(filename, schema)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err != nil {
+			continue
+		}
+		_, err = r.Read()
+		r.Close()
+		if err == nil || err == io.EOF {
+			return schema, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a skater or viewer metrics file", filename)
+}
+
+// IsManifest reports whether filename names the manifest of a rotated
+// metrics file rather than the file itself.
+func IsManifest(filename string) bool {
+	return strings.HasSuffix(filename, manifestSuffix)
+}
+
+// Schema returns the schema of the events.
+func (r *Reader) Schema() *Schema {
+	return r.schema
+}
+
+// Format returns the format of the file, one of Formats.
+func (r *Reader) Format() string {
+	return r.format
+}
+
+// Read returns the values of the next event in schema order, or io.EOF after
+// the last event of the last segment. Values have the types described by
+// ColumnType, so a CSV file gives durations rounded to 2 decimal places and
+// timestamps rounded to the second.
+func (r *Reader) Read() ([]any, error) {
+	for {
+		values, err := r.decoder.decode()
+		if err != io.EOF {
+			if err != nil {
+				err = fmt.Errorf("failed to read %s: %w", r.segments[r.next-1].path, err)
+			}
+			return values, err
+		}
+		if r.next == len(r.segments) {
+			return nil, io.EOF
+		}
+		if err := r.openNext(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// openNext closes the current segment and opens the next.
+func (r *Reader) openNext() error {
+	if err := r.Close(); err != nil {
+		return err
+	}
+
+	seg := r.segments[r.next]
+	r.next++
+
+	d, err := r.newDecoder(seg, r.schema)
+	if err != nil {
+		return err
+	}
+	r.decoder = d
+	return nil
+}
+
+// Close closes the segment being read.
+func (r *Reader) Close() error {
+	if r.decoder == nil {
+		return nil
+	}
+	err := r.decoder.close()
+	r.decoder = nil
+	return err
+}
@@ -0,0 +1,208 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"load-testing/internal/skater"
+	"load-testing/internal/viewer"
+)
+
+func readAll(t *testing.T, filename string, schema *Schema) [][]any {
+	t.Helper()
+
+	r, err := Open(filename, schema)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+
+	var events [][]any
+	for {
+		values, err := r.Read()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		events = append(events, values)
+	}
+}
+
+func TestReader_RoundTrip(t *testing.T) {
+	timestamp := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []Event{
+		ViewerEvent(viewer.ViewerResult{
+			EventID:      "event-1",
+			ViewerNumber: 2,
+			MessageCount: 3,
+			Timestamp:    timestamp,
+			Latency:      1500 * time.Microsecond,
+			SkaterIDs:    []string{"skater-1", "skater-2"},
+			EndToEnd:     []time.Duration{40 * time.Millisecond, 0},
+			Bytes:        512,
+		}),
+		ViewerEvent(viewer.ViewerResult{EventID: "event-1", ViewerNumber: 3, Timestamp: timestamp.Add(time.Second), Error: viewer.ErrMalformedBatch}),
+		ViewerEvent(viewer.ViewerResult{EventID: "event-1", ViewerNumber: 3, Timestamp: timestamp.Add(2 * time.Second), Handshake: &viewer.Handshake{Duration: 20 * time.Millisecond, Status: 101}}),
+	}
+
+	tests := []struct {
+		format      string
+		compression string
+		rotate      bool
+	}{
+		{FormatCSV, CompressionNone, false},
+		{FormatCSV, CompressionGzip, true},
+		{FormatJSONL, CompressionNone, false},
+		{FormatJSONL, CompressionZstd, true},
+		{FormatParquet, CompressionNone, false},
+		{FormatParquet, CompressionNone, true},
+	}
+
+	for _, tt := range tests {
+		name := tt.format + "-" + tt.compression
+		if tt.rotate {
+			name += "-rotated"
+		}
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "viewer-metrics."+tt.format)
+			opts := []SinkOption{WithCompression(tt.compression)}
+			if tt.rotate {
+				opts = append(opts, WithRotation(1, 0))
+			}
+
+			sink, err := NewSink(tt.format, filename, ViewerSchema, opts...)
+			if err != nil {
+				t.Fatalf("NewSink() error = %v", err)
+			}
+			// Each event is written a batch at a time, so when rotating every
+			// event is in a segment of its own.
+			for _, event := range events {
+				for i := 0; i < flushBatchSize; i++ {
+					if err := sink.Write(event); err != nil {
+						t.Fatalf("Write() error = %v", err)
+					}
+				}
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			got := readAll(t, filename, ViewerSchema)
+			if len(got) != len(events)*flushBatchSize {
+				t.Fatalf("expected %d events, got %d", len(events)*flushBatchSize, len(got))
+			}
+			for i, values := range got {
+				want := events[i/flushBatchSize].Values()
+				for j, c := range ViewerSchema.Columns {
+					if !equalValues(c.Type, values[j], want[j]) {
+						t.Errorf("event %d %s = %#v, want %#v", i, c.Name, values[j], want[j])
+					}
+				}
+			}
+		})
+	}
+}
+
+// equalValues compares a value read back with the value written, allowing
+// for the rounding of CSV durations.
+func equalValues(t ColumnType, got, want any) bool {
+	switch t {
+	case TimeColumn:
+		return got.(time.Time).Equal(want.(time.Time))
+	case FloatColumn:
+		if got == nil || want == nil {
+			return got == want
+		}
+		return math.Abs(got.(float64)-want.(float64)) < 0.01
+	case StringListColumn, FloatListColumn:
+		if reflect.ValueOf(got).Len() == 0 && reflect.ValueOf(want).Len() == 0 {
+			return true
+		}
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+func TestReader_WrongSchema(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "metrics."+format)
+			sink, err := NewSink(format, filename, SkaterSchema)
+			if err != nil {
+				t.Fatalf("NewSink() error = %v", err)
+			}
+			if err := sink.Write(SkaterEvent(skater.UpdateResult{EventID: "event-1", Timestamp: time.Now()})); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			sink.Close()
+
+			r, err := Open(filename, ViewerSchema)
+			if err == nil {
+				_, err = r.Read()
+				r.Close()
+			}
+			if err == nil || !strings.Contains(err.Error(), "viewer metrics file") {
+				t.Errorf("expected a schema mismatch error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOpen_UnknownFormat(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "metrics.txt")
+	sink, err := NewCSVSink(filename, SkaterSchema)
+	if err != nil {
+		t.Fatalf("NewCSVSink() error = %v", err)
+	}
+	sink.Close()
+
+	if _, err := Open(filename, SkaterSchema); err == nil || !strings.Contains(err.Error(), "cannot tell the format") {
+		t.Errorf("expected an unknown format error, got %v", err)
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.csv"), SkaterSchema); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+}
+
+func TestDetectSchema(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			events := map[*Schema]Event{
+				SkaterSchema: SkaterEvent(skater.UpdateResult{EventID: "event-1", Timestamp: time.Now()}),
+				ViewerSchema: ViewerEvent(viewer.ViewerResult{EventID: "event-1", ViewerNumber: 1, Timestamp: time.Now()}),
+			}
+			for schema, event := range events {
+				filename := filepath.Join(dir, schema.Name+"-metrics."+format)
+				sink, err := NewSink(format, filename, schema)
+				if err != nil {
+					t.Fatalf("NewSink() error = %v", err)
+				}
+				if err := sink.Write(event); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				sink.Close()
+
+				got, err := DetectSchema(filename)
+				if err != nil {
+					t.Fatalf("DetectSchema() error = %v", err)
+				}
+				if got != schema {
+					t.Errorf("expected the %s schema, got %s", schema.Name, got.Name)
+				}
+			}
+		})
+	}
+
+	if _, err := DetectSchema(filepath.Join(t.TempDir(), "missing.csv")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+package metrics
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Metrics file compressions accepted by WithCompression.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// Compressions lists the metrics file compressions, none first as the
+// default.
+var Compressions = []string{CompressionNone, CompressionGzip, CompressionZstd}
+
+var compressionExtensions = map[string]string{
+	CompressionNone: "",
+	CompressionGzip: ".gz",
+	CompressionZstd: ".zst",
+}
+
+const manifestSuffix = ".manifest.json"
+
+// Manifest lists the segments of a rotated metrics file. It is rewritten
+// whenever a segment is started or finished, so it describes the files
+// written so far during a run too.
+type Manifest struct {
+	Schema      string    `json:"schema"`
+	Format      string    `json:"format"`
+	Compression string    `json:"compression"`
+	Columns     []string  `json:"columns"`
+	Segments    []Segment `json:"segments"`
+}
+
+// Segment is one file of a rotated metrics file. Ended is nil, and Events
+// and Bytes are not yet final, while the segment is being written.
+type Segment struct {
+	// File is the segment's file name, in the manifest's directory.
+	File    string     `json:"file"`
+	Started time.Time  `json:"started"`
+	Ended   *time.Time `json:"ended,omitempty"`
+	Events  int        `json:"events"`
+	Bytes   int64      `json:"bytes"`
+}
+
+// ManifestPath returns the path of the manifest of a metrics file rotated
+// with WithRotation: the file name with its extension replaced, e.g.
+// metrics.manifest.json for metrics.csv.
+func ManifestPath(filename string) string {
+	stem, _ := splitExtension(filename)
+	return stem + manifestSuffix
+}
+
+// segmentName returns the name of the nth segment of a rotated metrics file,
+// e.g. metrics-0001.csv.gz for metrics.csv.
+func segmentName(filename string, n int, compression string) string {
+	stem, ext := splitExtension(filename)
+	return fmt.Sprintf("%s-%04d%s%s", stem, n, ext, compressionExtensions[compression])
+}
+
+// compressedName returns filename with the extension of compression added,
+// unless it already has it.
+func compressedName(filename, compression string) string {
+	ext := compressionExtensions[compression]
+	if strings.HasSuffix(filename, ext) {
+		return filename
+	}
+	return filename + ext
+}
+
+// splitExtension splits a metrics file name into the part before its format
+// extension and the extension, ignoring any compression extension.
+func splitExtension(filename string) (stem, ext string) {
+	for _, compressionExt := range compressionExtensions {
+		if compressionExt != "" && strings.HasSuffix(filename, compressionExt) {
+			filename = strings.TrimSuffix(filename, compressionExt)
+			break
+		}
+	}
+	ext = filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext), ext
+}
+
+// write replaces the manifest at path. The new manifest is written to a
+// temporary file first, so readers never see a partly written one.
+func (m *Manifest) write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics manifest: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write metrics manifest: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write metrics manifest: %w", err)
+	}
+	return nil
+}
+
+// ReadManifest reads the manifest of a rotated metrics file.
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics manifest: %w", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse metrics manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// compressor is the writer of a compression: a gzip.Writer or zstd.Encoder.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func newCompressor(w io.Writer, compression string) (compressor, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, nil
+	}
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// segment is an open metrics file: the file, an optional compressor and the
+// encoder writing to them.
+type segment struct {
+	path       string
+	file       *os.File
+	counter    *countingWriter
+	compressor compressor
+	enc        encoder
+	started    time.Time
+	events     int
+}
+
+func openSegment(path string, schema *Schema, compression string, newEncoder func(io.Writer, *Schema) (encoder, error)) (*segment, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics file: %w", err)
+	}
+
+	seg := &segment{path: path, file: file, counter: &countingWriter{w: file}, started: time.Now()}
+	var w io.Writer = seg.counter
+	seg.compressor, err = newCompressor(w, compression)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to start %s compression: %w", compression, err)
+	}
+	if seg.compressor != nil {
+		w = seg.compressor
+	}
+
+	seg.enc, err = newEncoder(w, schema)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if seg.compressor != nil {
+		if err := seg.compressor.Flush(); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write metrics file: %w", err)
+		}
+	}
+	return seg, nil
+}
+
+func (s *segment) encode(values []any) error {
+	if err := s.enc.encode(values); err != nil {
+		return err
+	}
+	s.events++
+	return nil
+}
+
+func (s *segment) flush() error {
+	if err := s.enc.flush(); err != nil {
+		return err
+	}
+	if s.compressor != nil {
+		return s.compressor.Flush()
+	}
+	return nil
+}
+
+// full reports whether the segment has reached either rotation limit.
+func (s *segment) full(config sinkConfig, now time.Time) bool {
+	return (config.rotateSize > 0 && s.counter.n >= config.rotateSize) ||
+		(config.rotateInterval > 0 && now.Sub(s.started) >= config.rotateInterval)
+}
+
+func (s *segment) close() error {
+	err := s.enc.close()
+	if s.compressor != nil {
+		err = errors.Join(err, s.compressor.Close())
+	}
+	return errors.Join(err, s.file.Close())
+}
+
+// entry returns the manifest entry for the segment as written so far.
+func (s *segment) entry() Segment {
+	return Segment{
+		File:    filepath.Base(s.path),
+		Started: s.started,
+		Events:  s.events,
+		Bytes:   s.counter.n,
+	}
+}
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a file size such as 500MB or 2GB. Units are powers of
+// 1024, and a number without a unit is a number of bytes.
+func ParseSize(s string) (int64, error) {
+	number, unit := strings.TrimSpace(s), int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(strings.ToUpper(number), u.suffix) {
+			number, unit = strings.TrimSpace(number[:len(number)-len(u.suffix)]), u.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (e.g. 500MB, 2GB)", s)
+	}
+	return int64(n * float64(unit)), nil
+}
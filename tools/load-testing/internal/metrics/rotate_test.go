@@ -0,0 +1,208 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"load-testing/internal/skater"
+)
+
+func writeSkaterResults(t *testing.T, sink Sink, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		result := skater.UpdateResult{
+			EventID:   "event-1",
+			SkaterID:  fmt.Sprintf("skater-%d", i),
+			Timestamp: time.Now(),
+		}
+		if err := sink.Write(SkaterEvent(result)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+}
+
+func readSkaterIDs(t *testing.T, filename string) []string {
+	t.Helper()
+
+	reader, err := OpenCSV(filename)
+	if err != nil {
+		t.Fatalf("OpenCSV() error = %v", err)
+	}
+	defer reader.Close()
+
+	if got := len(reader.Header()); got != len(SkaterSchema.Columns) {
+		t.Fatalf("expected %d columns in the header, got %d", len(SkaterSchema.Columns), got)
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	ids := make([]string, len(records))
+	for i, record := range records {
+		ids[i] = record[2]
+	}
+	return ids
+}
+
+func TestFileSink_Rotation(t *testing.T) {
+	for _, compression := range Compressions {
+		t.Run(compression, func(t *testing.T) {
+			dir := t.TempDir()
+			filename := filepath.Join(dir, "metrics.csv")
+
+			sink, err := NewCSVSink(filename, SkaterSchema, WithRotation(1, 0), WithCompression(compression))
+			if err != nil {
+				t.Fatalf("NewCSVSink() error = %v", err)
+			}
+			writeSkaterResults(t, sink, 3*flushBatchSize)
+			if err := sink.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			manifest, err := ReadManifest(filepath.Join(dir, "metrics.manifest.json"))
+			if err != nil {
+				t.Fatalf("ReadManifest() error = %v", err)
+			}
+			if manifest.Schema != "skater" || manifest.Format != FormatCSV || manifest.Compression != compression {
+				t.Errorf("unexpected manifest %+v", manifest)
+			}
+
+			// Every flush fills a 1 byte segment, and closing the sink
+			// finishes the empty segment started after the last one.
+			if len(manifest.Segments) != 4 {
+				t.Fatalf("expected 4 segments, got %d", len(manifest.Segments))
+			}
+			for i, seg := range manifest.Segments {
+				if want := segmentName("metrics.csv", i+1, compression); seg.File != want {
+					t.Errorf("segment %d: expected file %s, got %s", i, want, seg.File)
+				}
+				if seg.Ended == nil {
+					t.Errorf("segment %d: expected an end time", i)
+				}
+				if info, err := os.Stat(filepath.Join(dir, seg.File)); err != nil || info.Size() != seg.Bytes {
+					t.Errorf("segment %d: expected %d bytes on disk, got %v (%v)", i, seg.Bytes, info.Size(), err)
+				}
+			}
+			if got := manifest.Segments[1].Events; got != flushBatchSize {
+				t.Errorf("expected %d events in a segment, got %d", flushBatchSize, got)
+			}
+
+			ids := readSkaterIDs(t, filename)
+			if len(ids) != 3*flushBatchSize {
+				t.Fatalf("expected %d records across segments, got %d", 3*flushBatchSize, len(ids))
+			}
+			for i, id := range ids {
+				if want := fmt.Sprintf("skater-%d", i); id != want {
+					t.Errorf("record %d: expected %s, got %s", i, want, id)
+				}
+			}
+		})
+	}
+}
+
+func TestFileSink_CompressedWithoutRotation(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "metrics.csv")
+
+	sink, err := NewCSVSink(filename, SkaterSchema, WithCompression(CompressionGzip))
+	if err != nil {
+		t.Fatalf("NewCSVSink() error = %v", err)
+	}
+	writeSkaterResults(t, sink, 5)
+
+	// The file can be read while it is still being written, up to the
+	// last flush.
+	if got := len(readSkaterIDs(t, filename)); got != 0 {
+		t.Errorf("expected no records before the first flush, got %d", got)
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if _, err := os.Stat(filename + ".gz"); err != nil {
+		t.Errorf("expected the file to be written with a .gz extension: %v", err)
+	}
+	if got := len(readSkaterIDs(t, filename)); got != 5 {
+		t.Errorf("expected 5 records, got %d", got)
+	}
+}
+
+func TestCSVReader_LiveSegment(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "metrics.csv")
+
+	sink, err := NewCSVSink(filename, SkaterSchema, WithRotation(0, time.Hour), WithCompression(CompressionZstd))
+	if err != nil {
+		t.Fatalf("NewCSVSink() error = %v", err)
+	}
+	defer sink.Close()
+
+	writeSkaterResults(t, sink, flushBatchSize)
+
+	if got := len(readSkaterIDs(t, ManifestPath(filename))); got != flushBatchSize {
+		t.Errorf("expected the %d flushed records of the open segment, got %d", flushBatchSize, got)
+	}
+}
+
+func TestParquetSink_RejectsCompression(t *testing.T) {
+	_, err := NewParquetSink(filepath.Join(t.TempDir(), "metrics.parquet"), SkaterSchema, WithCompression(CompressionGzip))
+	if err == nil {
+		t.Error("expected an error for compressed Parquet")
+	}
+}
+
+func TestSegmentName(t *testing.T) {
+	tests := []struct {
+		filename    string
+		compression string
+		segment     string
+		manifest    string
+	}{
+		{"metrics.csv", CompressionNone, "metrics-0001.csv", "metrics.manifest.json"},
+		{"metrics.csv", CompressionGzip, "metrics-0001.csv.gz", "metrics.manifest.json"},
+		{"out/viewer-metrics.jsonl.zst", CompressionZstd, "out/viewer-metrics-0001.jsonl.zst", "out/viewer-metrics.manifest.json"},
+		{"metrics", CompressionNone, "metrics-0001", "metrics.manifest.json"},
+	}
+	for _, tt := range tests {
+		if got := segmentName(tt.filename, 1, tt.compression); got != tt.segment {
+			t.Errorf("segmentName(%q, 1, %s) = %q, want %q", tt.filename, tt.compression, got, tt.segment)
+		}
+		if got := ManifestPath(tt.filename); got != tt.manifest {
+			t.Errorf("ManifestPath(%q) = %q, want %q", tt.filename, got, tt.manifest)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{"1024", 1024, false},
+		{"10B", 10, false},
+		{"4KB", 4 << 10, false},
+		{"500MB", 500 << 20, false},
+		{"1.5gb", 3 << 29, false},
+		{"", 0, true},
+		{"MB", 0, true},
+		{"-1MB", 0, true},
+		{"ten", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink writes result events to an output. Every output format is a Sink for
+// any Schema, so both simulators and every kind of result share the same
+// outputs. Implementations are safe for concurrent use.
+type Sink interface {
+	// Write adds a single event.
+	Write(event Event) error
+	// Close writes out any buffered events and closes the output.
+	Close() error
+}
+
+const (
+	flushBatchSize = 10
+	flushInterval  = time.Second
+)
+
+// Metrics file formats accepted by NewSink.
+const (
+	FormatCSV     = "csv"
+	FormatJSONL   = "jsonl"
+	FormatParquet = "parquet"
+)
+
+// Formats lists the metrics file formats, CSV first as the default.
+var Formats = []string{FormatCSV, FormatJSONL, FormatParquet}
+
+// SinkOption configures optional behaviour of a Sink created with NewSink or
+// one of the format constructors.
+type SinkOption func(*sinkConfig)
+
+type sinkConfig struct {
+	rotateSize     int64
+	rotateInterval time.Duration
+	compression    string
+}
+
+// WithRotation splits the output into numbered segments, starting a new one
+// once the current segment holds size bytes or has been open for interval,
+// and lists them in a manifest (see ManifestPath). A zero size or interval
+// does not limit segments by it; with both zero the output is a single file.
+func WithRotation(size int64, interval time.Duration) SinkOption {
+	return func(c *sinkConfig) {
+		c.rotateSize = size
+		c.rotateInterval = interval
+	}
+}
+
+// WithCompression compresses the output with compression, one of
+// Compressions, adding its extension to the file names.
+func WithCompression(compression string) SinkOption {
+	return func(c *sinkConfig) {
+		c.compression = compression
+	}
+}
+
+func newSinkConfig(opts []SinkOption) (sinkConfig, error) {
+	config := sinkConfig{compression: CompressionNone}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	if _, ok := compressionExtensions[config.compression]; !ok {
+		return config, fmt.Errorf("unknown metrics compression %q (want one of %s)", config.compression, strings.Join(Compressions, ", "))
+	}
+	if config.rotateSize < 0 || config.rotateInterval < 0 {
+		return config, fmt.Errorf("metrics rotation limits must not be negative")
+	}
+	return config, nil
+}
+
+func (c sinkConfig) rotating() bool {
+	return c.rotateSize > 0 || c.rotateInterval > 0
+}
+
+// NewSink creates a Sink that writes events of the given schema to the named
+// file in format, one of Formats.
+func NewSink(format, filename string, schema *Schema, opts ...SinkOption) (Sink, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVSink(filename, schema, opts...)
+	case FormatJSONL:
+		return NewJSONLSink(filename, schema, opts...)
+	case FormatParquet:
+		return NewParquetSink(filename, schema, opts...)
+	default:
+		return nil, fmt.Errorf("unknown metrics format %q (want one of %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// encoder writes events in one format, buffering them until flushed.
+type encoder interface {
+	encode(values []any) error
+	flush() error
+	// close writes out everything still buffered and anything that comes
+	// after the events, such as a footer. It does not close the file.
+	close() error
+}
+
+// fileSink writes events of a single schema to a file, or a rotated set of
+// segment files, through an encoder. It writes out buffered events every
+// flushBatchSize events, and after flushInterval otherwise, so the file can
+// be followed during a run without a write for every event.
+type fileSink struct {
+	schema     *Schema
+	filename   string
+	config     sinkConfig
+	newEncoder func(io.Writer, *Schema) (encoder, error)
+	stop       chan struct{}
+	stopped    chan struct{}
+	once       sync.Once
+
+	mu       sync.Mutex
+	segment  *segment
+	manifest *Manifest
+	pending  int
+}
+
+// newFileSink creates the named file, or the first segment of a rotated set,
+// and an encoder for it. The encoder writes anything that comes before the
+// events, such as a header, before returning.
+func newFileSink(format, filename string, schema *Schema, newEncoder func(io.Writer, *Schema) (encoder, error), opts []SinkOption) (*fileSink, error) {
+	config, err := newSinkConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &fileSink{
+		schema:     schema,
+		filename:   filename,
+		config:     config,
+		newEncoder: newEncoder,
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	if config.rotating() {
+		s.manifest = &Manifest{
+			Schema:      schema.Name,
+			Format:      format,
+			Compression: config.compression,
+			Columns:     schema.ColumnNames(),
+		}
+	}
+	if err := s.openSegment(); err != nil {
+		return nil, err
+	}
+
+	go s.flushLoop()
+	return s, nil
+}
+
+// openSegment starts the next output file. s.mu must be held, or s not yet
+// shared.
+func (s *fileSink) openSegment() error {
+	path := compressedName(s.filename, s.config.compression)
+	if s.manifest != nil {
+		path = segmentName(s.filename, len(s.manifest.Segments)+1, s.config.compression)
+	}
+
+	seg, err := openSegment(path, s.schema, s.config.compression, s.newEncoder)
+	if err != nil {
+		return err
+	}
+	s.segment = seg
+
+	if s.manifest != nil {
+		s.manifest.Segments = append(s.manifest.Segments, seg.entry())
+		if err := s.manifest.write(ManifestPath(s.filename)); err != nil {
+			return errors.Join(err, seg.close())
+		}
+	}
+	return nil
+}
+
+// closeSegment closes the current output file and records it in the
+// manifest. s.mu must be held.
+func (s *fileSink) closeSegment() error {
+	err := s.segment.close()
+	if s.manifest == nil {
+		return err
+	}
+
+	entry := s.segment.entry()
+	ended := time.Now()
+	entry.Ended = &ended
+	s.manifest.Segments[len(s.manifest.Segments)-1] = entry
+	return errors.Join(err, s.manifest.write(ManifestPath(s.filename)))
+}
+
+func (s *fileSink) Write(event Event) error {
+	if event.Schema() != s.schema {
+		return fmt.Errorf("cannot write a %s result to a %s metrics file", event.Schema().Name, s.schema.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.segment == nil {
+		return fmt.Errorf("cannot write to a closed metrics file")
+	}
+	if err := s.segment.encode(event.Values()); err != nil {
+		return fmt.Errorf("failed to write metrics record: %w", err)
+	}
+	s.pending++
+	if s.pending >= flushBatchSize {
+		return s.flush()
+	}
+	return nil
+}
+
+// writeBatch writes several events, taking the lock once. It stops at the
+// first event that cannot be written.
+func (s *fileSink) writeBatch(events []Event) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.segment == nil {
+		return 0, fmt.Errorf("cannot write to a closed metrics file")
+	}
+	for i, event := range events {
+		if event.Schema() != s.schema {
+			return i, fmt.Errorf("cannot write a %s result to a %s metrics file", event.Schema().Name, s.schema.Name)
+		}
+		if err := s.segment.encode(event.Values()); err != nil {
+			return i, fmt.Errorf("failed to write metrics record: %w", err)
+		}
+		s.pending++
+	}
+	if s.pending >= flushBatchSize {
+		return len(events), s.flush()
+	}
+	return len(events), nil
+}
+
+// flush writes out buffered events, then starts a new segment if the
+// current one is full. s.mu must be held.
+func (s *fileSink) flush() error {
+	s.pending = 0
+	if err := s.segment.flush(); err != nil {
+		return err
+	}
+
+	if s.manifest == nil || !s.segment.full(s.config, time.Now()) {
+		return nil
+	}
+	if err := s.closeSegment(); err != nil {
+		return fmt.Errorf("failed to close metrics segment: %w", err)
+	}
+	return s.openSegment()
+}
+
+func (s *fileSink) flushLoop() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.pending > 0 {
+				_ = s.flush()
+			}
+			s.mu.Unlock()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *fileSink) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	<-s.stopped
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.segment == nil {
+		return nil
+	}
+	s.pending = 0
+	err := s.closeSegment()
+	s.segment = nil
+	return err
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"load-testing/internal/skater"
+	"load-testing/internal/viewer"
+)
+
+func countLines(t *testing.T, filename string) int {
+	t.Helper()
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	return strings.Count(string(content), "\n")
+}
+
+func TestFileSink_FlushPolicy(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "metrics.csv")
+
+	sink, err := NewCSVSink(filename, SkaterSchema)
+	if err != nil {
+		t.Fatalf("NewCSVSink() error = %v", err)
+	}
+	defer sink.Close()
+
+	for i := 0; i < flushBatchSize+1; i++ {
+		if err := sink.Write(SkaterEvent(skater.UpdateResult{EventID: "event-1", Timestamp: time.Now()})); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if got := countLines(t, filename); got != flushBatchSize+1 {
+		t.Errorf("expected the header and a full batch to be written, got %d lines", got)
+	}
+
+	deadline := time.Now().Add(3 * flushInterval)
+	for countLines(t, filename) != flushBatchSize+2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the remaining record to be written within %s", flushInterval)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestFileSink_RejectsOtherSchemas(t *testing.T) {
+	sink, err := NewCSVSink(filepath.Join(t.TempDir(), "metrics.csv"), SkaterSchema)
+	if err != nil {
+		t.Fatalf("NewCSVSink() error = %v", err)
+	}
+	defer sink.Close()
+
+	err = sink.Write(ViewerEvent(viewer.ViewerResult{}))
+	if err == nil || !strings.Contains(err.Error(), "viewer result to a skater metrics file") {
+		t.Errorf("expected a schema mismatch error, got %v", err)
+	}
+}
+
+func TestEvents_MatchSchemas(t *testing.T) {
+	events := []Event{
+		SkaterEvent(skater.UpdateResult{}),
+		ViewerEvent(viewer.ViewerResult{}),
+		ViewerEvent(viewer.ViewerResult{Reconnect: &viewer.Reconnect{}, Handshake: &viewer.Handshake{Status: 101}}),
+	}
+	for _, event := range events {
+		schema := event.Schema()
+		if got := len(event.Values()); got != len(schema.Columns) {
+			t.Errorf("%s event has %d values for %d columns", schema.Name, got, len(schema.Columns))
+		}
+		if last := schema.Columns[len(schema.Columns)-1]; last.Name != "error" {
+			t.Errorf("%s schema should end with the error column, ends with %s", schema.Name, last.Name)
+		}
+	}
+}
+
+func TestNewSink_Formats(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			sink, err := NewSink(format, filepath.Join(t.TempDir(), "metrics."+format), SkaterSchema)
+			if err != nil {
+				t.Fatalf("NewSink() error = %v", err)
+			}
+			if err := sink.Write(SkaterEvent(skater.UpdateResult{EventID: "event-1", Timestamp: time.Now()})); err != nil {
+				t.Errorf("Write() error = %v", err)
+			}
+			if err := sink.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+		})
+	}
+
+	if _, err := NewSink("xml", filepath.Join(t.TempDir(), "metrics.xml"), SkaterSchema); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SLO names reported when a threshold is breached. They match the
+// command-line flags that set each threshold.
+const (
+	SLOMaxErrorRate         = "max-error-rate"
+	SLOMaxP99Response       = "max-p99-response"
+	SLOMaxP99Latency        = "max-p99-latency"
+	SLOMinMessagesPerViewer = "min-messages-per-viewer"
+	SLOMaxMissingSkaters    = "max-missing-skaters"
+	SLOMaxBatchSize         = "max-batch-size"
+)
+
+// DefaultMaxBatchSize is the most locations the API sends in one batch,
+// matching skatemap.stream.batchSize in services/api/src/main/resources/application.conf.
+const DefaultMaxBatchSize = 100
+
+// MinSamplesForContinuousCheck is the number of results needed before
+// thresholds are checked during a run. Percentiles and rates over a handful
+// of early results are too noisy to stop a run on.
+const MinSamplesForContinuousCheck = 100
+
+// Breach describes a single SLO threshold that was not met.
+type Breach struct {
+	SLO     string
+	Message string
+}
+
+func (b Breach) String() string {
+	return fmt.Sprintf("%s: %s", b.SLO, b.Message)
+}
+
+// SLOError is returned when one or more SLO thresholds were breached.
+type SLOError struct {
+	Breaches []Breach
+}
+
+func (e *SLOError) Error() string {
+	parts := make([]string, len(e.Breaches))
+	for i, b := range e.Breaches {
+		parts[i] = b.String()
+	}
+	return "SLO breached: " + strings.Join(parts, "; ")
+}
+
+// SkaterThresholds are the pass/fail thresholds for a skater simulation run.
+// A negative MaxErrorRate or a zero MaxP99Response disables that check.
+type SkaterThresholds struct {
+	MaxErrorRate   float64
+	MaxP99Response time.Duration
+}
+
+// Check compares a report against the thresholds and returns any breaches.
+// When final is false the check is being made during the run, and is skipped
+// until at least MinSamplesForContinuousCheck requests have been made.
+func (t SkaterThresholds) Check(report SkaterReport, final bool) []Breach {
+	if !final && report.Requests < MinSamplesForContinuousCheck {
+		return nil
+	}
+
+	var breaches []Breach
+	if b, ok := checkErrorRate(t.MaxErrorRate, report.Errors, report.Requests); ok {
+		breaches = append(breaches, b)
+	}
+	if b, ok := checkP99(SLOMaxP99Response, "response time", t.MaxP99Response, report.ResponseTime); ok {
+		breaches = append(breaches, b)
+	}
+	return breaches
+}
+
+// ViewerThresholds are the pass/fail thresholds for a viewer simulation run.
+// A negative MaxErrorRate or MaxMissingSkaters, or a zero MaxP99Latency,
+// MinMessagesPerViewer or MaxBatchSize, disables that check. The viewer error rate is errors
+// as a fraction of messages and errors combined. MaxMissingSkaters is only
+// checked when the report includes a delivery check.
+type ViewerThresholds struct {
+	MaxErrorRate         float64
+	MaxP99Latency        time.Duration
+	MinMessagesPerViewer int
+	MaxMissingSkaters    int
+	MaxBatchSize         int
+}
+
+// Check compares a report against the thresholds and returns any breaches.
+// When final is false the check is being made during the run: it is skipped
+// until at least MinSamplesForContinuousCheck results have been recorded, and
+// messages per viewer, which can only grow, and missing skaters, which are
+// only known at the end, are not checked.
+func (t ViewerThresholds) Check(report ViewerReport, final bool) []Breach {
+	results := report.Messages + report.Errors
+	if !final && results < MinSamplesForContinuousCheck {
+		return nil
+	}
+
+	var breaches []Breach
+	if b, ok := checkErrorRate(t.MaxErrorRate, report.Errors, results); ok {
+		breaches = append(breaches, b)
+	}
+	if b, ok := checkP99(SLOMaxP99Latency, "latency", t.MaxP99Latency, report.Latency); ok {
+		breaches = append(breaches, b)
+	}
+	if final && t.MinMessagesPerViewer > 0 && report.MessagesPerViewer.Min < t.MinMessagesPerViewer {
+		breaches = append(breaches, Breach{
+			SLO: SLOMinMessagesPerViewer,
+			Message: fmt.Sprintf("a viewer received %d messages, minimum is %d",
+				report.MessagesPerViewer.Min, t.MinMessagesPerViewer),
+		})
+	}
+	if t.MaxBatchSize > 0 && report.LocationsPerBatch.Max > t.MaxBatchSize {
+		breaches = append(breaches, Breach{
+			SLO: SLOMaxBatchSize,
+			Message: fmt.Sprintf("a batch held %d locations, maximum is %d",
+				report.LocationsPerBatch.Max, t.MaxBatchSize),
+		})
+	}
+	if final && t.MaxMissingSkaters >= 0 && report.Delivery != nil && report.Delivery.MissingSkaters > t.MaxMissingSkaters {
+		breaches = append(breaches, Breach{
+			SLO: SLOMaxMissingSkaters,
+			Message: fmt.Sprintf("%d skaters never reached a viewer that should have seen them, maximum is %d",
+				report.Delivery.MissingSkaters, t.MaxMissingSkaters),
+		})
+	}
+	return breaches
+}
+
+func checkErrorRate(maxRate float64, errors, total int) (Breach, bool) {
+	if maxRate < 0 {
+		return Breach{}, false
+	}
+
+	errorRate := fraction(errors, total)
+	if errorRate <= maxRate {
+		return Breach{}, false
+	}
+	return Breach{
+		SLO: SLOMaxErrorRate,
+		Message: fmt.Sprintf("error rate %.2f%% (%d of %d) exceeds %.2f%%",
+			errorRate*100, errors, total, maxRate*100),
+	}, true
+}
+
+func checkP99(slo, what string, limit time.Duration, stats LatencyStats) (Breach, bool) {
+	if limit <= 0 || stats.Count == 0 {
+		return Breach{}, false
+	}
+
+	limitMs := milliseconds(limit)
+	if stats.P99 <= limitMs {
+		return Breach{}, false
+	}
+	return Breach{
+		SLO:     slo,
+		Message: fmt.Sprintf("p99 %s %.2fms exceeds %.2fms", what, stats.P99, limitMs),
+	}, true
+}
@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSkaterThresholds_Check(t *testing.T) {
+	report := SkaterReport{
+		Requests:     200,
+		Errors:       4,
+		ResponseTime: LatencyStats{Count: 200, P99: 250},
+	}
+
+	tests := []struct {
+		name       string
+		thresholds SkaterThresholds
+		final      bool
+		expected   []string
+	}{
+		{"disabled", SkaterThresholds{MaxErrorRate: -1}, true, nil},
+		{"within thresholds", SkaterThresholds{MaxErrorRate: 0.05, MaxP99Response: 300 * time.Millisecond}, true, nil},
+		{"error rate breached", SkaterThresholds{MaxErrorRate: 0.01}, true, []string{SLOMaxErrorRate}},
+		{"p99 breached", SkaterThresholds{MaxErrorRate: -1, MaxP99Response: 200 * time.Millisecond}, true, []string{SLOMaxP99Response}},
+		{"both breached during run", SkaterThresholds{MaxErrorRate: 0, MaxP99Response: 200 * time.Millisecond}, false,
+			[]string{SLOMaxErrorRate, SLOMaxP99Response}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaches := tt.thresholds.Check(report, tt.final)
+			if len(breaches) != len(tt.expected) {
+				t.Fatalf("expected breaches %v, got %v", tt.expected, breaches)
+			}
+			for i, slo := range tt.expected {
+				if breaches[i].SLO != slo {
+					t.Errorf("breach %d: expected %s, got %s", i, slo, breaches[i].SLO)
+				}
+			}
+		})
+	}
+}
+
+func TestSkaterThresholds_CheckWaitsForSamples(t *testing.T) {
+	report := SkaterReport{Requests: MinSamplesForContinuousCheck - 1, Errors: 5}
+	thresholds := SkaterThresholds{MaxErrorRate: 0}
+
+	if breaches := thresholds.Check(report, false); len(breaches) != 0 {
+		t.Errorf("expected no breaches before enough samples, got %v", breaches)
+	}
+	if breaches := thresholds.Check(report, true); len(breaches) != 1 {
+		t.Errorf("expected final check to report the breach, got %v", breaches)
+	}
+}
+
+func TestViewerThresholds_Check(t *testing.T) {
+	report := ViewerReport{
+		Messages:          300,
+		Errors:            0,
+		MessagesPerViewer: CountStats{Min: 2, Mean: 100, Max: 150},
+		Latency:           LatencyStats{Count: 300, P99: 80},
+	}
+	thresholds := ViewerThresholds{MaxErrorRate: 0, MaxP99Latency: 50 * time.Millisecond, MinMessagesPerViewer: 5, MaxMissingSkaters: -1}
+
+	during := thresholds.Check(report, false)
+	if len(during) != 1 || during[0].SLO != SLOMaxP99Latency {
+		t.Errorf("expected only the latency breach during the run, got %v", during)
+	}
+
+	final := thresholds.Check(report, true)
+	if len(final) != 2 || final[1].SLO != SLOMinMessagesPerViewer {
+		t.Errorf("expected latency and messages per viewer breaches at the end, got %v", final)
+	}
+}
+
+func TestViewerThresholds_CheckMissingSkaters(t *testing.T) {
+	report := ViewerReport{Messages: 300, Delivery: &DeliveryReport{ExpectedSkaters: 10, MissingSkaters: 2}}
+
+	tests := []struct {
+		name       string
+		maxMissing int
+		final      bool
+		report     ViewerReport
+		want       bool
+	}{
+		{name: "unchecked", maxMissing: -1, final: true, report: report},
+		{name: "within limit", maxMissing: 2, final: true, report: report},
+		{name: "breached", maxMissing: 0, final: true, report: report, want: true},
+		{name: "not checked during the run", maxMissing: 0, report: report},
+		{name: "no delivery check", maxMissing: 0, final: true, report: ViewerReport{Messages: 300}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thresholds := ViewerThresholds{MaxErrorRate: -1, MaxMissingSkaters: tt.maxMissing}
+			breaches := thresholds.Check(tt.report, tt.final)
+			if got := len(breaches) == 1 && breaches[0].SLO == SLOMaxMissingSkaters; got != tt.want || len(breaches) > 1 {
+				t.Errorf("expected missing skaters breach %v, got %v", tt.want, breaches)
+			}
+		})
+	}
+}
+
+func TestViewerThresholds_CheckBatchSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxSize int
+		largest int
+		want    bool
+	}{
+		{name: "unchecked", maxSize: 0, largest: 150},
+		{name: "within limit", maxSize: DefaultMaxBatchSize, largest: 100},
+		{name: "breached", maxSize: DefaultMaxBatchSize, largest: 101, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := ViewerReport{Messages: 300, LocationsPerBatch: CountStats{Min: 1, Mean: 50, Max: tt.largest}}
+			thresholds := ViewerThresholds{MaxErrorRate: -1, MaxMissingSkaters: -1, MaxBatchSize: tt.maxSize}
+			breaches := thresholds.Check(report, false)
+			if got := len(breaches) == 1 && breaches[0].SLO == SLOMaxBatchSize; got != tt.want || len(breaches) > 1 {
+				t.Errorf("expected batch size breach %v, got %v", tt.want, breaches)
+			}
+		})
+	}
+}
+
+func TestSLOError(t *testing.T) {
+	err := &SLOError{Breaches: []Breach{
+		{SLO: SLOMaxErrorRate, Message: "error rate 2.00% (4 of 200) exceeds 1.00%"},
+		{SLO: SLOMaxP99Response, Message: "p99 response time 250.00ms exceeds 200.00ms"},
+	}}
+
+	expected := "SLO breached: max-error-rate: error rate 2.00% (4 of 200) exceeds 1.00%; " +
+		"max-p99-response: p99 response time 250.00ms exceeds 200.00ms"
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+}
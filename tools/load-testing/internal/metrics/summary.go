@@ -0,0 +1,843 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"load-testing/internal/skater"
+	"load-testing/internal/viewer"
+)
+
+// LatencyStats summarises a Histogram of response times or latencies in milliseconds.
+type LatencyStats struct {
+	Count uint64  `json:"count"`
+	Mean  float64 `json:"mean_ms"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+func latencyStats(h *Histogram) LatencyStats {
+	return LatencyStats{
+		Count: h.Count(),
+		Mean:  milliseconds(h.Mean()),
+		P50:   milliseconds(h.Percentile(50)),
+		P90:   milliseconds(h.Percentile(90)),
+		P99:   milliseconds(h.Percentile(99)),
+		Max:   milliseconds(h.Max()),
+	}
+}
+
+// Stats summarises the histogram's values as LatencyStats in milliseconds.
+func (h *Histogram) Stats() LatencyStats {
+	return latencyStats(h)
+}
+
+func (l LatencyStats) String() string {
+	return fmt.Sprintf("p50 %.2fms, p90 %.2fms, p99 %.2fms, max %.2fms", l.P50, l.P90, l.P99, l.Max)
+}
+
+// CountStats summarises a set of per-item counts.
+type CountStats struct {
+	Min  int     `json:"min"`
+	Mean float64 `json:"mean"`
+	Max  int     `json:"max"`
+}
+
+// RateStats summarises a set of per-item rates.
+type RateStats struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	Max  float64 `json:"max"`
+}
+
+// countAccumulator builds CountStats from counts recorded one at a time.
+type countAccumulator struct {
+	n, total, min, max int
+}
+
+func (a *countAccumulator) record(c int) {
+	if a.n == 0 || c < a.min {
+		a.min = c
+	}
+	if c > a.max {
+		a.max = c
+	}
+	a.n++
+	a.total += c
+}
+
+func (a *countAccumulator) stats() CountStats {
+	if a.n == 0 {
+		return CountStats{}
+	}
+	return CountStats{Min: a.min, Mean: float64(a.total) / float64(a.n), Max: a.max}
+}
+
+// SkaterEventReport holds summary statistics for the skaters of a single event.
+type SkaterEventReport struct {
+	EventID      string         `json:"event_id"`
+	Requests     int            `json:"requests"`
+	Errors       int            `json:"errors"`
+	Skipped      int            `json:"skipped,omitempty"`
+	ErrorsByKind map[string]int `json:"errors_by_kind"`
+	ResponseTime LatencyStats   `json:"response_time"`
+}
+
+// SkaterReport holds summary statistics for a whole skater simulation run.
+type SkaterReport struct {
+	StartTime         time.Time           `json:"start_time"`
+	EndTime           time.Time           `json:"end_time"`
+	DurationSeconds   float64             `json:"duration_seconds"`
+	Requests          int                 `json:"requests"`
+	Errors            int                 `json:"errors"`
+	ErrorRate         float64             `json:"error_rate"`
+	RequestsPerSecond float64             `json:"requests_per_second"`
+	ErrorsByKind      map[string]int      `json:"errors_by_kind"`
+	ResponseTime      LatencyStats        `json:"response_time"`
+	Skipped           int                 `json:"skipped,omitempty"`
+	ScheduleLag       *LatencyStats       `json:"schedule_lag,omitempty"`
+	MetricsQueue      *QueueStats         `json:"metrics_queue,omitempty"`
+	Events            []SkaterEventReport `json:"events"`
+}
+
+type skaterStats struct {
+	requests     int
+	errors       int
+	skipped      int
+	errorsByKind map[string]int
+	responseTime *Histogram
+	scheduleLag  *Histogram
+}
+
+func newSkaterStats() *skaterStats {
+	return &skaterStats{
+		errorsByKind: make(map[string]int),
+		responseTime: NewHistogram(),
+		scheduleLag:  NewHistogram(),
+	}
+}
+
+// record adds an update result. Updates skipped by the rate limit were never
+// sent, so they are counted on their own rather than as failed requests: the
+// client chose to throttle them, and they say nothing about the API.
+func (s *skaterStats) record(result skater.UpdateResult) {
+	if errors.Is(result.Error, skater.ErrSkipped) {
+		s.skipped++
+		return
+	}
+	s.requests++
+	s.responseTime.Record(result.ResponseTime)
+	if result.ScheduleLag != nil {
+		s.scheduleLag.Record(*result.ScheduleLag)
+	}
+	if result.Error != nil {
+		s.errors++
+		s.errorsByKind[ErrorKind(result.Error)]++
+	}
+}
+
+// SkaterSummary accumulates run statistics from skater update results,
+// overall and per event. It keeps streaming histograms rather than individual
+// results, so its memory use does not grow with the length of the run.
+// It is safe for concurrent use.
+type SkaterSummary struct {
+	mu      sync.Mutex
+	start   time.Time
+	end     time.Time
+	overall *skaterStats
+	events  map[string]*skaterStats
+	queue   *QueuedSink
+}
+
+// NewSkaterSummary creates a SkaterSummary for a run that started at start.
+func NewSkaterSummary(start time.Time) *SkaterSummary {
+	return &SkaterSummary{
+		start:   start,
+		overall: newSkaterStats(),
+		events:  make(map[string]*skaterStats),
+	}
+}
+
+// Record adds a single update result to the summary.
+func (s *SkaterSummary) Record(result skater.UpdateResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.overall.record(result)
+
+	event, ok := s.events[result.EventID]
+	if !ok {
+		event = newSkaterStats()
+		s.events[result.EventID] = event
+	}
+	event.record(result)
+}
+
+// TrackMetricsQueue adds what queue did with the results written to the
+// metrics file to the summary.
+func (s *SkaterSummary) TrackMetricsQueue(queue *QueuedSink) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queue = queue
+}
+
+// Errors returns the number of failed updates recorded so far.
+func (s *SkaterSummary) Errors() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.overall.errors
+}
+
+// Finish marks the end of the run. Throughput is calculated up to this time.
+func (s *SkaterSummary) Finish(end time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.end = end
+}
+
+// Report returns the summary statistics recorded so far.
+// Events are ordered by event ID.
+func (s *SkaterSummary) Report() SkaterReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := s.end.Sub(s.start)
+	report := SkaterReport{
+		StartTime:         s.start,
+		EndTime:           s.end,
+		DurationSeconds:   elapsed.Seconds(),
+		Requests:          s.overall.requests,
+		Errors:            s.overall.errors,
+		ErrorRate:         fraction(s.overall.errors, s.overall.requests),
+		RequestsPerSecond: rate(s.overall.requests, elapsed),
+		ErrorsByKind:      copyCounts(s.overall.errorsByKind),
+		ResponseTime:      latencyStats(s.overall.responseTime),
+		Skipped:           s.overall.skipped,
+		Events:            make([]SkaterEventReport, 0, len(s.events)),
+	}
+	if s.overall.scheduleLag.Count() > 0 {
+		lag := latencyStats(s.overall.scheduleLag)
+		report.ScheduleLag = &lag
+	}
+	if s.queue != nil {
+		stats := s.queue.Stats()
+		report.MetricsQueue = &stats
+	}
+
+	for _, eventID := range sortedKeys(s.events) {
+		event := s.events[eventID]
+		report.Events = append(report.Events, SkaterEventReport{
+			EventID:      eventID,
+			Requests:     event.requests,
+			Errors:       event.errors,
+			Skipped:      event.skipped,
+			ErrorsByKind: copyCounts(event.errorsByKind),
+			ResponseTime: latencyStats(event.responseTime),
+		})
+	}
+
+	return report
+}
+
+// WriteText writes a human-readable summary of the run.
+func (s *SkaterSummary) WriteText(w io.Writer) error {
+	report := s.Report()
+	elapsed := report.EndTime.Sub(report.StartTime)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Summary: %d requests, %d errors (%.2f%%), %.2f requests/second over %s\n",
+		report.Requests, report.Errors, report.ErrorRate*100, report.RequestsPerSecond, elapsed.Round(time.Second))
+	fmt.Fprintf(&b, "Response time: %s\n", report.ResponseTime)
+	if report.ScheduleLag != nil {
+		fmt.Fprintf(&b, "Schedule lag: %s\n", report.ScheduleLag)
+	}
+	if report.Skipped > 0 {
+		fmt.Fprintf(&b, "Skipped: %d updates over the rate limit, not sent\n", report.Skipped)
+	}
+	if len(report.ErrorsByKind) > 0 {
+		fmt.Fprintf(&b, "Errors by kind: %s\n", formatCounts(report.ErrorsByKind))
+	}
+	if report.MetricsQueue != nil {
+		fmt.Fprintf(&b, "Metrics file: %s\n", report.MetricsQueue)
+	}
+	for _, event := range report.Events {
+		var skipped string
+		if event.Skipped > 0 {
+			skipped = fmt.Sprintf(", %d skipped", event.Skipped)
+		}
+		fmt.Fprintf(&b, "Event %s: %d requests, %d errors%s, %s\n",
+			event.EventID, event.Requests, event.Errors, skipped, event.ResponseTime)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSONFile writes the report as indented JSON to the named file.
+func (s *SkaterSummary) WriteJSONFile(filename string) error {
+	return writeJSONFile(filename, s.Report())
+}
+
+// ViewerEventReport holds summary statistics for the viewers of a single event.
+type ViewerEventReport struct {
+	EventID     string       `json:"event_id"`
+	Viewers     int          `json:"viewers"`
+	Messages    int          `json:"messages"`
+	Errors      int          `json:"errors"`
+	Connections int          `json:"connections"`
+	Disconnects int          `json:"disconnects"`
+	Reconnects  int          `json:"reconnects"`
+	Locations   int          `json:"locations"`
+	Bytes       int          `json:"bytes"`
+	Latency     LatencyStats `json:"latency"`
+	EndToEnd    LatencyStats `json:"end_to_end_latency"`
+}
+
+// ViewerReport holds summary statistics for a whole viewer simulation run.
+// EndToEnd only counts locations matched with the skater update that sent them.
+// Handshake is the time each connection took to open, UpgradeStatuses counts
+// handshake responses by HTTP status, and FirstMessage is the time from
+// each connection opening to its first batch, as described by
+// viewer.FirstMessage. Leaves counts the
+// sessions churning viewers ended, AbruptLeaves those without a close frame.
+// Downtime is the time reconnecting viewers spent without a connection, and
+// MissedMessages and MissedLocations what they are estimated to have missed
+// meanwhile, as described by viewer.Reconnect. Bytes counts the size of every
+// batch received, and BytesPerSecondPerViewer is each viewer's total averaged
+// over the run.
+type ViewerReport struct {
+	StartTime         time.Time           `json:"start_time"`
+	EndTime           time.Time           `json:"end_time"`
+	DurationSeconds   float64             `json:"duration_seconds"`
+	Viewers           int                 `json:"viewers"`
+	Messages          int                 `json:"messages"`
+	Errors            int                 `json:"errors"`
+	Connections       int                 `json:"connections"`
+	Handshake         LatencyStats        `json:"handshake"`
+	UpgradeStatuses   map[string]int      `json:"upgrade_statuses"`
+	FirstMessage      LatencyStats        `json:"first_message"`
+	Leaves            int                 `json:"leaves"`
+	AbruptLeaves      int                 `json:"abrupt_leaves"`
+	Disconnects       int                 `json:"disconnects"`
+	Reconnects        int                 `json:"reconnects"`
+	Downtime          LatencyStats        `json:"downtime"`
+	MissedMessages    int                 `json:"missed_messages"`
+	MissedLocations   int                 `json:"missed_locations"`
+	Locations         int                 `json:"locations"`
+	Bytes             int                 `json:"bytes"`
+	MessagesPerSecond float64             `json:"messages_per_second"`
+	MessagesPerViewer CountStats          `json:"messages_per_viewer"`
+	MessageBytes      CountStats          `json:"message_bytes"`
+	LocationsPerBatch CountStats          `json:"locations_per_batch"`
+	BytesPerSecond    RateStats           `json:"bytes_per_second_per_viewer"`
+	ErrorsByKind      map[string]int      `json:"errors_by_kind"`
+	Latency           LatencyStats        `json:"latency"`
+	EndToEnd          LatencyStats        `json:"end_to_end_latency"`
+	Delivery          *DeliveryReport     `json:"delivery,omitempty"`
+	MetricsQueue      *QueueStats         `json:"metrics_queue,omitempty"`
+	Events            []ViewerEventReport `json:"events"`
+}
+
+type viewerStats struct {
+	messages        int
+	errors          int
+	connections     int
+	leaves          int
+	abruptLeaves    int
+	disconnects     int
+	reconnects      int
+	missedMessages  int
+	missedLocations int
+	locations       int
+	bytes           int
+	messageBytes    countAccumulator
+	batchLocations  countAccumulator
+	latency         *Histogram
+	endToEnd        *Histogram
+	handshake       *Histogram
+	firstMessage    *Histogram
+	downtime        *Histogram
+}
+
+func newViewerStats() *viewerStats {
+	return &viewerStats{
+		latency:      NewHistogram(),
+		endToEnd:     NewHistogram(),
+		handshake:    NewHistogram(),
+		firstMessage: NewHistogram(),
+		downtime:     NewHistogram(),
+	}
+}
+
+func (s *viewerStats) record(result viewer.ViewerResult, kind string) {
+	if h := result.Handshake; h != nil {
+		if h.Status == http.StatusSwitchingProtocols {
+			s.handshake.Record(h.Duration)
+		}
+		return
+	}
+	if result.Connect != nil {
+		s.connections++
+		return
+	}
+	if f := result.FirstMessage; f != nil {
+		s.firstMessage.Record(f.Wait)
+		return
+	}
+	if l := result.Leave; l != nil {
+		s.leaves++
+		if l.Abrupt {
+			s.abruptLeaves++
+		}
+		return
+	}
+	if r := result.Reconnect; r != nil {
+		s.reconnects++
+		s.missedMessages += r.MissedMessages
+		s.missedLocations += r.MissedLocations
+		s.downtime.Record(r.Downtime)
+		return
+	}
+	if result.Error != nil {
+		s.errors++
+		if kind == ErrorKindDisconnect {
+			s.disconnects++
+		}
+		return
+	}
+	s.messages++
+	s.locations += len(result.SkaterIDs)
+	s.bytes += result.Bytes
+	s.messageBytes.record(result.Bytes)
+	s.batchLocations.record(len(result.SkaterIDs))
+	s.latency.Record(result.Latency)
+	for _, d := range result.EndToEnd {
+		if d > 0 {
+			s.endToEnd.Record(d)
+		}
+	}
+}
+
+// ViewerSummary accumulates run statistics from viewer results, overall and
+// per event. It keeps streaming histograms rather than individual results,
+// so its memory use does not grow with the length of the run.
+// It is safe for concurrent use.
+type ViewerSummary struct {
+	mu              sync.Mutex
+	start           time.Time
+	end             time.Time
+	overall         *viewerStats
+	events          map[string]*viewerStats
+	errorsByKind    map[string]int
+	upgradeStatuses map[string]int
+	viewerMessages  map[int]int
+	viewerBytes     map[int]int
+	viewersPerEvent map[string]map[int]bool
+	delivery        *DeliveryChecker
+	queue           *QueuedSink
+}
+
+// NewViewerSummary creates a ViewerSummary for a run that started at start.
+func NewViewerSummary(start time.Time) *ViewerSummary {
+	return &ViewerSummary{
+		start:           start,
+		overall:         newViewerStats(),
+		events:          make(map[string]*viewerStats),
+		errorsByKind:    make(map[string]int),
+		upgradeStatuses: make(map[string]int),
+		viewerMessages:  make(map[int]int),
+		viewerBytes:     make(map[int]int),
+		viewersPerEvent: make(map[string]map[int]bool),
+	}
+}
+
+// TrackDelivery passes viewers and results on to checker, and adds its report
+// to the summary.
+func (s *ViewerSummary) TrackDelivery(checker *DeliveryChecker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delivery = checker
+}
+
+// TrackMetricsQueue adds what queue did with the results written to the
+// metrics file to the summary.
+func (s *ViewerSummary) TrackMetricsQueue(queue *QueuedSink) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queue = queue
+}
+
+// ExpectViewer registers a viewer before it produces any results, so that a
+// viewer which never receives a message still counts towards messages per viewer.
+func (s *ViewerSummary) ExpectViewer(eventID string, viewerNumber int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addViewer(eventID, viewerNumber)
+	if s.delivery != nil {
+		s.delivery.ExpectViewer(eventID, viewerNumber)
+	}
+}
+
+func (s *ViewerSummary) addViewer(eventID string, viewerNumber int) *viewerStats {
+	if _, ok := s.viewerMessages[viewerNumber]; !ok {
+		s.viewerMessages[viewerNumber] = 0
+		s.viewerBytes[viewerNumber] = 0
+	}
+
+	viewers, ok := s.viewersPerEvent[eventID]
+	if !ok {
+		viewers = make(map[int]bool)
+		s.viewersPerEvent[eventID] = viewers
+	}
+	viewers[viewerNumber] = true
+
+	event, ok := s.events[eventID]
+	if !ok {
+		event = newViewerStats()
+		s.events[eventID] = event
+	}
+	return event
+}
+
+// Record adds a single viewer result to the summary.
+func (s *ViewerSummary) Record(result viewer.ViewerResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kind := ErrorKind(result.Error)
+	switch {
+	case result.Error != nil:
+		s.errorsByKind[kind]++
+	case result.Handshake != nil:
+		if result.Handshake.Status != 0 {
+			s.upgradeStatuses[strconv.Itoa(result.Handshake.Status)]++
+		}
+	case isBatch(result):
+		s.viewerMessages[result.ViewerNumber]++
+		s.viewerBytes[result.ViewerNumber] += result.Bytes
+	}
+
+	s.overall.record(result, kind)
+	s.addViewer(result.EventID, result.ViewerNumber).record(result, kind)
+	if s.delivery != nil {
+		s.delivery.Record(result)
+	}
+}
+
+// Errors returns the number of viewer errors recorded so far.
+func (s *ViewerSummary) Errors() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.overall.errors
+}
+
+// Finish marks the end of the run. Throughput is calculated up to this time.
+func (s *ViewerSummary) Finish(end time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.end = end
+	if s.delivery != nil {
+		s.delivery.Finish(end)
+	}
+}
+
+// Report returns the summary statistics recorded so far.
+// Events are ordered by event ID.
+func (s *ViewerSummary) Report() ViewerReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := s.end.Sub(s.start)
+	report := ViewerReport{
+		StartTime:         s.start,
+		EndTime:           s.end,
+		DurationSeconds:   elapsed.Seconds(),
+		Viewers:           len(s.viewerMessages),
+		Messages:          s.overall.messages,
+		Errors:            s.overall.errors,
+		Connections:       s.overall.connections,
+		Handshake:         latencyStats(s.overall.handshake),
+		UpgradeStatuses:   copyCounts(s.upgradeStatuses),
+		FirstMessage:      latencyStats(s.overall.firstMessage),
+		Leaves:            s.overall.leaves,
+		AbruptLeaves:      s.overall.abruptLeaves,
+		Disconnects:       s.overall.disconnects,
+		Reconnects:        s.overall.reconnects,
+		Downtime:          latencyStats(s.overall.downtime),
+		MissedMessages:    s.overall.missedMessages,
+		MissedLocations:   s.overall.missedLocations,
+		Locations:         s.overall.locations,
+		Bytes:             s.overall.bytes,
+		MessagesPerSecond: rate(s.overall.messages, elapsed),
+		MessagesPerViewer: countStats(s.viewerMessages),
+		MessageBytes:      s.overall.messageBytes.stats(),
+		LocationsPerBatch: s.overall.batchLocations.stats(),
+		BytesPerSecond:    rateStats(countStats(s.viewerBytes), elapsed),
+		ErrorsByKind:      copyCounts(s.errorsByKind),
+		Latency:           latencyStats(s.overall.latency),
+		EndToEnd:          latencyStats(s.overall.endToEnd),
+		Events:            make([]ViewerEventReport, 0, len(s.events)),
+	}
+	if s.delivery != nil {
+		delivery := s.delivery.Report()
+		report.Delivery = &delivery
+	}
+	if s.queue != nil {
+		stats := s.queue.Stats()
+		report.MetricsQueue = &stats
+	}
+
+	for _, eventID := range sortedKeys(s.events) {
+		event := s.events[eventID]
+		report.Events = append(report.Events, ViewerEventReport{
+			EventID:     eventID,
+			Viewers:     len(s.viewersPerEvent[eventID]),
+			Messages:    event.messages,
+			Errors:      event.errors,
+			Connections: event.connections,
+			Disconnects: event.disconnects,
+			Reconnects:  event.reconnects,
+			Locations:   event.locations,
+			Bytes:       event.bytes,
+			Latency:     latencyStats(event.latency),
+			EndToEnd:    latencyStats(event.endToEnd),
+		})
+	}
+
+	return report
+}
+
+// WriteText writes a human-readable summary of the run.
+func (s *ViewerSummary) WriteText(w io.Writer) error {
+	report := s.Report()
+	elapsed := report.EndTime.Sub(report.StartTime)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Summary: %d messages to %d viewers, %d errors, %.2f messages/second over %s\n",
+		report.Messages, report.Viewers, report.Errors, report.MessagesPerSecond, elapsed.Round(time.Second))
+	fmt.Fprintf(&b, "Latency: %s\n", report.Latency)
+	if report.EndToEnd.Count > 0 {
+		fmt.Fprintf(&b, "End-to-end latency: %s (%d of %d locations matched)\n",
+			report.EndToEnd, report.EndToEnd.Count, report.Locations)
+	}
+	fmt.Fprintf(&b, "Messages per viewer: min %d, mean %.1f, max %d\n",
+		report.MessagesPerViewer.Min, report.MessagesPerViewer.Mean, report.MessagesPerViewer.Max)
+	if report.Messages > 0 {
+		fmt.Fprintf(&b, "Message size: min %d B, mean %.1f B, max %d B, %d B in total\n",
+			report.MessageBytes.Min, report.MessageBytes.Mean, report.MessageBytes.Max, report.Bytes)
+		fmt.Fprintf(&b, "Locations per batch: min %d, mean %.1f, max %d\n",
+			report.LocationsPerBatch.Min, report.LocationsPerBatch.Mean, report.LocationsPerBatch.Max)
+		fmt.Fprintf(&b, "Throughput per viewer: min %.1f B/s, mean %.1f B/s, max %.1f B/s\n",
+			report.BytesPerSecond.Min, report.BytesPerSecond.Mean, report.BytesPerSecond.Max)
+	}
+	if report.Connections > 0 {
+		fmt.Fprintf(&b, "Connections: %d, handshake %s\n", report.Connections, report.Handshake)
+	}
+	if len(report.UpgradeStatuses) > 0 {
+		fmt.Fprintf(&b, "Upgrade statuses: %s\n", formatCounts(report.UpgradeStatuses))
+	}
+	if report.FirstMessage.Count > 0 {
+		fmt.Fprintf(&b, "Time to first message: %s\n", report.FirstMessage)
+	}
+	if report.Leaves > 0 {
+		fmt.Fprintf(&b, "Churn: %d sessions ended, %d abruptly\n", report.Leaves, report.AbruptLeaves)
+	}
+	fmt.Fprintf(&b, "Disconnects: %d\n", report.Disconnects)
+	if report.Reconnects > 0 {
+		fmt.Fprintf(&b, "Reconnects: %d, downtime %s, about %d messages missed",
+			report.Reconnects, report.Downtime, report.MissedMessages)
+		if report.MissedLocations > 0 {
+			fmt.Fprintf(&b, " (%d locations sent meanwhile)", report.MissedLocations)
+		}
+		b.WriteString("\n")
+	}
+	if len(report.ErrorsByKind) > 0 {
+		fmt.Fprintf(&b, "Errors by kind: %s\n", formatCounts(report.ErrorsByKind))
+	}
+	if report.Delivery != nil {
+		if err := report.Delivery.WriteText(&b); err != nil {
+			return err
+		}
+	}
+	if report.MetricsQueue != nil {
+		fmt.Fprintf(&b, "Metrics file: %s\n", report.MetricsQueue)
+	}
+	for _, event := range report.Events {
+		fmt.Fprintf(&b, "Event %s: %d viewers, %d messages, %d disconnects, %s\n",
+			event.EventID, event.Viewers, event.Messages, event.Disconnects, event.Latency)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSONFile writes the report as indented JSON to the named file.
+func (s *ViewerSummary) WriteJSONFile(filename string) error {
+	return writeJSONFile(filename, s.Report())
+}
+
+// SimulationReport combines the skater and viewer reports of a run that
+// simulated both in one process.
+type SimulationReport struct {
+	Skaters SkaterReport `json:"skaters"`
+	Viewers ViewerReport `json:"viewers"`
+}
+
+// WriteSimulationJSONFile writes the reports of a skater and a viewer summary
+// to the named file as a single indented JSON SimulationReport.
+func WriteSimulationJSONFile(filename string, skaters *SkaterSummary, viewers *ViewerSummary) error {
+	return writeJSONFile(filename, SimulationReport{Skaters: skaters.Report(), Viewers: viewers.Report()})
+}
+
+// ReadSummaryFile reads a summary written by WriteJSONFile or
+// WriteSimulationJSONFile, returning nil for the report it does not hold.
+func ReadSummaryFile(filename string) (*SkaterReport, *ViewerReport, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read summary file: %w", err)
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode summary %s: %w", filename, err)
+	}
+	_, skaters := keys["requests_per_second"]
+	_, viewers := keys["messages_per_second"]
+	_, simulation := keys["skaters"]
+
+	switch {
+	case simulation:
+		var report SimulationReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode summary %s: %w", filename, err)
+		}
+		return &report.Skaters, &report.Viewers, nil
+	case skaters:
+		var report SkaterReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode summary %s: %w", filename, err)
+		}
+		return &report, nil, nil
+	case viewers:
+		var report ViewerReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode summary %s: %w", filename, err)
+		}
+		return nil, &report, nil
+	}
+	return nil, nil, fmt.Errorf("%s is not a skater, viewer or simulation summary", filename)
+}
+
+func writeJSONFile(filename string, report any) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode summary: %w", err)
+	}
+
+	if err := os.WriteFile(filename, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write summary file: %w", err)
+	}
+	return nil
+}
+
+// isBatch reports whether a viewer result is a received batch, rather than an
+// error or a connection event.
+func isBatch(result viewer.ViewerResult) bool {
+	return result.Error == nil && result.Handshake == nil && result.Connect == nil &&
+		result.FirstMessage == nil && result.Reconnect == nil && result.Leave == nil
+}
+
+func countStats(counts map[int]int) CountStats {
+	if len(counts) == 0 {
+		return CountStats{}
+	}
+
+	stats := CountStats{Min: -1}
+	total := 0
+	for _, c := range counts {
+		total += c
+		if stats.Min < 0 || c < stats.Min {
+			stats.Min = c
+		}
+		if c > stats.Max {
+			stats.Max = c
+		}
+	}
+	stats.Mean = float64(total) / float64(len(counts))
+	return stats
+}
+
+// rateStats converts per-item counts into rates over elapsed.
+func rateStats(counts CountStats, elapsed time.Duration) RateStats {
+	if elapsed <= 0 {
+		return RateStats{}
+	}
+	seconds := elapsed.Seconds()
+	return RateStats{
+		Min:  float64(counts.Min) / seconds,
+		Mean: counts.Mean / seconds,
+		Max:  float64(counts.Max) / seconds,
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	copied := make(map[string]int, len(counts))
+	for k, v := range counts {
+		copied[k] = v
+	}
+	return copied
+}
+
+func formatCounts(counts map[string]int) string {
+	parts := make([]string, 0, len(counts))
+	for _, k := range sortedKeys(counts) {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, counts[k]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000.0
+}
+
+func fraction(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
+
+func rate(count int, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(count) / elapsed.Seconds()
+}
@@ -0,0 +1,576 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"load-testing/internal/correlation"
+	"load-testing/internal/skater"
+	"load-testing/internal/viewer"
+)
+
+func TestSkaterSummary(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	summary := NewSkaterSummary(start)
+
+	for i := 0; i < 9; i++ {
+		summary.Record(skater.UpdateResult{EventID: "event-1", ResponseTime: 10 * time.Millisecond})
+	}
+	summary.Record(skater.UpdateResult{EventID: "event-1", Error: fmt.Errorf("unexpected status code: 500")})
+	summary.Finish(start.Add(5 * time.Second))
+
+	if summary.Errors() != 1 {
+		t.Errorf("expected 1 error, got %d", summary.Errors())
+	}
+
+	var buf bytes.Buffer
+	if err := summary.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	expected := []string{
+		"Summary: 10 requests, 1 errors (10.00%), 2.00 requests/second over 5s",
+		"Response time: p50 10.00ms, p90 10.00ms, p99 10.00ms, max 10.00ms",
+		"Errors by kind: other=1",
+		"Event event-1: 10 requests, 1 errors, p50 10.00ms, p90 10.00ms, p99 10.00ms, max 10.00ms",
+	}
+	for i, want := range expected {
+		if i >= len(lines) || lines[i] != want {
+			t.Errorf("line %d: expected %q, got summary %q", i, want, buf.String())
+		}
+	}
+}
+
+func TestSkaterSummary_ScheduleLag(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	summary := NewSkaterSummary(start)
+
+	summary.Record(skater.UpdateResult{EventID: "event-1", ResponseTime: 10 * time.Millisecond})
+	if report := summary.Report(); report.ScheduleLag != nil {
+		t.Errorf("expected no schedule lag without scheduled updates, got %+v", report.ScheduleLag)
+	}
+
+	lag := 40 * time.Millisecond
+	summary.Record(skater.UpdateResult{EventID: "event-1", ResponseTime: 50 * time.Millisecond, ScheduleLag: &lag})
+	summary.Finish(start.Add(time.Second))
+
+	report := summary.Report()
+	if report.ScheduleLag == nil || report.ScheduleLag.Count != 1 || report.ScheduleLag.Max != 40 {
+		t.Errorf("expected one schedule lag of 40ms, got %+v", report.ScheduleLag)
+	}
+
+	var buf bytes.Buffer
+	if err := summary.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if want := "Schedule lag: p50 40.00ms, p90 40.00ms, p99 40.00ms, max 40.00ms\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("expected %q in summary %q", want, buf.String())
+	}
+}
+
+func TestSkaterSummary_Skipped(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	summary := NewSkaterSummary(start)
+
+	for i := 0; i < 3; i++ {
+		summary.Record(skater.UpdateResult{EventID: "event-1", ResponseTime: 100 * time.Millisecond})
+	}
+	summary.Record(skater.UpdateResult{EventID: "event-1", Error: skater.ErrSkipped})
+	summary.Finish(start.Add(time.Second))
+
+	report := summary.Report()
+	if report.Requests != 3 || report.Errors != 0 || report.Skipped != 1 {
+		t.Errorf("expected 3 requests and 1 skipped update that is not an error, got %d requests, %d errors, %d skipped",
+			report.Requests, report.Errors, report.Skipped)
+	}
+	if report.ErrorRate != 0 || len(report.ErrorsByKind) != 0 || summary.Errors() != 0 {
+		t.Errorf("expected the skipped update out of the errors, got rate %v and kinds %v", report.ErrorRate, report.ErrorsByKind)
+	}
+	if report.Events[0].Skipped != 1 {
+		t.Errorf("expected the skipped update in the event report, got %d", report.Events[0].Skipped)
+	}
+	if breaches := (SkaterThresholds{MaxErrorRate: 0}).Check(report, true); len(breaches) != 0 {
+		t.Errorf("expected skipped updates not to breach the error rate SLO, got %v", breaches)
+	}
+	if report.ResponseTime.Count != 3 || report.ResponseTime.P50 != 100 {
+		t.Errorf("expected only sent updates to be timed, got %+v", report.ResponseTime)
+	}
+
+	var buf bytes.Buffer
+	if err := summary.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if want := "Skipped: 1 updates over the rate limit, not sent\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("expected %q in summary %q", want, buf.String())
+	}
+}
+
+func TestViewerSummary(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	summary := NewViewerSummary(start)
+
+	summary.Record(viewer.ViewerResult{ViewerNumber: 1, MessageCount: 1})
+	summary.Record(viewer.ViewerResult{ViewerNumber: 1, MessageCount: 2})
+	summary.Record(viewer.ViewerResult{ViewerNumber: 2, MessageCount: 1})
+	summary.Record(viewer.ViewerResult{ViewerNumber: 3, Error: fmt.Errorf("connection failed")})
+	summary.Finish(start.Add(3 * time.Second))
+
+	if summary.Errors() != 1 {
+		t.Errorf("expected 1 error, got %d", summary.Errors())
+	}
+
+	var buf bytes.Buffer
+	if err := summary.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "3 messages to 3 viewers, 1 errors, 1.00 messages/second over 3s") {
+		t.Errorf("unexpected summary: %q", buf.String())
+	}
+}
+
+func TestSummary_NoResults(t *testing.T) {
+	start := time.Now()
+	summary := NewSkaterSummary(start)
+	summary.Finish(start)
+
+	var buf bytes.Buffer
+	if err := summary.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "0 requests, 0 errors (0.00%), 0.00 requests/second") {
+		t.Errorf("unexpected summary: %q", buf.String())
+	}
+}
+
+func TestSkaterSummary_Report(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	summary := NewSkaterSummary(start)
+
+	for i := 1; i <= 100; i++ {
+		summary.Record(skater.UpdateResult{EventID: "event-a", ResponseTime: time.Duration(i) * time.Millisecond})
+	}
+	summary.Record(skater.UpdateResult{EventID: "event-b", Error: &skater.StatusError{StatusCode: 503}})
+	summary.Record(skater.UpdateResult{EventID: "event-b", Error: &skater.StatusError{StatusCode: 503}})
+	summary.Finish(start.Add(10 * time.Second))
+
+	report := summary.Report()
+
+	if report.Requests != 102 || report.Errors != 2 {
+		t.Errorf("expected 102 requests and 2 errors, got %d and %d", report.Requests, report.Errors)
+	}
+	if report.ErrorsByKind["http_503"] != 2 {
+		t.Errorf("expected 2 http_503 errors, got %v", report.ErrorsByKind)
+	}
+	if report.RequestsPerSecond != 10.2 {
+		t.Errorf("expected 10.2 requests/second, got %f", report.RequestsPerSecond)
+	}
+	if len(report.Events) != 2 || report.Events[0].EventID != "event-a" || report.Events[1].EventID != "event-b" {
+		t.Fatalf("expected events event-a and event-b in order, got %+v", report.Events)
+	}
+
+	eventA := report.Events[0].ResponseTime
+	if !within(eventA.P50, 50, 0.01) || !within(eventA.P90, 90, 0.01) || !within(eventA.P99, 99, 0.01) {
+		t.Errorf("unexpected event-a percentiles: %+v", eventA)
+	}
+	if eventA.Max != 100 {
+		t.Errorf("expected event-a max of 100ms, got %f", eventA.Max)
+	}
+	if report.Events[1].Errors != 2 || report.Events[1].ErrorsByKind["http_503"] != 2 {
+		t.Errorf("unexpected event-b report: %+v", report.Events[1])
+	}
+}
+
+func TestViewerSummary_Report(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	summary := NewViewerSummary(start)
+
+	summary.ExpectViewer("event-a", 1)
+	summary.ExpectViewer("event-a", 2)
+	summary.ExpectViewer("event-b", 3)
+
+	for i := 0; i < 4; i++ {
+		summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, Latency: 20 * time.Millisecond})
+	}
+	summary.Record(viewer.ViewerResult{EventID: "event-b", ViewerNumber: 3, Latency: 40 * time.Millisecond})
+	summary.Record(viewer.ViewerResult{
+		EventID:      "event-b",
+		ViewerNumber: 3,
+		Error:        fmt.Errorf("%w: %w", viewer.ErrConnectionLost, fmt.Errorf("websocket: close 1006")),
+	})
+	summary.Finish(start.Add(5 * time.Second))
+
+	report := summary.Report()
+
+	if report.Viewers != 3 || report.Messages != 5 || report.Errors != 1 || report.Disconnects != 1 {
+		t.Errorf("unexpected totals: %+v", report)
+	}
+	expectedPerViewer := CountStats{Min: 0, Mean: 5.0 / 3.0, Max: 4}
+	if report.MessagesPerViewer != expectedPerViewer {
+		t.Errorf("expected messages per viewer %+v, got %+v", expectedPerViewer, report.MessagesPerViewer)
+	}
+	if report.ErrorsByKind[ErrorKindDisconnect] != 1 {
+		t.Errorf("expected 1 disconnect error, got %v", report.ErrorsByKind)
+	}
+	if len(report.Events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(report.Events))
+	}
+	if report.Events[0].Viewers != 2 || report.Events[0].Messages != 4 || report.Events[0].Latency.Max != 20 {
+		t.Errorf("unexpected event-a report: %+v", report.Events[0])
+	}
+	if report.Events[1].Viewers != 1 || report.Events[1].Disconnects != 1 {
+		t.Errorf("unexpected event-b report: %+v", report.Events[1])
+	}
+}
+
+func TestViewerSummary_EndToEnd(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	summary := NewViewerSummary(start)
+
+	summary.Record(viewer.ViewerResult{
+		EventID:      "event-a",
+		ViewerNumber: 1,
+		SkaterIDs:    []string{"skater-1", "skater-2", "skater-3"},
+		EndToEnd:     []time.Duration{600 * time.Millisecond, 0, 800 * time.Millisecond},
+	})
+	summary.Finish(start.Add(time.Second))
+
+	report := summary.Report()
+	if report.Locations != 3 || report.EndToEnd.Count != 2 || report.EndToEnd.Max != 800 {
+		t.Errorf("expected 2 of 3 locations matched with a max of 800ms, got %d locations and %+v", report.Locations, report.EndToEnd)
+	}
+	if report.Events[0].EndToEnd.Count != 2 {
+		t.Errorf("expected 2 matched locations for event-a, got %+v", report.Events[0].EndToEnd)
+	}
+
+	var buf bytes.Buffer
+	if err := summary.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "(2 of 3 locations matched)") {
+		t.Errorf("expected end-to-end latency line, got %q", buf.String())
+	}
+}
+
+func TestViewerSummary_TrackDelivery(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	summary := NewViewerSummary(start)
+	checker := NewDeliveryChecker(DefaultDeliveryConfig(), start)
+	summary.TrackDelivery(checker)
+
+	checker.Publish(correlation.Update{EventID: "event-a", SkaterID: "skater-1", SentAt: start.Add(time.Second)})
+	checker.Publish(correlation.Update{EventID: "event-a", SkaterID: "skater-2", SentAt: start.Add(time.Second)})
+	summary.ExpectViewer("event-a", 1)
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, Timestamp: start.Add(1500 * time.Millisecond), SkaterIDs: []string{"skater-1"}})
+	summary.Finish(start.Add(10 * time.Second))
+
+	report := summary.Report()
+	if report.Delivery == nil || report.Delivery.ExpectedSkaters != 2 || report.Delivery.MissingSkaters != 1 {
+		t.Fatalf("expected 1 of 2 skaters missing, got %+v", report.Delivery)
+	}
+
+	var buf bytes.Buffer
+	if err := summary.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "Missing skaters: event-a/skater-2 (1 viewers)") {
+		t.Errorf("expected the missing skater to be named, got %q", buf.String())
+	}
+}
+
+func TestSkaterSummary_TrackMetricsQueue(t *testing.T) {
+	sink := newGatedSink()
+	close(sink.release)
+	q, err := NewQueuedSink(sink, QueueConfig{Size: 10, Overflow: OverflowDrop})
+	if err != nil {
+		t.Fatalf("NewQueuedSink() error = %v", err)
+	}
+	summary := NewSkaterSummary(time.Now())
+	summary.TrackMetricsQueue(q)
+
+	if err := q.Write(testSkaterEvent()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	q.Close()
+
+	report := summary.Report()
+	if report.MetricsQueue == nil || report.MetricsQueue.Written != 1 {
+		t.Fatalf("expected the queue stats in the report, got %+v", report.MetricsQueue)
+	}
+
+	var buf bytes.Buffer
+	if err := summary.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "Metrics file: 1 records written, 0 dropped") {
+		t.Errorf("expected the metrics file line, got %q", buf.String())
+	}
+}
+
+func TestViewerSummary_Reconnects(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	summary := NewViewerSummary(start)
+
+	summary.ExpectViewer("event-a", 1)
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, MessageCount: 1})
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, MessageCount: 1, Error: viewer.ErrConnectionLost})
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, MessageCount: 1, Error: viewer.ErrConnect})
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, MessageCount: 1,
+		Reconnect: &viewer.Reconnect{Attempts: 2, Downtime: 4 * time.Second, MissedMessages: 8, MissedLocations: 20}})
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, MessageCount: 2,
+		Reconnect: &viewer.Reconnect{Attempts: 1, Downtime: time.Second, MissedMessages: 2}})
+	summary.Finish(start.Add(time.Minute))
+
+	report := summary.Report()
+	if report.Messages != 1 || report.MessagesPerViewer.Max != 1 {
+		t.Errorf("expected reconnections not to count as messages, got %d messages", report.Messages)
+	}
+	if report.Reconnects != 2 || report.MissedMessages != 10 || report.MissedLocations != 20 {
+		t.Errorf("unexpected reconnection totals: %+v", report)
+	}
+	if report.Downtime.Count != 2 || report.Downtime.Max != 4000 {
+		t.Errorf("expected 2 gaps of up to 4000ms, got %+v", report.Downtime)
+	}
+	if report.Events[0].Reconnects != 2 {
+		t.Errorf("expected 2 reconnects for event-a, got %d", report.Events[0].Reconnects)
+	}
+
+	var buf bytes.Buffer
+	if err := summary.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "Reconnects: 2, downtime p50 ") ||
+		!strings.Contains(buf.String(), "about 10 messages missed (20 locations sent meanwhile)\n") {
+		t.Errorf("expected reconnects line, got %q", buf.String())
+	}
+}
+
+func TestViewerSummary_ConnectionTimings(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	summary := NewViewerSummary(start)
+
+	summary.ExpectViewer("event-a", 1)
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, Handshake: &viewer.Handshake{Duration: time.Second, Status: 503}})
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, Error: viewer.ErrConnect})
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, Handshake: &viewer.Handshake{Duration: 5 * time.Millisecond}})
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, Error: viewer.ErrConnect})
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, Handshake: &viewer.Handshake{Duration: 40 * time.Millisecond, Status: 101}})
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, Connect: &viewer.Connect{Number: 1}})
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, MessageCount: 1, FirstMessage: &viewer.FirstMessage{Wait: 600 * time.Millisecond}})
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, MessageCount: 1, SkaterIDs: []string{"skater-1"}})
+	summary.Finish(start.Add(time.Minute))
+
+	report := summary.Report()
+	if report.Messages != 1 || report.MessagesPerViewer.Max != 1 {
+		t.Errorf("expected connection events not to count as messages, got %d messages", report.Messages)
+	}
+	if report.Connections != 1 || report.Handshake.Count != 1 || report.Handshake.Max != 40 {
+		t.Errorf("expected only the successful handshake to be timed, got %d connections, %+v", report.Connections, report.Handshake)
+	}
+	if report.UpgradeStatuses["101"] != 1 || report.UpgradeStatuses["503"] != 1 || len(report.UpgradeStatuses) != 2 {
+		t.Errorf("expected handshakes without a response not to have a status, got %v", report.UpgradeStatuses)
+	}
+	if report.FirstMessage.Count != 1 || report.FirstMessage.Max != 600 {
+		t.Errorf("expected a 600ms wait for the first message, got %+v", report.FirstMessage)
+	}
+
+	var buf bytes.Buffer
+	if err := summary.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	for _, want := range []string{
+		"Connections: 1, handshake p50 ",
+		"Upgrade statuses: 101=1, 503=1\n",
+		"Time to first message: p50 ",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected output to contain %q, got %q", want, buf.String())
+		}
+	}
+}
+
+func TestViewerSummary_MessageSizes(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	summary := NewViewerSummary(start)
+
+	summary.ExpectViewer("event-a", 3)
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, SkaterIDs: []string{"a"}, Bytes: 100})
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, SkaterIDs: []string{"a", "b", "c"}, Bytes: 300})
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 2, SkaterIDs: []string{"a", "b"}, Bytes: 200})
+	summary.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 2, Bytes: 50, Error: viewer.ErrMalformedBatch})
+	summary.Finish(start.Add(10 * time.Second))
+
+	report := summary.Report()
+	if report.Bytes != 600 || report.Events[0].Bytes != 600 {
+		t.Errorf("expected 600 bytes of batches, got %d overall and %d for event-a", report.Bytes, report.Events[0].Bytes)
+	}
+	if want := (CountStats{Min: 100, Mean: 200, Max: 300}); report.MessageBytes != want {
+		t.Errorf("expected message sizes %+v, got %+v", want, report.MessageBytes)
+	}
+	if want := (CountStats{Min: 1, Mean: 2, Max: 3}); report.LocationsPerBatch != want {
+		t.Errorf("expected locations per batch %+v, got %+v", want, report.LocationsPerBatch)
+	}
+	if want := (RateStats{Min: 0, Mean: 20, Max: 40}); report.BytesPerSecond != want {
+		t.Errorf("expected bytes per second per viewer %+v, got %+v", want, report.BytesPerSecond)
+	}
+
+	var buf bytes.Buffer
+	if err := summary.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	for _, want := range []string{
+		"Message size: min 100 B, mean 200.0 B, max 300 B, 600 B in total\n",
+		"Locations per batch: min 1, mean 2.0, max 3\n",
+		"Throughput per viewer: min 0.0 B/s, mean 20.0 B/s, max 40.0 B/s\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected output to contain %q, got %q", want, buf.String())
+		}
+	}
+}
+
+func TestViewerSummary_NoEndToEndWithoutCorrelation(t *testing.T) {
+	start := time.Now()
+	summary := NewViewerSummary(start)
+	summary.Record(viewer.ViewerResult{ViewerNumber: 1, SkaterIDs: []string{"skater-1"}})
+	summary.Finish(start)
+
+	var buf bytes.Buffer
+	if err := summary.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if strings.Contains(buf.String(), "End-to-end") {
+		t.Errorf("expected no end-to-end latency line, got %q", buf.String())
+	}
+	if summary.Report().Delivery != nil || strings.Contains(buf.String(), "Delivery") {
+		t.Errorf("expected no delivery check, got %q", buf.String())
+	}
+}
+
+func TestSummary_WriteJSONFile(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	summary := NewSkaterSummary(start)
+	summary.Record(skater.UpdateResult{EventID: "event-a", ResponseTime: 15 * time.Millisecond})
+	summary.Finish(start.Add(time.Second))
+
+	filename := filepath.Join(t.TempDir(), "summary.json")
+	if err := summary.WriteJSONFile(filename); err != nil {
+		t.Fatalf("WriteJSONFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read summary file: %v", err)
+	}
+
+	var report SkaterReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("summary file is not valid JSON: %v", err)
+	}
+	if report.Requests != 1 || report.ResponseTime.P99 != 15 || len(report.Events) != 1 {
+		t.Errorf("unexpected report read back: %+v", report)
+	}
+}
+
+func TestWriteSimulationJSONFile(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	skaters := NewSkaterSummary(start)
+	skaters.Record(skater.UpdateResult{EventID: "event-a", ResponseTime: 15 * time.Millisecond})
+	skaters.Finish(start.Add(time.Second))
+
+	viewers := NewViewerSummary(start)
+	viewers.ExpectViewer("event-a", 1)
+	viewers.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, SkaterIDs: []string{"s1"}})
+	viewers.Finish(start.Add(time.Second))
+
+	filename := filepath.Join(t.TempDir(), "summary.json")
+	if err := WriteSimulationJSONFile(filename, skaters, viewers); err != nil {
+		t.Fatalf("WriteSimulationJSONFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read summary file: %v", err)
+	}
+
+	var report SimulationReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("summary file is not valid JSON: %v", err)
+	}
+	if report.Skaters.Requests != 1 || report.Viewers.Messages != 1 || report.Viewers.Locations != 1 {
+		t.Errorf("unexpected report read back: %+v", report)
+	}
+}
+
+func TestReadSummaryFile(t *testing.T) {
+	start := time.Date(2024, 10, 27, 12, 0, 0, 0, time.UTC)
+	skaters := NewSkaterSummary(start)
+	skaters.Record(skater.UpdateResult{EventID: "event-a", ResponseTime: 15 * time.Millisecond})
+	skaters.Finish(start.Add(time.Second))
+	viewers := NewViewerSummary(start)
+	viewers.Record(viewer.ViewerResult{EventID: "event-a", ViewerNumber: 1, SkaterIDs: []string{"s1"}})
+	viewers.Finish(start.Add(time.Second))
+
+	dir := t.TempDir()
+	skaterFile := filepath.Join(dir, "skaters.json")
+	viewerFile := filepath.Join(dir, "viewers.json")
+	simulationFile := filepath.Join(dir, "simulation.json")
+	if err := skaters.WriteJSONFile(skaterFile); err != nil {
+		t.Fatalf("WriteJSONFile() error = %v", err)
+	}
+	if err := viewers.WriteJSONFile(viewerFile); err != nil {
+		t.Fatalf("WriteJSONFile() error = %v", err)
+	}
+	if err := WriteSimulationJSONFile(simulationFile, skaters, viewers); err != nil {
+		t.Fatalf("WriteSimulationJSONFile() error = %v", err)
+	}
+
+	tests := []struct {
+		filename    string
+		wantSkaters bool
+		wantViewers bool
+	}{
+		{skaterFile, true, false},
+		{viewerFile, false, true},
+		{simulationFile, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.filename), func(t *testing.T) {
+			s, v, err := ReadSummaryFile(tt.filename)
+			if err != nil {
+				t.Fatalf("ReadSummaryFile() error = %v", err)
+			}
+			if (s != nil) != tt.wantSkaters || (v != nil) != tt.wantViewers {
+				t.Fatalf("expected skaters %v and viewers %v, got %+v and %+v", tt.wantSkaters, tt.wantViewers, s, v)
+			}
+			if s != nil && s.Requests != 1 {
+				t.Errorf("expected 1 request, got %d", s.Requests)
+			}
+			if v != nil && v.Messages != 1 {
+				t.Errorf("expected 1 message, got %d", v.Messages)
+			}
+		})
+	}
+
+	manifest := filepath.Join(dir, "metrics.manifest.json")
+	if err := os.WriteFile(manifest, []byte(`{"schema": "skater", "segments": []}`), 0o644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	if _, _, err := ReadSummaryFile(manifest); err == nil || !strings.Contains(err.Error(), "not a skater, viewer or simulation summary") {
+		t.Errorf("expected a manifest to be rejected, got %v", err)
+	}
+}
+
+func within(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= want*tolerance
+}
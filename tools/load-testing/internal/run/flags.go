@@ -0,0 +1,195 @@
+// Package run holds the setup shared by the simulate commands: the flags for
+// metrics output and skater load, metrics files and live metrics, waiting for
+// a run to end while checking its SLOs, and deciding how it exits.
+package run
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"load-testing/internal/metrics"
+	"load-testing/internal/simulation"
+	"load-testing/internal/skater"
+)
+
+const (
+	defaultSpeedKmh   = 20
+	defaultPackSpread = 200
+)
+
+// MetricsFlags holds the flags that configure how results are written to
+// metrics files and served live.
+type MetricsFlags struct {
+	Format         string
+	RotateSize     int64
+	RotateInterval time.Duration
+	Compression    string
+	QueueSize      int
+	Overflow       string
+	SampleEvery    int
+	Listen         string
+
+	rotateSize     string
+	rotateInterval string
+}
+
+// Register defines the metrics flags. listenExample is the address suggested
+// in the help of --metrics-listen.
+func (f *MetricsFlags) Register(listenExample string) {
+	flag.StringVar(&f.Format, "metrics-format", metrics.FormatCSV, fmt.Sprintf("Metrics file format, one of %s", strings.Join(metrics.Formats, ", ")))
+	flag.StringVar(&f.rotateSize, "metrics-rotate-size", "", "Optional size after which the metrics file is continued in a new numbered segment (e.g., 500MB, 2GB)")
+	flag.StringVar(&f.rotateInterval, "metrics-rotate-interval", "", "Optional time after which the metrics file is continued in a new numbered segment (e.g., 1h)")
+	flag.StringVar(&f.Compression, "metrics-compression", metrics.CompressionNone, fmt.Sprintf("Metrics file compression, one of %s", strings.Join(metrics.Compressions, ", ")))
+	flag.IntVar(&f.QueueSize, "metrics-queue-size", metrics.DefaultQueueSize, "Number of results queued for the metrics file writer")
+	flag.StringVar(&f.Overflow, "metrics-overflow", metrics.OverflowBlock, fmt.Sprintf("What to do with results when the metrics queue is full, one of %s", strings.Join(metrics.OverflowPolicies, ", ")))
+	flag.IntVar(&f.SampleEvery, "metrics-sample-every", metrics.DefaultSampleEvery, "Keep one in this many results while the metrics queue is over half full, with --metrics-overflow sample")
+	flag.StringVar(&f.Listen, "metrics-listen", "", fmt.Sprintf("Optional address to serve live Prometheus metrics on at /metrics (e.g., %s)", listenExample))
+}
+
+// Parse checks the metrics flags after flag.Parse, exiting on invalid values.
+func (f *MetricsFlags) Parse() {
+	if !slices.Contains(metrics.Formats, f.Format) {
+		log.Fatalf("Unknown metrics format %q, want one of %s", f.Format, strings.Join(metrics.Formats, ", "))
+	}
+
+	if !slices.Contains(metrics.Compressions, f.Compression) {
+		log.Fatalf("Unknown metrics compression %q, want one of %s", f.Compression, strings.Join(metrics.Compressions, ", "))
+	}
+	if f.Format == metrics.FormatParquet && f.Compression != metrics.CompressionNone {
+		log.Fatalf("Parquet metrics files are compressed already, --metrics-compression must be %s", metrics.CompressionNone)
+	}
+
+	if f.QueueSize <= 0 {
+		log.Fatalf("Metrics queue size must be positive, got: %d", f.QueueSize)
+	}
+	if !slices.Contains(metrics.OverflowPolicies, f.Overflow) {
+		log.Fatalf("Unknown metrics overflow policy %q, want one of %s", f.Overflow, strings.Join(metrics.OverflowPolicies, ", "))
+	}
+	if f.SampleEvery <= 0 {
+		log.Fatalf("Metrics sampling interval must be positive, got: %d", f.SampleEvery)
+	}
+
+	if f.rotateSize != "" {
+		size, err := metrics.ParseSize(f.rotateSize)
+		if err != nil {
+			log.Fatalf("Invalid metrics rotation size: %v", err)
+		}
+		if size <= 0 {
+			log.Fatalf("Metrics rotation size must be positive, got: %s", f.rotateSize)
+		}
+		f.RotateSize = size
+	}
+
+	if f.rotateInterval != "" {
+		interval, err := time.ParseDuration(f.rotateInterval)
+		if err != nil {
+			log.Fatalf("Invalid metrics rotation interval: %v", err)
+		}
+		if interval <= 0 {
+			log.Fatalf("Metrics rotation interval must be positive, got: %v", interval)
+		}
+		f.RotateInterval = interval
+	}
+}
+
+// SkaterFlags holds the flags that configure simulated skaters and the load
+// they send.
+type SkaterFlags struct {
+	NumEvents       int
+	SkatersPerEvent int
+	UpdateInterval  time.Duration
+	EventIDs        string
+	RateLimit       float64
+	RampUpDuration  time.Duration
+	LoadModel       string
+	Movement        string
+	RouteFile       string
+	SpeedKmh        float64
+	PackSpread      float64
+	InvalidRate     float64
+	OTLPEndpoint    string
+
+	updateInterval string
+	rampUp         string
+}
+
+// Register defines the skater flags.
+func (f *SkaterFlags) Register() {
+	flag.IntVar(&f.NumEvents, "events", 1, "Number of events to simulate")
+	flag.IntVar(&f.SkatersPerEvent, "skaters-per-event", 10, "Number of skaters per event")
+	flag.StringVar(&f.updateInterval, "update-interval", "3s", "Interval between location updates (e.g., 3s, 1m)")
+	flag.StringVar(&f.EventIDs, "event-id", "", "Comma-separated list of event IDs to use (optional, generates random if not provided)")
+	flag.Float64Var(&f.RateLimit, "rate-limit", 0, "Optional maximum skater requests per second (0 = unlimited)")
+	flag.StringVar(&f.rampUp, "ramp-up-duration", "", "Optional duration to gradually increase skater load (e.g., 5m, 10s)")
+	flag.StringVar(&f.LoadModel, "load-model", simulation.LoadModelClosed, fmt.Sprintf("Load model, one of %s (open sends updates at their scheduled times however long earlier ones take)",
+		strings.Join(simulation.LoadModels, ", ")))
+
+	flag.StringVar(&f.Movement, "movement", "", fmt.Sprintf("Movement model, one of %s (default: route when --route-file is set, otherwise random-walk)",
+		strings.Join(skater.MoverNames(), ", ")))
+	flag.StringVar(&f.RouteFile, "route-file", "", "GeoJSON or GPX route for skaters to follow (required for route movement)")
+	flag.Float64Var(&f.SpeedKmh, "speed-kmh", defaultSpeedKmh, "Skater speed in km/h when following a route")
+	flag.Float64Var(&f.PackSpread, "pack-spread", defaultPackSpread, "Length in metres of the pack skaters are spread along when following a route")
+	flag.Float64Var(&f.InvalidRate, "teleport-invalid-rate", 0, "Fraction of teleport jumps that send out-of-range coordinates (0-1)")
+	flag.StringVar(&f.OTLPEndpoint, "otlp-endpoint", "", "Optional OTLP/HTTP collector URL to export a span per update to (e.g., http://localhost:4318)")
+}
+
+// Parse checks the skater flags after flag.Parse, exiting on invalid values,
+// and resolves the default movement model.
+func (f *SkaterFlags) Parse() {
+	if f.NumEvents <= 0 {
+		log.Fatalf("Number of events must be positive, got: %d", f.NumEvents)
+	}
+
+	if f.SkatersPerEvent <= 0 {
+		log.Fatalf("Number of skaters per event must be positive, got: %d", f.SkatersPerEvent)
+	}
+
+	interval, err := time.ParseDuration(f.updateInterval)
+	if err != nil {
+		log.Fatalf("Invalid update interval: %v", err)
+	}
+	f.UpdateInterval = interval
+
+	if f.rampUp != "" {
+		rampUp, err := time.ParseDuration(f.rampUp)
+		if err != nil {
+			log.Fatalf("Invalid ramp-up duration: %v", err)
+		}
+		if rampUp < time.Second {
+			log.Fatalf("Ramp-up duration must be at least 1 second, got: %v", rampUp)
+		}
+		if rampUp > time.Hour {
+			log.Fatalf("Ramp-up duration must be at most 1 hour, got: %v", rampUp)
+		}
+		f.RampUpDuration = rampUp
+	}
+
+	if f.RateLimit < 0 {
+		log.Fatalf("Rate limit must be non-negative, got: %f", f.RateLimit)
+	}
+
+	if !slices.Contains(simulation.LoadModels, f.LoadModel) {
+		log.Fatalf("Unknown load model %q, want one of %s", f.LoadModel, strings.Join(simulation.LoadModels, ", "))
+	}
+
+	if f.SpeedKmh <= 0 {
+		log.Fatalf("Speed must be positive, got: %f", f.SpeedKmh)
+	}
+
+	if f.PackSpread < 0 {
+		log.Fatalf("Pack spread must be non-negative, got: %f", f.PackSpread)
+	}
+
+	if f.InvalidRate < 0 || f.InvalidRate > 1 {
+		log.Fatalf("Teleport invalid rate must be between 0 and 1, got: %f", f.InvalidRate)
+	}
+
+	f.Movement = simulation.ResolveMovement(f.Movement, f.RouteFile)
+	if f.Movement == skater.MovementRoute && f.RouteFile == "" {
+		log.Fatalf("--route-file is required for %s movement", skater.MovementRoute)
+	}
+}
@@ -0,0 +1,124 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"load-testing/internal/metrics"
+	"load-testing/internal/skater"
+	"load-testing/internal/viewer"
+)
+
+// NewSink creates the metrics file for results of schema and the queue in
+// front of it. queueMetrics may be nil.
+func (f MetricsFlags) NewSink(filename string, schema *metrics.Schema, queueMetrics *metrics.QueueMetrics) (*metrics.QueuedSink, error) {
+	fileSink, err := metrics.NewSink(f.Format, filename, schema,
+		metrics.WithRotation(f.RotateSize, f.RotateInterval),
+		metrics.WithCompression(f.Compression))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics writer for %s: %w", filename, err)
+	}
+	sink, err := metrics.NewQueuedSink(fileSink, metrics.QueueConfig{
+		Size:        f.QueueSize,
+		Overflow:    f.Overflow,
+		SampleEvery: f.SampleEvery,
+		Metrics:     queueMetrics,
+	})
+	if err != nil {
+		fileSink.Close()
+		return nil, fmt.Errorf("failed to create metrics writer for %s: %w", filename, err)
+	}
+	return sink, nil
+}
+
+// CloseSinks closes metrics file queues, logging any error. Closing a queue
+// again is harmless, so it can be deferred as well as called once results
+// have been recorded.
+func CloseSinks(sinks ...*metrics.QueuedSink) {
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			log.Printf("Error closing metrics file: %v", err)
+		}
+	}
+}
+
+// LiveMetrics are the Prometheus metrics served with --metrics-listen. Its
+// fields are nil when the flag is not set, which the metrics treat as not
+// recording.
+type LiveMetrics struct {
+	Skaters *metrics.SkaterMetrics
+	Viewers *metrics.ViewerMetrics
+	Queue   *metrics.QueueMetrics
+
+	registry *metrics.Registry
+	server   *http.Server
+}
+
+// ServeLiveMetrics starts serving live metrics when --metrics-listen is set,
+// with skater metrics, viewer metrics or both.
+func (f MetricsFlags) ServeLiveMetrics(skaters, viewers bool) (*LiveMetrics, error) {
+	live := &LiveMetrics{}
+	if f.Listen == "" {
+		return live, nil
+	}
+
+	live.registry = metrics.NewRegistry()
+	if skaters {
+		live.Skaters = metrics.NewSkaterMetrics(live.registry)
+	}
+	if viewers {
+		live.Viewers = metrics.NewViewerMetrics(live.registry)
+	}
+	live.Queue = metrics.NewQueueMetrics(live.registry)
+
+	server, err := metrics.Serve(f.Listen, live.registry)
+	if err != nil {
+		return nil, err
+	}
+	live.server = server
+	log.Printf("Serving Prometheus metrics on %s/metrics", f.Listen)
+	return live, nil
+}
+
+// Close stops serving live metrics.
+func (l *LiveMetrics) Close() {
+	if l.server != nil {
+		l.server.Close()
+	}
+}
+
+// SkaterRecorder returns a function that records a skater result in the
+// summary, the live metrics and the metrics file, logging failed updates.
+// Skaters call it directly, so only the metrics queue's overflow policy
+// decides whether a slow metrics file can hold them up. Updates skipped by
+// the rate limit are counted but not logged, as there can be many.
+func SkaterRecorder(summary *metrics.SkaterSummary, live *metrics.SkaterMetrics,
+	sink *metrics.QueuedSink) func(skater.UpdateResult) {
+	return func(result skater.UpdateResult) {
+		summary.Record(result)
+		live.Record(result)
+		if err := sink.Write(metrics.SkaterEvent(result)); err != nil {
+			log.Printf("Error writing skater metric: %v", err)
+		}
+		if result.Error != nil && !errors.Is(result.Error, skater.ErrSkipped) {
+			log.Printf("Error updating location for skater %s in event %s: %v",
+				result.SkaterID, result.EventID, result.Error)
+		}
+	}
+}
+
+// RecordViewers records viewer results in the summary, the live metrics and
+// the metrics file until results is closed, passing each one to logResult.
+func RecordViewers(results <-chan viewer.ViewerResult, summary *metrics.ViewerSummary,
+	live *metrics.ViewerMetrics, sink *metrics.QueuedSink, logResult func(viewer.ViewerResult)) {
+	for result := range results {
+		summary.Record(result)
+		live.Record(result)
+		if err := sink.Write(metrics.ViewerEvent(result)); err != nil {
+			log.Printf("Error writing viewer metric: %v", err)
+		}
+		logResult(result)
+	}
+}
@@ -0,0 +1,101 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"load-testing/internal/metrics"
+)
+
+const sloCheckInterval = 5 * time.Second
+
+// SLOCheck checks the run's SLO thresholds against its results so far, or
+// with final set against the complete run.
+type SLOCheck func(final bool) []metrics.Breach
+
+// Wait blocks until the run is interrupted with SIGINT or SIGTERM, until
+// duration has passed if it is positive, or until check finds an SLO
+// breached while the run is in progress.
+func Wait(duration time.Duration, check SLOCheck) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	if duration > 0 {
+		log.Printf("Simulation running for %s. Press Ctrl+C to stop early.", duration)
+	} else {
+		log.Printf("Simulation running. Press Ctrl+C to stop.")
+	}
+	wait(sigChan, duration, sloCheckInterval, check)
+}
+
+func wait(sigChan <-chan os.Signal, duration, checkInterval time.Duration, check SLOCheck) {
+	var finished <-chan time.Time
+	if duration > 0 {
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+		finished = timer.C
+	}
+
+	sloTicker := time.NewTicker(checkInterval)
+	defer sloTicker.Stop()
+
+	for {
+		select {
+		case <-sigChan:
+			return
+		case <-finished:
+			log.Printf("Run duration of %s reached", duration)
+			return
+		case <-sloTicker.C:
+			if breaches := check(false); len(breaches) > 0 {
+				log.Printf("Stopping early: %v", &metrics.SLOError{Breaches: breaches})
+				return
+			}
+		}
+	}
+}
+
+// LogSummary logs a finished summary, under title if it is not empty.
+func LogSummary(title string, summary interface{ WriteText(io.Writer) error }) {
+	if title != "" {
+		log.Println(title)
+	}
+	if err := summary.WriteText(log.Writer()); err != nil {
+		log.Printf("Error writing summary: %v", err)
+	}
+}
+
+// WriteSummaryFile writes the summary to filename as JSON with write, if
+// filename is set.
+func WriteSummaryFile(filename string, write func(filename string) error) error {
+	if filename == "" {
+		return nil
+	}
+	if err := write(filename); err != nil {
+		return err
+	}
+	log.Printf("Summary written to: %s", filename)
+	return nil
+}
+
+// Outcome returns the error a finished run exits with: whether errs exceeds
+// the error budget, which is unlimited when -1, joined with the SLO breaches
+// check finds. failures describes errs in the budget error, e.g.
+// "3 failed updates".
+func Outcome(budget, errs int, failures string, check SLOCheck) error {
+	var budgetErr, sloErr error
+	if budget >= 0 && errs > budget {
+		budgetErr = fmt.Errorf("error budget exceeded: %s, budget is %d", failures, budget)
+	}
+	if breaches := check(true); len(breaches) > 0 {
+		sloErr = &metrics.SLOError{Breaches: breaches}
+	}
+	return errors.Join(budgetErr, sloErr)
+}
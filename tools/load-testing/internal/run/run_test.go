@@ -0,0 +1,229 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"load-testing/internal/metrics"
+	"load-testing/internal/simulation"
+	"load-testing/internal/skater"
+)
+
+func noBreaches(bool) []metrics.Breach {
+	return nil
+}
+
+func TestWait_Duration(t *testing.T) {
+	start := time.Now()
+	wait(nil, 50*time.Millisecond, time.Hour, noBreaches)
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected to wait for the run duration, returned after %v", elapsed)
+	}
+}
+
+func TestWait_Signal(t *testing.T) {
+	sigChan := make(chan os.Signal, 1)
+	sigChan <- syscall.SIGTERM
+
+	done := make(chan struct{})
+	go func() {
+		wait(sigChan, 0, time.Hour, noBreaches)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected a signal to end the wait")
+	}
+}
+
+func TestWait_SLOBreach(t *testing.T) {
+	checks := 0
+	check := func(final bool) []metrics.Breach {
+		if final {
+			t.Error("expected checks during the run not to be final")
+		}
+		checks++
+		if checks < 3 {
+			return nil
+		}
+		return []metrics.Breach{{SLO: "max-error-rate", Message: "too many errors"}}
+	}
+
+	wait(nil, 0, 10*time.Millisecond, check)
+
+	if checks != 3 {
+		t.Errorf("expected to stop at the first breach, on check 3, got %d checks", checks)
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	breach := []metrics.Breach{{SLO: "max-error-rate", Message: "too many errors"}}
+
+	tests := []struct {
+		name       string
+		budget     int
+		errs       int
+		breaches   []metrics.Breach
+		wantBudget bool
+		wantSLO    bool
+	}{
+		{name: "unlimited budget", budget: -1, errs: 100},
+		{name: "within budget", budget: 5, errs: 5},
+		{name: "over budget", budget: 5, errs: 6, wantBudget: true},
+		{name: "SLO breached", budget: -1, breaches: breach, wantSLO: true},
+		{name: "both", budget: 0, errs: 1, breaches: breach, wantBudget: true, wantSLO: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Outcome(tt.budget, tt.errs, "some failures", func(final bool) []metrics.Breach {
+				if !final {
+					t.Error("expected the check at the end of the run to be final")
+				}
+				return tt.breaches
+			})
+
+			if !tt.wantBudget && !tt.wantSLO {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := strings.Contains(err.Error(), "error budget exceeded: some failures"); got != tt.wantBudget {
+				t.Errorf("error %q mentions the error budget: %t, want %t", err, got, tt.wantBudget)
+			}
+			var sloErr *metrics.SLOError
+			if got := errors.As(err, &sloErr); got != tt.wantSLO {
+				t.Errorf("error %q is an SLOError: %t, want %t", err, got, tt.wantSLO)
+			}
+		})
+	}
+}
+
+func TestWriteSummaryFile_NoFile(t *testing.T) {
+	err := WriteSummaryFile("", func(string) error {
+		t.Error("expected no summary file to be written")
+		return nil
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSkaterRecorder(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "metrics.csv")
+	flags := MetricsFlags{Format: metrics.FormatCSV, Compression: metrics.CompressionNone,
+		QueueSize: 10, Overflow: metrics.OverflowBlock, SampleEvery: 1}
+	sink, err := flags.NewSink(filename, metrics.SkaterSchema, nil)
+	if err != nil {
+		t.Fatalf("NewSink() error = %v", err)
+	}
+
+	now := time.Now()
+	summary := metrics.NewSkaterSummary(now)
+	record := SkaterRecorder(summary, nil, sink)
+	record(skater.UpdateResult{EventID: "event-1", SkaterID: "skater-1", Timestamp: now, ResponseTime: 10 * time.Millisecond})
+	record(skater.UpdateResult{EventID: "event-1", SkaterID: "skater-1", Timestamp: now, Error: errors.New("connection refused")})
+	record(skater.UpdateResult{EventID: "event-1", SkaterID: "skater-1", Timestamp: now, Error: skater.ErrSkipped})
+	CloseSinks(sink)
+
+	report := summary.Report()
+	if report.Requests != 2 || report.Errors != 1 || report.Skipped != 1 {
+		t.Errorf("expected 2 requests, 1 error and 1 skipped, got %d, %d and %d", report.Requests, report.Errors, report.Skipped)
+	}
+
+	r, err := metrics.OpenCSV(filename)
+	if err != nil {
+		t.Fatalf("OpenCSV() error = %v", err)
+	}
+	defer r.Close()
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if len(records) != 3 {
+		t.Errorf("expected every result in the metrics file, got %d records", len(records))
+	}
+}
+
+// slowSink takes delay to write each event, standing in for a slow disk.
+type slowSink struct {
+	delay time.Duration
+}
+
+func (s slowSink) Write(metrics.Event) error {
+	time.Sleep(s.delay)
+	return nil
+}
+
+func (s slowSink) Close() error {
+	return nil
+}
+
+func TestSkaterRecorder_SlowMetricsFile(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	const (
+		interval = 5 * time.Millisecond
+		duration = 200 * time.Millisecond
+	)
+	plans, err := simulation.BuildPlans([]string{"event-1"}, 1, interval, server.URL,
+		simulation.Movement{Name: skater.MovementRandomWalk})
+	if err != nil {
+		t.Fatalf("BuildPlans() error = %v", err)
+	}
+
+	// The metrics file takes longer to write a result than the whole run
+	// takes, so with a one-slot queue nearly every result overflows it.
+	sink, err := metrics.NewQueuedSink(slowSink{delay: duration}, metrics.QueueConfig{
+		Size:        1,
+		Overflow:    metrics.OverflowDrop,
+		SampleEvery: 1,
+	})
+	if err != nil {
+		t.Fatalf("NewQueuedSink() error = %v", err)
+	}
+	defer sink.Close()
+
+	summary := metrics.NewSkaterSummary(time.Now())
+	stopChan := make(chan struct{})
+	var wg sync.WaitGroup
+	flags := SkaterFlags{LoadModel: simulation.LoadModelClosed}
+	flags.StartSkaters(context.Background(), stopChan, plans, nil, nil, nil, SkaterRecorder(summary, nil, sink), &wg)
+
+	time.Sleep(duration)
+	close(stopChan)
+	wg.Wait()
+
+	if want := int32(duration / interval / 2); requests.Load() < want {
+		t.Errorf("expected the slow metrics file not to hold up updates, got %d in %s, want at least %d",
+			requests.Load(), duration, want)
+	}
+	if got := summary.Report().Requests; got != int(requests.Load()) {
+		t.Errorf("expected every update in the summary, got %d of %d", got, requests.Load())
+	}
+	if stats := sink.Stats(); stats.Dropped == 0 {
+		t.Errorf("expected results the metrics queue had no room for to be dropped, got %s", stats)
+	}
+}
@@ -0,0 +1,104 @@
+package run
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"load-testing/internal/metrics"
+	"load-testing/internal/simulation"
+	"load-testing/internal/skater"
+	"load-testing/internal/tracing"
+
+	"golang.org/x/time/rate"
+)
+
+const tracingFlushTimeout = 10 * time.Second
+
+// NewMovement creates the default movement of the run's skaters, traced with
+// a tracer provider that exports spans to --otlp-endpoint when it is set. The
+// returned function flushes and shuts down the tracer provider.
+func (f SkaterFlags) NewMovement() (simulation.Movement, func(), error) {
+	movement, err := simulation.NewMovement(f.Movement, f.RouteFile, skater.MoverConfig{
+		SpeedKmh:    f.SpeedKmh,
+		PackSpread:  f.PackSpread,
+		InvalidRate: f.InvalidRate,
+	})
+	if err != nil {
+		return simulation.Movement{}, nil, err
+	}
+
+	tracerProvider, err := tracing.NewProvider(context.Background(), tracing.Config{Endpoint: f.OTLPEndpoint})
+	if err != nil {
+		return simulation.Movement{}, nil, err
+	}
+	movement.SkaterOpts = append(movement.SkaterOpts, skater.WithTracerProvider(tracerProvider))
+	if f.OTLPEndpoint != "" {
+		log.Printf("Exporting update spans to %s", f.OTLPEndpoint)
+	}
+
+	shutdown := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer cancel()
+		if err := tracerProvider.Shutdown(ctx); err != nil {
+			log.Printf("Error exporting spans: %v", err)
+		}
+	}
+	return movement, shutdown, nil
+}
+
+// LogLoad logs how the skaters move and the load they send.
+func (f SkaterFlags) LogLoad(movement simulation.Movement) {
+	log.Printf("Movement model: %s", f.Movement)
+	if f.Movement == skater.MovementRoute && movement.Config.Route != nil {
+		shape := "there and back"
+		if movement.Config.Route.Closed() {
+			shape = "loop"
+		}
+		log.Printf("Following route from %s (%.0fm %s) at %.1f km/h with a %.0fm pack",
+			f.RouteFile, movement.Config.Route.Length(), shape, f.SpeedKmh, f.PackSpread)
+	}
+
+	log.Printf("Load model: %s", f.LoadModel)
+	if f.RateLimit > 0 {
+		log.Printf("Rate limiting enabled: %.2f requests/second", f.RateLimit)
+	}
+	if f.RampUpDuration > 0 {
+		log.Printf("Ramp-up enabled: %s", f.RampUpDuration)
+	}
+}
+
+// StartSkaters starts sending updates for every plan with the configured load
+// model until stopChan is closed, raising the rate limit first if rampUp is
+// set, passing each result to record. limiter, rampUp and liveMetrics may be
+// nil. Each skater is done in wg once it has stopped and its last result has
+// been recorded.
+func (f SkaterFlags) StartSkaters(ctx context.Context, stopChan <-chan struct{}, plans []simulation.SkaterPlan,
+	limiter *rate.Limiter, rampUp *simulation.RampUp, liveMetrics *metrics.SkaterMetrics,
+	record func(skater.UpdateResult), wg *sync.WaitGroup) {
+	if rampUp != nil {
+		go func() {
+			log.Printf("Ramping up from %.2f to %.2f requests/second over %s", rampUp.Initial, rampUp.Target, rampUp.Duration)
+			if err := rampUp.Run(ctx, limiter); err == nil {
+				log.Printf("Ramp-up complete: %.2f requests/second", rampUp.Target)
+			}
+		}()
+	}
+
+	runSkater := simulation.RunSkater
+	if f.LoadModel == simulation.LoadModelOpen {
+		runSkater = simulation.RunSkaterOpen
+	}
+
+	start := time.Now()
+
+	log.Printf("Starting %d skaters...", len(plans))
+	for _, p := range plans {
+		wg.Add(1)
+		go func(plan simulation.SkaterPlan) {
+			defer wg.Done()
+			runSkater(ctx, stopChan, start, plan, limiter, liveMetrics, record)
+		}(p)
+	}
+}
@@ -0,0 +1,278 @@
+package scenario
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultUpdateInterval = 3 * time.Second
+)
+
+// Curve shapes describing how skater join or leave times are distributed.
+const (
+	ShapeImmediate = "immediate"
+	ShapeLinear    = "linear"
+	ShapeRandom    = "random"
+)
+
+// Duration is a time.Duration that is written in scenario files as a
+// string such as "90s" or "2h30m".
+type Duration time.Duration
+
+// UnmarshalYAML parses a duration string.
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return fmt.Errorf("line %d: duration must be a string such as \"90s\": %w", value.Line, err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// Scenario describes a whole simulated session: the events taking place,
+// how many skaters join each one and when, and how long the session runs.
+type Scenario struct {
+	Name     string   `yaml:"name"`
+	Duration Duration `yaml:"duration"`
+	Events   []Event  `yaml:"events"`
+}
+
+// Event describes a single skating event within a scenario.
+// ID is optional; a random UUID is generated when it is empty, and IDGenerated
+// records that so tools that must share IDs with another process can reject it.
+// Movement, RouteFile and SpeedKmh are optional overrides of the command-line movement settings.
+// Load resolves a relative RouteFile against the directory of the scenario file.
+type Event struct {
+	Name           string   `yaml:"name"`
+	ID             string   `yaml:"id"`
+	Skaters        int      `yaml:"skaters"`
+	Viewers        int      `yaml:"viewers"`
+	UpdateInterval Duration `yaml:"updateInterval"`
+	IntervalJitter Duration `yaml:"intervalJitter"`
+	Join           Curve    `yaml:"join"`
+	Leave          *Curve   `yaml:"leave"`
+	Movement       string   `yaml:"movement"`
+	RouteFile      string   `yaml:"routeFile"`
+	SpeedKmh       float64  `yaml:"speedKmh"`
+	IDGenerated    bool     `yaml:"-"`
+}
+
+// Curve describes how join or leave times are spread across a group of skaters.
+// Times are offsets from the start of the scenario: every skater joins (or leaves)
+// between Start and Start+Over, spaced according to Shape.
+type Curve struct {
+	Shape string   `yaml:"shape"`
+	Start Duration `yaml:"start"`
+	Over  Duration `yaml:"over"`
+}
+
+// Load reads and validates a scenario from a YAML or JSON file.
+// Defaults are applied to optional fields, missing event IDs are generated
+// and an unnamed scenario is named after its file. Relative route files are
+// resolved against the scenario file's directory, so a scenario can be run
+// from any working directory.
+func Load(filename string) (*Scenario, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario file: %w", err)
+	}
+
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scenario file %s: %w", filename, err)
+	}
+
+	if s.Name == "" {
+		base := filepath.Base(filename)
+		s.Name = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	dir := filepath.Dir(filename)
+	for i := range s.Events {
+		e := &s.Events[i]
+		if e.RouteFile != "" && !filepath.IsAbs(e.RouteFile) {
+			e.RouteFile = filepath.Join(dir, e.RouteFile)
+		}
+	}
+	return s, nil
+}
+
+// Parse decodes and validates a scenario from YAML or JSON content.
+// Unknown fields are rejected so that typos are not silently ignored.
+func Parse(data []byte) (*Scenario, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var s Scenario
+	if err := decoder.Decode(&s); err != nil {
+		return nil, err
+	}
+
+	s.applyDefaults()
+
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Scenario) applyDefaults() {
+	for i := range s.Events {
+		e := &s.Events[i]
+		if e.Name == "" {
+			e.Name = fmt.Sprintf("event-%d", i+1)
+		}
+		if e.ID == "" {
+			e.ID = uuid.New().String()
+			e.IDGenerated = true
+		}
+		if e.UpdateInterval == 0 {
+			e.UpdateInterval = Duration(defaultUpdateInterval)
+		}
+		if e.Join.Shape == "" {
+			e.Join.Shape = ShapeImmediate
+		}
+		if e.Leave != nil && e.Leave.Shape == "" {
+			e.Leave.Shape = ShapeImmediate
+		}
+	}
+}
+
+// Validate checks that the scenario is internally consistent.
+func (s *Scenario) Validate() error {
+	if s.Duration <= 0 {
+		return errors.New("duration must be positive")
+	}
+
+	if len(s.Events) == 0 {
+		return errors.New("at least one event is required")
+	}
+
+	seen := make(map[string]bool, len(s.Events))
+	for _, e := range s.Events {
+		if err := e.validate(s.Duration); err != nil {
+			return fmt.Errorf("event %q: %w", e.Name, err)
+		}
+		if seen[e.ID] {
+			return fmt.Errorf("event %q: duplicate event ID %s", e.Name, e.ID)
+		}
+		seen[e.ID] = true
+	}
+
+	return nil
+}
+
+func (e Event) validate(duration Duration) error {
+	if _, err := uuid.Parse(e.ID); err != nil {
+		return fmt.Errorf("invalid UUID format for event ID (%s): %w", e.ID, err)
+	}
+
+	if e.Skaters <= 0 {
+		return fmt.Errorf("skaters must be positive, got: %d", e.Skaters)
+	}
+
+	if e.Viewers < 0 {
+		return fmt.Errorf("viewers must be non-negative, got: %d", e.Viewers)
+	}
+
+	if e.UpdateInterval <= 0 {
+		return fmt.Errorf("updateInterval must be positive, got: %s", time.Duration(e.UpdateInterval))
+	}
+
+	if e.IntervalJitter < 0 || e.IntervalJitter >= e.UpdateInterval {
+		return fmt.Errorf("intervalJitter must be non-negative and less than updateInterval, got: %s",
+			time.Duration(e.IntervalJitter))
+	}
+
+	if e.SpeedKmh < 0 {
+		return fmt.Errorf("speedKmh must be non-negative, got: %f", e.SpeedKmh)
+	}
+
+	if err := e.Join.validate(duration); err != nil {
+		return fmt.Errorf("join: %w", err)
+	}
+
+	if e.Leave != nil {
+		if err := e.Leave.validate(duration); err != nil {
+			return fmt.Errorf("leave: %w", err)
+		}
+		if e.Leave.Start < e.Join.Start {
+			return errors.New("leave must not start before join")
+		}
+	}
+
+	return nil
+}
+
+func (c Curve) validate(duration Duration) error {
+	switch c.Shape {
+	case ShapeImmediate, ShapeLinear, ShapeRandom:
+	default:
+		return fmt.Errorf("unknown shape %q (must be %s, %s or %s)", c.Shape, ShapeImmediate, ShapeLinear, ShapeRandom)
+	}
+
+	if c.Start < 0 || c.Over < 0 {
+		return errors.New("start and over must be non-negative")
+	}
+
+	if c.Start+c.Over > duration {
+		return fmt.Errorf("curve ends at %s, after the scenario duration of %s",
+			time.Duration(c.Start+c.Over), time.Duration(duration))
+	}
+
+	return nil
+}
+
+// Offsets returns n offsets from the start of the scenario, in ascending order,
+// distributed according to the curve's shape.
+func (c Curve) Offsets(n int) []time.Duration {
+	start := time.Duration(c.Start)
+	over := time.Duration(c.Over)
+
+	offsets := make([]time.Duration, n)
+	for i := range offsets {
+		switch c.Shape {
+		case ShapeLinear:
+			if n > 1 {
+				offsets[i] = start + over*time.Duration(i)/time.Duration(n-1)
+			} else {
+				offsets[i] = start
+			}
+		case ShapeRandom:
+			offsets[i] = start + time.Duration(rand.Int63n(int64(over)+1))
+		default:
+			offsets[i] = start
+		}
+	}
+
+	if c.Shape == ShapeRandom {
+		sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	}
+	return offsets
+}
+
+// TotalSkaters returns the number of skaters across all events.
+func (s *Scenario) TotalSkaters() int {
+	total := 0
+	for _, e := range s.Events {
+		total += e.Skaters
+	}
+	return total
+}
@@ -0,0 +1,287 @@
+package scenario
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParse_YAML(t *testing.T) {
+	s, err := Parse([]byte(`
+name: test
+duration: 1h
+events:
+  - name: ride
+    id: 123e4567-e89b-12d3-a456-426614174000
+    skaters: 20
+    viewers: 2
+    updateInterval: 4s
+    intervalJitter: 500ms
+    join:
+      shape: linear
+      over: 5m
+    leave:
+      shape: random
+      start: 50m
+      over: 10m
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if s.Name != "test" {
+		t.Errorf("expected name 'test', got %q", s.Name)
+	}
+	if time.Duration(s.Duration) != time.Hour {
+		t.Errorf("expected duration 1h, got %s", time.Duration(s.Duration))
+	}
+	if len(s.Events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(s.Events))
+	}
+
+	e := s.Events[0]
+	if e.ID != "123e4567-e89b-12d3-a456-426614174000" || e.IDGenerated {
+		t.Errorf("expected provided event ID to be kept, got %q (generated: %v)", e.ID, e.IDGenerated)
+	}
+	if e.Skaters != 20 || e.Viewers != 2 {
+		t.Errorf("expected 20 skaters and 2 viewers, got %d and %d", e.Skaters, e.Viewers)
+	}
+	if time.Duration(e.UpdateInterval) != 4*time.Second || time.Duration(e.IntervalJitter) != 500*time.Millisecond {
+		t.Errorf("unexpected interval %s ± %s", time.Duration(e.UpdateInterval), time.Duration(e.IntervalJitter))
+	}
+	if e.Join.Shape != ShapeLinear || time.Duration(e.Join.Over) != 5*time.Minute {
+		t.Errorf("unexpected join curve %+v", e.Join)
+	}
+	if e.Leave == nil || e.Leave.Shape != ShapeRandom || time.Duration(e.Leave.Start) != 50*time.Minute {
+		t.Errorf("unexpected leave curve %+v", e.Leave)
+	}
+}
+
+func TestParse_JSON(t *testing.T) {
+	s, err := Parse([]byte(`{
+		"name": "json",
+		"duration": "30m",
+		"events": [{"skaters": 5}]
+	}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	e := s.Events[0]
+	if e.Name != "event-1" {
+		t.Errorf("expected default name 'event-1', got %q", e.Name)
+	}
+	if _, err := uuid.Parse(e.ID); err != nil || !e.IDGenerated {
+		t.Errorf("expected generated UUID event ID, got %q (generated: %v)", e.ID, e.IDGenerated)
+	}
+	if time.Duration(e.UpdateInterval) != defaultUpdateInterval {
+		t.Errorf("expected default update interval, got %s", time.Duration(e.UpdateInterval))
+	}
+	if e.Join.Shape != ShapeImmediate {
+		t.Errorf("expected default join shape %q, got %q", ShapeImmediate, e.Join.Shape)
+	}
+	if e.Leave != nil {
+		t.Errorf("expected no leave curve, got %+v", e.Leave)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "missing duration",
+			content: "events: [{skaters: 1}]",
+			wantErr: "duration must be positive",
+		},
+		{
+			name:    "no events",
+			content: "duration: 1h",
+			wantErr: "at least one event",
+		},
+		{
+			name:    "unknown field",
+			content: "duration: 1h\nevents: [{skaters: 1, skatres: 2}]",
+			wantErr: "skatres",
+		},
+		{
+			name:    "invalid duration",
+			content: "duration: forever\nevents: [{skaters: 1}]",
+			wantErr: "invalid duration",
+		},
+		{
+			name:    "no skaters",
+			content: "duration: 1h\nevents: [{name: empty}]",
+			wantErr: "skaters must be positive",
+		},
+		{
+			name:    "invalid event ID",
+			content: "duration: 1h\nevents: [{id: not-a-uuid, skaters: 1}]",
+			wantErr: "invalid UUID format",
+		},
+		{
+			name:    "duplicate event ID",
+			content: "duration: 1h\nevents: [{id: 123e4567-e89b-12d3-a456-426614174000, skaters: 1}, {id: 123e4567-e89b-12d3-a456-426614174000, skaters: 1}]",
+			wantErr: "duplicate event ID",
+		},
+		{
+			name:    "jitter not less than interval",
+			content: "duration: 1h\nevents: [{skaters: 1, updateInterval: 3s, intervalJitter: 3s}]",
+			wantErr: "intervalJitter",
+		},
+		{
+			name:    "unknown shape",
+			content: "duration: 1h\nevents: [{skaters: 1, join: {shape: bell}}]",
+			wantErr: "unknown shape",
+		},
+		{
+			name:    "curve past end",
+			content: "duration: 1h\nevents: [{skaters: 1, join: {shape: linear, start: 50m, over: 20m}}]",
+			wantErr: "after the scenario duration",
+		},
+		{
+			name:    "leave before join",
+			content: "duration: 1h\nevents: [{skaters: 1, join: {start: 30m}, leave: {start: 10m}}]",
+			wantErr: "leave must not start before join",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.content))
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCurveOffsets(t *testing.T) {
+	tests := []struct {
+		name  string
+		curve Curve
+		n     int
+		want  []time.Duration
+	}{
+		{
+			name:  "immediate",
+			curve: Curve{Shape: ShapeImmediate, Start: Duration(time.Minute)},
+			n:     3,
+			want:  []time.Duration{time.Minute, time.Minute, time.Minute},
+		},
+		{
+			name:  "linear",
+			curve: Curve{Shape: ShapeLinear, Start: Duration(time.Minute), Over: Duration(2 * time.Minute)},
+			n:     3,
+			want:  []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute},
+		},
+		{
+			name:  "linear single skater",
+			curve: Curve{Shape: ShapeLinear, Start: Duration(time.Minute), Over: Duration(2 * time.Minute)},
+			n:     1,
+			want:  []time.Duration{time.Minute},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.curve.Offsets(tt.n)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d offsets, got %d", len(tt.want), len(got))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("offset %d: expected %s, got %s", i, tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCurveOffsets_Random(t *testing.T) {
+	curve := Curve{Shape: ShapeRandom, Start: Duration(time.Minute), Over: Duration(time.Minute)}
+
+	offsets := curve.Offsets(50)
+	for i, offset := range offsets {
+		if offset < time.Minute || offset > 2*time.Minute {
+			t.Errorf("offset %d (%s) outside curve range", i, offset)
+		}
+		if i > 0 && offset < offsets[i-1] {
+			t.Errorf("offsets not sorted at %d", i)
+		}
+	}
+}
+
+func TestLoad_ExampleScenarios(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "scenarios", "*.yaml"))
+	if err != nil {
+		t.Fatalf("failed to list example scenarios: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("expected example scenarios")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			if _, err := Load(file); err != nil {
+				t.Errorf("Load() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestLoad_RouteFileRelativeToScenario(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "scenarios")
+	if err := os.MkdirAll(filepath.Join(dir, "routes"), 0o755); err != nil {
+		t.Fatalf("failed to create scenario directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "routes", "ride.gpx"), []byte("<gpx/>"), 0o644); err != nil {
+		t.Fatalf("failed to write route file: %v", err)
+	}
+	absolute := filepath.Join(t.TempDir(), "absolute.gpx")
+
+	content := `
+duration: 1h
+events:
+  - skaters: 1
+    routeFile: routes/ride.gpx
+  - skaters: 1
+    routeFile: ` + absolute + `
+  - skaters: 1
+`
+	filename := filepath.Join(dir, "ride.yaml")
+	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write scenario file: %v", err)
+	}
+
+	s, err := Load(filename)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := []string{filepath.Join(dir, "routes", "ride.gpx"), absolute, ""}
+	for i, e := range s.Events {
+		if e.RouteFile != want[i] {
+			t.Errorf("event %d: expected route file %q, got %q", i, want[i], e.RouteFile)
+		}
+	}
+	if _, err := os.Stat(s.Events[0].RouteFile); err != nil {
+		t.Errorf("expected the route file next to the scenario to be found from the working directory: %v", err)
+	}
+}
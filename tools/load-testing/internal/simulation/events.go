@@ -0,0 +1,54 @@
+package simulation
+
+import (
+	"fmt"
+	"strings"
+
+	"load-testing/internal/skater"
+
+	"github.com/google/uuid"
+)
+
+// ResolveMovement returns the movement model to use: movement if given,
+// otherwise route when a route file is set, otherwise random-walk.
+func ResolveMovement(movement, routeFile string) string {
+	if movement != "" {
+		return movement
+	}
+	if routeFile != "" {
+		return skater.MovementRoute
+	}
+	return skater.MovementRandomWalk
+}
+
+// ParseEventIDs parses a comma-separated list of event UUIDs, which must number
+// numEvents. If the list is empty, numEvents random IDs are generated.
+func ParseEventIDs(eventIDsStr string, numEvents int) ([]string, error) {
+	if eventIDsStr == "" {
+		eventIDs := make([]string, numEvents)
+		for i := 0; i < numEvents; i++ {
+			eventIDs[i] = uuid.New().String()
+		}
+		return eventIDs, nil
+	}
+
+	eventIDs := strings.Split(eventIDsStr, ",")
+	for i := range eventIDs {
+		eventIDs[i] = strings.TrimSpace(eventIDs[i])
+	}
+
+	if len(eventIDs) != numEvents {
+		return nil, fmt.Errorf("number of provided event IDs (%d) does not match --events (%d)", len(eventIDs), numEvents)
+	}
+
+	for i, id := range eventIDs {
+		if id == "" {
+			return nil, fmt.Errorf("empty event ID at position %d", i+1)
+		}
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, fmt.Errorf("invalid UUID format for event ID %d (%s): %w", i+1, id, err)
+		}
+	}
+
+	return eventIDs, nil
+}
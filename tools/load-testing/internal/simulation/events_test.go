@@ -1,14 +1,16 @@
-package main
+package simulation
 
 import (
 	"strings"
 	"testing"
 
+	"load-testing/internal/skater"
+
 	"github.com/google/uuid"
 )
 
 func TestParseEventIDs_EmptyString(t *testing.T) {
-	eventIDs, err := parseEventIDs("", 3)
+	eventIDs, err := ParseEventIDs("", 3)
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
@@ -26,7 +28,7 @@ func TestParseEventIDs_EmptyString(t *testing.T) {
 
 func TestParseEventIDs_ValidSingleID(t *testing.T) {
 	testID := uuid.New().String()
-	eventIDs, err := parseEventIDs(testID, 1)
+	eventIDs, err := ParseEventIDs(testID, 1)
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
@@ -46,7 +48,7 @@ func TestParseEventIDs_ValidMultipleIDs(t *testing.T) {
 	testID3 := uuid.New().String()
 
 	input := strings.Join([]string{testID1, testID2, testID3}, ",")
-	eventIDs, err := parseEventIDs(input, 3)
+	eventIDs, err := ParseEventIDs(input, 3)
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
@@ -65,7 +67,7 @@ func TestParseEventIDs_WithWhitespace(t *testing.T) {
 	testID2 := uuid.New().String()
 
 	input := testID1 + " , " + testID2
-	eventIDs, err := parseEventIDs(input, 2)
+	eventIDs, err := ParseEventIDs(input, 2)
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
@@ -84,7 +86,7 @@ func TestParseEventIDs_CountMismatch(t *testing.T) {
 	testID2 := uuid.New().String()
 
 	input := strings.Join([]string{testID1, testID2}, ",")
-	_, err := parseEventIDs(input, 3)
+	_, err := ParseEventIDs(input, 3)
 
 	if err == nil {
 		t.Fatal("Expected error for count mismatch, got nil")
@@ -98,7 +100,7 @@ func TestParseEventIDs_CountMismatch(t *testing.T) {
 
 func TestParseEventIDs_InvalidUUID(t *testing.T) {
 	input := "not-a-uuid"
-	_, err := parseEventIDs(input, 1)
+	_, err := ParseEventIDs(input, 1)
 
 	if err == nil {
 		t.Fatal("Expected error for invalid UUID, got nil")
@@ -116,7 +118,7 @@ func TestParseEventIDs_MultipleIDsOneInvalid(t *testing.T) {
 	testID3 := uuid.New().String()
 
 	input := strings.Join([]string{testID1, invalidID, testID3}, ",")
-	_, err := parseEventIDs(input, 3)
+	_, err := ParseEventIDs(input, 3)
 
 	if err == nil {
 		t.Fatal("Expected error for invalid UUID, got nil")
@@ -132,7 +134,7 @@ func TestParseEventIDs_EmptyUUID(t *testing.T) {
 	testID1 := uuid.New().String()
 	input := testID1 + ",,"
 
-	_, err := parseEventIDs(input, 3)
+	_, err := ParseEventIDs(input, 3)
 
 	if err == nil {
 		t.Fatal("Expected error for empty UUID, got nil")
@@ -143,3 +145,26 @@ func TestParseEventIDs_EmptyUUID(t *testing.T) {
 		t.Errorf("Expected error message to contain %q, got: %s", expectedMsg, err.Error())
 	}
 }
+
+func TestResolveMovement(t *testing.T) {
+	tests := []struct {
+		name      string
+		movement  string
+		routeFile string
+		want      string
+	}{
+		{name: "default without route", want: skater.MovementRandomWalk},
+		{name: "default with route", routeFile: "route.gpx", want: skater.MovementRoute},
+		{name: "explicit overrides route", movement: skater.MovementStationary, routeFile: "route.gpx", want: skater.MovementStationary},
+		{name: "explicit without route", movement: skater.MovementTeleport, want: skater.MovementTeleport},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResolveMovement(tt.movement, tt.routeFile)
+			if got != tt.want {
+				t.Errorf("ResolveMovement(%q, %q) = %q, want %q", tt.movement, tt.routeFile, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// Package simulation plans and runs simulated skaters, shared by the commands
+// that run them on their own or alongside viewers.
+package simulation
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"load-testing/internal/scenario"
+	"load-testing/internal/skater"
+
+	"github.com/google/uuid"
+)
+
+// SkaterPlan describes when a single skater is active and how often it sends updates.
+// JoinAt and LeaveAt are offsets from the start of the run; a zero LeaveAt means
+// the skater stays until the run stops.
+type SkaterPlan struct {
+	Skater   *skater.Skater
+	Interval time.Duration
+	Jitter   time.Duration
+	JoinAt   time.Duration
+	LeaveAt  time.Duration
+}
+
+// NextDelay returns the time until the skater's next update,
+// uniformly jittered by up to ±Jitter around the interval.
+func (p SkaterPlan) NextDelay() time.Duration {
+	if p.Jitter <= 0 {
+		return p.Interval
+	}
+	return p.Interval + time.Duration(rand.Int63n(int64(2*p.Jitter)+1)) - p.Jitter
+}
+
+// minStay returns the shortest time a skater stays after joining. Its first
+// update is due up to Interval+Jitter after it joins, and it stays as long
+// again so the update is sent even when timers fire late.
+func (p SkaterPlan) minStay() time.Duration {
+	return 2 * (p.Interval + p.Jitter)
+}
+
+// Movement holds the movement settings used to create a skater's Mover,
+// along with any other options every skater is created with.
+type Movement struct {
+	Name       string
+	Config     skater.MoverConfig
+	SkaterOpts []skater.Option
+}
+
+// NewMovement creates movement settings for the named model, loading the
+// route from routeFile if one is given.
+func NewMovement(name, routeFile string, config skater.MoverConfig) (Movement, error) {
+	m := Movement{Name: name, Config: config}
+
+	if routeFile != "" {
+		route, err := skater.LoadRoute(routeFile)
+		if err != nil {
+			return Movement{}, err
+		}
+		m.Config.Route = route
+	}
+
+	return m, nil
+}
+
+func (m Movement) newSkater(eventID, baseURL string) (*skater.Skater, error) {
+	mover, err := skater.NewMover(m.Name, m.Config)
+	if err != nil {
+		return nil, err
+	}
+	opts := append([]skater.Option{skater.WithMover(mover)}, m.SkaterOpts...)
+	return skater.New(eventID, uuid.New().String(), baseURL, opts...), nil
+}
+
+// BuildPlans creates one plan per skater from a flat configuration: every
+// skater joins immediately and updates at the same interval until the run stops.
+func BuildPlans(eventIDs []string, skatersPerEvent int, interval time.Duration, baseURL string, m Movement) ([]SkaterPlan, error) {
+	plans := make([]SkaterPlan, 0, len(eventIDs)*skatersPerEvent)
+	for _, eventID := range eventIDs {
+		for j := 0; j < skatersPerEvent; j++ {
+			s, err := m.newSkater(eventID, baseURL)
+			if err != nil {
+				return nil, err
+			}
+			plans = append(plans, SkaterPlan{
+				Skater:   s,
+				Interval: interval,
+			})
+		}
+	}
+	return plans, nil
+}
+
+// BuildScenarioPlans creates one plan per skater from a scenario, spreading join
+// and leave times according to each event's curves. Every skater in the scenario
+// gets a plan, staying long enough to send at least one update. Movement settings
+// in an event override the defaults.
+func BuildScenarioPlans(sc *scenario.Scenario, baseURL string, defaults Movement) ([]SkaterPlan, error) {
+	plans := make([]SkaterPlan, 0, sc.TotalSkaters())
+	for _, event := range sc.Events {
+		m, err := eventMovement(event, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("event %q: %w", event.Name, err)
+		}
+
+		joins := event.Join.Offsets(event.Skaters)
+		var leaves []time.Duration
+		if event.Leave != nil {
+			leaves = event.Leave.Offsets(event.Skaters)
+		}
+
+		for i := 0; i < event.Skaters; i++ {
+			s, err := m.newSkater(event.ID, baseURL)
+			if err != nil {
+				return nil, fmt.Errorf("event %q: %w", event.Name, err)
+			}
+
+			plan := SkaterPlan{
+				Skater:   s,
+				Interval: time.Duration(event.UpdateInterval),
+				Jitter:   time.Duration(event.IntervalJitter),
+				JoinAt:   joins[i],
+			}
+			if leaves != nil {
+				// Join and leave offsets are spread separately, so with
+				// overlapping curves a skater can be due to leave before it
+				// joins. It stays long enough to send an update instead.
+				plan.LeaveAt = max(leaves[i], plan.JoinAt+plan.minStay())
+			}
+			plans = append(plans, plan)
+		}
+	}
+	return plans, nil
+}
+
+func eventMovement(event scenario.Event, defaults Movement) (Movement, error) {
+	m := defaults
+	if event.SpeedKmh > 0 {
+		m.Config.SpeedKmh = event.SpeedKmh
+	}
+	if event.RouteFile != "" {
+		route, err := skater.LoadRoute(event.RouteFile)
+		if err != nil {
+			return Movement{}, err
+		}
+		m.Config.Route = route
+	}
+	if event.Movement != "" {
+		m.Name = event.Movement
+	} else if event.RouteFile != "" {
+		m.Name = skater.MovementRoute
+	}
+	return m, nil
+}
+
+// PeakRequestRate returns the request rate, in requests per second, produced when
+// every planned skater is active at once.
+func PeakRequestRate(plans []SkaterPlan) float64 {
+	rate := 0.0
+	for _, p := range plans {
+		rate += 1 / p.Interval.Seconds()
+	}
+	return rate
+}
@@ -0,0 +1,151 @@
+package simulation
+
+import (
+	"testing"
+	"time"
+
+	"load-testing/internal/scenario"
+	"load-testing/internal/skater"
+)
+
+func TestSkaterPlanNextDelay(t *testing.T) {
+	plan := SkaterPlan{Interval: 3 * time.Second}
+	if got := plan.NextDelay(); got != 3*time.Second {
+		t.Errorf("expected 3s without jitter, got %s", got)
+	}
+
+	plan.Jitter = 500 * time.Millisecond
+	for i := 0; i < 100; i++ {
+		got := plan.NextDelay()
+		if got < 2500*time.Millisecond || got > 3500*time.Millisecond {
+			t.Fatalf("delay %s outside 3s ± 500ms", got)
+		}
+	}
+}
+
+func TestBuildPlans(t *testing.T) {
+	m := Movement{Name: skater.MovementRandomWalk}
+
+	plans, err := BuildPlans([]string{"event-1", "event-2"}, 3, 2*time.Second, "https://example.com", m)
+	if err != nil {
+		t.Fatalf("BuildPlans() error = %v", err)
+	}
+
+	if len(plans) != 6 {
+		t.Fatalf("expected 6 plans, got %d", len(plans))
+	}
+
+	for _, p := range plans {
+		if p.Interval != 2*time.Second || p.JoinAt != 0 || p.LeaveAt != 0 {
+			t.Errorf("unexpected plan %+v", p)
+		}
+	}
+
+	if rate := PeakRequestRate(plans); rate != 3 {
+		t.Errorf("expected peak rate of 3 requests/second, got %f", rate)
+	}
+}
+
+func TestBuildScenarioPlans(t *testing.T) {
+	sc, err := scenario.Parse([]byte(`
+duration: 1h
+events:
+  - name: ride
+    id: 123e4567-e89b-12d3-a456-426614174000
+    skaters: 3
+    updateInterval: 4s
+    intervalJitter: 1s
+    join:
+      shape: linear
+      over: 10m
+    leave:
+      shape: linear
+      start: 40m
+      over: 10m
+  - name: meet-up
+    skaters: 2
+    movement: stationary
+`))
+	if err != nil {
+		t.Fatalf("scenario.Parse() error = %v", err)
+	}
+
+	plans, err := BuildScenarioPlans(sc, "https://example.com", Movement{Name: skater.MovementRandomWalk})
+	if err != nil {
+		t.Fatalf("BuildScenarioPlans() error = %v", err)
+	}
+
+	if len(plans) != 5 {
+		t.Fatalf("expected 5 plans, got %d", len(plans))
+	}
+
+	wantJoins := []time.Duration{0, 5 * time.Minute, 10 * time.Minute}
+	wantLeaves := []time.Duration{40 * time.Minute, 45 * time.Minute, 50 * time.Minute}
+	for i := 0; i < 3; i++ {
+		p := plans[i]
+		if p.Skater.EventID != "123e4567-e89b-12d3-a456-426614174000" {
+			t.Errorf("plan %d: unexpected event ID %s", i, p.Skater.EventID)
+		}
+		if p.Interval != 4*time.Second || p.Jitter != time.Second {
+			t.Errorf("plan %d: unexpected interval %s ± %s", i, p.Interval, p.Jitter)
+		}
+		if p.JoinAt != wantJoins[i] || p.LeaveAt != wantLeaves[i] {
+			t.Errorf("plan %d: expected join %s leave %s, got join %s leave %s",
+				i, wantJoins[i], wantLeaves[i], p.JoinAt, p.LeaveAt)
+		}
+	}
+
+	for i := 3; i < 5; i++ {
+		p := plans[i]
+		if p.LeaveAt != 0 {
+			t.Errorf("plan %d: expected skater to stay until the end, leaves at %s", i, p.LeaveAt)
+		}
+		before := p.Skater.Location
+		p.Skater.Move()
+		if p.Skater.Location != before {
+			t.Errorf("plan %d: expected stationary skater from event movement override", i)
+		}
+	}
+}
+
+func TestBuildScenarioPlans_OverlappingCurves(t *testing.T) {
+	sc, err := scenario.Parse([]byte(`
+duration: 1h
+events:
+  - name: churn
+    id: 123e4567-e89b-12d3-a456-426614174000
+    skaters: 50
+    updateInterval: 4s
+    intervalJitter: 1s
+    join:
+      shape: random
+      over: 10m
+    leave:
+      shape: random
+      over: 10m
+  - name: drop-in
+    id: 123e4567-e89b-12d3-a456-426614174001
+    skaters: 5
+    join:
+      start: 5m
+    leave:
+      start: 5m
+`))
+	if err != nil {
+		t.Fatalf("scenario.Parse() error = %v", err)
+	}
+
+	plans, err := BuildScenarioPlans(sc, "https://example.com", Movement{Name: skater.MovementRandomWalk})
+	if err != nil {
+		t.Fatalf("BuildScenarioPlans() error = %v", err)
+	}
+
+	if len(plans) != sc.TotalSkaters() {
+		t.Fatalf("expected a plan for each of the %d skaters, got %d", sc.TotalSkaters(), len(plans))
+	}
+	for i, p := range plans {
+		if minLeave := p.JoinAt + p.minStay(); p.LeaveAt < minLeave {
+			t.Errorf("plan %d: joins at %s but leaves at %s, before its first update", i, p.JoinAt, p.LeaveAt)
+		}
+	}
+}
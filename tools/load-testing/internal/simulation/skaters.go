@@ -0,0 +1,242 @@
+package simulation
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"load-testing/internal/correlation"
+	"load-testing/internal/metrics"
+	"load-testing/internal/skater"
+
+	"golang.org/x/time/rate"
+)
+
+const rampUpSteps = 100
+
+// Load models, which decide when skaters send updates.
+const (
+	// LoadModelClosed sends each skater's updates one at a time, so a slow
+	// API delays the next update and lowers the load it is offered.
+	LoadModelClosed = "closed"
+	// LoadModelOpen sends updates at their scheduled times however long
+	// earlier updates take, and measures response times from those times.
+	LoadModelOpen = "open"
+)
+
+// LoadModels lists the supported load models.
+var LoadModels = []string{LoadModelClosed, LoadModelOpen}
+
+// RampUp describes a linear increase of a limiter's rate from Initial to Target
+// requests per second over Duration.
+type RampUp struct {
+	Initial  float64
+	Target   float64
+	Duration time.Duration
+}
+
+// NewLimiter creates the request rate limiter for a run, or returns nil if the
+// run is neither rate limited nor ramped up. rateLimit is the maximum requests
+// per second (0 = unlimited). With a ramp-up, the limiter starts at a tenth of
+// the lower of rateLimit and the plans' peak request rate, and the returned
+// RampUp raises it to that rate.
+func NewLimiter(rateLimit float64, rampUpDuration time.Duration, plans []SkaterPlan) (*rate.Limiter, *RampUp) {
+	if rateLimit <= 0 && rampUpDuration <= 0 {
+		return nil, nil
+	}
+
+	if rampUpDuration <= 0 {
+		return rate.NewLimiter(rate.Limit(rateLimit), burst(rateLimit)), nil
+	}
+
+	targetRate := PeakRequestRate(plans)
+	if rateLimit > 0 && rateLimit < targetRate {
+		targetRate = rateLimit
+	}
+	rampUp := &RampUp{
+		Initial:  math.Max(targetRate*0.1, 0.1),
+		Target:   targetRate,
+		Duration: rampUpDuration,
+	}
+	return rate.NewLimiter(rate.Limit(rampUp.Initial), burst(rampUp.Initial)), rampUp
+}
+
+// Run raises the limiter's rate in steps until the target rate is reached. It
+// returns ctx.Err() if ctx is cancelled first.
+func (r *RampUp) Run(ctx context.Context, limiter *rate.Limiter) error {
+	stepDuration := r.Duration / rampUpSteps
+	rateIncrement := (r.Target - r.Initial) / rampUpSteps
+
+	for i := 0; i < rampUpSteps; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(stepDuration):
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+				newRate := r.Initial + rateIncrement*float64(i+1)
+				limiter.SetLimit(rate.Limit(newRate))
+				limiter.SetBurst(burst(newRate))
+			}
+		}
+	}
+	return nil
+}
+
+func burst(r float64) int {
+	b := int(math.Ceil(r))
+	if b < 1 {
+		b = 1
+	}
+	return b
+}
+
+// PublishSends returns a skater option that passes every location the skater
+// sends to publish, for viewers to measure end-to-end latency against.
+func PublishSends(publish func(correlation.Update)) skater.Option {
+	return skater.WithSendHook(func(eventID, skaterID string, location skater.Location, sentAt time.Time) {
+		publish(correlation.Update{
+			EventID:   eventID,
+			SkaterID:  skaterID,
+			Longitude: location.Longitude,
+			Latitude:  location.Latitude,
+			SentAt:    sentAt,
+		})
+	})
+}
+
+// RunSkater sends updates for a single skater according to its plan until it
+// leaves or the run stops. Updates are scheduled from the previous scheduled
+// time rather than the previous completion, so slow responses do not drift the schedule.
+// Each result is passed to record, which is called from the skater's goroutine
+// and must not block for long. limiter and liveMetrics may be nil.
+func RunSkater(ctx context.Context, stopChan <-chan struct{}, start time.Time, plan SkaterPlan,
+	limiter *rate.Limiter, liveMetrics *metrics.SkaterMetrics, record func(skater.UpdateResult)) {
+	if !waitToJoin(ctx, stopChan, start, plan) {
+		return
+	}
+
+	liveMetrics.SkaterJoined()
+	defer liveMetrics.SkaterLeft()
+
+	var leave <-chan time.Time
+	if plan.LeaveAt > 0 {
+		leaveTimer := time.NewTimer(time.Until(start.Add(plan.LeaveAt)))
+		defer leaveTimer.Stop()
+		leave = leaveTimer.C
+	}
+
+	next := time.Now().Add(plan.NextDelay())
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if limiter != nil {
+				if err := limiter.Wait(ctx); err != nil {
+					return
+				}
+			}
+			plan.Skater.Move()
+			record(plan.Skater.UpdateLocation())
+
+			next = next.Add(plan.NextDelay())
+			if now := time.Now(); next.Before(now) {
+				next = now
+			}
+			timer.Reset(time.Until(next))
+		case <-leave:
+			return
+		case <-ctx.Done():
+			return
+		case <-stopChan:
+			return
+		}
+	}
+}
+
+// RunSkaterOpen sends updates for a single skater like RunSkater, but with the
+// open load model: each update is sent at its scheduled time in a goroutine of
+// its own, however long earlier updates take, so a slow API does not lower the
+// load it is offered. Response times are measured from the scheduled times, so
+// they include any time an update waited to be sent.
+//
+// The limiter, if any, caps the rate updates are sent at rather than holding
+// them back: an update it does not allow at its scheduled time is skipped, and
+// reported with skater.ErrSkipped so the summary can count it. record is
+// called concurrently from the goroutines of updates in flight. It returns
+// when the skater leaves or the run stops and its updates in flight have
+// finished. limiter and liveMetrics may be nil.
+func RunSkaterOpen(ctx context.Context, stopChan <-chan struct{}, start time.Time, plan SkaterPlan,
+	limiter *rate.Limiter, liveMetrics *metrics.SkaterMetrics, record func(skater.UpdateResult)) {
+	if !waitToJoin(ctx, stopChan, start, plan) {
+		return
+	}
+
+	liveMetrics.SkaterJoined()
+	defer liveMetrics.SkaterLeft()
+
+	var inFlight sync.WaitGroup
+	defer inFlight.Wait()
+
+	var leave <-chan time.Time
+	if plan.LeaveAt > 0 {
+		leaveTimer := time.NewTimer(time.Until(start.Add(plan.LeaveAt)))
+		defer leaveTimer.Stop()
+		leave = leaveTimer.C
+	}
+
+	next := time.Now().Add(plan.NextDelay())
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			intended := next
+			next = next.Add(plan.NextDelay())
+			timer.Reset(time.Until(next))
+
+			if limiter != nil && !limiter.Allow() {
+				record(plan.Skater.SkipUpdate(intended))
+				continue
+			}
+			plan.Skater.Move()
+			location := plan.Skater.Location
+			inFlight.Add(1)
+			go func() {
+				defer inFlight.Done()
+				record(plan.Skater.UpdateLocationAt(location, intended))
+			}()
+		case <-leave:
+			return
+		case <-ctx.Done():
+			return
+		case <-stopChan:
+			return
+		}
+	}
+}
+
+// waitToJoin waits until the skater is due to join, and reports whether it
+// joined before the run stopped.
+func waitToJoin(ctx context.Context, stopChan <-chan struct{}, start time.Time, plan SkaterPlan) bool {
+	if plan.JoinAt <= 0 {
+		return true
+	}
+
+	join := time.NewTimer(time.Until(start.Add(plan.JoinAt)))
+	defer join.Stop()
+	select {
+	case <-join.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-stopChan:
+		return false
+	}
+}
@@ -0,0 +1,344 @@
+package simulation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"load-testing/internal/correlation"
+	"load-testing/internal/scenario"
+	"load-testing/internal/skater"
+
+	"golang.org/x/time/rate"
+)
+
+func TestNewLimiter(t *testing.T) {
+	plans := []SkaterPlan{{Interval: time.Second}, {Interval: time.Second}, {Interval: 500 * time.Millisecond}}
+
+	tests := []struct {
+		name        string
+		rateLimit   float64
+		rampUp      time.Duration
+		wantLimiter bool
+		wantLimit   float64
+		wantRampUp  *RampUp
+	}{
+		{name: "unlimited"},
+		{name: "rate limit", rateLimit: 5, wantLimiter: true, wantLimit: 5},
+		{name: "ramp up to peak rate", rampUp: time.Minute, wantLimiter: true, wantLimit: 0.4,
+			wantRampUp: &RampUp{Initial: 0.4, Target: 4, Duration: time.Minute}},
+		{name: "ramp up to rate limit", rateLimit: 2, rampUp: time.Minute, wantLimiter: true, wantLimit: 0.2,
+			wantRampUp: &RampUp{Initial: 0.2, Target: 2, Duration: time.Minute}},
+		{name: "ramp up from minimum rate", rateLimit: 0.5, rampUp: time.Minute, wantLimiter: true, wantLimit: 0.1,
+			wantRampUp: &RampUp{Initial: 0.1, Target: 0.5, Duration: time.Minute}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, rampUp := NewLimiter(tt.rateLimit, tt.rampUp, plans)
+
+			if (limiter != nil) != tt.wantLimiter {
+				t.Fatalf("expected limiter %v, got %v", tt.wantLimiter, limiter)
+			}
+			if limiter != nil && !approxEqual(float64(limiter.Limit()), tt.wantLimit) {
+				t.Errorf("expected limit %.2f, got %.2f", tt.wantLimit, float64(limiter.Limit()))
+			}
+			if limiter != nil && limiter.Burst() < 1 {
+				t.Errorf("expected burst of at least 1, got %d", limiter.Burst())
+			}
+
+			switch {
+			case tt.wantRampUp == nil && rampUp != nil:
+				t.Errorf("expected no ramp-up, got %+v", rampUp)
+			case tt.wantRampUp != nil && rampUp == nil:
+				t.Errorf("expected ramp-up %+v, got none", tt.wantRampUp)
+			case tt.wantRampUp != nil && (!approxEqual(rampUp.Initial, tt.wantRampUp.Initial) ||
+				!approxEqual(rampUp.Target, tt.wantRampUp.Target) || rampUp.Duration != tt.wantRampUp.Duration):
+				t.Errorf("expected ramp-up %+v, got %+v", tt.wantRampUp, rampUp)
+			}
+		})
+	}
+}
+
+func TestRampUpRun(t *testing.T) {
+	limiter := rate.NewLimiter(1, 1)
+	rampUp := &RampUp{Initial: 1, Target: 10, Duration: 10 * time.Millisecond}
+
+	if err := rampUp.Run(context.Background(), limiter); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !approxEqual(float64(limiter.Limit()), 10) || limiter.Burst() != 10 {
+		t.Errorf("expected limit 10 and burst 10, got %.2f and %d", float64(limiter.Limit()), limiter.Burst())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := (&RampUp{Initial: 1, Target: 10, Duration: time.Hour}).Run(ctx, limiter); err == nil {
+		t.Error("expected an error when the context is cancelled")
+	}
+}
+
+func TestRunSkater(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	var published []correlation.Update
+	m := Movement{
+		Name: skater.MovementRandomWalk,
+		SkaterOpts: []skater.Option{PublishSends(func(u correlation.Update) {
+			published = append(published, u)
+		})},
+	}
+	plans, err := BuildPlans([]string{"event-1"}, 1, 10*time.Millisecond, server.URL, m)
+	if err != nil {
+		t.Fatalf("BuildPlans() error = %v", err)
+	}
+	plan := plans[0]
+	plan.JoinAt = 20 * time.Millisecond
+	plan.LeaveAt = 75 * time.Millisecond
+
+	results := make(chan skater.UpdateResult, 100)
+	start := time.Now()
+	RunSkater(context.Background(), make(chan struct{}), start, plan, nil, nil, sendTo(results))
+	elapsed := time.Since(start)
+	close(results)
+
+	if elapsed < plan.LeaveAt {
+		t.Errorf("expected skater to run until it leaves at %s, returned after %s", plan.LeaveAt, elapsed)
+	}
+
+	count := 0
+	for result := range results {
+		count++
+		if result.Error != nil {
+			t.Errorf("unexpected error: %v", result.Error)
+		}
+		if result.Timestamp.Before(start.Add(plan.JoinAt)) {
+			t.Errorf("update sent at %s before the skater joined", result.Timestamp.Sub(start))
+		}
+	}
+	if count == 0 || count > 6 {
+		t.Errorf("expected between 1 and 6 updates, got %d", count)
+	}
+	if int(requests.Load()) != count {
+		t.Errorf("expected %d requests, server received %d", count, requests.Load())
+	}
+	if len(published) != count {
+		t.Errorf("expected %d published sends, got %d", count, len(published))
+	}
+	for _, u := range published {
+		if u.EventID != "event-1" || u.SkaterID != plan.Skater.ID || u.SentAt.IsZero() {
+			t.Errorf("unexpected published update %+v", u)
+		}
+	}
+}
+
+func TestRunSkater_OverlappingCurves(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sc, err := scenario.Parse([]byte(`
+duration: 1m
+events:
+  - name: drop-in
+    id: 123e4567-e89b-12d3-a456-426614174000
+    skaters: 20
+    updateInterval: 20ms
+    join:
+      start: 10ms
+    leave:
+      start: 10ms
+`))
+	if err != nil {
+		t.Fatalf("scenario.Parse() error = %v", err)
+	}
+	plans, err := BuildScenarioPlans(sc, server.URL, Movement{Name: skater.MovementRandomWalk})
+	if err != nil {
+		t.Fatalf("BuildScenarioPlans() error = %v", err)
+	}
+
+	start := time.Now()
+	counts := make([]int, len(plans))
+	var wg sync.WaitGroup
+	for i, plan := range plans {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results := make(chan skater.UpdateResult, 100)
+			RunSkater(context.Background(), make(chan struct{}), start, plan, nil, nil, sendTo(results))
+			close(results)
+			for range results {
+				counts[i]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, count := range counts {
+		if count == 0 {
+			t.Errorf("skater %d left without sending an update", i)
+		}
+	}
+}
+
+func TestRunSkater_Stop(t *testing.T) {
+	plans, err := BuildPlans([]string{"event-1"}, 1, time.Hour, "http://127.0.0.1:0", Movement{Name: skater.MovementRandomWalk})
+	if err != nil {
+		t.Fatalf("BuildPlans() error = %v", err)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RunSkater(context.Background(), stop, time.Now(), plans[0], nil, nil, func(skater.UpdateResult) {})
+	}()
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("skater did not stop")
+	}
+}
+
+func TestRunSkaterOpen(t *testing.T) {
+	const serviceTime = 50 * time.Millisecond
+	var requests, inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			peak := maxInFlight.Load()
+			if n <= peak || maxInFlight.CompareAndSwap(peak, n) {
+				break
+			}
+		}
+		time.Sleep(serviceTime)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	plans, err := BuildPlans([]string{"event-1"}, 1, 10*time.Millisecond, server.URL, Movement{Name: skater.MovementRandomWalk})
+	if err != nil {
+		t.Fatalf("BuildPlans() error = %v", err)
+	}
+	plan := plans[0]
+	plan.LeaveAt = 105 * time.Millisecond
+
+	results := make(chan skater.UpdateResult, 100)
+	start := time.Now()
+	RunSkaterOpen(context.Background(), make(chan struct{}), start, plan, nil, nil, sendTo(results))
+	elapsed := time.Since(start)
+	close(results)
+
+	var timestamps []time.Time
+	var finished time.Time
+	for result := range results {
+		if end := result.Timestamp.Add(result.ResponseTime); end.After(finished) {
+			finished = end
+		}
+		if result.Error != nil {
+			t.Errorf("unexpected error: %v", result.Error)
+		}
+		if result.ScheduleLag == nil {
+			t.Errorf("expected a schedule lag")
+		}
+		if result.ResponseTime < serviceTime {
+			t.Errorf("expected response time of at least %s, got %s", serviceTime, result.ResponseTime)
+		}
+		timestamps = append(timestamps, result.Timestamp)
+	}
+	// Updates are sent every 10ms even though each takes 50ms, which a
+	// closed model would cut to 2 or 3.
+	if len(timestamps) < 8 || len(timestamps) > 10 {
+		t.Errorf("expected 8 to 10 updates, got %d", len(timestamps))
+	}
+	if int(requests.Load()) != len(timestamps) {
+		t.Errorf("expected %d requests, server received %d", len(timestamps), requests.Load())
+	}
+	if want := finished.Sub(start); elapsed < want {
+		t.Errorf("expected skater to wait for its updates in flight until %s, returned after %s", want, elapsed)
+	}
+	if maxInFlight.Load() < 2 {
+		t.Errorf("expected updates to overlap, at most %d were in flight", maxInFlight.Load())
+	}
+	slices.SortFunc(timestamps, time.Time.Compare)
+	for i := 1; i < len(timestamps); i++ {
+		if gap := timestamps[i].Sub(timestamps[i-1]); gap != 10*time.Millisecond {
+			t.Errorf("expected updates scheduled 10ms apart, got %s between updates %d and %d", gap, i-1, i)
+		}
+	}
+}
+
+func TestRunSkaterOpen_Limiter(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	plans, err := BuildPlans([]string{"event-1"}, 1, 10*time.Millisecond, server.URL, Movement{Name: skater.MovementRandomWalk})
+	if err != nil {
+		t.Fatalf("BuildPlans() error = %v", err)
+	}
+	plan := plans[0]
+	plan.LeaveAt = 105 * time.Millisecond
+
+	results := make(chan skater.UpdateResult, 100)
+	limiter := rate.NewLimiter(rate.Limit(1), 2)
+	RunSkaterOpen(context.Background(), make(chan struct{}), time.Now(), plan, limiter, nil, sendTo(results))
+	close(results)
+
+	// The limiter allows a burst of 2 and skips the rest rather than
+	// queueing them, reporting each one it skips.
+	var sent, skipped int
+	for result := range results {
+		switch {
+		case result.Error == nil:
+			sent++
+		case errors.Is(result.Error, skater.ErrSkipped):
+			skipped++
+			if result.ResponseTime != 0 || result.ScheduleLag != nil {
+				t.Errorf("expected a skipped update to be untimed, got %+v", result)
+			}
+		default:
+			t.Errorf("unexpected error: %v", result.Error)
+		}
+	}
+	if sent != 2 {
+		t.Errorf("expected 2 updates sent, got %d", sent)
+	}
+	if skipped < 7 || skipped > 8 {
+		t.Errorf("expected 7 or 8 updates skipped, got %d", skipped)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("expected 2 requests, server received %d", requests.Load())
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
+
+// sendTo records skater results on results, which must have room for them all.
+func sendTo(results chan<- skater.UpdateResult) func(skater.UpdateResult) {
+	return func(result skater.UpdateResult) {
+		results <- result
+	}
+}
@@ -0,0 +1,233 @@
+package skater
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	londonLatBase  = 51.5074
+	londonLonBase  = -0.1278
+	locationSpread = 0.1
+	movementDelta  = 0.0001
+	metresPerKm    = 1000.0
+	secondsPerHour = 3600.0
+	maxLatitude    = 90.0
+	maxLongitude   = 180.0
+)
+
+// Built-in movement model names, as accepted by NewMover.
+const (
+	MovementRandomWalk = "random-walk"
+	MovementRoute      = "route"
+	MovementStationary = "stationary"
+	MovementTeleport   = "teleport"
+)
+
+// Mover is a movement model for a simulated skater.
+// Each skater owns its own Mover, so implementations may keep per-skater state.
+type Mover interface {
+	// Start returns the skater's initial location.
+	Start() Location
+	// Move returns the skater's next location given its current location and the current time.
+	Move(current Location, now time.Time) Location
+}
+
+// MoverConfig holds the parameters available to movement models when a skater is created.
+// Models ignore the fields they do not use.
+type MoverConfig struct {
+	Route       *Route
+	SpeedKmh    float64
+	PackSpread  float64
+	InvalidRate float64
+}
+
+// MoverFactory creates a Mover for a single skater.
+type MoverFactory func(config MoverConfig) (Mover, error)
+
+var (
+	moversMu sync.RWMutex
+	movers   = map[string]MoverFactory{
+		MovementRandomWalk: func(MoverConfig) (Mover, error) {
+			return NewRandomWalk(), nil
+		},
+		MovementRoute: func(config MoverConfig) (Mover, error) {
+			if config.Route == nil {
+				return nil, errors.New("route movement requires a route")
+			}
+			offset := rand.Float64() * config.PackSpread
+			return NewRouteFollower(config.Route, offset, config.SpeedKmh), nil
+		},
+		MovementStationary: func(MoverConfig) (Mover, error) {
+			return NewStationary(randomLocationNearLondon()), nil
+		},
+		MovementTeleport: func(config MoverConfig) (Mover, error) {
+			return NewTeleporter(config.InvalidRate), nil
+		},
+	}
+)
+
+// RegisterMover makes a movement model available by name to NewMover.
+// Registering a name that already exists replaces the previous factory.
+// The returned function undoes the registration, restoring any factory it
+// replaced, e.g. for tests to pass to t.Cleanup.
+func RegisterMover(name string, factory MoverFactory) (unregister func()) {
+	moversMu.Lock()
+	defer moversMu.Unlock()
+
+	previous, replaced := movers[name]
+	movers[name] = factory
+	return func() {
+		moversMu.Lock()
+		defer moversMu.Unlock()
+
+		if replaced {
+			movers[name] = previous
+		} else {
+			delete(movers, name)
+		}
+	}
+}
+
+// NewMover creates a Mover using the factory registered under name.
+func NewMover(name string, config MoverConfig) (Mover, error) {
+	moversMu.RLock()
+	factory, ok := movers[name]
+	moversMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown movement model %q (available: %v)", name, MoverNames())
+	}
+	return factory(config)
+}
+
+// MoverNames returns the names of all registered movement models in sorted order.
+func MoverNames() []string {
+	moversMu.RLock()
+	defer moversMu.RUnlock()
+
+	names := make([]string, 0, len(movers))
+	for name := range movers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func randomLocationNearLondon() Location {
+	return Location{
+		Latitude:  londonLatBase + rand.Float64()*locationSpread,
+		Longitude: londonLonBase + rand.Float64()*locationSpread,
+	}
+}
+
+// RandomWalk starts at a random location near London and moves by a small
+// random amount each update, simulating GPS movement of approximately 10 metres.
+type RandomWalk struct{}
+
+// NewRandomWalk creates a RandomWalk mover.
+func NewRandomWalk() *RandomWalk {
+	return &RandomWalk{}
+}
+
+// Start returns a random location near London.
+func (w *RandomWalk) Start() Location {
+	return randomLocationNearLondon()
+}
+
+// Move returns the current location offset by a small random amount.
+func (w *RandomWalk) Move(current Location, _ time.Time) Location {
+	return Location{
+		Latitude:  current.Latitude + (rand.Float64()-0.5)*movementDelta,
+		Longitude: current.Longitude + (rand.Float64()-0.5)*movementDelta,
+	}
+}
+
+// RouteFollower advances along a Route at a constant speed.
+type RouteFollower struct {
+	route    *Route
+	distance float64
+	speed    float64
+	lastMove time.Time
+}
+
+// NewRouteFollower creates a RouteFollower that starts offsetMetres along the route.
+// Skaters created with different offsets are spread out along the route as a pack.
+func NewRouteFollower(route *Route, offsetMetres, speedKmh float64) *RouteFollower {
+	return &RouteFollower{
+		route:    route,
+		distance: offsetMetres,
+		speed:    speedKmh * metresPerKm / secondsPerHour,
+		lastMove: time.Now(),
+	}
+}
+
+// Start returns the position on the route at the follower's offset.
+func (f *RouteFollower) Start() Location {
+	return f.route.PositionAt(f.distance)
+}
+
+// Move advances along the route by the distance covered since the previous move.
+func (f *RouteFollower) Move(_ Location, now time.Time) Location {
+	f.distance += f.speed * now.Sub(f.lastMove).Seconds()
+	f.lastMove = now
+	return f.route.PositionAt(f.distance)
+}
+
+// Stationary never moves from its starting location.
+type Stationary struct {
+	location Location
+}
+
+// NewStationary creates a Stationary mover fixed at the given location.
+func NewStationary(location Location) *Stationary {
+	return &Stationary{location: location}
+}
+
+// Start returns the fixed location.
+func (m *Stationary) Start() Location {
+	return m.location
+}
+
+// Move returns the fixed location.
+func (m *Stationary) Move(Location, time.Time) Location {
+	return m.location
+}
+
+// Teleporter jumps to a random location anywhere in the world on every update,
+// exercising server-side validation with movement no real skater could make.
+// A fraction of jumps, given by invalidRate, land outside the valid coordinate
+// range and should be rejected by the server.
+type Teleporter struct {
+	invalidRate float64
+}
+
+// NewTeleporter creates a Teleporter. invalidRate is the probability, between
+// 0 and 1, that a jump produces out-of-range coordinates.
+func NewTeleporter(invalidRate float64) *Teleporter {
+	return &Teleporter{invalidRate: invalidRate}
+}
+
+// Start returns a random location near London.
+func (t *Teleporter) Start() Location {
+	return randomLocationNearLondon()
+}
+
+// Move returns a random location anywhere in the world, or an invalid one.
+func (t *Teleporter) Move(Location, time.Time) Location {
+	if rand.Float64() < t.invalidRate {
+		return Location{
+			Latitude:  maxLatitude + 1 + rand.Float64()*maxLatitude,
+			Longitude: maxLongitude + 1 + rand.Float64()*maxLongitude,
+		}
+	}
+
+	return Location{
+		Latitude:  (rand.Float64()*2 - 1) * maxLatitude,
+		Longitude: (rand.Float64()*2 - 1) * maxLongitude,
+	}
+}
@@ -0,0 +1,170 @@
+package skater
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func testRoute(t *testing.T) *Route {
+	t.Helper()
+
+	route, err := NewRoute([]Location{
+		{Latitude: 51.5074, Longitude: -0.1278},
+		{Latitude: 51.5174, Longitude: -0.1278},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return route
+}
+
+func TestNewMover_BuiltIns(t *testing.T) {
+	config := MoverConfig{Route: testRoute(t), SpeedKmh: 20, PackSpread: 100}
+
+	for _, name := range []string{MovementRandomWalk, MovementRoute, MovementStationary, MovementTeleport} {
+		t.Run(name, func(t *testing.T) {
+			mover, err := NewMover(name, config)
+			if err != nil {
+				t.Fatalf("NewMover(%q) error = %v", name, err)
+			}
+			if mover == nil {
+				t.Fatalf("NewMover(%q) returned nil", name)
+			}
+		})
+	}
+}
+
+func TestNewMover_Unknown(t *testing.T) {
+	_, err := NewMover("hovercraft", MoverConfig{})
+	if err == nil {
+		t.Error("expected error for unknown movement model")
+	}
+}
+
+func TestNewMover_RouteWithoutRoute(t *testing.T) {
+	_, err := NewMover(MovementRoute, MoverConfig{SpeedKmh: 20})
+	if err == nil {
+		t.Error("expected error for route movement without a route")
+	}
+}
+
+func TestRegisterMover(t *testing.T) {
+	const name = "test-fixed"
+	fixed := Location{Latitude: 1, Longitude: 2}
+
+	t.Run("registered", func(t *testing.T) {
+		t.Cleanup(RegisterMover(name, func(MoverConfig) (Mover, error) {
+			return NewStationary(fixed), nil
+		}))
+
+		if !slices.Contains(MoverNames(), name) {
+			t.Fatalf("expected %q in MoverNames()", name)
+		}
+
+		mover, err := NewMover(name, MoverConfig{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		s := New("event-1", "skater-1", "https://example.com", WithMover(mover))
+		if s.Location != fixed {
+			t.Errorf("expected location %+v, got %+v", fixed, s.Location)
+		}
+	})
+
+	if slices.Contains(MoverNames(), name) {
+		t.Errorf("expected %q to be unregistered after the test", name)
+	}
+}
+
+func TestRegisterMover_Replace(t *testing.T) {
+	fixed := Location{Latitude: 1, Longitude: 2}
+	unregister := RegisterMover(MovementStationary, func(MoverConfig) (Mover, error) {
+		return NewStationary(fixed), nil
+	})
+
+	mover, err := NewMover(MovementStationary, MoverConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := mover.Start(); got != fixed {
+		t.Errorf("expected the replacement factory, got a skater at %+v", got)
+	}
+
+	unregister()
+	mover, err = NewMover(MovementStationary, MoverConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := mover.Start(); got == fixed {
+		t.Error("expected the built-in factory to be restored")
+	}
+}
+
+func TestStationary(t *testing.T) {
+	location := Location{Latitude: 51.5, Longitude: -0.1}
+	s := New("event-1", "skater-1", "https://example.com", WithMover(NewStationary(location)))
+
+	s.Move()
+	s.Move()
+
+	if s.Location != location {
+		t.Errorf("expected stationary skater at %+v, got %+v", location, s.Location)
+	}
+}
+
+func TestTeleporter_ValidJumps(t *testing.T) {
+	teleporter := NewTeleporter(0)
+
+	for i := 0; i < 100; i++ {
+		loc := teleporter.Move(Location{}, time.Now())
+		if math.Abs(loc.Latitude) > maxLatitude || math.Abs(loc.Longitude) > maxLongitude {
+			t.Fatalf("expected valid coordinates, got %+v", loc)
+		}
+	}
+}
+
+func TestTeleporter_InvalidJumps(t *testing.T) {
+	teleporter := NewTeleporter(1)
+
+	loc := teleporter.Move(Location{}, time.Now())
+	if loc.Latitude <= maxLatitude || loc.Longitude <= maxLongitude {
+		t.Errorf("expected out-of-range coordinates, got %+v", loc)
+	}
+}
+
+func TestRouteFollower_Start(t *testing.T) {
+	route := testRoute(t)
+	follower := NewRouteFollower(route, 100, 18)
+
+	s := New("event-1", "skater-1", "https://example.com", WithMover(follower))
+
+	expected := route.PositionAt(100)
+	if s.Location != expected {
+		t.Errorf("expected starting location %+v, got %+v", expected, s.Location)
+	}
+
+	expectedSpeed := 5.0
+	if math.Abs(follower.speed-expectedSpeed) > positionTolerance {
+		t.Errorf("expected speed %.2f m/s, got %.2f m/s", expectedSpeed, follower.speed)
+	}
+}
+
+func TestRouteFollower_Move(t *testing.T) {
+	route := testRoute(t)
+	follower := NewRouteFollower(route, 0, 18)
+	start := follower.lastMove
+
+	loc := follower.Move(Location{}, start.Add(10*time.Second))
+
+	if math.Abs(follower.distance-50) > positionTolerance {
+		t.Errorf("expected to travel 50m, travelled %.2fm", follower.distance)
+	}
+
+	expected := route.PositionAt(50)
+	if loc != expected {
+		t.Errorf("expected location %+v, got %+v", expected, loc)
+	}
+}
@@ -0,0 +1,264 @@
+package skater
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+const (
+	earthRadiusMetres = 6371000.0
+	minRoutePoints    = 2
+)
+
+// Route is a polyline of waypoints that skaters follow.
+// Distances along the route are measured in metres from the first waypoint.
+// A closed route, whose last waypoint is its first, is a loop: positions past
+// the end wrap around to the start. An open route is skated there and back,
+// so positions past the end turn back along it rather than jumping to the start.
+type Route struct {
+	points     []Location
+	cumulative []float64
+	closed     bool
+}
+
+// NewRoute creates a Route from an ordered list of waypoints.
+// At least two waypoints are required and the route must have a non-zero length.
+func NewRoute(points []Location) (*Route, error) {
+	if len(points) < minRoutePoints {
+		return nil, fmt.Errorf("route must have at least %d points, got %d", minRoutePoints, len(points))
+	}
+
+	cumulative := make([]float64, len(points))
+	for i := 1; i < len(points); i++ {
+		cumulative[i] = cumulative[i-1] + haversineMetres(points[i-1], points[i])
+	}
+
+	if cumulative[len(cumulative)-1] == 0 {
+		return nil, errors.New("route has zero length")
+	}
+
+	return &Route{
+		points:     points,
+		cumulative: cumulative,
+		closed:     points[0] == points[len(points)-1],
+	}, nil
+}
+
+// LoadRoute reads a route from a GeoJSON or GPX file.
+// The format is detected from the file content rather than its extension.
+func LoadRoute(filename string) (*Route, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read route file: %w", err)
+	}
+
+	var points []Location
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '<' {
+		points, err = parseGPX(trimmed)
+	} else {
+		points, err = parseGeoJSON(trimmed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse route file %s: %w", filename, err)
+	}
+
+	return NewRoute(points)
+}
+
+// Length returns the total length of the route in metres.
+func (r *Route) Length() float64 {
+	return r.cumulative[len(r.cumulative)-1]
+}
+
+// Closed reports whether the route ends where it starts, so it is skated as a loop.
+func (r *Route) Closed() bool {
+	return r.closed
+}
+
+// PositionAt returns the location at the given distance in metres along the route.
+// Distances beyond the route length, or negative distances, wrap around a closed
+// route and turn back at the ends of an open one.
+func (r *Route) PositionAt(distance float64) Location {
+	length := r.Length()
+	if r.closed {
+		distance = math.Mod(distance, length)
+		if distance < 0 {
+			distance += length
+		}
+	} else {
+		distance = math.Mod(distance, 2*length)
+		if distance < 0 {
+			distance += 2 * length
+		}
+		if distance > length {
+			distance = 2*length - distance
+		}
+	}
+
+	for i := 1; i < len(r.cumulative); i++ {
+		if distance <= r.cumulative[i] {
+			segment := r.cumulative[i] - r.cumulative[i-1]
+			if segment == 0 {
+				return r.points[i]
+			}
+			fraction := (distance - r.cumulative[i-1]) / segment
+			from, to := r.points[i-1], r.points[i]
+			return Location{
+				Latitude:  from.Latitude + (to.Latitude-from.Latitude)*fraction,
+				Longitude: from.Longitude + (to.Longitude-from.Longitude)*fraction,
+			}
+		}
+	}
+
+	return r.points[len(r.points)-1]
+}
+
+func haversineMetres(a, b Location) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := (b.Latitude - a.Latitude) * math.Pi / 180
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMetres * math.Asin(math.Sqrt(h))
+}
+
+type geoJSONObject struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geoJSONObject  `json:"geometry"`
+	Features    []geoJSONObject `json:"features"`
+	Geometries  []geoJSONObject `json:"geometries"`
+}
+
+// parseGeoJSON extracts the first LineString or MultiLineString found in a
+// GeoJSON document. GeoJSON positions are ordered [longitude, latitude].
+func parseGeoJSON(data []byte) ([]Location, error) {
+	var obj geoJSONObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON: %w", err)
+	}
+
+	points, err := geoJSONPoints(obj)
+	if err != nil {
+		return nil, err
+	}
+	if points == nil {
+		return nil, errors.New("no LineString or MultiLineString geometry found")
+	}
+	return points, nil
+}
+
+func geoJSONPoints(obj geoJSONObject) ([]Location, error) {
+	switch obj.Type {
+	case "LineString":
+		var coords [][]float64
+		if err := json.Unmarshal(obj.Coordinates, &coords); err != nil {
+			return nil, fmt.Errorf("invalid LineString coordinates: %w", err)
+		}
+		return positionsToLocations(coords)
+	case "MultiLineString":
+		var lines [][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &lines); err != nil {
+			return nil, fmt.Errorf("invalid MultiLineString coordinates: %w", err)
+		}
+		var points []Location
+		for _, line := range lines {
+			locations, err := positionsToLocations(line)
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, locations...)
+		}
+		return points, nil
+	case "Feature":
+		if obj.Geometry == nil {
+			return nil, nil
+		}
+		return geoJSONPoints(*obj.Geometry)
+	case "FeatureCollection":
+		return firstGeoJSONPoints(obj.Features)
+	case "GeometryCollection":
+		return firstGeoJSONPoints(obj.Geometries)
+	default:
+		return nil, nil
+	}
+}
+
+func firstGeoJSONPoints(objects []geoJSONObject) ([]Location, error) {
+	for _, child := range objects {
+		points, err := geoJSONPoints(child)
+		if err != nil {
+			return nil, err
+		}
+		if points != nil {
+			return points, nil
+		}
+	}
+	return nil, nil
+}
+
+func positionsToLocations(coords [][]float64) ([]Location, error) {
+	points := make([]Location, 0, len(coords))
+	for i, c := range coords {
+		if len(c) < 2 {
+			return nil, fmt.Errorf("position %d has %d values, expected at least 2", i, len(c))
+		}
+		points = append(points, Location{Latitude: c[1], Longitude: c[0]})
+	}
+	return points, nil
+}
+
+type gpxDocument struct {
+	Tracks []struct {
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+	Routes []struct {
+		Points []gpxPoint `xml:"rtept"`
+	} `xml:"rte"`
+}
+
+type gpxPoint struct {
+	Latitude  float64 `xml:"lat,attr"`
+	Longitude float64 `xml:"lon,attr"`
+}
+
+// parseGPX extracts track points from a GPX document, falling back to
+// route points when the document contains no tracks.
+func parseGPX(data []byte) ([]Location, error) {
+	var doc gpxDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid GPX: %w", err)
+	}
+
+	var points []Location
+	for _, track := range doc.Tracks {
+		for _, segment := range track.Segments {
+			for _, p := range segment.Points {
+				points = append(points, Location{Latitude: p.Latitude, Longitude: p.Longitude})
+			}
+		}
+	}
+
+	if len(points) == 0 {
+		for _, route := range doc.Routes {
+			for _, p := range route.Points {
+				points = append(points, Location{Latitude: p.Latitude, Longitude: p.Longitude})
+			}
+		}
+	}
+
+	if len(points) == 0 {
+		return nil, errors.New("no track or route points found")
+	}
+	return points, nil
+}
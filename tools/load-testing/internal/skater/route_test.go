@@ -0,0 +1,234 @@
+package skater
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const positionTolerance = 1e-9
+
+func writeRouteFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write route file: %v", err)
+	}
+	return filename
+}
+
+func TestNewRoute_TooFewPoints(t *testing.T) {
+	_, err := NewRoute([]Location{{Latitude: 51.5, Longitude: -0.1}})
+	if err == nil {
+		t.Error("expected error for single-point route")
+	}
+}
+
+func TestNewRoute_ZeroLength(t *testing.T) {
+	p := Location{Latitude: 51.5, Longitude: -0.1}
+	_, err := NewRoute([]Location{p, p})
+	if err == nil {
+		t.Error("expected error for zero-length route")
+	}
+}
+
+func TestRouteLength(t *testing.T) {
+	route, err := NewRoute([]Location{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 1, Longitude: 0},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := earthRadiusMetres * math.Pi / 180
+	if math.Abs(route.Length()-expected) > 1 {
+		t.Errorf("expected length %.1f, got %.1f", expected, route.Length())
+	}
+}
+
+func TestRoutePositionAt(t *testing.T) {
+	route, err := NewRoute([]Location{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 1, Longitude: 0},
+		{Latitude: 1, Longitude: 1},
+		{Latitude: 0, Longitude: 0},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !route.Closed() {
+		t.Fatal("expected a route that ends at its start to be closed")
+	}
+
+	firstLeg := route.cumulative[1]
+
+	tests := []struct {
+		name     string
+		distance float64
+		expected Location
+	}{
+		{name: "start", distance: 0, expected: Location{Latitude: 0, Longitude: 0}},
+		{name: "halfway along first leg", distance: firstLeg / 2, expected: Location{Latitude: 0.5, Longitude: 0}},
+		{name: "first waypoint", distance: firstLeg, expected: Location{Latitude: 1, Longitude: 0}},
+		{name: "wraps past end", distance: route.Length() + firstLeg/2, expected: Location{Latitude: 0.5, Longitude: 0}},
+		{name: "negative wraps from end", distance: -route.Length() + firstLeg/2, expected: Location{Latitude: 0.5, Longitude: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := route.PositionAt(tt.distance)
+			if math.Abs(got.Latitude-tt.expected.Latitude) > positionTolerance ||
+				math.Abs(got.Longitude-tt.expected.Longitude) > positionTolerance {
+				t.Errorf("PositionAt(%f) = %+v, want %+v", tt.distance, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRoutePositionAt_OpenRoute(t *testing.T) {
+	route, err := NewRoute([]Location{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 1, Longitude: 0},
+		{Latitude: 1, Longitude: 1},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if route.Closed() {
+		t.Fatal("expected a route that ends away from its start to be open")
+	}
+
+	firstLeg := route.cumulative[1]
+	secondLeg := route.Length() - firstLeg
+
+	tests := []struct {
+		name     string
+		distance float64
+		expected Location
+	}{
+		{name: "end", distance: route.Length(), expected: Location{Latitude: 1, Longitude: 1}},
+		{name: "turns back past end", distance: route.Length() + secondLeg/2, expected: Location{Latitude: 1, Longitude: 0.5}},
+		{name: "back along first leg", distance: route.Length() + secondLeg + firstLeg/2, expected: Location{Latitude: 0.5, Longitude: 0}},
+		{name: "back at start", distance: 2 * route.Length(), expected: Location{Latitude: 0, Longitude: 0}},
+		{name: "out again", distance: 2*route.Length() + firstLeg/2, expected: Location{Latitude: 0.5, Longitude: 0}},
+		{name: "negative turns back at start", distance: -firstLeg / 2, expected: Location{Latitude: 0.5, Longitude: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := route.PositionAt(tt.distance)
+			if math.Abs(got.Latitude-tt.expected.Latitude) > positionTolerance ||
+				math.Abs(got.Longitude-tt.expected.Longitude) > positionTolerance {
+				t.Errorf("PositionAt(%f) = %+v, want %+v", tt.distance, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadRoute_GeoJSONLineString(t *testing.T) {
+	filename := writeRouteFile(t, "route.geojson", `{
+		"type": "FeatureCollection",
+		"features": [
+			{"type": "Feature", "properties": {}, "geometry": {"type": "Point", "coordinates": [0, 0]}},
+			{"type": "Feature", "properties": {}, "geometry": {
+				"type": "LineString",
+				"coordinates": [[-0.1278, 51.5074], [-0.1200, 51.5100], [-0.1100, 51.5150]]
+			}}
+		]
+	}`)
+
+	route, err := LoadRoute(filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(route.points) != 3 {
+		t.Fatalf("expected 3 points, got %d", len(route.points))
+	}
+
+	if route.points[0].Latitude != 51.5074 || route.points[0].Longitude != -0.1278 {
+		t.Errorf("expected first point to be (51.5074, -0.1278), got %+v", route.points[0])
+	}
+}
+
+func TestLoadRoute_GeoJSONMultiLineString(t *testing.T) {
+	filename := writeRouteFile(t, "route.json", `{
+		"type": "MultiLineString",
+		"coordinates": [
+			[[-0.1278, 51.5074], [-0.1200, 51.5100]],
+			[[-0.1100, 51.5150], [-0.1000, 51.5200]]
+		]
+	}`)
+
+	route, err := LoadRoute(filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(route.points) != 4 {
+		t.Errorf("expected 4 points, got %d", len(route.points))
+	}
+}
+
+func TestLoadRoute_GeoJSONWithoutLine(t *testing.T) {
+	filename := writeRouteFile(t, "route.geojson", `{"type": "Point", "coordinates": [0, 0]}`)
+
+	_, err := LoadRoute(filename)
+	if err == nil {
+		t.Error("expected error for GeoJSON without a line geometry")
+	}
+}
+
+func TestLoadRoute_GPXTrack(t *testing.T) {
+	filename := writeRouteFile(t, "route.gpx", `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk>
+    <trkseg>
+      <trkpt lat="51.5074" lon="-0.1278"></trkpt>
+      <trkpt lat="51.5100" lon="-0.1200"></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`)
+
+	route, err := LoadRoute(filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(route.points) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(route.points))
+	}
+
+	if route.points[1].Latitude != 51.5100 || route.points[1].Longitude != -0.1200 {
+		t.Errorf("expected second point to be (51.5100, -0.1200), got %+v", route.points[1])
+	}
+}
+
+func TestLoadRoute_GPXRoute(t *testing.T) {
+	filename := writeRouteFile(t, "route.gpx", `<gpx version="1.1">
+  <rte>
+    <rtept lat="51.5074" lon="-0.1278"/>
+    <rtept lat="51.5100" lon="-0.1200"/>
+    <rtept lat="51.5150" lon="-0.1100"/>
+  </rte>
+</gpx>`)
+
+	route, err := LoadRoute(filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(route.points) != 3 {
+		t.Errorf("expected 3 points, got %d", len(route.points))
+	}
+}
+
+func TestLoadRoute_MissingFile(t *testing.T) {
+	_, err := LoadRoute(filepath.Join(t.TempDir(), "missing.geojson"))
+	if err == nil {
+		t.Error("expected error for missing route file")
+	}
+}
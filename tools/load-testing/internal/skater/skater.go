@@ -2,21 +2,37 @@ package skater
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math/rand"
 	"net/http"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 const (
-	londonLatBase     = 51.5074
-	londonLonBase     = -0.1278
-	locationSpread    = 0.1
-	movementDelta     = 0.0001
 	httpClientTimeout = 10 * time.Second
+	tracerName        = "load-testing/internal/skater"
+	updateSpanName    = "PUT /skatingEvents/{eventId}/skaters/{skaterId}"
+)
+
+// Span attributes identifying the event and skater of an update.
+const (
+	AttributeEventID  = attribute.Key("skatemap.event_id")
+	AttributeSkaterID = attribute.Key("skatemap.skater_id")
 )
 
+// ErrSkipped is the error of an update that was due but not sent, because
+// sending it would have exceeded the run's rate limit.
+var ErrSkipped = errors.New("update skipped by the rate limit")
+
 // Location represents a geographic coordinate with latitude and longitude.
 type Location struct {
 	Latitude  float64 `json:"latitude"`
@@ -31,106 +47,211 @@ type Skater struct {
 	Location Location
 	client   *http.Client
 	baseURL  string
+	mover    Mover
+	tracer   trace.Tracer
+	onSend   func(eventID, skaterID string, location Location, sentAt time.Time)
+}
+
+// StatusError reports that the API answered a location update with a status
+// code other than 202 Accepted.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// Option configures optional behaviour of a Skater created with New.
+type Option func(*Skater)
+
+// WithMover sets the movement model used to choose the skater's starting
+// location and to move it between updates. The default is a random walk near London.
+func WithMover(mover Mover) Option {
+	return func(s *Skater) {
+		s.mover = mover
+	}
+}
+
+// WithTracerProvider sets the provider of the tracer used to start a client
+// span for each update. The span's context is sent in a W3C traceparent header.
+// By default no spans are recorded and no trace context is sent.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(s *Skater) {
+		s.tracer = provider.Tracer(tracerName)
+	}
+}
+
+// WithSendHook sets a function called with the location being sent just before
+// each update request, so received locations can be matched with their updates.
+func WithSendHook(hook func(eventID, skaterID string, location Location, sentAt time.Time)) Option {
+	return func(s *Skater) {
+		s.onSend = hook
+	}
 }
 
 // UpdateResult contains the result of a location update request,
 // including timing information and any errors encountered.
 type UpdateResult struct {
-	EventID      string
-	SkaterID     string
-	Timestamp    time.Time
+	EventID  string
+	SkaterID string
+	// Timestamp is when the update started: when it was sent, or for an
+	// update sent with UpdateLocationAt, when it was scheduled to be sent.
+	Timestamp time.Time
+	// ResponseTime is measured from Timestamp.
 	ResponseTime time.Duration
-	Error        error
+	// ScheduleLag is how long after Timestamp an update sent with
+	// UpdateLocationAt was sent, nil for other updates.
+	ScheduleLag *time.Duration
+	TraceID     string
+	Error       error
 }
 
-// New creates a new Skater with a random starting location near London.
+// New creates a new Skater whose starting location is chosen by its Mover.
 // The skater is initialised with its own HTTP client configured with a timeout.
-func New(eventID, skaterID, baseURL string) *Skater {
-	return &Skater{
+func New(eventID, skaterID, baseURL string, opts ...Option) *Skater {
+	s := &Skater{
 		ID:      skaterID,
 		EventID: eventID,
-		Location: Location{
-			Latitude:  londonLatBase + rand.Float64()*locationSpread,
-			Longitude: londonLonBase + rand.Float64()*locationSpread,
-		},
 		client: &http.Client{
 			Timeout: httpClientTimeout,
 		},
 		baseURL: baseURL,
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.tracer == nil {
+		s.tracer = noop.NewTracerProvider().Tracer(tracerName)
+	}
+
+	if s.mover == nil {
+		s.mover = NewRandomWalk()
+	}
+	s.Location = s.mover.Start()
+
+	return s
 }
 
-// Move updates the skater's location by a small random amount,
-// simulating realistic GPS movement of approximately 10 metres.
+// Move updates the skater's location using its Mover.
 func (s *Skater) Move() {
-	s.Location.Latitude += (rand.Float64() - 0.5) * movementDelta
-	s.Location.Longitude += (rand.Float64() - 0.5) * movementDelta
+	s.Location = s.mover.Move(s.Location, time.Now())
 }
 
 // UpdateLocation sends the current location to the API via HTTP PUT.
 // Returns an UpdateResult containing response time and any errors.
 // The API expects a 202 Accepted response for successful updates.
+// Each update is traced as a client span whose context is propagated to the API.
 func (s *Skater) UpdateLocation() UpdateResult {
+	return s.send(s.Location, time.Time{})
+}
+
+// UpdateLocationAt sends location like UpdateLocation, for an update that was
+// scheduled to be sent at intended. The response time is measured from
+// intended rather than from when the request is sent, so time the update spent
+// waiting to be sent counts towards it. It neither reads nor changes the
+// skater's location, so updates can be sent concurrently while the skater moves.
+func (s *Skater) UpdateLocationAt(location Location, intended time.Time) UpdateResult {
+	return s.send(location, intended)
+}
+
+// SkipUpdate returns the result of an update that was scheduled for intended
+// but not sent, so it is counted with the updates that were. Its error is
+// ErrSkipped and it has no response time.
+func (s *Skater) SkipUpdate(intended time.Time) UpdateResult {
+	return UpdateResult{
+		EventID:   s.EventID,
+		SkaterID:  s.ID,
+		Timestamp: intended,
+		Error:     ErrSkipped,
+	}
+}
+
+// send sends location, measuring the response time from intended, or from
+// when the request is sent if intended is zero.
+func (s *Skater) send(location Location, intended time.Time) UpdateResult {
 	start := time.Now()
+	result := UpdateResult{
+		EventID:   s.EventID,
+		SkaterID:  s.ID,
+		Timestamp: start,
+	}
+	if !intended.IsZero() {
+		lag := start.Sub(intended)
+		result.Timestamp = intended
+		result.ScheduleLag = &lag
+	}
+
+	ctx, span := s.tracer.Start(context.Background(), updateSpanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodPut,
+			AttributeEventID.String(s.EventID),
+			AttributeSkaterID.String(s.ID),
+		))
+	defer span.End()
+	result.TraceID = traceID(span)
 
 	payload := map[string]interface{}{
-		"coordinates": []float64{s.Location.Longitude, s.Location.Latitude},
+		"coordinates": []float64{location.Longitude, location.Latitude},
 	}
 
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return UpdateResult{
-			EventID:      s.EventID,
-			SkaterID:     s.ID,
-			Timestamp:    start,
-			ResponseTime: time.Since(start),
-			Error:        err,
-		}
+		return failed(span, result, err)
 	}
 
-	url := fmt.Sprintf("%s/skatingEvents/%s/skaters/%s", s.baseURL, s.EventID, s.ID)
-	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+	updateURL := fmt.Sprintf("%s/skatingEvents/%s/skaters/%s", s.baseURL, s.EventID, s.ID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, updateURL, bytes.NewReader(body))
 	if err != nil {
-		return UpdateResult{
-			EventID:      s.EventID,
-			SkaterID:     s.ID,
-			Timestamp:    start,
-			ResponseTime: time.Since(start),
-			Error:        err,
-		}
+		return failed(span, result, err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	span.SetAttributes(semconv.URLFull(req.URL.Redacted()), semconv.ServerAddress(req.URL.Hostname()))
+
+	if s.onSend != nil {
+		s.onSend(s.EventID, s.ID, location, time.Now())
+	}
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return UpdateResult{
-			EventID:      s.EventID,
-			SkaterID:     s.ID,
-			Timestamp:    start,
-			ResponseTime: time.Since(start),
-			Error:        err,
-		}
+		return failed(span, result, err)
 	}
 	defer resp.Body.Close()
 
-	responseTime := time.Since(start)
+	result.ResponseTime = time.Since(result.Timestamp)
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
 
 	if resp.StatusCode != http.StatusAccepted {
-		return UpdateResult{
-			EventID:      s.EventID,
-			SkaterID:     s.ID,
-			Timestamp:    start,
-			ResponseTime: responseTime,
-			Error:        fmt.Errorf("unexpected status code: %d", resp.StatusCode),
-		}
+		return failed(span, result, &StatusError{StatusCode: resp.StatusCode})
 	}
 
-	return UpdateResult{
-		EventID:      s.EventID,
-		SkaterID:     s.ID,
-		Timestamp:    start,
-		ResponseTime: responseTime,
-		Error:        nil,
+	return result
+}
+
+// failed records err on the update's span and returns it in result, timed
+// up to now unless a response time has been measured already.
+func failed(span trace.Span, result UpdateResult, err error) UpdateResult {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	if result.ResponseTime == 0 {
+		result.ResponseTime = time.Since(result.Timestamp)
+	}
+	result.Error = err
+	return result
+}
+
+// traceID returns the span's trace ID, or an empty string if it is not being traced.
+func traceID(span trace.Span) string {
+	sc := span.SpanContext()
+	if !sc.HasTraceID() {
+		return ""
 	}
+	return sc.TraceID().String()
 }
@@ -1,10 +1,25 @@
 package skater
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"load-testing/internal/delay"
+	"load-testing/internal/fakeserver"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestNew(t *testing.T) {
@@ -99,6 +114,58 @@ func TestUpdateLocation_Success(t *testing.T) {
 	}
 }
 
+func TestUpdateLocationAt(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	s := New("event-1", "skater-1", server.URL)
+	current := s.Location
+	location := Location{Latitude: 51.5, Longitude: -0.1}
+	intended := time.Now().Add(-100 * time.Millisecond)
+
+	result := s.UpdateLocationAt(location, intended)
+
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if want := `{"coordinates":[-0.1,51.5]}`; body != want {
+		t.Errorf("expected body %s, got %s", want, body)
+	}
+	if s.Location != current {
+		t.Errorf("expected the skater's location to be left at %+v, got %+v", current, s.Location)
+	}
+	if !result.Timestamp.Equal(intended) {
+		t.Errorf("expected timestamp %v, got %v", intended, result.Timestamp)
+	}
+	if result.ScheduleLag == nil || *result.ScheduleLag < 100*time.Millisecond {
+		t.Errorf("expected a schedule lag of at least 100ms, got %v", result.ScheduleLag)
+	}
+	if result.ResponseTime < 100*time.Millisecond {
+		t.Errorf("expected the response time to be measured from the intended time, got %v", result.ResponseTime)
+	}
+}
+
+func TestUpdateLocation_NoScheduleLag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	result := New("event-1", "skater-1", server.URL).UpdateLocation()
+
+	if result.ScheduleLag != nil {
+		t.Errorf("expected no schedule lag, got %v", *result.ScheduleLag)
+	}
+	if result.Timestamp.IsZero() || result.ResponseTime <= 0 {
+		t.Errorf("expected the failed update to be timed, got %v at %v", result.ResponseTime, result.Timestamp)
+	}
+}
+
 func TestUpdateLocation_ErrorStatusCode(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -123,3 +190,151 @@ func TestUpdateLocation_NetworkError(t *testing.T) {
 		t.Error("expected error for network failure")
 	}
 }
+
+func TestUpdateLocation_InjectedFaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		faults fakeserver.Faults
+		status int
+	}{
+		{"server error", fakeserver.Faults{ErrorRate: 1}, http.StatusServiceUnavailable},
+		{"throttled", fakeserver.Faults{ThrottleRate: 1}, http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := fakeserver.DefaultConfig()
+			config.Faults = tt.faults
+			fake := fakeserver.New(config)
+			defer fake.Close()
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			s := New(uuid.New().String(), uuid.New().String(), server.URL)
+			result := s.UpdateLocation()
+
+			var statusErr *StatusError
+			if !errors.As(result.Error, &statusErr) {
+				t.Fatalf("expected a StatusError, got %v", result.Error)
+			}
+			if statusErr.StatusCode != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, statusErr.StatusCode)
+			}
+		})
+	}
+}
+
+func TestUpdateLocation_InjectedLatency(t *testing.T) {
+	config := fakeserver.DefaultConfig()
+	config.Faults.UpdateLatency = delay.Delay{Distribution: delay.Fixed, A: 50 * time.Millisecond}
+	fake := fakeserver.New(config)
+	defer fake.Close()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	s := New(uuid.New().String(), uuid.New().String(), server.URL)
+	result := s.UpdateLocation()
+
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if result.ResponseTime < 50*time.Millisecond {
+		t.Errorf("expected response time of at least 50ms, got %s", result.ResponseTime)
+	}
+}
+
+func TestUpdateLocation_TraceContext(t *testing.T) {
+	traceparents := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+
+	s := New("event-1", "skater-1", server.URL, WithTracerProvider(provider))
+	result := s.UpdateLocation()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+
+	if span.SpanKind != trace.SpanKindClient {
+		t.Errorf("expected client span, got %v", span.SpanKind)
+	}
+	if span.Status.Code != codes.Error {
+		t.Errorf("expected error status for a 503, got %v", span.Status.Code)
+	}
+
+	expectedTraceparent := fmt.Sprintf("00-%s-%s-01", span.SpanContext.TraceID(), span.SpanContext.SpanID())
+	if got := <-traceparents; got != expectedTraceparent {
+		t.Errorf("expected traceparent %s, got %q", expectedTraceparent, got)
+	}
+
+	if result.TraceID != span.SpanContext.TraceID().String() {
+		t.Errorf("expected result trace ID %s, got %q", span.SpanContext.TraceID(), result.TraceID)
+	}
+
+	attributes := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		attributes[kv.Key] = kv.Value
+	}
+	if v := attributes[AttributeSkaterID]; v.AsString() != "skater-1" {
+		t.Errorf("expected skater ID attribute skater-1, got %q", v.AsString())
+	}
+	if v := attributes[semconv.HTTPResponseStatusCodeKey]; v.AsInt64() != http.StatusServiceUnavailable {
+		t.Errorf("expected status code attribute 503, got %d", v.AsInt64())
+	}
+}
+
+func TestUpdateLocation_NoTraceContextByDefault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tp := r.Header.Get("traceparent"); tp != "" {
+			t.Errorf("expected no traceparent header, got %q", tp)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	result := New("event-1", "skater-1", server.URL).UpdateLocation()
+
+	if result.TraceID != "" {
+		t.Errorf("expected no trace ID, got %q", result.TraceID)
+	}
+}
+
+func TestUpdateLocation_SendHook(t *testing.T) {
+	received := make(chan time.Time, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- time.Now()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	var sent Location
+	var sentAt time.Time
+	s := New("event-1", "skater-1", server.URL, WithSendHook(func(eventID, skaterID string, location Location, at time.Time) {
+		if eventID != "event-1" || skaterID != "skater-1" {
+			t.Errorf("expected event-1/skater-1, got %s/%s", eventID, skaterID)
+		}
+		sent = location
+		sentAt = at
+	}))
+
+	result := s.UpdateLocation()
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+
+	if sent != s.Location {
+		t.Errorf("expected hook to get location %+v, got %+v", s.Location, sent)
+	}
+	if receivedAt := <-received; sentAt.IsZero() || sentAt.After(receivedAt) {
+		t.Errorf("expected hook to run before the request reached the server, got %v (received %v)", sentAt, receivedAt)
+	}
+}
@@ -1,53 +1,59 @@
 package testutil
 
 import (
-	"encoding/csv"
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"load-testing/internal/metrics"
 )
 
 func AssertNoErrors(t *testing.T, csvPath string) {
 	t.Helper()
 
-	file, err := os.Open(csvPath)
-	require.NoError(t, err, "Failed to open metrics file")
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	require.NoError(t, err, "Failed to read CSV")
+	header, records := readCSV(t, csvPath)
 
-	require.Greater(t, len(records), 0, "CSV should have at least a header row")
-
-	if len(records) == 1 {
+	if len(records) == 0 {
 		return
 	}
 
-	errorColumnIndex := len(records[0]) - 1
+	errorColumnIndex := len(header) - 1
 
 	errorCount := 0
 	for i, record := range records {
-		if i == 0 {
-			continue
-		}
-		if len(record) != len(records[0]) {
-			t.Fatalf("Invalid CSV row %d: expected %d columns, got %d", i, len(records[0]), len(record))
+		if len(record) != len(header) {
+			t.Fatalf("Invalid CSV row %d: expected %d columns, got %d", i+1, len(header), len(record))
 		}
 		if record[errorColumnIndex] != "" {
 			errorCount++
-			t.Logf("Error in row %d: %s", i, record[errorColumnIndex])
+			t.Logf("Error in row %d: %s", i+1, record[errorColumnIndex])
 		}
 	}
 
 	assert.Equal(t, 0, errorCount, "Expected zero errors in metrics file")
 }
 
+// readCSV reads a CSV metrics file, or every segment of a rotated one, and
+// returns its header and records.
+func readCSV(t *testing.T, csvPath string) ([]string, [][]string) {
+	t.Helper()
+
+	reader, err := metrics.OpenCSV(csvPath)
+	require.NoError(t, err, "Failed to open metrics file")
+	defer reader.Close()
+
+	records, err := reader.ReadAll()
+	require.NoError(t, err, "Failed to read CSV")
+
+	return reader.Header(), records
+}
+
 func snapshotCSV(t *testing.T, csvPath string) string {
 	t.Helper()
 
@@ -69,24 +75,20 @@ func snapshotCSV(t *testing.T, csvPath string) string {
 func CountRecords(t *testing.T, csvPath string) int {
 	t.Helper()
 
-	file, err := os.Open(csvPath)
-	require.NoError(t, err, "Failed to open metrics file")
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	require.NoError(t, err, "Failed to read CSV")
-
-	if len(records) <= 1 {
-		return 0
-	}
-
-	return len(records) - 1
+	_, records := readCSV(t, csvPath)
+	return len(records)
 }
 
+// CountRecordsLive counts the records of a metrics file that is still being
+// written. A single file is copied first; the finished segments of a rotated
+// file do not change, and the last is read up to its last flush.
 func CountRecordsLive(t *testing.T, csvPath string) int {
 	t.Helper()
 
+	if _, err := os.Stat(csvPath); err != nil {
+		return CountRecords(t, csvPath)
+	}
+
 	snapshotPath := snapshotCSV(t, csvPath)
 	defer os.Remove(snapshotPath)
 
@@ -96,36 +98,15 @@ func CountRecordsLive(t *testing.T, csvPath string) int {
 func ExtractSkaterIDs(t *testing.T, viewerCSVPath string) map[string]bool {
 	t.Helper()
 
-	file, err := os.Open(viewerCSVPath)
-	require.NoError(t, err, "Failed to open viewer CSV")
-	defer file.Close()
+	header, records := readCSV(t, viewerCSVPath)
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	require.NoError(t, err, "Failed to read CSV")
-
-	require.Greater(t, len(records), 0, "CSV should have at least a header row")
-
-	if len(records) == 1 {
-		return make(map[string]bool)
-	}
-
-	skaterIDColumnIndex := -1
-	for i, header := range records[0] {
-		if header == "skater_ids" {
-			skaterIDColumnIndex = i
-			break
-		}
-	}
+	skaterIDColumnIndex := slices.Index(header, "skater_ids")
 	require.NotEqual(t, -1, skaterIDColumnIndex, "skater_ids column not found in viewer CSV")
 
 	skaterIDs := make(map[string]bool)
 	for i, record := range records {
-		if i == 0 {
-			continue
-		}
-		if len(record) != len(records[0]) {
-			t.Fatalf("Invalid CSV row %d: expected %d columns, got %d", i, len(records[0]), len(record))
+		if len(record) != len(header) {
+			t.Fatalf("Invalid CSV row %d: expected %d columns, got %d", i+1, len(header), len(record))
 		}
 
 		skaterIDsStr := record[skaterIDColumnIndex]
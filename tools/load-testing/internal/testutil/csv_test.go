@@ -0,0 +1,47 @@
+package testutil
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"load-testing/internal/metrics"
+	"load-testing/internal/viewer"
+)
+
+func TestCSVHelpersReadRotatedFiles(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "viewers.csv")
+
+	sink, err := metrics.NewCSVSink(filename, metrics.ViewerSchema,
+		metrics.WithRotation(1, 0), metrics.WithCompression(metrics.CompressionGzip))
+	if err != nil {
+		t.Fatalf("NewCSVSink() error = %v", err)
+	}
+	for i := 0; i < 25; i++ {
+		result := viewer.ViewerResult{
+			EventID:   "event-1",
+			Timestamp: time.Now(),
+			SkaterIDs: []string{fmt.Sprintf("skater-%d", i%5)},
+		}
+		if err := sink.Write(metrics.ViewerEvent(result)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	if got := CountRecordsLive(t, filename); got != 20 {
+		t.Errorf("CountRecordsLive() = %d, want the 20 records written out so far", got)
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := CountRecords(t, filename); got != 25 {
+		t.Errorf("CountRecords() = %d, want 25", got)
+	}
+	if got := len(ExtractSkaterIDs(t, filename)); got != 5 {
+		t.Errorf("ExtractSkaterIDs() found %d skaters, want 5", got)
+	}
+	AssertNoErrors(t, filename)
+}
@@ -14,9 +14,15 @@ import (
 )
 
 type Process struct {
-	cmd         *exec.Cmd
+	cmd *exec.Cmd
+	// stderrRead is closed once everything the process wrote to a stderr pipe
+	// has been read, or nil if its stderr is not piped.
+	stderrRead  <-chan struct{}
 	EventIDs    []string
 	MetricsFile string
+	// ViewerMetricsFile is the viewer metrics file of a simulate-event process;
+	// MetricsFile then holds its skater metrics.
+	ViewerMetricsFile string
 }
 
 var eventIDPattern = regexp.MustCompile(`Generated event IDs: \[(.*?)\]`)
@@ -38,20 +44,25 @@ func validateURL(t *testing.T, targetURL string) {
 	}
 }
 
-func StartSkaters(t *testing.T, targetURL string, events, skatersPerEvent int, interval string) *Process {
+// StartSkaters runs simulate-skaters with generated event IDs.
+// extraArgs are appended to the command line, e.g. "--duration", "30s".
+func StartSkaters(t *testing.T, targetURL string, events, skatersPerEvent int, interval string, extraArgs ...string) *Process {
 	t.Helper()
 	validateURL(t, targetURL)
 
 	tempDir := t.TempDir()
 	metricsFile := filepath.Join(tempDir, "skaters.csv")
 
-	cmd := exec.Command("../bin/simulate-skaters",
+	args := []string{
 		"--target-url", targetURL,
 		"--events", fmt.Sprintf("%d", events),
 		"--skaters-per-event", fmt.Sprintf("%d", skatersPerEvent),
 		"--update-interval", interval,
 		"--metrics-file", metricsFile,
-	)
+	}
+	args = append(args, extraArgs...)
+
+	cmd := exec.Command("../bin/simulate-skaters", args...)
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
@@ -62,57 +73,54 @@ func StartSkaters(t *testing.T, targetURL string, events, skatersPerEvent int, i
 		t.Fatalf("Failed to start simulate-skaters: %v", err)
 	}
 
-	t.Cleanup(func() {
-		if cmd.Process != nil {
-			cmd.Process.Signal(syscall.SIGTERM)
-			cmd.Wait()
-		}
-	})
-
-	scanner := bufio.NewScanner(stderr)
-	eventIDs := parseEventIDs(t, scanner)
+	p := &Process{cmd: cmd, MetricsFile: metricsFile}
+	eventIDs, stderrRead := parseEventIDs(t, bufio.NewScanner(stderr))
+	p.stderrRead = stderrRead
+	t.Cleanup(p.terminate)
 
-	return &Process{
-		cmd:         cmd,
-		EventIDs:    eventIDs,
-		MetricsFile: metricsFile,
+	if eventIDs == nil {
+		t.Fatal("Failed to parse Event IDs from simulate-skaters output")
 	}
+	p.EventIDs = eventIDs
+	return p
 }
 
-func StartSkatersWithEventID(t *testing.T, targetURL, eventID string, skatersPerEvent int, interval string) *Process {
+// StartSkatersWithEventID runs simulate-skaters for an existing event ID.
+// extraArgs are appended to the command line, e.g. "--duration", "30s".
+func StartSkatersWithEventID(t *testing.T, targetURL, eventID string, skatersPerEvent int, interval string, extraArgs ...string) *Process {
 	t.Helper()
 	validateURL(t, targetURL)
 
 	tempDir := t.TempDir()
 	metricsFile := filepath.Join(tempDir, "skaters.csv")
 
-	cmd := exec.Command("../bin/simulate-skaters",
+	args := []string{
 		"--target-url", targetURL,
 		"--event-id", eventID,
 		"--skaters-per-event", fmt.Sprintf("%d", skatersPerEvent),
 		"--update-interval", interval,
 		"--metrics-file", metricsFile,
-	)
+	}
+	args = append(args, extraArgs...)
+
+	cmd := exec.Command("../bin/simulate-skaters", args...)
 
 	if err := cmd.Start(); err != nil {
 		t.Fatalf("Failed to start simulate-skaters: %v", err)
 	}
 
-	t.Cleanup(func() {
-		if cmd.Process != nil {
-			cmd.Process.Signal(syscall.SIGTERM)
-			cmd.Wait()
-		}
-	})
-
-	return &Process{
+	p := &Process{
 		cmd:         cmd,
 		EventIDs:    []string{eventID},
 		MetricsFile: metricsFile,
 	}
+	t.Cleanup(p.terminate)
+	return p
 }
 
-func StartViewers(t *testing.T, targetURL string, eventIDs []string) *Process {
+// StartViewers runs simulate-viewers with one viewer per event.
+// extraArgs are appended to the command line, e.g. "--duration", "30s".
+func StartViewers(t *testing.T, targetURL string, eventIDs []string, extraArgs ...string) *Process {
 	t.Helper()
 	validateURL(t, targetURL)
 
@@ -127,6 +135,7 @@ func StartViewers(t *testing.T, targetURL string, eventIDs []string) *Process {
 
 	// Event IDs are UUIDs (comma-safe), joined as comma-separated list for --events flag
 	args = append(args, "--events", strings.Join(eventIDs, ","))
+	args = append(args, extraArgs...)
 
 	cmd := exec.Command("../bin/simulate-viewers", args...)
 
@@ -134,18 +143,52 @@ func StartViewers(t *testing.T, targetURL string, eventIDs []string) *Process {
 		t.Fatalf("Failed to start simulate-viewers: %v", err)
 	}
 
-	t.Cleanup(func() {
-		if cmd.Process != nil {
-			cmd.Process.Signal(syscall.SIGTERM)
-			cmd.Wait()
-		}
-	})
-
-	return &Process{
+	p := &Process{
 		cmd:         cmd,
 		EventIDs:    eventIDs,
 		MetricsFile: metricsFile,
 	}
+	t.Cleanup(p.terminate)
+	return p
+}
+
+// StartEvent runs simulate-event, with skaters and viewers in one process, for
+// the given event IDs. No output needs parsing, as the caller chooses the IDs.
+// extraArgs are appended to the command line, e.g. "--duration", "30s".
+func StartEvent(t *testing.T, targetURL string, eventIDs []string, skatersPerEvent, viewersPerEvent int, interval string, extraArgs ...string) *Process {
+	t.Helper()
+	validateURL(t, targetURL)
+
+	tempDir := t.TempDir()
+	metricsFile := filepath.Join(tempDir, "skaters.csv")
+	viewerMetricsFile := filepath.Join(tempDir, "viewers.csv")
+
+	args := []string{
+		"--target-url", targetURL,
+		"--events", fmt.Sprintf("%d", len(eventIDs)),
+		"--event-id", strings.Join(eventIDs, ","),
+		"--skaters-per-event", fmt.Sprintf("%d", skatersPerEvent),
+		"--viewers-per-event", fmt.Sprintf("%d", viewersPerEvent),
+		"--update-interval", interval,
+		"--skater-metrics-file", metricsFile,
+		"--viewer-metrics-file", viewerMetricsFile,
+	}
+	args = append(args, extraArgs...)
+
+	cmd := exec.Command("../bin/simulate-event", args...)
+
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start simulate-event: %v", err)
+	}
+
+	p := &Process{
+		cmd:               cmd,
+		EventIDs:          eventIDs,
+		MetricsFile:       metricsFile,
+		ViewerMetricsFile: viewerMetricsFile,
+	}
+	t.Cleanup(p.terminate)
+	return p
 }
 
 func (p *Process) Stop(t *testing.T) {
@@ -155,27 +198,51 @@ func (p *Process) Stop(t *testing.T) {
 		if err := p.cmd.Process.Signal(syscall.SIGTERM); err != nil {
 			t.Logf("Failed to send SIGTERM: %v", err)
 		}
-		p.cmd.Wait()
+		p.wait()
+	}
+}
+
+// Wait blocks until a process started with --duration stops by itself and
+// returns its exit error, which is non-nil when the process exited non-zero,
+// for example because its error budget was exceeded.
+func (p *Process) Wait(t *testing.T) error {
+	t.Helper()
+
+	return p.wait()
+}
+
+// terminate stops the process if it is still running, for t.Cleanup.
+func (p *Process) terminate() {
+	if p.cmd.Process != nil {
+		p.cmd.Process.Signal(syscall.SIGTERM)
+		p.wait()
 	}
 }
 
-func parseEventIDs(t *testing.T, scanner *bufio.Scanner) []string {
+// wait waits for the process to exit. os/exec closes the stderr pipe when
+// Wait returns, so the rest of stderr, such as the summary, is read first.
+func (p *Process) wait() error {
+	if p.stderrRead != nil {
+		<-p.stderrRead
+	}
+	return p.cmd.Wait()
+}
+
+// parseEventIDs logs the process's stderr and returns the event IDs it
+// generated, or nil if none were found in time. Reading and logging continue
+// in the background until the process closes stderr, and the returned channel
+// is closed then; callers wait for it before the test ends.
+func parseEventIDs(t *testing.T, scanner *bufio.Scanner) ([]string, <-chan struct{}) {
 	t.Helper()
 
 	timeout := time.After(10 * time.Second)
-	done := make(chan []string, 1)
-	quit := make(chan struct{})
+	found := make(chan []string, 1)
+	read := make(chan struct{})
 
 	go func() {
-		defer close(done)
+		defer close(read)
 		var foundEventIDs []string
 		for scanner.Scan() {
-			select {
-			case <-quit:
-				return
-			default:
-			}
-
 			line := scanner.Text()
 			t.Logf("simulate-skaters: %s", line)
 
@@ -188,25 +255,21 @@ func parseEventIDs(t *testing.T, scanner *bufio.Scanner) []string {
 
 					if len(eventIDs) > 0 {
 						foundEventIDs = eventIDs
-						done <- eventIDs
+						found <- eventIDs
 					}
 				}
 			}
 		}
 		if foundEventIDs == nil {
-			done <- nil
+			found <- nil
 		}
 	}()
 
 	select {
-	case eventIDs := <-done:
-		if eventIDs == nil {
-			t.Fatal("Failed to parse Event IDs from simulate-skaters output")
-		}
-		return eventIDs
+	case eventIDs := <-found:
+		return eventIDs, read
 	case <-timeout:
-		close(quit)
-		t.Fatal("Timeout waiting for Event IDs from simulate-skaters")
-		return nil
+		t.Log("Timeout waiting for Event IDs from simulate-skaters")
+		return nil, read
 	}
 }
@@ -0,0 +1,78 @@
+// Package tracing sets up OpenTelemetry tracing for the simulators, so requests
+// they send can be followed into the API's traces.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	// DefaultServiceName is the service.name resource attribute of exported spans.
+	DefaultServiceName = "skatemap-load-testing"
+
+	tracesPath = "/v1/traces"
+)
+
+// Config configures the tracer provider.
+type Config struct {
+	// ServiceName is reported as service.name. DefaultServiceName is used if empty.
+	ServiceName string
+	// Endpoint is the base URL of an OTLP/HTTP collector, e.g. http://localhost:4318.
+	// Spans are sent to its /v1/traces path unless the URL has a path of its own.
+	// If empty, spans are still created so trace context is propagated, but not exported.
+	// Headers such as API keys are read from OTEL_EXPORTER_OTLP_HEADERS.
+	Endpoint string
+}
+
+// NewProvider creates a tracer provider that samples every span, exporting
+// them in batches when an endpoint is configured. The provider must be shut
+// down at the end of the run to flush spans that have not been exported yet.
+func NewProvider(ctx context.Context, config Config) (*sdktrace.TracerProvider, error) {
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+	}
+
+	if config.Endpoint != "" {
+		endpointURL, err := tracesURL(config.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpointURL))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	return sdktrace.NewTracerProvider(opts...), nil
+}
+
+// tracesURL returns the URL spans are sent to for an OTLP endpoint.
+func tracesURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid OTLP endpoint: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid OTLP endpoint %q: must be an http or https URL", endpoint)
+	}
+
+	if u.Path == "" || u.Path == "/" {
+		u.Path = tracesPath
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTracesURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		expected string
+		wantErr  bool
+	}{
+		{"base URL", "http://localhost:4318", "http://localhost:4318/v1/traces", false},
+		{"trailing slash", "https://api.honeycomb.io:443/", "https://api.honeycomb.io:443/v1/traces", false},
+		{"explicit path", "http://collector:4318/custom/traces", "http://collector:4318/custom/traces", false},
+		{"no scheme", "localhost:4318", "", true},
+		{"grpc scheme", "grpc://localhost:4317", "", true},
+		{"unparseable", "http://[::1", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tracesURL(tt.endpoint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("tracesURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("tracesURL() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewProvider_ExportsSpans(t *testing.T) {
+	requests := make(chan *http.Request, 10)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	provider, err := NewProvider(context.Background(), Config{Endpoint: collector.URL})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+
+	_, span := provider.Tracer("test").Start(context.Background(), "update")
+	span.End()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := provider.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	select {
+	case r := <-requests:
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+			t.Errorf("expected POST /v1/traces, got %s %s", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/x-protobuf" {
+			t.Errorf("expected protobuf content type, got %q", ct)
+		}
+	default:
+		t.Fatal("expected spans to be exported on shutdown")
+	}
+}
+
+func TestNewProvider_WithoutEndpoint(t *testing.T) {
+	provider, err := NewProvider(context.Background(), Config{})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	defer provider.Shutdown(context.Background())
+
+	_, span := provider.Tracer("test").Start(context.Background(), "update")
+	defer span.End()
+
+	if !span.SpanContext().IsSampled() {
+		t.Error("expected spans to be sampled so trace context is propagated")
+	}
+}
+
+func TestNewProvider_InvalidEndpoint(t *testing.T) {
+	if _, err := NewProvider(context.Background(), Config{Endpoint: "localhost:4318"}); err == nil {
+		t.Error("expected an error for an endpoint without a scheme")
+	}
+}
@@ -2,12 +2,20 @@ package viewer
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"net"
 	"net/url"
 	"sync"
 	"time"
 
+	"load-testing/internal/correlation"
+	"load-testing/internal/delay"
+
 	"github.com/gorilla/websocket"
 )
 
@@ -20,6 +28,16 @@ const (
 	streamPathTemplate = "/skatingEvents/%s/stream"
 )
 
+// Errors reported in ViewerResult, wrapped around the underlying cause.
+// Use errors.Is to tell them apart.
+var (
+	ErrInvalidURL     = errors.New("invalid URL")
+	ErrConnect        = errors.New("connection failed")
+	ErrReadDeadline   = errors.New("failed to set read deadline")
+	ErrConnectionLost = errors.New("connection error")
+	ErrMalformedBatch = errors.New("failed to parse message")
+)
+
 // Location represents a geographic coordinate with latitude and longitude.
 type Location struct {
 	SkaterID  string  `json:"skaterId"`
@@ -36,6 +54,19 @@ type LocationBatch struct {
 
 // ViewerResult contains the result of receiving a WebSocket message,
 // including timing information and any errors encountered.
+//
+// Latency is the time from the server sending the batch to the viewer receiving
+// it. EndToEnd holds, in the same order as SkaterIDs, the time from the skater
+// sending each location to the viewer receiving it; it is only set when the
+// viewer has a correlation tracker, and zero for locations that could not be
+// matched or were sent before the viewer connected. Bytes is the size of the
+// message as received, for batches and malformed messages.
+//
+// Connection events are reported in results of their own, with no skaters and
+// one of Handshake, Connect, FirstMessage, Reconnect or Leave set: each
+// connection attempt's handshake, whether or not it succeeds, each connection
+// opening, its first batch arriving, each reconnection after a lost connection,
+// and each churning viewer's session ending.
 type ViewerResult struct {
 	EventID      string
 	ViewerNumber int
@@ -43,9 +74,106 @@ type ViewerResult struct {
 	MessageCount int
 	Latency      time.Duration
 	SkaterIDs    []string
+	EndToEnd     []time.Duration
+	Bytes        int
+	Handshake    *Handshake
+	Connect      *Connect
+	FirstMessage *FirstMessage
+	Reconnect    *Reconnect
+	Leave        *Leave
 	Error        error
 }
 
+// Handshake describes a WebSocket handshake attempt. Duration runs from
+// dialling to the upgrade completing or failing, and Status is the HTTP status
+// of the server's response: 101 Switching Protocols when the upgrade succeeded,
+// or zero when no response arrived, for example because the connection was refused.
+type Handshake struct {
+	Duration time.Duration
+	Status   int
+}
+
+// Connect describes a viewer's WebSocket connection opening. Number counts the
+// viewer's connections from 1.
+type Connect struct {
+	Number int
+}
+
+// FirstMessage describes the first batch arriving on a connection. Wait runs
+// from the connection opening, so it leaves out the handshake, which is timed
+// on its own; together they are how long someone opening the map waits to see
+// skaters.
+type FirstMessage struct {
+	Wait time.Duration
+}
+
+// Leave describes a churning viewer ending a session of the given length,
+// either cleanly with a close frame or abruptly by dropping the connection.
+type Leave struct {
+	Session time.Duration
+	Abrupt  bool
+}
+
+// Reconnect describes a viewer connecting again after losing its connection.
+// Downtime runs from the connection being lost to the new one opening, and
+// Attempts counts the connection attempts that took, including the one that
+// succeeded.
+//
+// MissedMessages estimates the batches the viewer would have received during
+// the gap from its message rate while connected. MissedLocations counts the
+// updates skaters sent to the event during the gap; it is only set when the
+// viewer has a correlation tracker, and does not count updates older than the
+// tracker's retention.
+type Reconnect struct {
+	Attempts        int
+	Downtime        time.Duration
+	MissedMessages  int
+	MissedLocations int
+}
+
+// Backoff configures how long a reconnecting viewer waits before each attempt.
+// The first attempt waits Initial, and each failed attempt multiplies the wait
+// by Multiplier up to Max. Jitter is the fraction of each wait, between 0 and 1,
+// that is randomly taken off, so that viewers dropped together do not all
+// reconnect at once.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+}
+
+// Churn makes a viewer leave after each session and join again later, as
+// people opening and closing the app do. Session lengths and the delays before
+// rejoining are drawn from their distributions, and AbruptRate is the fraction
+// of sessions, between 0 and 1, ended by dropping the TCP connection rather
+// than closing the WebSocket cleanly.
+type Churn struct {
+	SessionLength delay.Delay
+	RejoinDelay   delay.Delay
+	AbruptRate    float64
+}
+
+// DefaultBackoff returns the backoff used by the simulators.
+func DefaultBackoff() Backoff {
+	return Backoff{
+		Initial:    500 * time.Millisecond,
+		Max:        30 * time.Second,
+		Multiplier: 2,
+		Jitter:     0.5,
+	}
+}
+
+// delay returns the wait before the given attempt, counting from 1, with r
+// between 0 and 1 choosing how much jitter to take off.
+func (b Backoff) delay(attempt int, r float64) time.Duration {
+	d := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt-1))
+	if d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	return time.Duration(d * (1 - b.Jitter*r))
+}
+
 // Viewer represents a simulated viewer that receives location updates via WebSocket.
 type Viewer struct {
 	eventID      string
@@ -54,13 +182,62 @@ type Viewer struct {
 	results      chan<- ViewerResult
 	ctx          context.Context
 	wg           *sync.WaitGroup
+	onOpen       func()
+	onClose      func()
+	tracker      *correlation.Tracker
+	backoff      *Backoff
+	churn        *Churn
+	rng          *rand.Rand
+
+	// Owned by Start, and kept across connections.
+	messageCount int
+	connections  int
+	connectedFor time.Duration
+}
+
+// Option configures optional behaviour of a Viewer created with New.
+type Option func(*Viewer)
+
+// WithConnectionHooks sets functions called when each of the viewer's WebSocket
+// connections opens and when it closes. Either may be nil.
+func WithConnectionHooks(onOpen, onClose func()) Option {
+	return func(v *Viewer) {
+		v.onOpen = onOpen
+		v.onClose = onClose
+	}
+}
+
+// WithCorrelation sets the tracker used to match received locations with the
+// skater updates that sent them, to measure end-to-end latency.
+func WithCorrelation(tracker *correlation.Tracker) Option {
+	return func(v *Viewer) {
+		v.tracker = tracker
+	}
+}
+
+// WithReconnect makes the viewer connect again, waiting according to backoff,
+// whenever its connection fails or is closed, rather than stopping.
+func WithReconnect(backoff Backoff) Option {
+	return func(v *Viewer) {
+		v.backoff = &backoff
+	}
+}
+
+// WithChurn makes the viewer end each session and rejoin according to churn,
+// rather than staying connected for the whole run.
+func WithChurn(churn Churn) Option {
+	return func(v *Viewer) {
+		v.churn = &churn
+		v.rng = rand.New(rand.NewSource(time.Now().UnixNano() + int64(v.viewerNumber)))
+	}
 }
 
 // New creates a new Viewer instance configured to connect to the specified event.
-// The viewer will run until the context is cancelled or a fatal error occurs.
+// The viewer will run until the context is cancelled or a fatal error occurs,
+// or only until the context is cancelled if it reconnects.
 // Results are sent to the results channel as messages are received.
-func New(ctx context.Context, eventID string, viewerNumber int, baseURL string, results chan<- ViewerResult, wg *sync.WaitGroup) *Viewer {
-	return &Viewer{
+func New(ctx context.Context, eventID string, viewerNumber int, baseURL string, results chan<- ViewerResult, wg *sync.WaitGroup, opts ...Option) *Viewer {
+	v := &Viewer{
 		ctx:          ctx,
 		eventID:      eventID,
 		viewerNumber: viewerNumber,
@@ -68,10 +245,17 @@ func New(ctx context.Context, eventID string, viewerNumber int, baseURL string,
 		results:      results,
 		wg:           wg,
 	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
 }
 
 // Start initiates the WebSocket connection and begins receiving messages.
-// It runs until the context is cancelled or an error occurs.
+// It runs until the context is cancelled or an error occurs, unless the viewer
+// reconnects, in which case it only stops when the context is cancelled.
 func (v *Viewer) Start() {
 	defer v.wg.Done()
 
@@ -84,7 +268,7 @@ func (v *Viewer) Start() {
 			MessageCount: 0,
 			Latency:      0,
 			SkaterIDs:    nil,
-			Error:        fmt.Errorf("invalid URL: %w", err),
+			Error:        fmt.Errorf("%w: %w", ErrInvalidURL, err),
 		})
 		return
 	}
@@ -93,32 +277,109 @@ func (v *Viewer) Start() {
 		HandshakeTimeout: connectTimeout,
 	}
 
-	conn, _, err := dialer.Dial(wsURL, nil)
-	if err != nil {
+	// lostAt is when the previous connection was lost, zero before the first
+	// and after a churning viewer leaves of its own accord.
+	var lostAt time.Time
+	attempts := 0
+	for {
+		attempts++
+		dialStart := time.Now()
+		conn, resp, err := dialer.Dial(wsURL, nil)
+		handshake := &Handshake{Duration: time.Since(dialStart)}
+		if resp != nil {
+			handshake.Status = resp.StatusCode
+		}
 		v.sendResult(ViewerResult{
 			EventID:      v.eventID,
 			ViewerNumber: v.viewerNumber,
 			Timestamp:    time.Now(),
-			MessageCount: 0,
-			Latency:      0,
-			SkaterIDs:    nil,
-			Error:        fmt.Errorf("connection failed: %w", err),
+			MessageCount: v.messageCount,
+			Handshake:    handshake,
 		})
-		return
+		if err != nil {
+			v.sendResult(ViewerResult{
+				EventID:      v.eventID,
+				ViewerNumber: v.viewerNumber,
+				Timestamp:    time.Now(),
+				MessageCount: v.messageCount,
+				Latency:      0,
+				SkaterIDs:    nil,
+				Error:        fmt.Errorf("%w: %w", ErrConnect, err),
+			})
+			if v.backoff == nil || !v.sleep(v.backoff.delay(attempts+1, rand.Float64())) {
+				return
+			}
+			continue
+		}
+		connectedAt := dialStart.Add(handshake.Duration)
+		v.connections++
+
+		v.sendResult(ViewerResult{
+			EventID:      v.eventID,
+			ViewerNumber: v.viewerNumber,
+			Timestamp:    connectedAt,
+			MessageCount: v.messageCount,
+			Connect:      &Connect{Number: v.connections},
+		})
+		if !lostAt.IsZero() {
+			v.sendResult(ViewerResult{
+				EventID:      v.eventID,
+				ViewerNumber: v.viewerNumber,
+				Timestamp:    connectedAt,
+				MessageCount: v.messageCount,
+				Reconnect:    v.reconnect(attempts, lostAt, connectedAt),
+			})
+		}
+
+		left := v.receive(conn, connectedAt)
+		v.connectedFor += time.Since(connectedAt)
+
+		if v.ctx.Err() != nil {
+			return
+		}
+		lostAt = time.Time{}
+		attempts = 0
+		if left {
+			if !v.sleep(v.churn.RejoinDelay.Sample(v.rng)) {
+				return
+			}
+			continue
+		}
+
+		if v.backoff == nil {
+			return
+		}
+		lostAt = time.Now()
+		if !v.sleep(v.backoff.delay(1, rand.Float64())) {
+			return
+		}
 	}
+}
+
+// receive reads from a connection opened at connectedAt until it is
+// closed or lost, or until a churning viewer's session ends, in which case it
+// reports true.
+func (v *Viewer) receive(conn *websocket.Conn, connectedAt time.Time) bool {
 	defer conn.Close()
 
+	if v.onOpen != nil {
+		v.onOpen()
+	}
+	if v.onClose != nil {
+		defer v.onClose()
+	}
+
 	if err := conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
 		v.sendResult(ViewerResult{
 			EventID:      v.eventID,
 			ViewerNumber: v.viewerNumber,
 			Timestamp:    time.Now(),
-			MessageCount: 0,
+			MessageCount: v.messageCount,
 			Latency:      0,
 			SkaterIDs:    nil,
-			Error:        fmt.Errorf("failed to set read deadline: %w", err),
+			Error:        fmt.Errorf("%w: %w", ErrReadDeadline, err),
 		})
-		return
+		return false
 	}
 
 	conn.SetPongHandler(func(string) error {
@@ -130,7 +391,84 @@ func (v *Viewer) Start() {
 
 	go v.pingLoop(pingCtx, conn)
 
-	v.receiveLoop(conn)
+	if v.churn == nil {
+		v.receiveLoop(conn, connectedAt, nil)
+		return false
+	}
+
+	length := v.churn.SessionLength.Sample(v.rng)
+	abrupt := v.rng.Float64() < v.churn.AbruptRate
+	leaving := make(chan struct{})
+	session := time.AfterFunc(length, func() {
+		close(leaving)
+		leave(conn, abrupt)
+	})
+
+	v.receiveLoop(conn, connectedAt, leaving)
+	if session.Stop() || v.ctx.Err() != nil {
+		return false
+	}
+	<-leaving
+
+	v.sendResult(ViewerResult{
+		EventID:      v.eventID,
+		ViewerNumber: v.viewerNumber,
+		Timestamp:    time.Now(),
+		MessageCount: v.messageCount,
+		Leave:        &Leave{Session: length, Abrupt: abrupt},
+	})
+	return true
+}
+
+// leave ends a churning viewer's session. A clean leave sends a close frame
+// and gives the server a moment to answer it; an abrupt one resets the TCP
+// connection without one, as when the app is killed.
+func leave(conn *websocket.Conn, abrupt bool) {
+	if abrupt {
+		netConn := conn.NetConn()
+		if tlsConn, ok := netConn.(*tls.Conn); ok {
+			netConn = tlsConn.NetConn()
+		}
+		if tcpConn, ok := netConn.(*net.TCPConn); ok {
+			_ = tcpConn.SetLinger(0)
+		}
+		conn.Close()
+		return
+	}
+
+	message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	if err := conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeTimeout)); err != nil {
+		conn.Close()
+		return
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(writeTimeout))
+}
+
+// reconnect describes the gap between losing a connection at lostAt and
+// opening a new one at connectedAt after the given number of attempts.
+func (v *Viewer) reconnect(attempts int, lostAt, connectedAt time.Time) *Reconnect {
+	downtime := connectedAt.Sub(lostAt)
+	r := &Reconnect{Attempts: attempts, Downtime: downtime}
+	if v.connectedFor > 0 {
+		r.MissedMessages = int(math.Round(float64(v.messageCount) * downtime.Seconds() / v.connectedFor.Seconds()))
+	}
+	if v.tracker != nil {
+		r.MissedLocations = v.tracker.Sent(v.eventID, lostAt, connectedAt)
+	}
+	return r
+}
+
+// sleep waits for d, reporting false if the context is cancelled first.
+func (v *Viewer) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-v.ctx.Done():
+		return false
+	}
 }
 
 func (v *Viewer) buildWebSocketURL() (string, error) {
@@ -166,14 +504,18 @@ func (v *Viewer) pingLoop(ctx context.Context, conn *websocket.Conn) {
 				return
 			}
 		case <-ctx.Done():
+			// Closing the connection unblocks a read that is waiting for a
+			// message that may never come, so shutdown is not held up.
+			conn.Close()
 			return
 		}
 	}
 }
 
-func (v *Viewer) receiveLoop(conn *websocket.Conn) {
-	messageCount := 0
-
+// receiveLoop reads messages until the connection fails or is closed. Errors
+// after leaving is closed are expected, and not reported.
+func (v *Viewer) receiveLoop(conn *websocket.Conn, connectedAt time.Time, leaving <-chan struct{}) {
+	first := true
 	for {
 		select {
 		case <-v.ctx.Done():
@@ -181,13 +523,14 @@ func (v *Viewer) receiveLoop(conn *websocket.Conn) {
 		default:
 		}
 
-		receiveTime := time.Now()
-
 		_, message, err := conn.ReadMessage()
+		receiveTime := time.Now()
 		if err != nil {
 			select {
 			case <-v.ctx.Done():
 				return
+			case <-leaving:
+				return
 			default:
 			}
 
@@ -199,10 +542,10 @@ func (v *Viewer) receiveLoop(conn *websocket.Conn) {
 				EventID:      v.eventID,
 				ViewerNumber: v.viewerNumber,
 				Timestamp:    receiveTime,
-				MessageCount: messageCount,
+				MessageCount: v.messageCount,
 				Latency:      0,
 				SkaterIDs:    nil,
-				Error:        fmt.Errorf("connection error: %w", err),
+				Error:        fmt.Errorf("%w: %w", ErrConnectionLost, err),
 			})
 			return
 		}
@@ -213,34 +556,68 @@ func (v *Viewer) receiveLoop(conn *websocket.Conn) {
 				EventID:      v.eventID,
 				ViewerNumber: v.viewerNumber,
 				Timestamp:    receiveTime,
-				MessageCount: messageCount,
+				MessageCount: v.messageCount,
 				Latency:      0,
 				SkaterIDs:    nil,
-				Error:        fmt.Errorf("failed to parse message: %w", err),
+				Bytes:        len(message),
+				Error:        fmt.Errorf("%w: %w", ErrMalformedBatch, err),
 			})
 			continue
 		}
 
-		messageCount++
+		v.messageCount++
 		latency := receiveTime.UnixMilli() - batch.ServerTime
 
+		if first {
+			first = false
+			v.sendResult(ViewerResult{
+				EventID:      v.eventID,
+				ViewerNumber: v.viewerNumber,
+				Timestamp:    receiveTime,
+				MessageCount: v.messageCount,
+				FirstMessage: &FirstMessage{Wait: receiveTime.Sub(connectedAt)},
+			})
+		}
+
 		skaterIDs := make([]string, len(batch.Locations))
 		for i, loc := range batch.Locations {
 			skaterIDs[i] = loc.SkaterID
 		}
+		endToEnd := v.endToEnd(batch.Locations, connectedAt, receiveTime)
 
 		v.sendResult(ViewerResult{
 			EventID:      v.eventID,
 			ViewerNumber: v.viewerNumber,
 			Timestamp:    receiveTime,
-			MessageCount: messageCount,
+			MessageCount: v.messageCount,
 			Latency:      time.Duration(latency) * time.Millisecond,
 			SkaterIDs:    skaterIDs,
+			EndToEnd:     endToEnd,
+			Bytes:        len(message),
 			Error:        nil,
 		})
 	}
 }
 
+// endToEnd returns the end-to-end latency of each location, leaving zero for
+// locations that were not matched. Locations sent before the viewer connected
+// are left unmatched, as they measure how stale the initial batch is rather than
+// how quickly updates are delivered.
+func (v *Viewer) endToEnd(locations []Location, connectedAt, receiveTime time.Time) []time.Duration {
+	if v.tracker == nil {
+		return nil
+	}
+
+	latencies := make([]time.Duration, len(locations))
+	for i, loc := range locations {
+		sentAt, ok := v.tracker.Match(v.eventID, loc.SkaterID, loc.Longitude, loc.Latitude, receiveTime)
+		if ok && !sentAt.Before(connectedAt) {
+			latencies[i] = receiveTime.Sub(sentAt)
+		}
+	}
+	return latencies
+}
+
 func (v *Viewer) sendResult(result ViewerResult) {
 	select {
 	case v.results <- result:
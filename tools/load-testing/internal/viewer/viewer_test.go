@@ -3,6 +3,8 @@ package viewer
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -10,6 +12,11 @@ import (
 	"testing"
 	"time"
 
+	"load-testing/internal/correlation"
+	"load-testing/internal/delay"
+	"load-testing/internal/fakeserver"
+
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
@@ -17,6 +24,21 @@ var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
 
+// withoutConnects passes on results other than those reporting a connection
+// being opened, so tests can wait for the first message or error.
+func withoutConnects(results <-chan ViewerResult) <-chan ViewerResult {
+	received := make(chan ViewerResult, cap(results))
+	go func() {
+		defer close(received)
+		for result := range results {
+			if result.Handshake == nil && result.Connect == nil && result.FirstMessage == nil {
+				received <- result
+			}
+		}
+	}()
+	return received
+}
+
 func TestBuildWebSocketURL(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -140,9 +162,10 @@ func TestViewerReceivesMessages(t *testing.T) {
 	wg.Add(1)
 
 	go v.Start()
+	received := withoutConnects(results)
 
 	select {
-	case result := <-results:
+	case result := <-received:
 		if result.Error != nil {
 			t.Errorf("Unexpected error: %v", result.Error)
 		}
@@ -155,6 +178,9 @@ func TestViewerReceivesMessages(t *testing.T) {
 		if result.ViewerNumber != 1 {
 			t.Errorf("Expected viewer number 1, got %d", result.ViewerNumber)
 		}
+		if msg, _ := json.Marshal(batch); result.Bytes != len(msg) {
+			t.Errorf("Expected %d bytes, got %d", len(msg), result.Bytes)
+		}
 	case <-time.After(2 * time.Second):
 		t.Fatal("Timeout waiting for result")
 	}
@@ -175,9 +201,10 @@ func TestViewerConnectionFailure(t *testing.T) {
 	wg.Add(1)
 
 	go v.Start()
+	received := withoutConnects(results)
 
 	select {
-	case result := <-results:
+	case result := <-received:
 		if result.Error == nil {
 			t.Error("Expected connection error, got nil")
 		}
@@ -225,9 +252,10 @@ func TestViewerInvalidJSON(t *testing.T) {
 	wg.Add(1)
 
 	go v.Start()
+	received := withoutConnects(results)
 
 	select {
-	case result := <-results:
+	case result := <-received:
 		if result.Error == nil {
 			t.Error("Expected JSON parse error, got nil")
 		}
@@ -242,3 +270,557 @@ func TestViewerInvalidJSON(t *testing.T) {
 	wg.Wait()
 	close(results)
 }
+
+func TestViewerInjectedStreamFaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		faults   fakeserver.Faults
+		expected error
+	}{
+		{"dropped connection", fakeserver.Faults{DropRate: 1}, ErrConnectionLost},
+		{"malformed batch", fakeserver.Faults{MalformedRate: 1}, ErrMalformedBatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := fakeserver.DefaultConfig()
+			config.BatchInterval = 10 * time.Millisecond
+			config.Faults = tt.faults
+			fake := fakeserver.New(config)
+			defer fake.Close()
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			eventID := uuid.New().String()
+			putLocation(t, server.URL, eventID)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			results := make(chan ViewerResult, 10)
+			var wg sync.WaitGroup
+
+			v := New(ctx, eventID, 1, server.URL, results, &wg)
+			wg.Add(1)
+
+			go v.Start()
+			received := withoutConnects(results)
+
+			select {
+			case result := <-received:
+				if !errors.Is(result.Error, tt.expected) {
+					t.Errorf("Expected %v, got: %v", tt.expected, result.Error)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("Timeout waiting for error result")
+			}
+
+			cancel()
+			wg.Wait()
+			close(results)
+		})
+	}
+}
+
+func TestViewerConnectionHooks(t *testing.T) {
+	fake := fakeserver.New(fakeserver.DefaultConfig())
+	defer fake.Close()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan ViewerResult, 10)
+	var wg sync.WaitGroup
+
+	opened := make(chan struct{})
+	closed := make(chan struct{})
+	v := New(ctx, uuid.New().String(), 1, server.URL, results, &wg,
+		WithConnectionHooks(func() { close(opened) }, func() { close(closed) }))
+	wg.Add(1)
+
+	go v.Start()
+
+	select {
+	case <-opened:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for connection to open")
+	}
+
+	select {
+	case <-closed:
+		t.Fatal("Connection closed before the viewer stopped")
+	default:
+	}
+
+	cancel()
+	wg.Wait()
+
+	select {
+	case <-closed:
+	default:
+		t.Error("Expected close hook to run when the viewer stopped")
+	}
+}
+
+func TestViewerConnectionHooksNotCalledOnFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan ViewerResult, 10)
+	var wg sync.WaitGroup
+
+	called := false
+	hook := func() { called = true }
+	v := New(ctx, "test-event", 1, "http://127.0.0.1:1", results, &wg, WithConnectionHooks(hook, hook))
+	wg.Add(1)
+
+	v.Start()
+
+	if called {
+		t.Error("Expected no connection hooks when the connection fails")
+	}
+}
+
+func TestViewerEndToEndLatency(t *testing.T) {
+	config := fakeserver.DefaultConfig()
+	config.BatchInterval = 50 * time.Millisecond
+	fake := fakeserver.New(config)
+	defer fake.Close()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	eventID := uuid.New().String()
+	tracker := correlation.NewTracker(correlation.DefaultRetention)
+
+	// A location sent before the viewer connects arrives in the initial batch
+	// and is not counted as delivered end to end.
+	stored := putLocationAt(t, server.URL, eventID, tracker, -0.1, 51.5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan ViewerResult, 10)
+	var wg sync.WaitGroup
+
+	v := New(ctx, eventID, 1, server.URL, results, &wg, WithCorrelation(tracker))
+	wg.Add(1)
+
+	go v.Start()
+	received := withoutConnects(results)
+
+	select {
+	case result := <-received:
+		if len(result.SkaterIDs) != 1 || result.SkaterIDs[0] != stored || result.EndToEnd[0] != 0 {
+			t.Fatalf("Expected the stored location to be unmatched, got %v %v", result.SkaterIDs, result.EndToEnd)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for stored location")
+	}
+
+	sentAt := time.Now()
+	live := putLocationAt(t, server.URL, eventID, tracker, -0.2, 51.6)
+
+	select {
+	case result := <-received:
+		if result.Error != nil {
+			t.Fatalf("Unexpected error: %v", result.Error)
+		}
+		if len(result.SkaterIDs) != 1 || result.SkaterIDs[0] != live {
+			t.Fatalf("Expected live location for %s, got %v", live, result.SkaterIDs)
+		}
+		if result.EndToEnd[0] <= 0 || result.EndToEnd[0] > time.Since(sentAt) {
+			t.Errorf("Expected end-to-end latency between 0 and %s, got %s", time.Since(sentAt), result.EndToEnd[0])
+		}
+		if result.Latency < 0 {
+			t.Errorf("Expected non-negative latency, got %s", result.Latency)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for live location")
+	}
+
+	cancel()
+	wg.Wait()
+}
+
+// putLocationAt sends a location for a new skater, recording it in tracker first
+// as a skater would, and returns the skater ID.
+func putLocationAt(t *testing.T, baseURL, eventID string, tracker *correlation.Tracker, longitude, latitude float64) string {
+	t.Helper()
+
+	skaterID := uuid.New().String()
+	tracker.Publish(correlation.Update{
+		EventID:   eventID,
+		SkaterID:  skaterID,
+		Longitude: longitude,
+		Latitude:  latitude,
+		SentAt:    time.Now(),
+	})
+
+	url := baseURL + "/skatingEvents/" + eventID + "/skaters/" + skaterID
+	body := fmt.Sprintf(`{"coordinates":[%v,%v]}`, longitude, latitude)
+	req, err := http.NewRequest(http.MethodPut, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send location: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d", resp.StatusCode)
+	}
+	return skaterID
+}
+
+func putLocation(t *testing.T, baseURL, eventID string) {
+	t.Helper()
+
+	url := baseURL + "/skatingEvents/" + eventID + "/skaters/" + uuid.New().String()
+	req, err := http.NewRequest(http.MethodPut, url, strings.NewReader(`{"coordinates":[-0.1278,51.5074]}`))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send location: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d", resp.StatusCode)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2, Jitter: 0.5}
+
+	tests := []struct {
+		attempt  int
+		r        float64
+		expected time.Duration
+	}{
+		{attempt: 1, r: 0, expected: 100 * time.Millisecond},
+		{attempt: 2, r: 0, expected: 200 * time.Millisecond},
+		{attempt: 4, r: 0, expected: 800 * time.Millisecond},
+		{attempt: 5, r: 0, expected: time.Second},
+		{attempt: 20, r: 0, expected: time.Second},
+		{attempt: 2, r: 0.5, expected: 150 * time.Millisecond},
+		{attempt: 20, r: 1, expected: 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		if got := b.delay(tt.attempt, tt.r); got != tt.expected {
+			t.Errorf("delay(%d, %.1f) = %s, want %s", tt.attempt, tt.r, got, tt.expected)
+		}
+	}
+}
+
+func TestViewerReconnects(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		request := requests
+		mu.Unlock()
+
+		// The second and third attempts are refused, as while a server restarts.
+		if request == 2 || request == 3 {
+			http.Error(w, "restarting", http.StatusServiceUnavailable)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Failed to upgrade connection: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		msg, _ := json.Marshal(LocationBatch{Locations: []Location{{SkaterID: "skater-1"}}, ServerTime: time.Now().UnixMilli()})
+		if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			t.Errorf("Failed to write message: %v", err)
+			return
+		}
+
+		if request == 1 {
+			// Drop the first connection without a close frame.
+			return
+		}
+		time.Sleep(time.Second)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan ViewerResult, 10)
+	var wg sync.WaitGroup
+
+	opened := 0
+	backoff := Backoff{Initial: 10 * time.Millisecond, Max: 20 * time.Millisecond, Multiplier: 2}
+	v := New(ctx, "test-event", 1, server.URL, results, &wg,
+		WithReconnect(backoff), WithConnectionHooks(func() { opened++ }, nil))
+	wg.Add(1)
+
+	go v.Start()
+	received := withoutConnects(results)
+
+	var got []ViewerResult
+	for len(got) < 6 {
+		select {
+		case result := <-received:
+			got = append(got, result)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timeout waiting for results, got %+v", got)
+		}
+	}
+
+	cancel()
+	wg.Wait()
+
+	if got[0].Error != nil || got[0].MessageCount != 1 {
+		t.Errorf("Expected the first message, got %+v", got[0])
+	}
+	if !errors.Is(got[1].Error, ErrConnectionLost) {
+		t.Errorf("Expected the connection to be lost, got %v", got[1].Error)
+	}
+	for _, result := range got[2:4] {
+		if !errors.Is(result.Error, ErrConnect) {
+			t.Errorf("Expected a failed reconnection attempt, got %v", result.Error)
+		}
+	}
+
+	reconnect := got[4].Reconnect
+	if got[4].Error != nil || reconnect == nil {
+		t.Fatalf("Expected a reconnection, got %+v", got[4])
+	}
+	if reconnect.Attempts != 3 {
+		t.Errorf("Expected 3 attempts to reconnect, got %d", reconnect.Attempts)
+	}
+	// Waits of 10ms, 20ms and 20ms before the three attempts.
+	if reconnect.Downtime < 50*time.Millisecond {
+		t.Errorf("Expected at least 50ms of downtime, got %s", reconnect.Downtime)
+	}
+
+	if got[5].Error != nil || got[5].MessageCount != 2 {
+		t.Errorf("Expected the message count to carry on across connections, got %+v", got[5])
+	}
+	if opened != 2 {
+		t.Errorf("Expected 2 connections to open, got %d", opened)
+	}
+}
+
+func TestViewerReconnectsAfterClose(t *testing.T) {
+	config := fakeserver.DefaultConfig()
+	fake := fakeserver.New(config)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan ViewerResult, 10)
+	var wg sync.WaitGroup
+
+	opened := make(chan struct{}, 1)
+	backoff := Backoff{Initial: 10 * time.Millisecond, Max: 10 * time.Millisecond, Multiplier: 2}
+	v := New(ctx, uuid.New().String(), 1, server.URL, results, &wg,
+		WithReconnect(backoff), WithConnectionHooks(func() { opened <- struct{}{} }, nil))
+	wg.Add(1)
+
+	go v.Start()
+	received := withoutConnects(results)
+
+	select {
+	case <-opened:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for connection to open")
+	}
+
+	// Shutting the server down closes the stream cleanly, and the viewer keeps
+	// trying until cancelled.
+	fake.Close()
+
+	select {
+	case result := <-received:
+		if !errors.Is(result.Error, ErrConnect) {
+			t.Errorf("Expected a failed reconnection attempt, got %v", result.Error)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for a reconnection attempt")
+	}
+
+	cancel()
+	wg.Wait()
+}
+
+func TestViewerChurn(t *testing.T) {
+	tests := []struct {
+		name       string
+		abruptRate float64
+		cleanClose bool
+	}{
+		{"clean", 0, true},
+		{"abrupt", 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closes := make(chan error, 10)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					t.Errorf("Failed to upgrade connection: %v", err)
+					return
+				}
+				defer conn.Close()
+
+				for {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						closes <- err
+						return
+					}
+				}
+			}))
+			defer server.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			results := make(chan ViewerResult, 10)
+			var wg sync.WaitGroup
+
+			churn := Churn{
+				SessionLength: delay.Delay{Distribution: delay.Fixed, A: 50 * time.Millisecond},
+				RejoinDelay:   delay.Delay{Distribution: delay.Fixed, A: 10 * time.Millisecond},
+				AbruptRate:    tt.abruptRate,
+			}
+			v := New(ctx, "test-event", 1, server.URL, results, &wg, WithChurn(churn))
+			wg.Add(1)
+
+			go v.Start()
+
+			var got []ViewerResult
+			for len(got) < 5 {
+				select {
+				case result := <-results:
+					got = append(got, result)
+				case <-time.After(2 * time.Second):
+					t.Fatalf("Timeout waiting for results, got %+v", got)
+				}
+			}
+
+			cancel()
+			wg.Wait()
+
+			if c := got[1].Connect; c == nil || c.Number != 1 {
+				t.Errorf("Expected the first connection, got %+v", got[1])
+			}
+			if l := got[2].Leave; l == nil || l.Session != 50*time.Millisecond || l.Abrupt != !tt.cleanClose {
+				t.Errorf("Expected the session to end, got %+v", got[2])
+			}
+			if h, c := got[3].Handshake, got[4].Connect; h == nil || c == nil || c.Number != 2 {
+				t.Errorf("Expected the viewer to rejoin, got %+v and %+v", got[3], got[4])
+			}
+
+			select {
+			case err := <-closes:
+				if got := websocket.IsCloseError(err, websocket.CloseNormalClosure); got != tt.cleanClose {
+					t.Errorf("Expected a close frame %v, server saw %v", tt.cleanClose, err)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("Timeout waiting for the server to see the viewer leave")
+			}
+		})
+	}
+}
+
+func TestViewerHandshakeAndFirstMessage(t *testing.T) {
+	const handshakeDelay = 200 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(handshakeDelay)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Failed to upgrade connection: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		time.Sleep(50 * time.Millisecond)
+		batch := LocationBatch{Locations: []Location{{SkaterID: "skater-1"}}, ServerTime: time.Now().UnixMilli()}
+		if err := conn.WriteJSON(batch); err != nil {
+			t.Errorf("Failed to write message: %v", err)
+			return
+		}
+		time.Sleep(time.Second)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan ViewerResult, 10)
+	var wg sync.WaitGroup
+
+	v := New(ctx, "test-event", 1, server.URL, results, &wg)
+	wg.Add(1)
+
+	go v.Start()
+
+	var got []ViewerResult
+	for len(got) < 4 {
+		select {
+		case result := <-results:
+			got = append(got, result)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timeout waiting for results, got %+v", got)
+		}
+	}
+
+	cancel()
+	wg.Wait()
+
+	h := got[0].Handshake
+	if h == nil || h.Status != http.StatusSwitchingProtocols || h.Duration < handshakeDelay {
+		t.Fatalf("Expected a successful handshake first, got %+v", got[0])
+	}
+	if got[1].Connect == nil {
+		t.Errorf("Expected the connection to open, got %+v", got[1])
+	}
+	f := got[2].FirstMessage
+	if f == nil || f.Wait < 50*time.Millisecond || f.Wait >= handshakeDelay {
+		t.Errorf("Expected the first message to be timed from the connection opening, got %+v", got[2])
+	}
+	if len(got[3].SkaterIDs) != 1 {
+		t.Errorf("Expected the first batch after its timing, got %+v", got[3])
+	}
+}
+
+func TestViewerHandshakeRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	results := make(chan ViewerResult, 10)
+	var wg sync.WaitGroup
+
+	v := New(context.Background(), "test-event", 1, server.URL, results, &wg)
+	wg.Add(1)
+	v.Start()
+
+	h := <-results
+	if h.Handshake == nil || h.Handshake.Status != http.StatusServiceUnavailable {
+		t.Errorf("Expected the handshake to report the rejection status, got %+v", h)
+	}
+	if result := <-results; !errors.Is(result.Error, ErrConnect) {
+		t.Errorf("Expected a connection error after the handshake, got %+v", result)
+	}
+}
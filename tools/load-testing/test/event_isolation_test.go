@@ -13,32 +13,23 @@ const (
 func (s *SmokeTestSuite) TestEventIsolation() {
 	t := s.T()
 
-	eventA := testutil.StartSkaters(t, s.railwayURL, 1, 1, "2s")
-	eventB := testutil.StartSkaters(t, s.railwayURL, 1, 1, "2s")
+	duration := eventIsolationTestDuration.String()
 
-	viewerA := testutil.StartViewers(t, s.railwayURL, eventA.EventIDs)
-	viewerB := testutil.StartViewers(t, s.railwayURL, eventB.EventIDs)
+	eventA := testutil.StartSkaters(t, s.targetURL, 1, 1, "2s", "--duration", duration, "--max-error-rate", "0")
+	eventB := testutil.StartSkaters(t, s.targetURL, 1, 1, "2s", "--duration", duration, "--max-error-rate", "0")
+
+	viewerA := testutil.StartViewers(t, s.targetURL, eventA.EventIDs,
+		"--duration", duration, "--max-error-rate", "0", "--min-messages-per-viewer", "3")
+	viewerB := testutil.StartViewers(t, s.targetURL, eventB.EventIDs,
+		"--duration", duration, "--max-error-rate", "0", "--min-messages-per-viewer", "3")
 
 	t.Logf("Event A ID: %s", eventA.EventIDs[0])
 	t.Logf("Event B ID: %s", eventB.EventIDs[0])
 
-	time.Sleep(eventIsolationTestDuration)
-
-	eventA.Stop(t)
-	eventB.Stop(t)
-	viewerA.Stop(t)
-	viewerB.Stop(t)
-
-	testutil.AssertNoErrors(t, eventA.MetricsFile)
-	testutil.AssertNoErrors(t, eventB.MetricsFile)
-	testutil.AssertNoErrors(t, viewerA.MetricsFile)
-	testutil.AssertNoErrors(t, viewerB.MetricsFile)
-
-	viewerACount := testutil.CountRecords(t, viewerA.MetricsFile)
-	viewerBCount := testutil.CountRecords(t, viewerB.MetricsFile)
-
-	s.Assert().Greater(viewerACount, 2, "Event A viewer should have received messages")
-	s.Assert().Greater(viewerBCount, 2, "Event B viewer should have received messages")
+	s.Require().NoError(eventA.Wait(t), "Event A skaters should meet their SLOs")
+	s.Require().NoError(eventB.Wait(t), "Event B skaters should meet their SLOs")
+	s.Require().NoError(viewerA.Wait(t), "Event A viewer should receive messages without errors")
+	s.Require().NoError(viewerB.Wait(t), "Event B viewer should receive messages without errors")
 
 	skaterIDsA := testutil.ExtractSkaterIDs(t, viewerA.MetricsFile)
 	skaterIDsB := testutil.ExtractSkaterIDs(t, viewerB.MetricsFile)
@@ -54,5 +45,5 @@ func (s *SmokeTestSuite) TestEventIsolation() {
 		s.Assert().False(skaterIDsA[skaterID], "Skater %s from Event B should not appear in Event A viewer", skaterID)
 	}
 
-	s.Assert().False(testutil.DetectCrash(t), "No crashes should occur during event isolation test")
+	s.Assert().False(s.detectCrash(t), "No crashes should occur during event isolation test")
 }
@@ -21,15 +21,15 @@ func (s *SmokeTestSuite) TestScale() {
 	additionalSkaters := 5
 	totalSkaters := initialSkatersPerEvent + additionalSkaters
 
-	eventA := testutil.StartSkaters(t, s.railwayURL, 1, initialSkatersPerEvent, "3s")
-	eventB := testutil.StartSkaters(t, s.railwayURL, 1, initialSkatersPerEvent, "3s")
+	eventA := testutil.StartSkaters(t, s.targetURL, 1, initialSkatersPerEvent, "3s")
+	eventB := testutil.StartSkaters(t, s.targetURL, 1, initialSkatersPerEvent, "3s")
 
 	t.Logf("Event A ID: %s", eventA.EventIDs[0])
 	t.Logf("Event B ID: %s", eventB.EventIDs[0])
 
 	time.Sleep(scaleTestInitialRunTime)
 
-	eventAMore := testutil.StartSkatersWithEventID(t, s.railwayURL, eventA.EventIDs[0], additionalSkaters, "3s")
+	eventAMore := testutil.StartSkatersWithEventID(t, s.targetURL, eventA.EventIDs[0], additionalSkaters, "3s")
 
 	t.Logf("Added %d more skaters to Event A (now %d total)", additionalSkaters, totalSkaters)
 
@@ -43,5 +43,5 @@ func (s *SmokeTestSuite) TestScale() {
 	testutil.AssertNoErrors(t, eventB.MetricsFile)
 	testutil.AssertNoErrors(t, eventAMore.MetricsFile)
 
-	s.Assert().False(testutil.DetectCrash(t), "No crashes should occur during scale test")
+	s.Assert().False(s.detectCrash(t), "No crashes should occur during scale test")
 }
@@ -0,0 +1,52 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"load-testing/internal/metrics"
+	"load-testing/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+const (
+	simulateEventTestDuration = 15 * time.Second
+)
+
+func (s *SmokeTestSuite) TestSimulateEvent() {
+	t := s.T()
+
+	eventIDs := []string{uuid.New().String(), uuid.New().String()}
+	summaryFile := filepath.Join(t.TempDir(), "summary.json")
+
+	event := testutil.StartEvent(t, s.targetURL, eventIDs, 2, 1, "2s",
+		"--duration", simulateEventTestDuration.String(), "--summary-file", summaryFile,
+		"--max-error-rate", "0", "--max-viewer-error-rate", "0", "--min-messages-per-viewer", "3",
+		"--max-missing-skaters", "0")
+
+	s.Require().NoError(event.Wait(t), "Skaters and viewers should meet their SLOs")
+
+	testutil.AssertNoErrors(t, event.MetricsFile)
+	testutil.AssertNoErrors(t, event.ViewerMetricsFile)
+
+	data, err := os.ReadFile(summaryFile)
+	s.Require().NoError(err, "Summary file should be written")
+
+	var report metrics.SimulationReport
+	s.Require().NoError(json.Unmarshal(data, &report), "Summary file should be valid JSON")
+
+	s.Assert().Len(report.Skaters.Events, len(eventIDs), "Skaters should update every event")
+	s.Assert().Len(report.Viewers.Events, len(eventIDs), "Viewers should watch every event")
+	s.Assert().Positive(report.Viewers.EndToEnd.Count, "Received locations should be matched with sent updates")
+	s.Assert().Equal(uint64(report.Viewers.Locations), report.Viewers.EndToEnd.Count,
+		"Every location received should have been sent by a skater in the same process")
+
+	s.Require().NotNil(report.Viewers.Delivery, "Delivery should always be checked in-process")
+	s.Assert().Equal(len(eventIDs)*2, report.Viewers.Delivery.ExpectedSkaters, "Every skater should be expected")
+	s.Assert().Zero(report.Viewers.Delivery.LateLocations, "No location should arrive after it expired")
+
+	s.Assert().False(s.detectCrash(t), "No crashes should occur during the combined simulation")
+}
@@ -2,28 +2,59 @@ package test
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
 
+	"load-testing/internal/fakeserver"
+	"load-testing/internal/testutil"
+
 	"github.com/stretchr/testify/suite"
 )
 
+// SmokeTestSuite runs against the deployment at RAILWAY_URL, or against an
+// in-process fake API server when RAILWAY_URL is not set.
 type SmokeTestSuite struct {
 	suite.Suite
-	railwayURL string
+	targetURL  string
+	fakeServer *fakeserver.Server
+	httpServer *httptest.Server
 }
 
 func (s *SmokeTestSuite) SetupSuite() {
-	s.railwayURL = os.Getenv("RAILWAY_URL")
-	s.Require().NotEmpty(s.railwayURL, "RAILWAY_URL environment variable required")
+	s.targetURL = os.Getenv("RAILWAY_URL")
+	if s.targetURL == "" {
+		s.fakeServer = fakeserver.New(fakeserver.DefaultConfig())
+		s.httpServer = httptest.NewServer(s.fakeServer)
+		s.targetURL = s.httpServer.URL
+		s.T().Logf("RAILWAY_URL not set, using fake API server at %s", s.targetURL)
+	}
 
 	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(s.railwayURL + "/health")
-	s.Require().NoError(err, "Failed to connect to Railway service")
+	resp, err := client.Get(s.targetURL + "/health")
+	s.Require().NoError(err, "Failed to connect to service")
 	defer resp.Body.Close()
 
-	s.Require().Equal(200, resp.StatusCode, "Railway service must be healthy")
+	s.Require().Equal(200, resp.StatusCode, "Service must be healthy")
+}
+
+func (s *SmokeTestSuite) TearDownSuite() {
+	if s.fakeServer != nil {
+		s.fakeServer.Close()
+		s.httpServer.Close()
+	}
+}
+
+// detectCrash checks the Railway logs for crashes. The fake server runs
+// in-process, so a crash would fail the test directly.
+func (s *SmokeTestSuite) detectCrash(t *testing.T) bool {
+	t.Helper()
+
+	if s.fakeServer != nil {
+		return false
+	}
+	return testutil.DetectCrash(t)
 }
 
 func TestSmokeTestSuite(t *testing.T) {
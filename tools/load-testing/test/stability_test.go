@@ -29,8 +29,8 @@ func (s *SmokeTestSuite) TestStability() {
 	expectedRecordsPerEvent := skatersPerEvent * approximateUpdatesPerMin * durationMinutes
 	recordAssertionDelta := expectedRecordsPerEvent / 10
 
-	eventA := testutil.StartSkaters(t, s.railwayURL, 1, skatersPerEvent, "3s")
-	eventB := testutil.StartSkaters(t, s.railwayURL, 1, skatersPerEvent, "3s")
+	eventA := testutil.StartSkaters(t, s.targetURL, 1, skatersPerEvent, "3s")
+	eventB := testutil.StartSkaters(t, s.targetURL, 1, skatersPerEvent, "3s")
 
 	t.Logf("Event A ID: %s", eventA.EventIDs[0])
 	t.Logf("Event B ID: %s", eventB.EventIDs[0])
@@ -45,7 +45,7 @@ func (s *SmokeTestSuite) TestStability() {
 		elapsed := time.Duration(i) * stabilityCheckInterval
 		t.Logf("Stability test progress: %v / %v", elapsed, stabilityTestDuration)
 
-		s.Assert().False(testutil.DetectCrash(t), "No crashes should occur at %v checkpoint", elapsed)
+		s.Assert().False(s.detectCrash(t), "No crashes should occur at %v checkpoint", elapsed)
 	}
 
 	eventA.Stop(t)
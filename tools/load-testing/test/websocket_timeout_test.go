@@ -15,10 +15,10 @@ const (
 func (s *SmokeTestSuite) TestWebSocketTimeout() {
 	t := s.T()
 
-	skaters := testutil.StartSkaters(t, s.railwayURL, 1, 1, "2s")
+	skaters := testutil.StartSkaters(t, s.targetURL, 1, 1, "2s")
 	eventID := skaters.EventIDs[0]
 
-	viewer := testutil.StartViewers(t, s.railwayURL, skaters.EventIDs)
+	viewer := testutil.StartViewers(t, s.targetURL, skaters.EventIDs)
 
 	t.Logf("Event ID: %s", eventID)
 
@@ -33,7 +33,7 @@ func (s *SmokeTestSuite) TestWebSocketTimeout() {
 	time.Sleep(websocketIdleTime)
 	t.Logf("Waited %v (exceeds old 75s timeout)", websocketIdleTime)
 
-	skaters2 := testutil.StartSkatersWithEventID(t, s.railwayURL, eventID, 1, "2s")
+	skaters2 := testutil.StartSkatersWithEventID(t, s.targetURL, eventID, 1, "2s")
 	defer skaters2.Stop(t)
 
 	t.Logf("Restarted skaters with same Event ID")
@@ -48,5 +48,5 @@ func (s *SmokeTestSuite) TestWebSocketTimeout() {
 	viewer.Stop(t)
 	testutil.AssertNoErrors(t, viewer.MetricsFile)
 
-	s.Assert().False(testutil.DetectCrash(t), "No crashes should occur during websocket timeout test")
+	s.Assert().False(s.detectCrash(t), "No crashes should occur during websocket timeout test")
 }